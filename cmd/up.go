@@ -368,6 +368,16 @@ func (c *UpCommand) handleReload(mconfig *config.MayaConfig) *config.MayaConfig
 		newConf.LogLevel = mconfig.LogLevel
 	}
 
+	// Reload the tls certificates
+	if c.httpServer != nil {
+		if err := c.httpServer.ReloadTLS(newConf.TLS); err != nil {
+			c.Ui.Error(fmt.Sprintf("Failed to reload tls: %v", err))
+
+			// Keep the current tls config
+			newConf.TLS = mconfig.TLS
+		}
+	}
+
 	return newConf
 }
 
@@ -0,0 +1,250 @@
+package v1
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrorReason is a machine readable description of why a volume operation
+// failed. Clients should switch on the reason rather than on the message.
+type ErrorReason string
+
+const (
+	// ReasonNotFound means the volume does not exist
+	ReasonNotFound ErrorReason = "NotFound"
+
+	// ReasonAlreadyExists means a volume with the same name exists
+	ReasonAlreadyExists ErrorReason = "AlreadyExists"
+
+	// ReasonConflict means the volume is in a state that does not permit
+	// the operation e.g. it is attached to another instance
+	ReasonConflict ErrorReason = "Conflict"
+
+	// ReasonInvalid means the claim or the request is not valid
+	ReasonInvalid ErrorReason = "Invalid"
+
+	// ReasonUnavailable means the storage infrastructure e.g. the
+	// orchestrator could not be reached or is not healthy
+	ReasonUnavailable ErrorReason = "Unavailable"
+
+	// ReasonUnsupported means the storage infrastructure does not support
+	// the operation
+	ReasonUnsupported ErrorReason = "Unsupported"
+
+	// ReasonUnauthorized means the request did not provide a valid token
+	ReasonUnauthorized ErrorReason = "Unauthorized"
+
+	// ReasonForbidden means the request's token does not permit the
+	// operation
+	ReasonForbidden ErrorReason = "Forbidden"
+
+	// ReasonMethodNotAllowed means the http method is not served at the
+	// request's path
+	ReasonMethodNotAllowed ErrorReason = "MethodNotAllowed"
+
+	// ReasonUnknown is set for the errors that are not classified
+	ReasonUnknown ErrorReason = "Unknown"
+)
+
+// VolumeError is an error that carries the reason of failure of a volume
+// operation. It flows from the orchestration providers through the volume
+// plugins to mayaserver's http layer.
+type VolumeError struct {
+	Reason  ErrorReason
+	Message string
+
+	// Causes are the individual problems with the fields of a claim. This
+	// is set for the errors of claim validation.
+	Causes []FieldError
+
+	// Replicas are the outcomes at the individual replicas of the volume.
+	// This is set for the operations that change the replicas one by one
+	// e.g. the removal of a snapshot.
+	Replicas []ReplicaStatus
+}
+
+const (
+	// ReplicaDone means the operation completed at the replica
+	ReplicaDone = "Done"
+
+	// ReplicaFailed means the operation failed at the replica
+	ReplicaFailed = "Failed"
+
+	// ReplicaNotAttempted means the operation was not run at the replica
+	// e.g. since the request was cancelled
+	ReplicaNotAttempted = "NotAttempted"
+)
+
+// ReplicaStatus is the outcome of an operation at a single replica
+type ReplicaStatus struct {
+	// Address of the replica e.g. tcp://10.0.0.2:9502
+	Address string `json:"address"`
+
+	// Status is one of Done, Failed or NotAttempted
+	Status string `json:"status"`
+
+	// Message describes the failure, if any
+	Message string `json:"message,omitempty"`
+}
+
+// FieldError is a problem with a single field of a claim
+type FieldError struct {
+	// Field is the path of the field e.g. metadata.labels.jivafeip
+	Field string `json:"field"`
+
+	// Value is the invalid value, if any
+	Value string `json:"value,omitempty"`
+
+	// Message is the human readable description of the problem
+	Message string `json:"message"`
+}
+
+func (e FieldError) String() string {
+	if e.Value == "" {
+		return fmt.Sprintf("%s: %s", e.Field, e.Message)
+	}
+	return fmt.Sprintf("%s: %s: '%s'", e.Field, e.Message, e.Value)
+}
+
+func (e *VolumeError) Error() string {
+	return e.Message
+}
+
+// NewNotFoundError returns an error that indicates the named volume
+// was not found
+func NewNotFoundError(name string, cause error) *VolumeError {
+	msg := fmt.Sprintf("Volume '%s' not found", name)
+	if cause != nil {
+		msg = fmt.Sprintf("%s: %v", msg, cause)
+	}
+
+	return &VolumeError{
+		Reason:  ReasonNotFound,
+		Message: msg,
+	}
+}
+
+// NewAlreadyExistsError returns an error that indicates the named volume
+// exists already
+func NewAlreadyExistsError(name string) *VolumeError {
+	return &VolumeError{
+		Reason:  ReasonAlreadyExists,
+		Message: fmt.Sprintf("Volume '%s' already exists", name),
+	}
+}
+
+// NewConflictError returns an error that indicates the state of the volume
+// does not permit the operation
+func NewConflictError(cause error) *VolumeError {
+	return &VolumeError{
+		Reason:  ReasonConflict,
+		Message: cause.Error(),
+	}
+}
+
+// NewInvalidError returns an error that indicates an invalid claim or
+// request
+func NewInvalidError(cause error) *VolumeError {
+	return &VolumeError{
+		Reason:  ReasonInvalid,
+		Message: cause.Error(),
+	}
+}
+
+// NewFieldsInvalidError returns an error that indicates the named claim has
+// invalid fields. The problems are reported together as the error's causes.
+func NewFieldsInvalidError(name string, causes []FieldError) *VolumeError {
+	msgs := make([]string, 0, len(causes))
+	for _, cause := range causes {
+		msgs = append(msgs, cause.String())
+	}
+
+	return &VolumeError{
+		Reason:  ReasonInvalid,
+		Message: fmt.Sprintf("Claim '%s' is invalid: %s", name, strings.Join(msgs, "; ")),
+		Causes:  causes,
+	}
+}
+
+// NewUnavailableError returns an error that indicates the storage
+// infrastructure could not serve the request
+func NewUnavailableError(cause error) *VolumeError {
+	return &VolumeError{
+		Reason:  ReasonUnavailable,
+		Message: cause.Error(),
+	}
+}
+
+// NewUnsupportedError returns an error that indicates the storage
+// infrastructure does not support the operation
+func NewUnsupportedError(cause error) *VolumeError {
+	return &VolumeError{
+		Reason:  ReasonUnsupported,
+		Message: cause.Error(),
+	}
+}
+
+// ReasonForError returns the reason of the provided error. ReasonUnknown is
+// returned if the error is not a VolumeError.
+func ReasonForError(err error) ErrorReason {
+	if vErr, ok := err.(*VolumeError); ok {
+		return vErr.Reason
+	}
+
+	return ReasonUnknown
+}
+
+// IsNotFound returns true if the error indicates a missing volume
+func IsNotFound(err error) bool {
+	return ReasonForError(err) == ReasonNotFound
+}
+
+// IsAlreadyExists returns true if the error indicates an existing volume
+func IsAlreadyExists(err error) bool {
+	return ReasonForError(err) == ReasonAlreadyExists
+}
+
+// IsConflict returns true if the error indicates the state of the volume
+// does not permit the operation
+func IsConflict(err error) bool {
+	return ReasonForError(err) == ReasonConflict
+}
+
+// IsInvalid returns true if the error indicates an invalid claim or request
+func IsInvalid(err error) bool {
+	return ReasonForError(err) == ReasonInvalid
+}
+
+// IsUnavailable returns true if the error indicates the storage
+// infrastructure could not serve the request
+func IsUnavailable(err error) bool {
+	return ReasonForError(err) == ReasonUnavailable
+}
+
+// IsUnsupported returns true if the error indicates the storage
+// infrastructure does not support the operation
+func IsUnsupported(err error) bool {
+	return ReasonForError(err) == ReasonUnsupported
+}
+
+// ErrorResponse is the body of a failed http request to mayaserver
+type ErrorResponse struct {
+	// Code is the http status code
+	Code int `json:"code"`
+
+	// Reason is the machine readable reason of the failure
+	Reason ErrorReason `json:"reason"`
+
+	// Message is the human readable description of the failure
+	Message string `json:"message"`
+
+	// RequestID identifies the failed request in mayaserver's logs
+	RequestID string `json:"requestID"`
+
+	// Causes are the individual problems of an invalid claim
+	Causes []FieldError `json:"causes,omitempty"`
+
+	// Replicas are the outcomes at the replicas of a partially failed
+	// operation
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+}
@@ -14,12 +14,42 @@
 package v1
 
 import (
+	"time"
+
 	//nomadapi "github.com/hashicorp/nomad/api"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 )
 
+const (
+	// OrchClassAnnotation is the annotation of a persistent volume claim
+	// that names the orchestration provider to place the volume
+	OrchClassAnnotation = "volume.beta.openebs.io/orchestrator-class"
+
+	// RequestIDAnnotation is the annotation of a persistent volume claim
+	// or a persistent volume that carries the id of the mayaserver request
+	// operating on it. This is used for logging only.
+	RequestIDAnnotation = "volume.beta.openebs.io/request-id"
+
+	// CloneSourceVolumeAnnotation is the annotation of a persistent volume
+	// claim that names the volume to clone from. The resulting persistent
+	// volume carries this annotation as its provenance.
+	CloneSourceVolumeAnnotation = "volume.beta.openebs.io/clone-source-volume"
+
+	// CloneSourceSnapshotAnnotation is the annotation of a persistent volume
+	// claim that names the snapshot of the source volume to clone from. A
+	// snapshot is taken by the volume plugin if this is not set. The
+	// resulting persistent volume carries this annotation as its provenance.
+	CloneSourceSnapshotAnnotation = "volume.beta.openebs.io/clone-source-snapshot"
+)
+
+// RequestID provides the id of the mayaserver request that was set against
+// the object's annotations
+func RequestID(meta metav1.ObjectMeta) string {
+	return meta.Annotations[RequestIDAnnotation]
+}
+
 // PersistentVolumeClaim is a user's REQUEST for and CLAIM to a persistent volume
 type PersistentVolumeClaim struct {
 	metav1.TypeMeta `json:",inline"`
@@ -195,6 +225,26 @@ type PersistentVolumeStatus struct {
 	// Reason is a brief CamelCase string that describes any failure and is meant for machine parsing and tidy display in the CLI
 	// +optional
 	Reason string
+	// Replicas reports the status of each replica of the volume
+	// +optional
+	Replicas []VolumeReplicaStatus `json:"replicas,omitempty"`
+}
+
+// VolumeReplicaStatus is the status of a single replica of a volume
+type VolumeReplicaStatus struct {
+	// Name of the replica
+	Name string `json:"name"`
+
+	// Address is the ip of the replica
+	// +optional
+	Address string `json:"address,omitempty"`
+
+	// NodeID is the id of the node the replica is placed on
+	// +optional
+	NodeID string `json:"nodeID,omitempty"`
+
+	// Status of the replica e.g. pending, running, failed
+	Status string `json:"status"`
 }
 
 type PersistentVolumePhase string
@@ -222,6 +272,176 @@ type PersistentVolumeList struct {
 	Items []PersistentVolume
 }
 
+// VolumeSnapshot is a point-in-time copy of a persistent volume
+type VolumeSnapshot struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the volume whose snapshot is taken
+	Spec VolumeSnapshotSpec `json:"spec"`
+
+	// Status represents the current information of the snapshot.
+	// Read-only.
+	// +optional
+	Status VolumeSnapshotStatus `json:"status,omitempty"`
+}
+
+// VolumeSnapshotSpec is the specification of a volume snapshot
+type VolumeSnapshotSpec struct {
+	// VolumeName is the name of the persistent volume whose snapshot is
+	// taken
+	VolumeName string `json:"volumeName"`
+}
+
+// VolumeSnapshotStatus is the information of a volume snapshot as
+// reported by the storage
+type VolumeSnapshotStatus struct {
+	// Created is the time at which the snapshot was taken
+	// +optional
+	Created string `json:"created,omitempty"`
+
+	// Size is the size of the snapshot
+	// +optional
+	Size string `json:"size,omitempty"`
+}
+
+// VolumeSnapshotList is a list of VolumeSnapshot items
+type VolumeSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []VolumeSnapshot `json:"items"`
+}
+
+// StorageClass describes the parameters of a class of volumes. The
+// parameters are the defaults of the labels of the claims that name this
+// class as their storage class.
+type StorageClass struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Provisioner is the volume plugin that provisions the volumes of this
+	// class e.g. openebs.io/jiva or its short name e.g. jiva
+	Provisioner string `json:"provisioner"`
+
+	// Orchestrator is the orchestration provider that places the volumes of
+	// this class. The default orchestrator is used if this is not set.
+	// +optional
+	Orchestrator string `json:"orchestrator,omitempty"`
+
+	// Parameters are the defaults of the claim's labels
+	// +optional
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
+// StorageClassList is a list of StorageClass items
+type StorageClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []StorageClass `json:"items"`
+}
+
+// VolumeAttachment records the compute instance that holds a persistent
+// volume
+type VolumeAttachment struct {
+	metav1.TypeMeta `json:",inline"`
+	// Standard object's metadata.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Spec defines the volume & the instance it is attached to
+	Spec VolumeAttachmentSpec `json:"spec"`
+
+	// Status provides the details to connect to the attached volume.
+	// Read-only.
+	// +optional
+	Status VolumeAttachmentStatus `json:"status,omitempty"`
+}
+
+// VolumeAttachmentSpec is the specification of a volume attachment
+type VolumeAttachmentSpec struct {
+	// VolumeName is the name of the attached persistent volume
+	VolumeName string `json:"volumeName"`
+
+	// InstanceID is the id of the compute instance that holds the volume
+	InstanceID string `json:"instanceID"`
+}
+
+// VolumeAttachmentStatus provides the iSCSI target of an attached volume
+type VolumeAttachmentStatus struct {
+	// TargetPortal is the iSCSI target portal i.e. ip:port of the volume
+	// +optional
+	TargetPortal string `json:"targetPortal,omitempty"`
+
+	// IQN is the iSCSI qualified name of the volume
+	// +optional
+	IQN string `json:"iqn,omitempty"`
+}
+
+// VolumeAttachmentList is a list of VolumeAttachment items
+type VolumeAttachmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	// +optional
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []VolumeAttachment `json:"items"`
+}
+
+// QueryOptions is used to specify various flags while reading volume(s)
+// from the storage infrastructure.
+type QueryOptions struct {
+	// Region is the target region for this query
+	// +optional
+	Region string
+
+	// Datacenter, if set, limits the query to this datacenter of the
+	// storage infrastructure
+	// +optional
+	Datacenter string
+
+	// Prefix, if set, is used to filter the volumes by their names
+	// +optional
+	Prefix string
+
+	// AllowStale, if set, lets any follower of the storage infrastructure
+	// service the query. Results may be arbitrarily stale.
+	// +optional
+	AllowStale bool
+
+	// WaitIndex, if set, blocks the query until the index of the volume(s)
+	// exceeds this value. It is used along with WaitTime.
+	// +optional
+	WaitIndex uint64
+
+	// WaitTime is the maximum duration the query is blocked for a change.
+	// +optional
+	WaitTime time.Duration
+
+	// RequestID is the id of the mayaserver request that made this query.
+	// This is used for logging only.
+	// +optional
+	RequestID string
+}
+
+// QueryMeta provides the metadata of a query w.r.t the storage
+// infrastructure.
+type QueryMeta struct {
+	// LastIndex is the index of the volume(s) at the time of the query.
+	// This can be used as the WaitIndex of a subsequent blocking query.
+	LastIndex uint64
+
+	// LastContact is the time elapsed since the last contact between the
+	// queried follower & the leader of the storage infrastructure.
+	LastContact time.Duration
+}
+
 // Represents a Persistent Disk resource in OpenEBS.
 //
 // An OpenEBS disk must exist before mounting to a container. An OpenEBS disk
@@ -275,6 +495,11 @@ type ResourceRequirements struct {
 // ResourceName is the name identifying various resources in a ResourceList.
 type ResourceName string
 
+const (
+	// Volume size, in bytes (e,g. 5Gi = 5GiB = 5 * 1024 * 1024 * 1024)
+	ResourceStorage ResourceName = "storage"
+)
+
 // ResourceList is a set of (resource name, quantity) pairs.
 type ResourceList map[ResourceName]resource.Quantity
 
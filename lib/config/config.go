@@ -42,6 +42,21 @@ type MayaConfig struct {
 	// k8s etc
 	ServiceProvider string `mapstructure:"service_provider"`
 
+	// DefaultVolumePlugin is the volume plugin that caters to the volume
+	// requests which do not specify a storage class.
+	// Defaults to openebs.io/jiva
+	DefaultVolumePlugin string `mapstructure:"default_volume_plugin"`
+
+	// DefaultOrchProvider is the orchestration provider that caters to the
+	// volume requests which do not specify an orchestrator class.
+	// Defaults to nomad
+	DefaultOrchProvider string `mapstructure:"default_orchestrator"`
+
+	// EnableLegacyVolumeAPI is used to serve the deprecated volume routes
+	// i.e. /latest/volume/info/{name} & /latest/volume/delete/{name}.
+	// These are superseded by /latest/volumes/{name}.
+	EnableLegacyVolumeAPI bool `mapstructure:"enable_legacy_volume_api"`
+
 	// Ports is used to control the network ports we bind to.
 	Ports *Ports `mapstructure:"ports"`
 
@@ -82,6 +97,220 @@ type MayaConfig struct {
 	// HTTPAPIResponseHeaders allows users to configure the Nomad http agent to
 	// set arbritrary headers on API responses
 	HTTPAPIResponseHeaders map[string]string `mapstructure:"http_api_response_headers"`
+
+	// ACL is used to control the access to the http api via tokens
+	ACL *ACLConfig `mapstructure:"acl"`
+
+	// TLS is used to serve the http api over TLS
+	TLS *TLSConfig `mapstructure:"tls"`
+
+	// Telemetry is used to expose the metrics of mayaserver
+	Telemetry *Telemetry `mapstructure:"telemetry"`
+
+	// IPAM is used to allocate the ip addresses of the volumes
+	IPAM *IPAMConfig `mapstructure:"ipam"`
+
+	// StorageClasses are the storage classes mapped against their names
+	StorageClasses map[string]*StorageClass `mapstructure:"-"`
+}
+
+// StorageClass is a class of volumes defined in the config. The parameters
+// of the class are the defaults of the labels of the claims that name this
+// class as their storage class.
+//
+// NOTE:
+//    The storage classes defined in the config can not be modified via the
+// http api.
+type StorageClass struct {
+	// Name is the name the claims refer to
+	Name string `mapstructure:"-"`
+
+	// Provisioner is the volume plugin that provisions the volumes
+	Provisioner string `mapstructure:"provisioner"`
+
+	// Orchestrator is the orchestration provider that places the volumes
+	Orchestrator string `mapstructure:"orchestrator"`
+
+	// Parameters are the defaults of the claim's labels
+	Parameters map[string]string `mapstructure:"-"`
+}
+
+// IPAMConfig is used to allocate the ip addresses of the volumes whose
+// claims do not request any.
+//
+// NOTE:
+//    The allocations are persisted under data_dir. These are held in memory
+// only if data_dir is not set.
+type IPAMConfig struct {
+	// Pools are the CIDRs of the ip addresses mapped against their
+	// datacenter
+	Pools map[string][]string `mapstructure:"-"`
+}
+
+// Merge is used to merge two IPAM configs together. The pools of b
+// override the pools of a that belong to the same datacenter.
+func (i *IPAMConfig) Merge(b *IPAMConfig) *IPAMConfig {
+	result := *i
+
+	result.Pools = make(map[string][]string)
+	for dc, cidrs := range i.Pools {
+		result.Pools[dc] = cidrs
+	}
+	for dc, cidrs := range b.Pools {
+		result.Pools[dc] = cidrs
+	}
+
+	return &result
+}
+
+// Telemetry is the telemetry configuration for mayaserver
+type Telemetry struct {
+	// PrometheusMetrics exposes the metrics in Prometheus text format
+	PrometheusMetrics bool `mapstructure:"prometheus_metrics"`
+
+	// MetricsPath is the http path at which the metrics are exposed.
+	// Defaults to /metrics
+	MetricsPath string `mapstructure:"metrics_path"`
+
+	// DisableVolumeGauges skips listing the volumes, per scrape, to report
+	// the number of volumes per phase
+	DisableVolumeGauges bool `mapstructure:"disable_volume_gauges"`
+}
+
+// Merge is used to merge two telemetry configs together
+func (t *Telemetry) Merge(b *Telemetry) *Telemetry {
+	result := *t
+
+	if b.PrometheusMetrics {
+		result.PrometheusMetrics = true
+	}
+	if b.MetricsPath != "" {
+		result.MetricsPath = b.MetricsPath
+	}
+	if b.DisableVolumeGauges {
+		result.DisableVolumeGauges = true
+	}
+
+	return &result
+}
+
+// TLSConfig is used to serve the http api over TLS & optionally verify
+// the certificates of the clients.
+//
+// NOTE:
+//    The certificate, key & CA files are read again on SIGHUP. However,
+// enabling or disabling TLS requires a restart.
+type TLSConfig struct {
+	// Enabled serves the http api over TLS
+	Enabled bool `mapstructure:"enabled"`
+
+	// CertFile is the path to the PEM encoded certificate of the server
+	CertFile string `mapstructure:"cert_file"`
+
+	// KeyFile is the path to the PEM encoded private key of the server
+	KeyFile string `mapstructure:"key_file"`
+
+	// CAFile is the path to the PEM encoded CA certificate(s) that are used
+	// to verify the certificates of the clients
+	CAFile string `mapstructure:"ca_file"`
+
+	// VerifyClient requires the clients to present a certificate signed
+	// by the CA i.e. mutual TLS
+	VerifyClient bool `mapstructure:"verify_client"`
+}
+
+// Merge is used to merge two TLS configs together
+func (t *TLSConfig) Merge(b *TLSConfig) *TLSConfig {
+	result := *t
+
+	if b.Enabled {
+		result.Enabled = true
+	}
+	if b.CertFile != "" {
+		result.CertFile = b.CertFile
+	}
+	if b.KeyFile != "" {
+		result.KeyFile = b.KeyFile
+	}
+	if b.CAFile != "" {
+		result.CAFile = b.CAFile
+	}
+	if b.VerifyClient {
+		result.VerifyClient = true
+	}
+
+	return &result
+}
+
+// Validate verifies if the TLS config has the files it needs
+func (t *TLSConfig) Validate() error {
+	if !t.Enabled {
+		return nil
+	}
+
+	if t.CertFile == "" || t.KeyFile == "" {
+		return fmt.Errorf("tls: both cert_file & key_file are required")
+	}
+
+	if t.VerifyClient && t.CAFile == "" {
+		return fmt.Errorf("tls: ca_file is required to verify the clients")
+	}
+
+	return nil
+}
+
+const (
+	// ACLPolicyReadOnly allows reading the volumes & the meta data
+	ACLPolicyReadOnly = "read-only"
+
+	// ACLPolicyVolumeWrite allows reading as well as provisioning & deleting
+	// the volumes
+	ACLPolicyVolumeWrite = "volume-write"
+
+	// ACLPolicyMetadataOnly allows reading the meta data only
+	ACLPolicyMetadataOnly = "metadata-only"
+)
+
+// ACLConfig is used to configure the token based access control of the
+// http api.
+//
+// NOTE:
+//    A request is expected to provide the secret of a token in the
+// X-Maya-Token header, when the ACLs are enabled. An EC2 request may
+// instead be signed via AWS Signature Version 4 with the access key id &
+// the secret of a token.
+type ACLConfig struct {
+	// Enabled turns on the enforcement of ACLs
+	Enabled bool `mapstructure:"enabled"`
+
+	// Tokens are the known tokens mapped against their names
+	Tokens map[string]*ACLToken `mapstructure:"-"`
+}
+
+// ACLToken is a secret that is granted a policy
+type ACLToken struct {
+	// Name identifies the token in the logs
+	Name string `mapstructure:"-"`
+
+	// Secret is the value that is sent in the request header
+	Secret string `mapstructure:"secret"`
+
+	// Policy is one of read-only, volume-write or metadata-only
+	Policy string `mapstructure:"policy"`
+
+	// AccessKeyID, if set, lets EC2 clients e.g. aws-cli sign their requests
+	// with this access key id & the token's secret as the secret access key
+	AccessKeyID string `mapstructure:"access_key_id"`
+}
+
+// IsACLPolicy returns true if the policy is a known ACL policy
+func IsACLPolicy(policy string) bool {
+	switch policy {
+	case ACLPolicyReadOnly, ACLPolicyVolumeWrite, ACLPolicyMetadataOnly:
+		return true
+	default:
+		return false
+	}
 }
 
 // Ports encapsulates the various ports we bind to for network services. If any
@@ -113,9 +342,14 @@ func DefaultMayaConfig() *MayaConfig {
 		Ports: &Ports{
 			HTTP: 5656,
 		},
-		Addresses:      &Addresses{},
-		AdvertiseAddrs: &AdvertiseAddrs{},
-		SyslogFacility: "LOCAL0",
+		Addresses:           &Addresses{},
+		AdvertiseAddrs:      &AdvertiseAddrs{},
+		SyslogFacility:      "LOCAL0",
+		DefaultVolumePlugin: "openebs.io/jiva",
+		DefaultOrchProvider: "nomad",
+		Telemetry: &Telemetry{
+			MetricsPath: "/metrics",
+		},
 	}
 }
 
@@ -181,6 +415,15 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 	if b.SyslogFacility != "" {
 		result.SyslogFacility = b.SyslogFacility
 	}
+	if b.DefaultVolumePlugin != "" {
+		result.DefaultVolumePlugin = b.DefaultVolumePlugin
+	}
+	if b.DefaultOrchProvider != "" {
+		result.DefaultOrchProvider = b.DefaultOrchProvider
+	}
+	if b.EnableLegacyVolumeAPI {
+		result.EnableLegacyVolumeAPI = true
+	}
 
 	// Apply the ports config
 	if result.Ports == nil && b.Ports != nil {
@@ -217,6 +460,71 @@ func (mc *MayaConfig) Merge(b *MayaConfig) *MayaConfig {
 		result.HTTPAPIResponseHeaders[k] = v
 	}
 
+	// Apply the telemetry config
+	if result.Telemetry == nil && b.Telemetry != nil {
+		telemetry := *b.Telemetry
+		result.Telemetry = &telemetry
+	} else if b.Telemetry != nil {
+		result.Telemetry = result.Telemetry.Merge(b.Telemetry)
+	}
+
+	// Apply the tls config
+	if result.TLS == nil && b.TLS != nil {
+		tls := *b.TLS
+		result.TLS = &tls
+	} else if b.TLS != nil {
+		result.TLS = result.TLS.Merge(b.TLS)
+	}
+
+	// Apply the acl config
+	if result.ACL == nil && b.ACL != nil {
+		acl := *b.ACL
+		result.ACL = &acl
+	} else if b.ACL != nil {
+		result.ACL = result.ACL.Merge(b.ACL)
+	}
+
+	// Apply the ipam config
+	if result.IPAM == nil && b.IPAM != nil {
+		ipam := *b.IPAM
+		result.IPAM = &ipam
+	} else if b.IPAM != nil {
+		result.IPAM = result.IPAM.Merge(b.IPAM)
+	}
+
+	// Apply the storage classes. The classes of b override the classes
+	// that have the same name.
+	if b.StorageClasses != nil {
+		classes := make(map[string]*StorageClass)
+		for name, class := range result.StorageClasses {
+			classes[name] = class
+		}
+		for name, class := range b.StorageClasses {
+			classes[name] = class
+		}
+		result.StorageClasses = classes
+	}
+
+	return &result
+}
+
+// Merge is used to merge two ACL configs together. The tokens of b
+// override the tokens of a that have the same name.
+func (a *ACLConfig) Merge(b *ACLConfig) *ACLConfig {
+	result := *a
+
+	if b.Enabled {
+		result.Enabled = true
+	}
+
+	result.Tokens = make(map[string]*ACLToken)
+	for name, token := range a.Tokens {
+		result.Tokens[name] = token
+	}
+	for name, token := range b.Tokens {
+		result.Tokens[name] = token
+	}
+
 	return &result
 }
 
@@ -4,8 +4,10 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/hashicorp/hcl"
@@ -85,6 +87,14 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 		"enable_syslog",
 		"syslog_facility",
 		"http_api_response_headers",
+		"default_volume_plugin",
+		"default_orchestrator",
+		"enable_legacy_volume_api",
+		"acl",
+		"tls",
+		"telemetry",
+		"ipam",
+		"storage_class",
 	}
 	if err := checkHCLKeys(list, valid); err != nil {
 		return multierror.Prefix(err, "config:")
@@ -100,6 +110,11 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 	delete(m, "interfaces")
 	delete(m, "advertise")
 	delete(m, "http_api_response_headers")
+	delete(m, "acl")
+	delete(m, "tls")
+	delete(m, "telemetry")
+	delete(m, "ipam")
+	delete(m, "storage_class")
 
 	// Decode the rest
 	if err := mapstructure.WeakDecode(m, result); err != nil {
@@ -127,6 +142,42 @@ func parseConfig(result *MayaConfig, list *ast.ObjectList) error {
 		}
 	}
 
+	// Parse telemetry
+	if o := list.Filter("telemetry"); len(o.Items) > 0 {
+		if err := parseTelemetry(&result.Telemetry, o); err != nil {
+			return multierror.Prefix(err, "telemetry ->")
+		}
+	}
+
+	// Parse tls
+	if o := list.Filter("tls"); len(o.Items) > 0 {
+		if err := parseTLS(&result.TLS, o); err != nil {
+			return multierror.Prefix(err, "tls ->")
+		}
+	}
+
+	// Parse acl
+	if o := list.Filter("acl"); len(o.Items) > 0 {
+		if err := parseACL(&result.ACL, o); err != nil {
+			return multierror.Prefix(err, "acl ->")
+		}
+	}
+
+	// Parse ipam
+	if o := list.Filter("ipam"); len(o.Items) > 0 {
+		if err := parseIPAM(&result.IPAM, o); err != nil {
+			return multierror.Prefix(err, "ipam ->")
+		}
+	}
+
+	// Parse storage classes
+	if o := list.Filter("storage_class"); len(o.Items) > 0 {
+		result.StorageClasses = make(map[string]*StorageClass)
+		if err := parseStorageClasses(result.StorageClasses, o); err != nil {
+			return multierror.Prefix(err, "storage_class ->")
+		}
+	}
+
 	// Parse the nomad config
 	//if o := list.Filter("nomad"); len(o.Items) > 0 {
 	//	if err := parseNomadConfig(&result.Nomad, o); err != nil {
@@ -241,6 +292,326 @@ func parseAdvertise(result **AdvertiseAddrs, list *ast.ObjectList) error {
 	return nil
 }
 
+func parseTelemetry(result **Telemetry, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'telemetry' block allowed")
+	}
+
+	// Get our telemetry object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"prometheus_metrics",
+		"metrics_path",
+		"disable_volume_gauges",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var telemetry Telemetry
+	if err := mapstructure.WeakDecode(m, &telemetry); err != nil {
+		return err
+	}
+
+	if telemetry.MetricsPath != "" && !strings.HasPrefix(telemetry.MetricsPath, "/") {
+		return fmt.Errorf("metrics_path '%s' must start with /", telemetry.MetricsPath)
+	}
+
+	*result = &telemetry
+	return nil
+}
+
+func parseTLS(result **TLSConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'tls' block allowed")
+	}
+
+	// Get our tls object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"enabled",
+		"cert_file",
+		"key_file",
+		"ca_file",
+		"verify_client",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+
+	var tls TLSConfig
+	if err := mapstructure.WeakDecode(m, &tls); err != nil {
+		return err
+	}
+
+	if err := tls.Validate(); err != nil {
+		return err
+	}
+
+	*result = &tls
+	return nil
+}
+
+func parseACL(result **ACLConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'acl' block allowed")
+	}
+
+	// Get our acl object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"enabled",
+		"token",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, listVal); err != nil {
+		return err
+	}
+	delete(m, "token")
+
+	var acl ACLConfig
+	if err := mapstructure.WeakDecode(m, &acl); err != nil {
+		return err
+	}
+
+	// Parse the tokens
+	acl.Tokens = make(map[string]*ACLToken)
+	if ot, ok := listVal.(*ast.ObjectType); ok {
+		if o := ot.List.Filter("token"); len(o.Items) > 0 {
+			if err := parseACLTokens(acl.Tokens, o); err != nil {
+				return multierror.Prefix(err, "token ->")
+			}
+		}
+	}
+
+	*result = &acl
+	return nil
+}
+
+func parseACLTokens(result map[string]*ACLToken, list *ast.ObjectList) error {
+	secrets := make(map[string]string)
+	accessKeyIDs := make(map[string]string)
+
+	for _, item := range list.Items {
+		if len(item.Keys) != 1 {
+			return fmt.Errorf("token must be named e.g. token \"ops\" { ... }")
+		}
+		name := item.Keys[0].Token.Value().(string)
+
+		if _, found := result[name]; found {
+			return fmt.Errorf("token '%s' defined more than once", name)
+		}
+
+		// Check for invalid keys
+		valid := []string{
+			"secret",
+			"policy",
+			"access_key_id",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", name))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+
+		var token ACLToken
+		if err := mapstructure.WeakDecode(m, &token); err != nil {
+			return err
+		}
+		token.Name = name
+
+		if token.Secret == "" {
+			return fmt.Errorf("token '%s' is missing the secret", name)
+		}
+
+		if other, found := secrets[token.Secret]; found {
+			return fmt.Errorf("token '%s' has the same secret as token '%s'", name, other)
+		}
+		secrets[token.Secret] = name
+
+		if token.AccessKeyID != "" {
+			if other, found := accessKeyIDs[token.AccessKeyID]; found {
+				return fmt.Errorf("token '%s' has the same access key id as token '%s'", name, other)
+			}
+			accessKeyIDs[token.AccessKeyID] = name
+		}
+
+		if !IsACLPolicy(token.Policy) {
+			return fmt.Errorf("token '%s' has an invalid policy '%s'", name, token.Policy)
+		}
+
+		result[name] = &token
+	}
+
+	return nil
+}
+
+func parseIPAM(result **IPAMConfig, list *ast.ObjectList) error {
+	list = list.Elem()
+	if len(list.Items) > 1 {
+		return fmt.Errorf("only one 'ipam' block allowed")
+	}
+
+	// Get our ipam object
+	listVal := list.Items[0].Val
+
+	// Check for invalid keys
+	valid := []string{
+		"pool",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return err
+	}
+
+	// Parse the pools
+	ipam := IPAMConfig{
+		Pools: make(map[string][]string),
+	}
+	if ot, ok := listVal.(*ast.ObjectType); ok {
+		if o := ot.List.Filter("pool"); len(o.Items) > 0 {
+			if err := parseIPAMPools(ipam.Pools, o); err != nil {
+				return multierror.Prefix(err, "pool ->")
+			}
+		}
+	}
+
+	*result = &ipam
+	return nil
+}
+
+func parseIPAMPools(result map[string][]string, list *ast.ObjectList) error {
+	for _, item := range list.Items {
+		if len(item.Keys) != 1 {
+			return fmt.Errorf("pool must be named after its datacenter e.g. pool \"dc1\" { ... }")
+		}
+		dc := item.Keys[0].Token.Value().(string)
+
+		if _, found := result[dc]; found {
+			return fmt.Errorf("pool '%s' defined more than once", dc)
+		}
+
+		// Check for invalid keys
+		valid := []string{
+			"cidrs",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", dc))
+		}
+
+		var pool struct {
+			CIDRs []string `mapstructure:"cidrs"`
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		if err := mapstructure.WeakDecode(m, &pool); err != nil {
+			return err
+		}
+
+		if len(pool.CIDRs) == 0 {
+			return fmt.Errorf("pool '%s' is missing the cidrs", dc)
+		}
+
+		for _, cidr := range pool.CIDRs {
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("pool '%s' has an invalid cidr '%s'", dc, cidr)
+			}
+		}
+
+		result[dc] = pool.CIDRs
+	}
+
+	return nil
+}
+
+func parseStorageClasses(result map[string]*StorageClass, list *ast.ObjectList) error {
+	for _, item := range list.Items {
+		if len(item.Keys) != 1 {
+			return fmt.Errorf("storage class must be named e.g. storage_class \"gold\" { ... }")
+		}
+		name := item.Keys[0].Token.Value().(string)
+
+		if _, found := result[name]; found {
+			return fmt.Errorf("storage class '%s' defined more than once", name)
+		}
+
+		// Check for invalid keys
+		valid := []string{
+			"provisioner",
+			"orchestrator",
+			"parameters",
+		}
+		if err := checkHCLKeys(item.Val, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", name))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		delete(m, "parameters")
+
+		var class StorageClass
+		if err := mapstructure.WeakDecode(m, &class); err != nil {
+			return err
+		}
+		class.Name = name
+
+		if class.Provisioner == "" {
+			return fmt.Errorf("storage class '%s' is missing the provisioner", name)
+		}
+
+		// Parameters are in HCL as a list of objects, hence these are
+		// merged together
+		if ot, ok := item.Val.(*ast.ObjectType); ok {
+			if o := ot.List.Filter("parameters"); len(o.Items) > 0 {
+				class.Parameters = make(map[string]string)
+				for _, po := range o.Elem().Items {
+					var pm map[string]interface{}
+					if err := hcl.DecodeObject(&pm, po.Val); err != nil {
+						return err
+					}
+					if err := mapstructure.WeakDecode(pm, &class.Parameters); err != nil {
+						return multierror.Prefix(err, fmt.Sprintf("'%s' -> parameters ->", name))
+					}
+				}
+			}
+		}
+
+		result[name] = &class
+	}
+
+	return nil
+}
+
 func checkHCLKeys(node ast.Node, valid []string) error {
 	var list *ast.ObjectList
 	switch n := node.(type) {
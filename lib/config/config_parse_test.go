@@ -3,6 +3,7 @@ package config
 import (
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -36,6 +37,53 @@ func TestMayaConfig_Parse(t *testing.T) {
 				HTTPAPIResponseHeaders: map[string]string{
 					"Access-Control-Allow-Origin": "*",
 				},
+				DefaultVolumePlugin:   "openebs.io/jiva",
+				DefaultOrchProvider:   "nomad",
+				EnableLegacyVolumeAPI: true,
+				ACL: &ACLConfig{
+					Enabled: true,
+					Tokens: map[string]*ACLToken{
+						"ops": &ACLToken{
+							Name:        "ops",
+							Secret:      "ops-secret",
+							Policy:      ACLPolicyVolumeWrite,
+							AccessKeyID: "AKIDOPS",
+						},
+						"monitor": &ACLToken{
+							Name:   "monitor",
+							Secret: "monitor-secret",
+							Policy: ACLPolicyReadOnly,
+						},
+					},
+				},
+				TLS: &TLSConfig{
+					Enabled:      true,
+					CertFile:     "/etc/mayaserver/tls/server.pem",
+					KeyFile:      "/etc/mayaserver/tls/server-key.pem",
+					CAFile:       "/etc/mayaserver/tls/ca.pem",
+					VerifyClient: true,
+				},
+				Telemetry: &Telemetry{
+					PrometheusMetrics:   true,
+					MetricsPath:         "/v1/metrics",
+					DisableVolumeGauges: true,
+				},
+				IPAM: &IPAMConfig{
+					Pools: map[string][]string{
+						"dc2": []string{"172.28.128.0/24", "172.28.129.0/25"},
+					},
+				},
+				StorageClasses: map[string]*StorageClass{
+					"gold": &StorageClass{
+						Name:         "gold",
+						Provisioner:  "openebs.io/jiva",
+						Orchestrator: "nomad",
+						Parameters: map[string]string{
+							"jivareplicas":   "2",
+							"jivafeipsubnet": "24",
+						},
+					},
+				},
 			},
 			false,
 		},
@@ -61,3 +109,136 @@ func TestMayaConfig_Parse(t *testing.T) {
 		}
 	}
 }
+
+func TestMayaConfig_ParseACL_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"invalid policy": `acl {
+	token "ops" {
+		secret = "s1"
+		policy = "admin"
+	}
+}`,
+		"missing secret": `acl {
+	token "ops" {
+		policy = "read-only"
+	}
+}`,
+		"duplicate secret": `acl {
+	token "ops" {
+		secret = "s1"
+		policy = "read-only"
+	}
+	token "dev" {
+		secret = "s1"
+		policy = "volume-write"
+	}
+}`,
+		"duplicate access key id": `acl {
+	token "ops" {
+		secret = "s1"
+		policy = "read-only"
+		access_key_id = "AKID"
+	}
+	token "dev" {
+		secret = "s2"
+		policy = "volume-write"
+		access_key_id = "AKID"
+	}
+}`,
+		"invalid key": `acl {
+	tokens = "s1"
+}`,
+	}
+
+	for desc, hcl := range cases {
+		if _, err := ParseMayaConfig(strings.NewReader(hcl)); err == nil {
+			t.Fatalf("%s: expected error, got nothing", desc)
+		}
+	}
+}
+
+func TestMayaConfig_ParseTLS_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing key file": `tls {
+	enabled = true
+	cert_file = "server.pem"
+}`,
+		"missing ca file": `tls {
+	enabled = true
+	cert_file = "server.pem"
+	key_file = "server-key.pem"
+	verify_client = true
+}`,
+		"invalid key": `tls {
+	cert = "server.pem"
+}`,
+	}
+
+	for desc, hcl := range cases {
+		if _, err := ParseMayaConfig(strings.NewReader(hcl)); err == nil {
+			t.Fatalf("%s: expected error, got nothing", desc)
+		}
+	}
+}
+
+func TestMayaConfig_ParseIPAM_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"invalid cidr": `ipam {
+	pool "dc1" {
+		cidrs = ["172.28.128.0/33"]
+	}
+}`,
+		"missing cidrs": `ipam {
+	pool "dc1" {
+	}
+}`,
+		"duplicate pool": `ipam {
+	pool "dc1" {
+		cidrs = ["172.28.128.0/24"]
+	}
+	pool "dc1" {
+		cidrs = ["172.28.129.0/24"]
+	}
+}`,
+		"unnamed pool": `ipam {
+	pool {
+		cidrs = ["172.28.128.0/24"]
+	}
+}`,
+	}
+
+	for desc, hcl := range cases {
+		if _, err := ParseMayaConfig(strings.NewReader(hcl)); err == nil {
+			t.Fatalf("%s: expected error, got nothing", desc)
+		}
+	}
+}
+
+func TestMayaConfig_ParseStorageClass_Invalid(t *testing.T) {
+	cases := map[string]string{
+		"missing provisioner": `storage_class "gold" {
+	orchestrator = "nomad"
+}`,
+		"duplicate class": `storage_class "gold" {
+	provisioner = "jiva"
+}
+storage_class "gold" {
+	provisioner = "jiva"
+}`,
+		"unnamed class": `storage_class {
+	provisioner = "jiva"
+}`,
+		"invalid key": `storage_class "gold" {
+	provisioner = "jiva"
+	params {
+		jivareplicas = "2"
+	}
+}`,
+	}
+
+	for desc, hcl := range cases {
+		if _, err := ParseMayaConfig(strings.NewReader(hcl)); err == nil {
+			t.Fatalf("%s: expected error, got nothing", desc)
+		}
+	}
+}
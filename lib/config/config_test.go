@@ -63,6 +63,42 @@ func TestMayaConfig_Merge(t *testing.T) {
 			"Access-Control-Allow-Origin":  "*",
 			"Access-Control-Allow-Methods": "GET, POST, OPTIONS",
 		},
+		DefaultVolumePlugin:   "openebs.io/jiva",
+		DefaultOrchProvider:   "nomad",
+		EnableLegacyVolumeAPI: true,
+		ACL: &ACLConfig{
+			Enabled: true,
+			Tokens: map[string]*ACLToken{
+				"ops": &ACLToken{
+					Name:   "ops",
+					Secret: "ops-secret",
+					Policy: ACLPolicyVolumeWrite,
+				},
+			},
+		},
+		TLS: &TLSConfig{
+			Enabled:      true,
+			CertFile:     "/tmp/server.pem",
+			KeyFile:      "/tmp/server-key.pem",
+			CAFile:       "/tmp/ca.pem",
+			VerifyClient: true,
+		},
+		Telemetry: &Telemetry{
+			PrometheusMetrics:   true,
+			MetricsPath:         "/metrics",
+			DisableVolumeGauges: true,
+		},
+		IPAM: &IPAMConfig{
+			Pools: map[string][]string{
+				"dc2": []string{"172.28.128.0/24"},
+			},
+		},
+		StorageClasses: map[string]*StorageClass{
+			"gold": &StorageClass{
+				Name:        "gold",
+				Provisioner: "openebs.io/jiva",
+			},
+		},
 	}
 
 	result := c1.Merge(c2)
@@ -0,0 +1,317 @@
+// Package ipam manages the ip addresses of the volumes.
+//
+// NOTE:
+//    The ip addresses are allocated from the CIDR pools of a datacenter. An
+// ip address that is requested explicitly is reserved as well, irrespective
+// of it being in a pool, s.t. it is not allocated to any other volume.
+package ipam
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// allocationsFile is the file under the state directory that persists the
+// allocations
+const allocationsFile = "ipam.json"
+
+// IPAM allocates & reserves the ip addresses of the volumes. The ip
+// addresses are owned by the volume's name.
+type IPAM interface {
+	// HasPool returns true if the datacenter has a pool to allocate from
+	HasPool(dc string) bool
+
+	// Allocate provides n free ip addresses from the pools of the
+	// datacenter
+	Allocate(dc, owner string, n int) ([]string, error)
+
+	// Reserve marks the ip addresses as in use by the owner. This fails
+	// if any of the ip addresses is in use by another owner. The ip
+	// addresses that were not in use by the owner earlier are provided.
+	Reserve(dc, owner string, ips []string) ([]string, error)
+
+	// Release frees the provided ip addresses of the owner or all of them
+	// if none are provided
+	Release(owner string, ips ...string) error
+}
+
+// poolIPAM is an implementation of IPAM interface that allocates from the
+// CIDR pools of each datacenter
+type poolIPAM struct {
+	sync.Mutex
+
+	// pools are the CIDRs mapped against their datacenter
+	pools map[string][]*net.IPNet
+
+	// allocs are the owners of the ip addresses in use, mapped against
+	// their datacenter
+	allocs map[string]map[string]string
+
+	// stateDir persists the allocations. The allocations are held in
+	// memory only, if this is not set.
+	stateDir string
+}
+
+// NewIPAM provides an IPAM that allocates from the CIDR pools mapped
+// against their datacenter. The allocations persisted earlier under the
+// state directory are restored.
+func NewIPAM(pools map[string][]string, stateDir string) (IPAM, error) {
+	p := &poolIPAM{
+		pools:    make(map[string][]*net.IPNet),
+		allocs:   make(map[string]map[string]string),
+		stateDir: stateDir,
+	}
+
+	for dc, cidrs := range pools {
+		for _, cidr := range cidrs {
+			_, ipNet, err := net.ParseCIDR(cidr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid cidr '%s' of datacenter '%s': %v", cidr, dc, err)
+			}
+			p.pools[dc] = append(p.pools[dc], ipNet)
+		}
+	}
+
+	if err := p.restore(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *poolIPAM) HasPool(dc string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.pools[dc]) != 0
+}
+
+func (p *poolIPAM) Allocate(dc, owner string, n int) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.pools[dc]) == 0 {
+		return nil, v1.NewInvalidError(fmt.Errorf("Datacenter '%s' has no ip address pool", dc))
+	}
+
+	var ips []string
+	for _, ipNet := range p.pools[dc] {
+		for ip := firstHost(ipNet); ip != nil && len(ips) < n; ip = nextHost(ipNet, ip) {
+			if _, inUse := p.allocs[dc][ip.String()]; !inUse {
+				ips = append(ips, ip.String())
+			}
+		}
+	}
+
+	if len(ips) < n {
+		return nil, v1.NewUnavailableError(fmt.Errorf("Ip address pool of datacenter '%s' has %d of the %d ip addresses required by volume '%s'", dc, len(ips), n, owner))
+	}
+
+	if _, err := p.commit(dc, owner, ips); err != nil {
+		return nil, err
+	}
+
+	return ips, nil
+}
+
+func (p *poolIPAM) Reserve(dc, owner string, ips []string) ([]string, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	for _, ip := range ips {
+		parsed := net.ParseIP(ip)
+		if parsed == nil {
+			return nil, v1.NewInvalidError(fmt.Errorf("Invalid ip address '%s' of volume '%s'", ip, owner))
+		}
+
+		if other, inUse := p.allocs[dc][parsed.String()]; inUse && other != owner {
+			return nil, v1.NewConflictError(fmt.Errorf("Ip address '%s' of volume '%s' is in use by volume '%s'", ip, owner, other))
+		}
+	}
+
+	normalized := make([]string, 0, len(ips))
+	for _, ip := range ips {
+		normalized = append(normalized, net.ParseIP(ip).String())
+	}
+
+	return p.commit(dc, owner, normalized)
+}
+
+func (p *poolIPAM) Release(owner string, ips ...string) error {
+	p.Lock()
+	defer p.Unlock()
+
+	selected := func(ip string) bool {
+		if len(ips) == 0 {
+			return true
+		}
+		for _, s := range ips {
+			if s == ip {
+				return true
+			}
+		}
+		return false
+	}
+
+	released := false
+	for _, allocs := range p.allocs {
+		for ip, o := range allocs {
+			if o == owner && selected(ip) {
+				delete(allocs, ip)
+				released = true
+			}
+		}
+	}
+
+	if !released {
+		return nil
+	}
+
+	return p.persist()
+}
+
+// commit records the ip addresses against the owner & persists the
+// allocations. The ip addresses that were not recorded earlier are provided.
+// The records are undone if these can not be persisted.
+func (p *poolIPAM) commit(dc, owner string, ips []string) ([]string, error) {
+	if p.allocs[dc] == nil {
+		p.allocs[dc] = make(map[string]string)
+	}
+
+	var added []string
+	for _, ip := range ips {
+		if _, inUse := p.allocs[dc][ip]; !inUse {
+			p.allocs[dc][ip] = owner
+			added = append(added, ip)
+		}
+	}
+
+	if len(added) == 0 {
+		return nil, nil
+	}
+
+	if err := p.persist(); err != nil {
+		for _, ip := range added {
+			delete(p.allocs[dc], ip)
+		}
+		return nil, err
+	}
+
+	return added, nil
+}
+
+// persist writes the allocations to the state directory. The file is
+// replaced atomically s.t. a crash does not leave a partial file.
+func (p *poolIPAM) persist() error {
+	if p.stateDir == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(p.allocs, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(p.stateDir, 0755); err != nil {
+		return fmt.Errorf("unable to create ipam state dir: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(p.stateDir, allocationsFile)
+	if err != nil {
+		return fmt.Errorf("unable to persist ip address allocations: %v", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to persist ip address allocations: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to persist ip address allocations: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(p.stateDir, allocationsFile))
+}
+
+// restore reads the allocations from the state directory, if any
+func (p *poolIPAM) restore() error {
+	if p.stateDir == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(p.stateDir, allocationsFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read ip address allocations: %v", err)
+	}
+
+	if err := json.Unmarshal(data, &p.allocs); err != nil {
+		return fmt.Errorf("unable to decode ip address allocations: %v", err)
+	}
+
+	return nil
+}
+
+// firstHost provides the first ip address of the network that can be
+// assigned to a host. The network address i.e. the one with all zero host
+// bits is skipped unless it is a point to point network.
+func firstHost(ipNet *net.IPNet) net.IP {
+	ip := ipNet.IP.Mask(ipNet.Mask)
+	if ones, bits := ipNet.Mask.Size(); bits-ones < 2 {
+		return ip
+	}
+
+	return nextHost(ipNet, ip)
+}
+
+// nextHost provides the ip address after ip in the network. Returns nil if
+// there is none. The broadcast address of an IPv4 network is skipped unless
+// it is a point to point network.
+func nextHost(ipNet *net.IPNet, ip net.IP) net.IP {
+	next := make(net.IP, len(ip))
+	copy(next, ip)
+
+	for i := len(next) - 1; i >= 0; i-- {
+		next[i]++
+		if next[i] != 0 {
+			break
+		}
+	}
+
+	if !ipNet.Contains(next) || next.Equal(ipNet.IP.Mask(ipNet.Mask)) {
+		return nil
+	}
+
+	if ones, bits := ipNet.Mask.Size(); bits == 8*net.IPv4len && bits-ones >= 2 && isBroadcast(ipNet, next) {
+		return nil
+	}
+
+	return next
+}
+
+// isBroadcast returns true if ip is the last address of the network
+func isBroadcast(ipNet *net.IPNet, ip net.IP) bool {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return false
+	}
+
+	for i := range ip4 {
+		if ip4[i]|ipNet.Mask[i] != 0xff {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,127 @@
+package ipam
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+func TestAllocate(t *testing.T) {
+	i, err := NewIPAM(map[string][]string{"dc1": {"172.28.128.0/30", "172.28.129.8/31"}}, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The network & broadcast addresses of a /30 are skipped
+	ips, err := i.Allocate("dc1", "jivavol1", 3)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []string{"172.28.128.1", "172.28.128.2", "172.28.129.8"}
+	if !reflect.DeepEqual(ips, expected) {
+		t.Fatalf("bad ips: expected: %v, got: %v", expected, ips)
+	}
+
+	ips, err = i.Allocate("dc1", "jivavol2", 1)
+	if err != nil || !reflect.DeepEqual(ips, []string{"172.28.129.9"}) {
+		t.Fatalf("bad ips: %v, err: %v", ips, err)
+	}
+
+	if _, err := i.Allocate("dc1", "jivavol3", 1); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	if _, err := i.Allocate("dc2", "jivavol3", 1); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	// Released addresses can be allocated again
+	if err := i.Release("jivavol1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ips, err = i.Allocate("dc1", "jivavol3", 1)
+	if err != nil || !reflect.DeepEqual(ips, []string{"172.28.128.1"}) {
+		t.Fatalf("bad ips: %v, err: %v", ips, err)
+	}
+}
+
+func TestReserve(t *testing.T) {
+	i, err := NewIPAM(map[string][]string{"dc1": {"172.28.128.0/29"}}, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := i.Reserve("dc1", "jivavol1", []string{"172.28.128.1", "10.0.0.1"}); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Reserving again by the same owner reserves only the new ones
+	added, err := i.Reserve("dc1", "jivavol1", []string{"172.28.128.1", "172.28.128.3"})
+	if err != nil || !reflect.DeepEqual(added, []string{"172.28.128.3"}) {
+		t.Fatalf("bad reserved ips: %v, err: %v", added, err)
+	}
+
+	// Only the provided ips are released
+	if err := i.Release("jivavol1", "172.28.128.3"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := i.Reserve("dc1", "jivavol2", []string{"10.0.0.1"}); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+
+	// A reserved address is not allocated
+	ips, err := i.Allocate("dc1", "jivavol2", 1)
+	if err != nil || !reflect.DeepEqual(ips, []string{"172.28.128.2"}) {
+		t.Fatalf("bad ips: %v, err: %v", ips, err)
+	}
+
+	if _, err := i.Reserve("dc1", "jivavol2", []string{"172.28.128"}); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+}
+
+func TestIPAM_Persisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "ipam")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	pools := map[string][]string{"dc1": {"172.28.128.0/29"}}
+
+	i, err := NewIPAM(pools, dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := i.Allocate("dc1", "jivavol1", 2); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The allocations survive a restart
+	i, err = NewIPAM(pools, dir)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ips, err := i.Allocate("dc1", "jivavol2", 1)
+	if err != nil || !reflect.DeepEqual(ips, []string{"172.28.128.3"}) {
+		t.Fatalf("bad ips: %v, err: %v", ips, err)
+	}
+
+	if _, err := i.Reserve("dc1", "jivavol3", []string{"172.28.128.1"}); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+}
+
+func TestNewIPAM_InvalidCIDR(t *testing.T) {
+	if _, err := NewIPAM(map[string][]string{"dc1": {"172.28.128.0/33"}}, ""); err == nil {
+		t.Fatalf("expected error for an invalid cidr")
+	}
+}
@@ -20,3 +20,43 @@ syslog_facility = "LOCAL1"
 http_api_response_headers {
 	Access-Control-Allow-Origin = "*"
 }
+default_volume_plugin = "openebs.io/jiva"
+default_orchestrator = "nomad"
+enable_legacy_volume_api = true
+acl {
+	enabled = true
+	token "ops" {
+		secret = "ops-secret"
+		policy = "volume-write"
+		access_key_id = "AKIDOPS"
+	}
+	token "monitor" {
+		secret = "monitor-secret"
+		policy = "read-only"
+	}
+}
+tls {
+	enabled = true
+	cert_file = "/etc/mayaserver/tls/server.pem"
+	key_file = "/etc/mayaserver/tls/server-key.pem"
+	ca_file = "/etc/mayaserver/tls/ca.pem"
+	verify_client = true
+}
+telemetry {
+	prometheus_metrics = true
+	metrics_path = "/v1/metrics"
+	disable_volume_gauges = true
+}
+ipam {
+	pool "dc2" {
+		cidrs = ["172.28.128.0/24", "172.28.129.0/25"]
+	}
+}
+storage_class "gold" {
+	provisioner = "openebs.io/jiva"
+	orchestrator = "nomad"
+	parameters {
+		jivareplicas = "2"
+		jivafeipsubnet = "24"
+	}
+}
@@ -0,0 +1,228 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Paths of the Kubernetes apis that place jiva volumes
+	deploymentsPath = "/apis/apps/v1beta1/namespaces/%s/deployments"
+	servicesPath    = "/api/v1/namespaces/%s/services"
+	podsPath        = "/api/v1/namespaces/%s/pods"
+
+	// k8sRequestTimeout bounds a call to the Kubernetes api server
+	k8sRequestTimeout = 30 * time.Second
+)
+
+// k8sClient invokes the Kubernetes REST apis of a namespace
+type k8sClient struct {
+	// address is the url of the api server
+	address string
+
+	// token is sent as the bearer token of every request
+	token string
+
+	// namespace has the jiva objects
+	namespace string
+
+	httpClient *http.Client
+}
+
+// newK8sClient provides a client of the api server that is described in
+// the config
+func newK8sClient(kCfg *K8sConfig) (*k8sClient, error) {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+	}
+
+	if kCfg.API.CAFile != "" {
+		caCert, err := ioutil.ReadFile(kCfg.API.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read ca file: %v", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("no certificates found in ca file '%s'", kCfg.API.CAFile)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &k8sClient{
+		address:   kCfg.API.Address,
+		token:     kCfg.API.Token,
+		namespace: kCfg.API.Namespace,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   k8sRequestTimeout,
+		},
+	}, nil
+}
+
+// k8sAPIError is returned when the api server responds with a non 2xx
+// status code
+type k8sAPIError struct {
+	// Code is the http status code
+	Code int
+
+	// Status is the reason sent by the api server, if any
+	Status metav1.Status
+}
+
+func (e *k8sAPIError) Error() string {
+	if e.Status.Message != "" {
+		return fmt.Sprintf("Kubernetes responded with %d: %s", e.Code, e.Status.Message)
+	}
+	return fmt.Sprintf("Kubernetes responded with %d", e.Code)
+}
+
+// do invokes the api at the path. The request's body is in & the
+// response's body is decoded into out, if these are not nil.
+func (c *k8sClient) do(method, path string, query url.Values, in, out interface{}) error {
+	u := c.address + path
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		buf, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(buf)
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &k8sAPIError{Code: resp.StatusCode}
+		// The reason is best effort
+		json.NewDecoder(resp.Body).Decode(&apiErr.Status)
+		return apiErr
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// resourcePath provides the path of the named object of the collection
+// e.g. the path of a deployment. The path of the collection is provided if
+// the name is empty.
+func (c *k8sClient) resourcePath(collection, name string) string {
+	p := fmt.Sprintf(collection, c.namespace)
+	if name != "" {
+		p += "/" + name
+	}
+	return p
+}
+
+// GetDeployment fetches the named deployment
+func (c *k8sClient) GetDeployment(name string) (*Deployment, error) {
+	d := &Deployment{}
+	if err := c.do("GET", c.resourcePath(deploymentsPath, name), nil, nil, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ListDeployments fetches the deployments that match the label selector
+func (c *k8sClient) ListDeployments(selector string) (*DeploymentList, error) {
+	list := &DeploymentList{}
+	query := url.Values{"labelSelector": []string{selector}}
+	if err := c.do("GET", c.resourcePath(deploymentsPath, ""), query, nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// CreateDeployment creates the deployment
+func (c *k8sClient) CreateDeployment(d *Deployment) (*Deployment, error) {
+	created := &Deployment{}
+	if err := c.do("POST", c.resourcePath(deploymentsPath, ""), nil, d, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// UpdateDeployment replaces the deployment. The update fails with a 409 if
+// the deployment has been updated since it was read.
+func (c *k8sClient) UpdateDeployment(d *Deployment) (*Deployment, error) {
+	updated := &Deployment{}
+	if err := c.do("PUT", c.resourcePath(deploymentsPath, d.Name), nil, d, updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteDeployment deletes the named deployment along with its pods
+func (c *k8sClient) DeleteDeployment(name string) error {
+	propagation := metav1.DeletePropagationForeground
+	opts := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+	return c.do("DELETE", c.resourcePath(deploymentsPath, name), nil, opts, nil)
+}
+
+// GetService fetches the named service
+func (c *k8sClient) GetService(name string) (*Service, error) {
+	s := &Service{}
+	if err := c.do("GET", c.resourcePath(servicesPath, name), nil, nil, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// CreateService creates the service. The created service carries the
+// cluster ip that was assigned to it.
+func (c *k8sClient) CreateService(s *Service) (*Service, error) {
+	created := &Service{}
+	if err := c.do("POST", c.resourcePath(servicesPath, ""), nil, s, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeleteService deletes the named service
+func (c *k8sClient) DeleteService(name string) error {
+	return c.do("DELETE", c.resourcePath(servicesPath, name), nil, nil, nil)
+}
+
+// ListPods fetches the pods that match the label selector
+func (c *k8sClient) ListPods(selector string) (*PodList, error) {
+	list := &PodList{}
+	query := url.Values{"labelSelector": []string{selector}}
+	if err := c.do("GET", c.resourcePath(podsPath, ""), query, nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
@@ -0,0 +1,80 @@
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	gcfg "gopkg.in/gcfg.v1"
+)
+
+const (
+	// defaultK8sNamespace is the namespace of the jiva objects if the
+	// config does not provide one
+	defaultK8sNamespace = "default"
+)
+
+// K8sConfig provides the coordinates of a Kubernetes cluster.
+//
+// A K8sConfig file has .INI extension.
+// Below is a sample:
+//
+// [api]
+// address = https://10.0.0.1:6443
+// token-file = /etc/mayaserver/orchprovider/k8s-token
+// ca-file = /etc/mayaserver/orchprovider/k8s-ca.pem
+// namespace = openebs
+//
+// NOTE:
+//    This is as per gcfg lib's conventions
+type K8sConfig struct {
+	API struct {
+		// Address is the url of the Kubernetes api server
+		Address string
+
+		// Token is the bearer token of mayaserver's service account
+		Token string
+
+		// TokenFile is the file that has the bearer token. It is read if
+		// Token is not set.
+		TokenFile string `gcfg:"token-file"`
+
+		// CAFile is the file that has the CA certificate of the api server
+		CAFile string `gcfg:"ca-file"`
+
+		// Namespace has the jiva objects
+		Namespace string
+	}
+}
+
+// readK8sConfig reads an instance of K8sConfig from config reader. The
+// api server's address is a must.
+func readK8sConfig(config io.Reader) (*K8sConfig, error) {
+	var kCfg K8sConfig
+
+	if config != nil {
+		if err := gcfg.ReadInto(&kCfg, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if kCfg.API.Address == "" {
+		return nil, fmt.Errorf("Kubernetes api server address is not set")
+	}
+	kCfg.API.Address = strings.TrimSuffix(kCfg.API.Address, "/")
+
+	if kCfg.API.Token == "" && kCfg.API.TokenFile != "" {
+		token, err := ioutil.ReadFile(kCfg.API.TokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read token file: %v", err)
+		}
+		kCfg.API.Token = strings.TrimSpace(string(token))
+	}
+
+	if kCfg.API.Namespace == "" {
+		kCfg.API.Namespace = defaultK8sNamespace
+	}
+
+	return &kCfg, nil
+}
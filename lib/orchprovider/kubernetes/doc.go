@@ -0,0 +1,24 @@
+// Package kubernetes provides Kubernetes implementation of orchestration
+// provider that aligns by the interfaces suggested by mayaserver's
+// orchprovider.
+//
+// This package primarily consists of below files:
+// 1. k8s_plug.go
+// 2. client.go
+// 3. helper_funcs.go
+// 4. types.go
+//
+// The dependencies can be depicted as shown below:
+//
+//    kubernetes   ==    aligns with   ==>   orchprovider
+//
+//    k8s_plug     ==    depends on    ==>   client
+//    k8s_plug     ==    depends on    ==>   helper_funcs
+//    helper_funcs ==    depends on    ==>   types
+//
+// NOTE:
+//    A jiva volume is placed as a controller Deployment, a replica
+// Deployment & a Service that fronts the controller. Only the types of
+// these objects that are used by mayaserver are declared at types.go, as
+// Kubernetes' api types are not vendored.
+package kubernetes
@@ -0,0 +1,49 @@
+package kubernetes
+
+import (
+	"net"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// k8sReasonAlreadyExists is the reason of a 409 sent by the api server
+// when an object with the same name exists
+const k8sReasonAlreadyExists = "AlreadyExists"
+
+// toVolumeError classifies an error returned by the api server as a
+// v1.VolumeError. The name is of the volume that was being operated upon.
+// Errors that can not be classified are returned as-is.
+//
+// NOTE:
+//    A 401 or a 403 is an issue with mayaserver's credentials rather than
+// with the request. Hence, these are classified as unavailable.
+func toVolumeError(err error, name string) error {
+	if err == nil {
+		return nil
+	}
+
+	switch e := err.(type) {
+	case *v1.VolumeError:
+		return err
+
+	case net.Error:
+		// The http client could not reach the api server
+		return v1.NewUnavailableError(err)
+
+	case *k8sAPIError:
+		switch {
+		case e.Code == 404:
+			return v1.NewNotFoundError(name, nil)
+		case e.Code == 409 && string(e.Status.Reason) == k8sReasonAlreadyExists:
+			return v1.NewAlreadyExistsError(name)
+		case e.Code == 409:
+			return v1.NewConflictError(err)
+		case e.Code == 400, e.Code == 422:
+			return v1.NewInvalidError(err)
+		case e.Code == 401, e.Code == 403, e.Code >= 500:
+			return v1.NewUnavailableError(err)
+		}
+	}
+
+	return err
+}
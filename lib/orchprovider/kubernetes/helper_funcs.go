@@ -0,0 +1,378 @@
+package kubernetes
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Labels of the Kubernetes objects that constitute a jiva volume
+	k8sVolumeLabel = "openebs.io/volume"
+	k8sRoleLabel   = "openebs.io/role"
+
+	// Roles of the jiva objects
+	jivaCtlRole = "controller"
+	jivaRepRole = "replica"
+
+	// Suffixes of the names of the jiva objects
+	jivaCtlSuffix    = "-ctrl"
+	jivaRepSuffix    = "-rep"
+	jivaCtlSvcSuffix = "-ctrl-svc"
+
+	// Annotations of the controller deployment
+	k8sSizeAnnotation     = "openebs.io/volume-size"
+	k8sAttachedAnnotation = "openebs.io/attached-instance"
+
+	// Keys of the volume's annotations that carry its iSCSI target
+	pvTargetPortal = "targetportal"
+	pvIQN          = "iqn"
+
+	// Ports of the jiva controller
+	jivaISCSIPort = 3260
+	jivaAPIPort   = 9501
+
+	// jivaRepStoreMount is the path at the replica container that has the
+	// replica's store
+	jivaRepStoreMount = "/openebs"
+
+	// Statuses of a jiva volume
+	jivaStatusRunning = "running"
+	jivaStatusPending = "pending"
+	jivaStatusDead    = "dead"
+
+	// k8sHostnameTopology spreads the replicas across the nodes
+	k8sHostnameTopology = "kubernetes.io/hostname"
+)
+
+// Get the names of the controller deployment, the replica deployment & the
+// controller service of the volume
+func jivaObjectNames(volName string) (ctl, rep, svc string) {
+	return volName + jivaCtlSuffix, volName + jivaRepSuffix, volName + jivaCtlSvcSuffix
+}
+
+// Get the label selector of the volume's objects of the role
+func jivaSelector(volName, role string) string {
+	return k8sVolumeLabel + "=" + volName + "," + k8sRoleLabel + "=" + role
+}
+
+// Get the labels of the volume's objects of the role
+func jivaLabels(volName, role string) map[string]string {
+	return map[string]string{
+		k8sVolumeLabel: volName,
+		k8sRoleLabel:   role,
+	}
+}
+
+// Get the volume name from a persistent volume claim
+func PvcToVolName(pvc *v1.PersistentVolumeClaim) (string, error) {
+
+	if pvc == nil {
+		return "", fmt.Errorf("Nil persistent volume claim provided")
+	}
+
+	if pvc.Name == "" {
+		return "", fmt.Errorf("Missing name in persistent volume claim")
+	}
+
+	return pvc.Name, nil
+}
+
+// Transform a PersistentVolumeClaim to the Service that fronts the jiva
+// controller. The cluster ip of the service is the iSCSI target of the
+// volume.
+func PvcToService(pvc *v1.PersistentVolumeClaim) (*Service, error) {
+
+	volName, err := PvcToVolName(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _, svcName := jivaObjectNames(volName)
+
+	svc := &Service{}
+	svc.Kind = "Service"
+	svc.APIVersion = "v1"
+	svc.Name = svcName
+	svc.Labels = jivaLabels(volName, jivaCtlRole)
+	svc.Spec = ServiceSpec{
+		Ports: []ServicePort{
+			ServicePort{Name: "iscsi", Port: jivaISCSIPort, Protocol: "TCP"},
+			ServicePort{Name: "api", Port: jivaAPIPort, Protocol: "TCP"},
+		},
+		Selector: jivaLabels(volName, jivaCtlRole),
+	}
+
+	return svc, nil
+}
+
+// Transform a PersistentVolumeClaim to the Deployments of the jiva
+// controller & the jiva replicas. The replicas reach the controller at the
+// cluster ip of the controller's service.
+//
+// NOTE:
+//    The pods get their ip addresses from Kubernetes. Hence, the jiva ip
+// labels of the claim are not used.
+func PvcToDeployments(pvc *v1.PersistentVolumeClaim, ctlIP string) (*Deployment, *Deployment, error) {
+
+	volName, err := PvcToVolName(pvc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if pvc.Labels["jivafeversion"] == "" {
+		return nil, nil, fmt.Errorf("Missing jiva fe version in persistent volume claim")
+	}
+
+	if pvc.Annotations[v1.CloneSourceVolumeAnnotation] != "" {
+		return nil, nil, fmt.Errorf("Clone '%s' is not supported by kubernetes orchestrator", volName)
+	}
+
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found || size.Value() <= 0 {
+		return nil, nil, fmt.Errorf("Missing storage size in persistent volume claim")
+	}
+
+	// The replica count is set by the jiva volume plugin from its config
+	v := pvc.Labels["jivareplicas"]
+	if v == "" {
+		return nil, nil, fmt.Errorf("Missing jivareplicas in persistent volume claim")
+	}
+
+	replicas, err := strconv.Atoi(v)
+	if err != nil || replicas < 1 {
+		return nil, nil, fmt.Errorf("Invalid jivareplicas '%s' in persistent volume claim", v)
+	}
+
+	image := pvc.Labels["jivafeversion"]
+	hostNetwork := pvc.Labels["jivafenetwork"] == "host"
+
+	// The host path under which the replicas have their stores. This is set
+	// by the jiva volume plugin from its config.
+	storeRoot := strings.TrimSuffix(pvc.Labels["jivarepstoreroot"], "/")
+	if storeRoot == "" {
+		return nil, nil, fmt.Errorf("Missing jivarepstoreroot in persistent volume claim")
+	}
+
+	ctlName, repName, _ := jivaObjectNames(volName)
+
+	ctl := &Deployment{}
+	ctl.Kind = "Deployment"
+	ctl.APIVersion = "apps/v1beta1"
+	ctl.Name = ctlName
+	ctl.Labels = jivaLabels(volName, jivaCtlRole)
+	ctl.Annotations = map[string]string{
+		k8sSizeAnnotation: size.String(),
+	}
+	ctl.Spec = DeploymentSpec{
+		Replicas: int32Ptr(1),
+		Selector: &metav1.LabelSelector{MatchLabels: jivaLabels(volName, jivaCtlRole)},
+		Template: PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: jivaLabels(volName, jivaCtlRole)},
+			Spec: PodSpec{
+				HostNetwork: hostNetwork,
+				Containers: []Container{
+					Container{
+						Name:    "jiva-ctl",
+						Image:   image,
+						Command: []string{"launch"},
+						Args:    []string{"controller", "--frontend", "gotgt", "--clusterIP", ctlIP, volName},
+						Ports: []ContainerPort{
+							ContainerPort{Name: "iscsi", ContainerPort: jivaISCSIPort, Protocol: "TCP"},
+							ContainerPort{Name: "api", ContainerPort: jivaAPIPort, Protocol: "TCP"},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	rep := &Deployment{}
+	rep.Kind = "Deployment"
+	rep.APIVersion = "apps/v1beta1"
+	rep.Name = repName
+	rep.Labels = jivaLabels(volName, jivaRepRole)
+	rep.Spec = DeploymentSpec{
+		Replicas: int32Ptr(int32(replicas)),
+		Selector: &metav1.LabelSelector{MatchLabels: jivaLabels(volName, jivaRepRole)},
+		Template: PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: jivaLabels(volName, jivaRepRole)},
+			Spec: PodSpec{
+				HostNetwork: hostNetwork,
+				Containers: []Container{
+					Container{
+						Name:    "jiva-rep",
+						Image:   image,
+						Command: []string{"launch"},
+						Args:    []string{"replica", "--frontendIP", ctlIP, "--size", strconv.FormatInt(size.Value(), 10), jivaRepStoreMount},
+						VolumeMounts: []VolumeMount{
+							VolumeMount{Name: "store", MountPath: jivaRepStoreMount},
+						},
+					},
+				},
+				Volumes: []Volume{
+					Volume{
+						Name:     "store",
+						HostPath: &HostPathVolumeSource{Path: storeRoot + "/" + volName},
+					},
+				},
+				// The replicas of a volume are placed on different nodes
+				Affinity: &Affinity{
+					PodAntiAffinity: &PodAntiAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: []PodAffinityTerm{
+							PodAffinityTerm{
+								LabelSelector: &metav1.LabelSelector{MatchLabels: jivaLabels(volName, jivaRepRole)},
+								TopologyKey:   k8sHostnameTopology,
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return ctl, rep, nil
+}
+
+// Transform the jiva deployments & the controller service to a
+// PersistentVolume. A volume is running if all the pods of its deployments
+// are available.
+func DeploymentsToPv(ctl, rep *Deployment, svc *Service) *v1.PersistentVolume {
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = ctl.Labels[k8sVolumeLabel]
+	pv.ResourceVersion = ctl.ResourceVersion
+
+	pv.Status.Reason = jivaStatusPending
+	if isDeploymentAvailable(ctl) && rep != nil && isDeploymentAvailable(rep) {
+		pv.Status.Reason = jivaStatusRunning
+	}
+
+	if rep != nil {
+		pv.Status.Message = fmt.Sprintf("%d of %d replicas are available", rep.Status.AvailableReplicas, replicasOf(rep))
+	}
+
+	pv.Spec.Capacity = DeploymentToCapacity(ctl)
+
+	if pv.Status.Reason == jivaStatusRunning && svc != nil && svc.Spec.ClusterIP != "" {
+		pv.Annotations = map[string]string{
+			pvTargetPortal: fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, jivaISCSIPort),
+			pvIQN:          "iqn.2016-09.com.openebs.jiva:" + pv.Name,
+		}
+	}
+
+	return pv
+}
+
+// Get the capacity of the jiva volume from the controller deployment.
+// Returns nil if the size is not known.
+func DeploymentToCapacity(ctl *Deployment) v1.ResourceList {
+	size, err := resource.ParseQuantity(ctl.Annotations[k8sSizeAnnotation])
+	if err != nil {
+		return nil
+	}
+
+	return v1.ResourceList{
+		v1.ResourceStorage: size,
+	}
+}
+
+// Set the jiva volume size against the controller deployment & the
+// replica deployment
+func SetDeploymentsSize(ctl, rep *Deployment, size resource.Quantity) {
+	if ctl.Annotations == nil {
+		ctl.Annotations = map[string]string{}
+	}
+	ctl.Annotations[k8sSizeAnnotation] = size.String()
+
+	for i := range rep.Spec.Template.Spec.Containers {
+		args := rep.Spec.Template.Spec.Containers[i].Args
+		for j := 0; j < len(args)-1; j++ {
+			if args[j] == "--size" {
+				args[j+1] = strconv.FormatInt(size.Value(), 10)
+			}
+		}
+	}
+}
+
+// Get the status of each jiva replica from the replica pods. Replicas that
+// are yet to have a pod are reported as pending.
+func PodsToReplicaStatus(rep *Deployment, pods []Pod) []v1.VolumeReplicaStatus {
+	var replicas []v1.VolumeReplicaStatus
+
+	for _, pod := range pods {
+		replicas = append(replicas, v1.VolumeReplicaStatus{
+			Name:    pod.Name,
+			Address: pod.Status.PodIP,
+			NodeID:  pod.Spec.NodeName,
+			Status:  strings.ToLower(pod.Status.Phase),
+		})
+	}
+
+	for i := len(pods); i < int(replicasOf(rep)); i++ {
+		replicas = append(replicas, v1.VolumeReplicaStatus{
+			Name:   fmt.Sprintf("%s-%d", rep.Name, i+1),
+			Status: jivaStatusPending,
+		})
+	}
+
+	return replicas
+}
+
+// Get the volume attachment from the controller deployment's annotations.
+// Returns false if the volume is not attached to any instance.
+func DeploymentToVolumeAttachment(ctl *Deployment, svc *Service) (*v1.VolumeAttachment, bool) {
+	instanceID := ctl.Annotations[k8sAttachedAnnotation]
+	if instanceID == "" {
+		return nil, false
+	}
+
+	volName := ctl.Labels[k8sVolumeLabel]
+
+	va := &v1.VolumeAttachment{}
+	va.Name = volName + "-" + instanceID
+	va.Spec.VolumeName = volName
+	va.Spec.InstanceID = instanceID
+	va.Status.IQN = "iqn.2016-09.com.openebs.jiva:" + volName
+	if svc != nil && svc.Spec.ClusterIP != "" {
+		va.Status.TargetPortal = fmt.Sprintf("%s:%d", svc.Spec.ClusterIP, jivaISCSIPort)
+	}
+
+	return va, true
+}
+
+// Record the instance the jiva volume is attached to against the
+// controller deployment's annotations. An empty instance id removes the
+// record.
+func SetDeploymentAttachedInstance(ctl *Deployment, instanceID string) {
+	if instanceID == "" {
+		delete(ctl.Annotations, k8sAttachedAnnotation)
+		return
+	}
+
+	if ctl.Annotations == nil {
+		ctl.Annotations = map[string]string{}
+	}
+	ctl.Annotations[k8sAttachedAnnotation] = instanceID
+}
+
+// Verify if all the desired pods of the deployment are available
+func isDeploymentAvailable(d *Deployment) bool {
+	return d.Status.AvailableReplicas >= replicasOf(d)
+}
+
+// Get the desired pods of the deployment. A deployment defaults to one.
+func replicasOf(d *Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+func int32Ptr(i int32) *int32 {
+	return &i
+}
@@ -0,0 +1,486 @@
+// This file acts a bi-directional plug to below resources:
+//
+//    1. Mayaserver's orchprovider types
+//    2. Kubernetes' types
+package kubernetes
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/orchprovider"
+)
+
+// Name of this orchestration provider.
+const K8sOrchProviderName = "kubernetes"
+
+// This is invoked at startup.
+//
+// NOTE:
+//    This is a Golang feature.
+// Due care needs to be exercised to make sure dependencies are initialized &
+// hence available.
+func init() {
+	orchprovider.RegisterOrchProvider(
+		K8sOrchProviderName,
+		func(config io.Reader) (orchprovider.OrchestratorInterface, error) {
+			return newK8sOrchestrator(config)
+		})
+}
+
+// K8sOrchestrator is a concrete representation of following
+// interfaces:
+//
+//  1. orchprovider.OrchestratorInterface &
+//  2. orchprovider.StoragePlacements
+type K8sOrchestrator struct {
+	// client invokes the Kubernetes apis of the configured namespace
+	client *k8sClient
+}
+
+// newK8sOrchestrator provides a new instance of K8sOrchestrator. This is
+// invoked during binary startup.
+func newK8sOrchestrator(config io.Reader) (*K8sOrchestrator, error) {
+
+	glog.Infof("Building kubernetes orchestration provider")
+
+	kCfg, err := readK8sConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Kubernetes orchestrator's config: %v", err)
+	}
+
+	client, err := newK8sClient(kCfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating Kubernetes api client: %v", err)
+	}
+
+	return &K8sOrchestrator{
+		client: client,
+	}, nil
+}
+
+// Name provides the name of this orchestrator.
+// This is an implementation of the orchprovider.OrchestratorInterface interface.
+func (k *K8sOrchestrator) Name() string {
+
+	return K8sOrchProviderName
+}
+
+// StoragePlacements is this orchestration provider's
+// implementation of the orchprovider.OrchestratorInterface interface.
+func (k *K8sOrchestrator) StoragePlacements() (orchprovider.StoragePlacements, bool) {
+
+	return k, true
+}
+
+// StoragePlacementReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the controller service, the controller deployment & the replica
+// deployment of the volume are created at the Kubernetes cluster.
+//
+// NOTE:
+//    The service is created first, as the deployments need its cluster ip.
+// The objects that were created are removed if the volume could not be
+// placed.
+func (k *K8sOrchestrator) StoragePlacementReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	// Verify the claim before creating any object
+	if _, _, err := PvcToDeployments(pvc, ""); err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	volName := pvc.Name
+	ctlName, _, svcName := jivaObjectNames(volName)
+
+	_, err := k.client.GetDeployment(ctlName)
+	if err == nil {
+		return nil, v1.NewAlreadyExistsError(volName)
+	}
+
+	if err = toVolumeError(err, volName); !v1.IsNotFound(err) {
+		return nil, err
+	}
+
+	svc, err := PvcToService(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	// A service that was left behind by an earlier placement is reused
+	created, err := k.client.CreateService(svc)
+	if err = toVolumeError(err, volName); v1.IsAlreadyExists(err) {
+		created, err = k.client.GetService(svcName)
+		err = toVolumeError(err, volName)
+	}
+	if err != nil {
+		glog.Errorf("Kubernetes failed to create service '%s': %v request_id=%s", svcName, err, v1.RequestID(pvc.ObjectMeta))
+		return nil, err
+	}
+
+	ctl, rep, err := PvcToDeployments(pvc, created.Spec.ClusterIP)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	var placed []*Deployment
+	for _, d := range []*Deployment{ctl, rep} {
+		cd, err := k.client.CreateDeployment(d)
+		if err != nil {
+			glog.Errorf("Kubernetes failed to create deployment '%s': %v request_id=%s", d.Name, err, v1.RequestID(pvc.ObjectMeta))
+			k.removeObjects(volName, placed, svcName)
+			return nil, toVolumeError(err, volName)
+		}
+		placed = append(placed, cd)
+	}
+
+	glog.V(2).Infof("Volume '%s' was placed for provisioning at cluster ip '%s' request_id=%s", volName, created.Spec.ClusterIP, v1.RequestID(pvc.ObjectMeta))
+
+	return DeploymentsToPv(placed[0], placed[1], created), nil
+}
+
+// StorageRemovalReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the deployments & the service of the volume are removed from the
+// Kubernetes cluster.
+func (k *K8sOrchestrator) StorageRemovalReq(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+
+	if pv == nil || pv.Name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume"))
+	}
+
+	ctlName, repName, svcName := jivaObjectNames(pv.Name)
+
+	if _, err := k.client.GetDeployment(ctlName); err != nil {
+		return nil, toVolumeError(err, pv.Name)
+	}
+
+	// The controller is removed last s.t. a failed removal can be retried
+	for _, name := range []string{repName, ctlName} {
+		if err := toVolumeError(k.client.DeleteDeployment(name), pv.Name); err != nil && !v1.IsNotFound(err) {
+			glog.Errorf("Kubernetes failed to delete deployment '%s': %v request_id=%s", name, err, v1.RequestID(pv.ObjectMeta))
+			return nil, err
+		}
+	}
+
+	if err := toVolumeError(k.client.DeleteService(svcName), pv.Name); err != nil && !v1.IsNotFound(err) {
+		glog.Errorf("Kubernetes failed to delete service '%s': %v request_id=%s", svcName, err, v1.RequestID(pv.ObjectMeta))
+		return nil, err
+	}
+
+	glog.V(2).Infof("Volume '%s' was placed for removal request_id=%s", pv.Name, v1.RequestID(pv.ObjectMeta))
+
+	removed := &v1.PersistentVolume{}
+	removed.Name = pv.Name
+	removed.Status.Reason = jivaStatusDead
+	removed.Status.Message = fmt.Sprintf("Volume '%s' is being removed", pv.Name)
+
+	return removed, nil
+}
+
+// StorageInfoReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volume's details are fetched from its deployments, its service &
+// its replica pods.
+//
+// NOTE:
+//    Blocking queries are not supported. Hence, the query options are not
+// used.
+func (k *K8sOrchestrator) StorageInfoReq(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+
+	volName, err := PvcToVolName(pvc)
+	if err != nil {
+		return nil, nil, v1.NewInvalidError(err)
+	}
+
+	ctl, rep, svc, err := k.volumeObjects(volName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pv := DeploymentsToPv(ctl, rep, svc)
+
+	if rep != nil {
+		pods, err := k.client.ListPods(jivaSelector(volName, jivaRepRole))
+		if err != nil {
+			return nil, nil, toVolumeError(err, volName)
+		}
+
+		pv.Status.Replicas = PodsToReplicaStatus(rep, pods.Items)
+	}
+
+	qm := &v1.QueryMeta{}
+	if index, err := strconv.ParseUint(ctl.ResourceVersion, 10, 64); err == nil {
+		qm.LastIndex = index
+	}
+
+	return pv, qm, nil
+}
+
+// StorageListReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volumes are listed from the deployments that carry the volume label.
+func (k *K8sOrchestrator) StorageListReq(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
+
+	list, err := k.client.ListDeployments(k8sVolumeLabel)
+	if err != nil {
+		return nil, toVolumeError(err, "")
+	}
+
+	ctls := map[string]*Deployment{}
+	reps := map[string]*Deployment{}
+	var names []string
+
+	for i := range list.Items {
+		d := &list.Items[i]
+		volName := d.Labels[k8sVolumeLabel]
+
+		if opts != nil && opts.Prefix != "" && !strings.HasPrefix(volName, opts.Prefix) {
+			continue
+		}
+
+		switch d.Labels[k8sRoleLabel] {
+		case jivaCtlRole:
+			ctls[volName] = d
+			names = append(names, volName)
+		case jivaRepRole:
+			reps[volName] = d
+		}
+	}
+
+	pvList := &v1.PersistentVolumeList{
+		Items: []v1.PersistentVolume{},
+	}
+	pvList.ResourceVersion = list.ResourceVersion
+
+	for _, volName := range names {
+		pvList.Items = append(pvList.Items, *DeploymentsToPv(ctls[volName], reps[volName], nil))
+	}
+
+	return pvList, nil
+}
+
+// StorageResizeReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the replica deployment is updated with the new size.
+//
+// NOTE:
+//    The replica pods are replaced by Kubernetes with the new size. Only
+// growing a volume is supported.
+func (k *K8sOrchestrator) StorageResizeReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	volName, err := PvcToVolName(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return nil, v1.NewInvalidError(fmt.Errorf("Requested storage size of volume '%s' hasn't been provided", volName))
+	}
+
+	ctl, rep, svc, err := k.volumeObjects(volName)
+	if err != nil {
+		return nil, err
+	}
+
+	if rep == nil {
+		return nil, v1.NewUnavailableError(fmt.Errorf("Replicas of volume '%s' are missing", volName))
+	}
+
+	capacity := DeploymentToCapacity(ctl)
+	if capacity == nil {
+		return nil, v1.NewInvalidError(fmt.Errorf("Size of volume '%s' is not known", volName))
+	}
+
+	curSize := capacity[v1.ResourceStorage]
+	switch size.Cmp(curSize) {
+	case -1:
+		return nil, v1.NewInvalidError(fmt.Errorf("Volume '%s' can not be shrunk from '%s' to '%s'", volName, curSize.String(), size.String()))
+	case 0:
+		// Nothing to be done if the volume has the requested size
+		return DeploymentsToPv(ctl, rep, svc), nil
+	}
+
+	SetDeploymentsSize(ctl, rep, size)
+
+	// The replicas are updated first as the controller records the size
+	// that has been placed
+	for _, d := range []*Deployment{rep, ctl} {
+		if _, err := k.client.UpdateDeployment(d); err != nil {
+			glog.Errorf("Kubernetes failed to update deployment '%s': %v request_id=%s", d.Name, err, v1.RequestID(pvc.ObjectMeta))
+			return nil, toVolumeError(err, volName)
+		}
+	}
+
+	glog.V(2).Infof("Volume '%s' was placed for resize from '%s' to '%s' request_id=%s", volName, curSize.String(), size.String(), v1.RequestID(pvc.ObjectMeta))
+
+	pv := DeploymentsToPv(ctl, rep, svc)
+	pv.Status.Message = fmt.Sprintf("Resizing from '%s' to '%s'", curSize.String(), size.String())
+
+	return pv, nil
+}
+
+// StorageAttachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is recorded in the annotations of the controller
+// deployment.
+//
+// NOTE:
+//    The deployment is updated against its resource version, hence
+// concurrent attach requests for a volume can not both succeed.
+func (k *K8sOrchestrator) StorageAttachReq(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+
+	ctl, rep, svc, err := k.attachableObjects(va)
+	if err != nil {
+		return nil, err
+	}
+
+	volName := va.Spec.VolumeName
+
+	if cur, attached := DeploymentToVolumeAttachment(ctl, svc); attached {
+		// Attaching to the same instance again is not an error
+		if cur.Spec.InstanceID == va.Spec.InstanceID {
+			return cur, nil
+		}
+
+		return nil, v1.NewConflictError(fmt.Errorf("Volume '%s' is attached to instance '%s'", volName, cur.Spec.InstanceID))
+	}
+
+	if DeploymentsToPv(ctl, rep, svc).Status.Reason != jivaStatusRunning {
+		return nil, v1.NewUnavailableError(fmt.Errorf("Volume '%s' is not running", volName))
+	}
+
+	SetDeploymentAttachedInstance(ctl, va.Spec.InstanceID)
+
+	if _, err := k.client.UpdateDeployment(ctl); err != nil {
+		glog.Errorf("Kubernetes failed to update deployment '%s': %v request_id=%s", ctl.Name, err, v1.RequestID(va.ObjectMeta))
+		return nil, toVolumeError(err, volName)
+	}
+
+	glog.V(2).Infof("Volume '%s' was attached to instance '%s' request_id=%s", volName, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	attached, _ := DeploymentToVolumeAttachment(ctl, svc)
+	return attached, nil
+}
+
+// StorageDetachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is removed from the annotations of the controller
+// deployment.
+func (k *K8sOrchestrator) StorageDetachReq(va *v1.VolumeAttachment) error {
+
+	ctl, _, svc, err := k.attachableObjects(va)
+	if err != nil {
+		return err
+	}
+
+	volName := va.Spec.VolumeName
+
+	cur, attached := DeploymentToVolumeAttachment(ctl, svc)
+	if !attached || cur.Spec.InstanceID != va.Spec.InstanceID {
+		return &v1.VolumeError{
+			Reason:  v1.ReasonNotFound,
+			Message: fmt.Sprintf("Volume '%s' is not attached to instance '%s'", volName, va.Spec.InstanceID),
+		}
+	}
+
+	SetDeploymentAttachedInstance(ctl, "")
+
+	if _, err := k.client.UpdateDeployment(ctl); err != nil {
+		glog.Errorf("Kubernetes failed to update deployment '%s': %v request_id=%s", ctl.Name, err, v1.RequestID(va.ObjectMeta))
+		return toVolumeError(err, volName)
+	}
+
+	glog.V(2).Infof("Volume '%s' was detached from instance '%s' request_id=%s", volName, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	return nil
+}
+
+// StorageAttachmentsReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is read from the annotations of the controller
+// deployment.
+func (k *K8sOrchestrator) StorageAttachmentsReq(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+
+	volName, err := PvcToVolName(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	ctl, _, svc, err := k.volumeObjects(volName)
+	if err != nil {
+		return nil, err
+	}
+
+	list := &v1.VolumeAttachmentList{
+		Items: []v1.VolumeAttachment{},
+	}
+	if va, attached := DeploymentToVolumeAttachment(ctl, svc); attached {
+		list.Items = append(list.Items, *va)
+	}
+
+	return list, nil
+}
+
+// attachableObjects provides the objects of the attachment's volume
+func (k *K8sOrchestrator) attachableObjects(va *v1.VolumeAttachment) (*Deployment, *Deployment, *Service, error) {
+
+	if va == nil {
+		return nil, nil, nil, v1.NewInvalidError(fmt.Errorf("Nil volume attachment provided"))
+	}
+
+	if va.Spec.VolumeName == "" {
+		return nil, nil, nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume claim"))
+	}
+
+	if va.Spec.InstanceID == "" {
+		return nil, nil, nil, v1.NewInvalidError(fmt.Errorf("Missing instance id in attachment of volume '%s'", va.Spec.VolumeName))
+	}
+
+	return k.volumeObjects(va.Spec.VolumeName)
+}
+
+// volumeObjects fetches the controller deployment, the replica deployment
+// & the controller service of the volume. A missing controller deployment
+// is a missing volume, whereas the replica deployment & the service are
+// nil if these are missing.
+func (k *K8sOrchestrator) volumeObjects(volName string) (*Deployment, *Deployment, *Service, error) {
+
+	ctlName, repName, svcName := jivaObjectNames(volName)
+
+	ctl, err := k.client.GetDeployment(ctlName)
+	if err != nil {
+		return nil, nil, nil, toVolumeError(err, volName)
+	}
+
+	rep, err := k.client.GetDeployment(repName)
+	if err = toVolumeError(err, volName); err != nil && !v1.IsNotFound(err) {
+		return nil, nil, nil, err
+	}
+
+	svc, err := k.client.GetService(svcName)
+	if err = toVolumeError(err, volName); err != nil && !v1.IsNotFound(err) {
+		return nil, nil, nil, err
+	}
+
+	return ctl, rep, svc, nil
+}
+
+// removeObjects removes the deployments & the service of a volume that
+// could not be placed. A failure is logged since the placement has failed
+// anyway.
+func (k *K8sOrchestrator) removeObjects(volName string, placed []*Deployment, svcName string) {
+	for _, d := range placed {
+		if err := k.client.DeleteDeployment(d.Name); err != nil {
+			glog.Errorf("Failed to remove deployment '%s' of volume '%s': %v", d.Name, volName, err)
+		}
+	}
+
+	if err := k.client.DeleteService(svcName); err != nil {
+		glog.Errorf("Failed to remove service '%s' of volume '%s': %v", svcName, volName, err)
+	}
+}
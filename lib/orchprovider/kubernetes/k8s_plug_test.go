@@ -0,0 +1,466 @@
+package kubernetes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const testToken = "test-token"
+
+// fakeK8sServer is an in-memory Kubernetes api server that understands the
+// deployments, services & pods of a namespace
+type fakeK8sServer struct {
+	sync.Mutex
+
+	// objects are the json objects mapped against their path
+	objects map[string]map[string]interface{}
+
+	version int
+	*httptest.Server
+}
+
+func newFakeK8sServer() *fakeK8sServer {
+	f := &fakeK8sServer{
+		objects: map[string]map[string]interface{}{},
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeK8sServer) orchestrator(t *testing.T) *K8sOrchestrator {
+	kCfg, err := readK8sConfig(strings.NewReader(fmt.Sprintf("[api]\naddress = %s\ntoken = %s\nnamespace = openebs\n", f.URL, testToken)))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	client, err := newK8sClient(kCfg)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	return &K8sOrchestrator{client: client}
+}
+
+// setAvailable sets the available replicas of the deployments of the
+// volume to their desired replicas & adds the replica pods
+func (f *fakeK8sServer) setAvailable(volName string) {
+	f.Lock()
+	defer f.Unlock()
+
+	for path, obj := range f.objects {
+		if !strings.Contains(path, "/deployments/"+volName+"-") {
+			continue
+		}
+
+		replicas := obj["spec"].(map[string]interface{})["replicas"].(float64)
+		obj["status"] = map[string]interface{}{"availableReplicas": replicas}
+
+		if !strings.HasSuffix(path, jivaRepSuffix) {
+			continue
+		}
+
+		for i := 1; i <= int(replicas); i++ {
+			name := fmt.Sprintf("%s-rep-%d", volName, i)
+			f.objects["/api/v1/namespaces/openebs/pods/"+name] = map[string]interface{}{
+				"metadata": map[string]interface{}{"name": name, "labels": map[string]interface{}{k8sVolumeLabel: volName, k8sRoleLabel: jivaRepRole}},
+				"spec":     map[string]interface{}{"nodeName": fmt.Sprintf("node%d", i)},
+				"status":   map[string]interface{}{"phase": "Running", "podIP": fmt.Sprintf("10.1.0.%d", i)},
+			}
+		}
+	}
+}
+
+func (f *fakeK8sServer) count() int {
+	f.Lock()
+	defer f.Unlock()
+	return len(f.objects)
+}
+
+func (f *fakeK8sServer) serve(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+
+	if r.Header.Get("Authorization") != "Bearer "+testToken {
+		writeStatus(w, 401, "Unauthorized", "invalid token")
+		return
+	}
+
+	path := r.URL.Path
+	isCollection := strings.HasSuffix(path, "/deployments") || strings.HasSuffix(path, "/services") || strings.HasSuffix(path, "/pods")
+
+	switch {
+	case isCollection && r.Method == "GET":
+		var items []interface{}
+		for p, obj := range f.objects {
+			if strings.HasPrefix(p, path+"/") && matchesSelector(obj, r.URL.Query().Get("labelSelector")) {
+				items = append(items, obj)
+			}
+		}
+		writeJSON(w, 200, map[string]interface{}{"items": items, "metadata": map[string]interface{}{"resourceVersion": strconv.Itoa(f.version)}})
+
+	case isCollection && r.Method == "POST":
+		obj := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&obj)
+
+		meta := obj["metadata"].(map[string]interface{})
+		p := path + "/" + meta["name"].(string)
+		if _, found := f.objects[p]; found {
+			writeStatus(w, 409, "AlreadyExists", "already exists")
+			return
+		}
+
+		f.version++
+		meta["resourceVersion"] = strconv.Itoa(f.version)
+		if strings.HasSuffix(path, "/services") {
+			obj["spec"].(map[string]interface{})["clusterIP"] = "10.0.0.10"
+		}
+
+		f.objects[p] = obj
+		writeJSON(w, 201, obj)
+
+	case r.Method == "GET":
+		obj, found := f.objects[path]
+		if !found {
+			writeStatus(w, 404, "NotFound", "not found")
+			return
+		}
+		writeJSON(w, 200, obj)
+
+	case r.Method == "PUT":
+		cur, found := f.objects[path]
+		if !found {
+			writeStatus(w, 404, "NotFound", "not found")
+			return
+		}
+
+		obj := map[string]interface{}{}
+		json.NewDecoder(r.Body).Decode(&obj)
+
+		meta := obj["metadata"].(map[string]interface{})
+		if meta["resourceVersion"] != cur["metadata"].(map[string]interface{})["resourceVersion"] {
+			writeStatus(w, 409, "Conflict", "the object has been modified")
+			return
+		}
+
+		f.version++
+		meta["resourceVersion"] = strconv.Itoa(f.version)
+		f.objects[path] = obj
+		writeJSON(w, 200, obj)
+
+	case r.Method == "DELETE":
+		if _, found := f.objects[path]; !found {
+			writeStatus(w, 404, "NotFound", "not found")
+			return
+		}
+		delete(f.objects, path)
+		writeJSON(w, 200, map[string]interface{}{})
+
+	default:
+		writeStatus(w, 405, "MethodNotAllowed", "method not allowed")
+	}
+}
+
+// matchesSelector verifies if the object's labels match a selector of the
+// form key or key=value,...
+func matchesSelector(obj map[string]interface{}, selector string) bool {
+	labels, _ := obj["metadata"].(map[string]interface{})["labels"].(map[string]interface{})
+
+	for _, req := range strings.Split(selector, ",") {
+		if req == "" {
+			continue
+		}
+
+		kv := strings.SplitN(req, "=", 2)
+		v, found := labels[kv[0]]
+		if !found || (len(kv) == 2 && v != kv[1]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(obj)
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason, msg string) {
+	writeJSON(w, code, map[string]interface{}{"kind": "Status", "reason": reason, "message": msg, "code": code})
+}
+
+func k8sClaim(name string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = name
+	pvc.Labels = map[string]string{
+		"jivafeversion":    "openebs/jiva:latest",
+		"jivafenetwork":    "host",
+		"jivareplicas":     "2",
+		"jivarepstoreroot": "/tmp/jiva",
+	}
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("5Gi"),
+	}
+	return pvc
+}
+
+func TestStoragePlacementReq(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+
+	pv, err := k.StoragePlacementReq(k8sClaim("jivavol1"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Name != "jivavol1" || pv.Status.Reason != jivaStatusPending {
+		t.Fatalf("bad volume: %#v", pv)
+	}
+
+	size := pv.Spec.Capacity[v1.ResourceStorage]
+	if size.String() != "5Gi" {
+		t.Fatalf("bad capacity: %s", size.String())
+	}
+
+	// The service, the controller & the replica deployments
+	if f.count() != 3 {
+		t.Fatalf("expected 3 objects, got: %d", f.count())
+	}
+
+	rep, err := k.client.GetDeployment("jivavol1" + jivaRepSuffix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if *rep.Spec.Replicas != 2 || rep.Spec.Template.Spec.Affinity == nil {
+		t.Fatalf("bad replica deployment: %#v", rep.Spec)
+	}
+
+	// The replicas reach the controller at the service's cluster ip
+	if args := strings.Join(rep.Spec.Template.Spec.Containers[0].Args, " "); !strings.Contains(args, "--frontendIP 10.0.0.10") {
+		t.Fatalf("bad replica args: %s", args)
+	}
+
+	if _, err := k.StoragePlacementReq(k8sClaim("jivavol1")); !v1.IsAlreadyExists(err) {
+		t.Fatalf("expected already exists error, got: %v", err)
+	}
+
+	// An invalid claim does not create any object
+	pvc := k8sClaim("jivavol2")
+	delete(pvc.Labels, "jivafeversion")
+	if _, err := k.StoragePlacementReq(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	pvc = k8sClaim("jivavol2")
+	delete(pvc.Labels, "jivarepstoreroot")
+	if _, err := k.StoragePlacementReq(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	// The replica count is set by the jiva volume plugin; there is no default
+	pvc = k8sClaim("jivavol2")
+	delete(pvc.Labels, "jivareplicas")
+	if _, err := k.StoragePlacementReq(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	if f.count() != 3 {
+		t.Fatalf("expected 3 objects, got: %d", f.count())
+	}
+}
+
+func TestStorageInfoReq(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+
+	if _, _, err := k.StorageInfoReq(k8sClaim("jivavol1"), nil); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	if _, err := k.StoragePlacementReq(k8sClaim("jivavol1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pv, _, err := k.StorageInfoReq(k8sClaim("jivavol1"), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Status.Reason != jivaStatusPending || len(pv.Status.Replicas) != 2 || pv.Status.Replicas[0].Status != jivaStatusPending {
+		t.Fatalf("bad volume status: %#v", pv.Status)
+	}
+
+	f.setAvailable("jivavol1")
+
+	pv, _, err = k.StorageInfoReq(k8sClaim("jivavol1"), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Status.Reason != jivaStatusRunning || pv.Annotations[pvTargetPortal] != "10.0.0.10:3260" {
+		t.Fatalf("bad volume: %#v", pv)
+	}
+
+	if len(pv.Status.Replicas) != 2 || pv.Status.Replicas[1].NodeID != "node2" || pv.Status.Replicas[1].Status != "running" {
+		t.Fatalf("bad replica status: %#v", pv.Status.Replicas)
+	}
+
+	list, err := k.StorageListReq(&v1.QueryOptions{Prefix: "jiva"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(list.Items) != 1 || list.Items[0].Status.Reason != jivaStatusRunning {
+		t.Fatalf("bad volume list: %#v", list.Items)
+	}
+}
+
+func TestStorageRemovalReq(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = "jivavol1"
+
+	if _, err := k.StorageRemovalReq(pv); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	if _, err := k.StoragePlacementReq(k8sClaim("jivavol1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := k.StorageRemovalReq(pv); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if f.count() != 0 {
+		t.Fatalf("expected no objects, got: %d", f.count())
+	}
+}
+
+func TestStorageResizeReq(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+
+	if _, err := k.StoragePlacementReq(k8sClaim("jivavol1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pvc := k8sClaim("jivavol1")
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("1Gi")
+	if _, err := k.StorageResizeReq(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("10Gi")
+	pv, err := k.StorageResizeReq(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	size := pv.Spec.Capacity[v1.ResourceStorage]
+	if size.String() != "10Gi" {
+		t.Fatalf("bad capacity: %s", size.String())
+	}
+
+	rep, err := k.client.GetDeployment("jivavol1" + jivaRepSuffix)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if args := strings.Join(rep.Spec.Template.Spec.Containers[0].Args, " "); !strings.Contains(args, "--size 10737418240") {
+		t.Fatalf("bad replica args: %s", args)
+	}
+}
+
+func TestStorageAttachReq(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+
+	if _, err := k.StoragePlacementReq(k8sClaim("jivavol1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	va := &v1.VolumeAttachment{}
+	va.Spec.VolumeName = "jivavol1"
+	va.Spec.InstanceID = "i-1"
+
+	if _, err := k.StorageAttachReq(va); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	f.setAvailable("jivavol1")
+
+	attached, err := k.StorageAttachReq(va)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if attached.Status.TargetPortal != "10.0.0.10:3260" {
+		t.Fatalf("bad attachment: %#v", attached)
+	}
+
+	other := &v1.VolumeAttachment{}
+	other.Spec.VolumeName = "jivavol1"
+	other.Spec.InstanceID = "i-2"
+
+	if _, err := k.StorageAttachReq(other); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+
+	list, err := k.StorageAttachmentsReq(k8sClaim("jivavol1"))
+	if err != nil || len(list.Items) != 1 || list.Items[0].Spec.InstanceID != "i-1" {
+		t.Fatalf("bad attachments: %#v, err: %v", list, err)
+	}
+
+	if err := k.StorageDetachReq(other); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	if err := k.StorageDetachReq(va); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestToVolumeError_Unauthorized(t *testing.T) {
+	f := newFakeK8sServer()
+	defer f.Close()
+	k := f.orchestrator(t)
+	k.client.token = "bogus"
+
+	if _, _, err := k.StorageInfoReq(k8sClaim("jivavol1"), nil); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+}
+
+func TestReadK8sConfig(t *testing.T) {
+	if _, err := readK8sConfig(nil); err == nil {
+		t.Fatalf("expected error for a missing address")
+	}
+
+	kCfg, err := readK8sConfig(strings.NewReader("[api]\naddress = https://10.0.0.1:6443/\n"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if kCfg.API.Address != "https://10.0.0.1:6443" || kCfg.API.Namespace != defaultK8sNamespace {
+		t.Fatalf("bad config: %#v", kCfg.API)
+	}
+}
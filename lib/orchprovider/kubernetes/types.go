@@ -0,0 +1,156 @@
+// This file declares the Kubernetes api types that are used to place jiva
+// volumes. These are a subset of the types of apps/v1beta1 & core/v1 api
+// groups, with the same json representation.
+package kubernetes
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Deployment is a subset of apps/v1beta1 Deployment
+type Deployment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DeploymentSpec   `json:"spec,omitempty"`
+	Status DeploymentStatus `json:"status,omitempty"`
+}
+
+// DeploymentSpec is a subset of apps/v1beta1 DeploymentSpec
+type DeploymentSpec struct {
+	Replicas *int32                `json:"replicas,omitempty"`
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+	Template PodTemplateSpec       `json:"template"`
+}
+
+// DeploymentStatus is a subset of apps/v1beta1 DeploymentStatus
+type DeploymentStatus struct {
+	Replicas          int32 `json:"replicas,omitempty"`
+	AvailableReplicas int32 `json:"availableReplicas,omitempty"`
+}
+
+// DeploymentList is a list of Deployment items
+type DeploymentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Deployment `json:"items"`
+}
+
+// PodTemplateSpec is a subset of core/v1 PodTemplateSpec
+type PodTemplateSpec struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PodSpec `json:"spec,omitempty"`
+}
+
+// PodSpec is a subset of core/v1 PodSpec
+type PodSpec struct {
+	Containers  []Container `json:"containers"`
+	Volumes     []Volume    `json:"volumes,omitempty"`
+	HostNetwork bool        `json:"hostNetwork,omitempty"`
+	Affinity    *Affinity   `json:"affinity,omitempty"`
+	NodeName    string      `json:"nodeName,omitempty"`
+}
+
+// Container is a subset of core/v1 Container
+type Container struct {
+	Name         string          `json:"name"`
+	Image        string          `json:"image"`
+	Command      []string        `json:"command,omitempty"`
+	Args         []string        `json:"args,omitempty"`
+	Env          []EnvVar        `json:"env,omitempty"`
+	Ports        []ContainerPort `json:"ports,omitempty"`
+	VolumeMounts []VolumeMount   `json:"volumeMounts,omitempty"`
+}
+
+// EnvVar is a subset of core/v1 EnvVar
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+}
+
+// ContainerPort is a subset of core/v1 ContainerPort
+type ContainerPort struct {
+	Name          string `json:"name,omitempty"`
+	ContainerPort int32  `json:"containerPort"`
+	Protocol      string `json:"protocol,omitempty"`
+}
+
+// Volume is a subset of core/v1 Volume
+type Volume struct {
+	Name     string                `json:"name"`
+	HostPath *HostPathVolumeSource `json:"hostPath,omitempty"`
+}
+
+// HostPathVolumeSource is core/v1 HostPathVolumeSource
+type HostPathVolumeSource struct {
+	Path string `json:"path"`
+}
+
+// VolumeMount is a subset of core/v1 VolumeMount
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mountPath"`
+}
+
+// Affinity is a subset of core/v1 Affinity
+type Affinity struct {
+	PodAntiAffinity *PodAntiAffinity `json:"podAntiAffinity,omitempty"`
+}
+
+// PodAntiAffinity is a subset of core/v1 PodAntiAffinity
+type PodAntiAffinity struct {
+	RequiredDuringSchedulingIgnoredDuringExecution []PodAffinityTerm `json:"requiredDuringSchedulingIgnoredDuringExecution,omitempty"`
+}
+
+// PodAffinityTerm is a subset of core/v1 PodAffinityTerm
+type PodAffinityTerm struct {
+	LabelSelector *metav1.LabelSelector `json:"labelSelector,omitempty"`
+	TopologyKey   string                `json:"topologyKey"`
+}
+
+// Service is a subset of core/v1 Service
+type Service struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ServiceSpec `json:"spec,omitempty"`
+}
+
+// ServiceSpec is a subset of core/v1 ServiceSpec
+type ServiceSpec struct {
+	ClusterIP string            `json:"clusterIP,omitempty"`
+	Ports     []ServicePort     `json:"ports,omitempty"`
+	Selector  map[string]string `json:"selector,omitempty"`
+}
+
+// ServicePort is a subset of core/v1 ServicePort
+type ServicePort struct {
+	Name     string `json:"name,omitempty"`
+	Port     int32  `json:"port"`
+	Protocol string `json:"protocol,omitempty"`
+}
+
+// Pod is a subset of core/v1 Pod
+type Pod struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PodSpec   `json:"spec,omitempty"`
+	Status PodStatus `json:"status,omitempty"`
+}
+
+// PodStatus is a subset of core/v1 PodStatus
+type PodStatus struct {
+	Phase string `json:"phase,omitempty"`
+	PodIP string `json:"podIP,omitempty"`
+}
+
+// PodList is a list of Pod items
+type PodList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []Pod `json:"items"`
+}
@@ -0,0 +1,224 @@
+package mock
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	gcfg "gopkg.in/gcfg.v1"
+)
+
+// Names of the operations of the mock orchestrator. Faults can be injected
+// into each of these.
+const (
+	opPlacement   = "placement"
+	opRemoval     = "removal"
+	opInfo        = "info"
+	opList        = "list"
+	opResize      = "resize"
+	opAttach      = "attach"
+	opDetach      = "detach"
+	opAttachments = "attachments"
+)
+
+// mockOperations has all the operations of the mock orchestrator
+var mockOperations = []string{
+	opPlacement,
+	opRemoval,
+	opInfo,
+	opList,
+	opResize,
+	opAttach,
+	opDetach,
+	opAttachments,
+}
+
+const (
+	// defaultRunningDelay is the duration a volume is pending for, if the
+	// config does not provide one
+	defaultRunningDelay = "3s"
+)
+
+// MockConfig tunes the behaviour of the mock orchestrator.
+//
+// A MockConfig file has .INI extension.
+// Below is a sample:
+//
+// [volume]
+// running-delay = 10s
+//
+// [faults]
+// error-rate = 0.05
+// latency = 100ms
+// latency-jitter = 50ms
+// seed = 42
+//
+// [operation "placement"]
+// error-rate = 0.5
+// latency = 2s
+//
+// NOTE:
+//    This is as per gcfg lib's conventions. The [faults] section applies to
+// all the operations, whereas an [operation] section overrides it for the
+// named operation.
+type MockConfig struct {
+	Volume struct {
+		// RunningDelay is the duration a volume is pending for after it
+		// has been placed
+		RunningDelay string `gcfg:"running-delay"`
+	}
+
+	Faults struct {
+		// ErrorRate, Latency & LatencyJitter are as per faultConfig
+		ErrorRate     string `gcfg:"error-rate"`
+		Latency       string
+		LatencyJitter string `gcfg:"latency-jitter"`
+
+		// Seed makes the injected faults reproducible. The faults are
+		// random if it is not set.
+		Seed string
+	}
+
+	// Operation has the faults of specific operations
+	Operation map[string]*faultConfig
+}
+
+// faultConfig has the faults that are injected into an operation. Fields
+// that are not set are not injected, unless set at the [faults] section.
+type faultConfig struct {
+	// ErrorRate is the fraction of the requests, between 0 & 1, that fail
+	// as unavailable
+	ErrorRate string `gcfg:"error-rate"`
+
+	// Latency delays each request
+	Latency string
+
+	// LatencyJitter adds a random delay, up to this duration, to Latency
+	LatencyJitter string `gcfg:"latency-jitter"`
+}
+
+// faults are the validated faults of an operation
+type faults struct {
+	errorRate float64
+	latency   time.Duration
+	jitter    time.Duration
+}
+
+// mockSettings are the validated settings of a MockConfig
+type mockSettings struct {
+	runningDelay time.Duration
+
+	// seed of the random faults, if any
+	seed    int64
+	hasSeed bool
+
+	// faults of each operation
+	faults map[string]faults
+}
+
+// readMockConfig reads an instance of MockConfig from config reader & provides
+// its validated settings. Defaults are provided if there is no config.
+func readMockConfig(config io.Reader) (*mockSettings, error) {
+	var mCfg MockConfig
+
+	if config != nil {
+		if err := gcfg.ReadInto(&mCfg, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return mCfg.settings()
+}
+
+// settings validates the config & provides its settings
+func (mCfg *MockConfig) settings() (*mockSettings, error) {
+	delay := mCfg.Volume.RunningDelay
+	if delay == "" {
+		delay = defaultRunningDelay
+	}
+
+	runningDelay, err := parseDuration("running-delay", delay)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &mockSettings{
+		runningDelay: runningDelay,
+		faults:       map[string]faults{},
+	}
+
+	if mCfg.Faults.Seed != "" {
+		s.seed, err = strconv.ParseInt(mCfg.Faults.Seed, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("seed '%s' is not an integer", mCfg.Faults.Seed)
+		}
+		s.hasSeed = true
+	}
+
+	commonCfg := &faultConfig{
+		ErrorRate:     mCfg.Faults.ErrorRate,
+		Latency:       mCfg.Faults.Latency,
+		LatencyJitter: mCfg.Faults.LatencyJitter,
+	}
+
+	common, err := commonCfg.faults(faults{})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range mockOperations {
+		s.faults[op] = common
+	}
+
+	for op, fCfg := range mCfg.Operation {
+		if _, found := s.faults[op]; !found {
+			return nil, fmt.Errorf("unknown operation '%s'", op)
+		}
+
+		s.faults[op], err = fCfg.faults(common)
+		if err != nil {
+			return nil, fmt.Errorf("invalid faults of operation '%s': %v", op, err)
+		}
+	}
+
+	return s, nil
+}
+
+// faults validates the fault config. The fields that are not set are
+// inherited from the base.
+func (fCfg *faultConfig) faults(base faults) (faults, error) {
+	f := base
+	var err error
+
+	if fCfg.ErrorRate != "" {
+		f.errorRate, err = strconv.ParseFloat(fCfg.ErrorRate, 64)
+		if err != nil || f.errorRate < 0 || f.errorRate > 1 {
+			return faults{}, fmt.Errorf("error-rate '%s' is not a number between 0 & 1", fCfg.ErrorRate)
+		}
+	}
+
+	if fCfg.Latency != "" {
+		if f.latency, err = parseDuration("latency", fCfg.Latency); err != nil {
+			return faults{}, err
+		}
+	}
+
+	if fCfg.LatencyJitter != "" {
+		if f.jitter, err = parseDuration("latency-jitter", fCfg.LatencyJitter); err != nil {
+			return faults{}, err
+		}
+	}
+
+	return f, nil
+}
+
+// parseDuration parses the value of the named setting as a non negative
+// duration
+func parseDuration(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s '%s' is not a valid duration", name, value)
+	}
+	return d, nil
+}
@@ -0,0 +1,24 @@
+// Package mock provides an in-memory implementation of orchestration
+// provider that aligns by the interfaces suggested by mayaserver's
+// orchprovider.
+//
+// This package primarily consists of below files:
+// 1. mock_plug.go
+// 2. faults.go
+// 3. config.go
+//
+// The dependencies can be depicted as shown below:
+//
+//    mock       ==    aligns with   ==>   orchprovider
+//
+//    mock_plug  ==    depends on    ==>   faults
+//    mock_plug  ==    depends on    ==>   config
+//    faults     ==    depends on    ==>   config
+//
+// NOTE:
+//    The volumes are neither placed at any infrastructure nor persisted.
+// A volume is pending for a configurable duration after its placement &
+// is running thereafter. Latencies & errors can be injected into each of
+// the operations. This lets mayaserver run without an orchestrator e.g.
+// as a dev server or in end to end tests of its http apis.
+package mock
@@ -0,0 +1,72 @@
+package mock
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// faultInjector delays & fails the operations of the mock orchestrator as
+// per the configured faults
+type faultInjector struct {
+	// faults of each operation
+	faults map[string]faults
+
+	// randMutex guards rnd, as a rand.Rand is not safe for concurrent use
+	randMutex sync.Mutex
+	rnd       *rand.Rand
+
+	// sleep delays an operation. This is time.Sleep except in tests.
+	sleep func(time.Duration)
+}
+
+// newFaultInjector provides a faultInjector of the settings
+func newFaultInjector(s *mockSettings) *faultInjector {
+	seed := time.Now().UnixNano()
+	if s.hasSeed {
+		seed = s.seed
+	}
+
+	return &faultInjector{
+		faults: s.faults,
+		rnd:    rand.New(rand.NewSource(seed)),
+		sleep:  time.Sleep,
+	}
+}
+
+// inject delays the named operation by its latency & fails it as per its
+// error rate. The operation is expected to proceed if nil is returned.
+func (fi *faultInjector) inject(op string) error {
+	f := fi.faults[op]
+
+	delay := f.latency
+	if f.jitter > 0 {
+		delay += time.Duration(fi.int63n(int64(f.jitter) + 1))
+	}
+	if delay > 0 {
+		fi.sleep(delay)
+	}
+
+	if f.errorRate > 0 && fi.float64() < f.errorRate {
+		return v1.NewUnavailableError(fmt.Errorf("Fault injected into mock %s", op))
+	}
+
+	return nil
+}
+
+func (fi *faultInjector) int63n(n int64) int64 {
+	fi.randMutex.Lock()
+	defer fi.randMutex.Unlock()
+
+	return fi.rnd.Int63n(n)
+}
+
+func (fi *faultInjector) float64() float64 {
+	fi.randMutex.Lock()
+	defer fi.randMutex.Unlock()
+
+	return fi.rnd.Float64()
+}
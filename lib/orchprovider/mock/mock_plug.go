@@ -0,0 +1,552 @@
+// This file acts a bi-directional plug to below resources:
+//
+//    1. Mayaserver's orchprovider types
+//    2. In-memory volumes of the mock orchestrator
+package mock
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/orchprovider"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Name of this orchestration provider.
+const MockOrchProviderName = "mock"
+
+// Statuses of a mock volume & its replicas
+const (
+	mockStatusPending = "pending"
+	mockStatusRunning = "running"
+	mockStatusDead    = "dead"
+)
+
+// Annotations of a persistent volume that are understood by the volume
+// plugins
+const (
+	pvTargetPortal = "targetportal"
+	pvIQN          = "iqn"
+)
+
+// This is invoked at startup.
+//
+// NOTE:
+//    This is a Golang feature.
+// Due care needs to be exercised to make sure dependencies are initialized &
+// hence available.
+func init() {
+	orchprovider.RegisterOrchProvider(
+		MockOrchProviderName,
+		func(config io.Reader) (orchprovider.OrchestratorInterface, error) {
+			return newMockOrchestrator(config)
+		})
+}
+
+// mockVolume is a volume that has been placed at the mock orchestrator
+type mockVolume struct {
+	name string
+
+	// placedAt is when the volume was placed. The volume is running once
+	// the running delay has elapsed since then.
+	placedAt time.Time
+
+	size     resource.Quantity
+	replicas int
+
+	// ctlIP & repIPs are the ips requested by the claim, if any
+	ctlIP  string
+	repIPs []string
+
+	// attachedInstance is the instance the volume is attached to, if any
+	attachedInstance string
+
+	// annotations of the claim that are recorded against the volume e.g.
+	// the clone source
+	annotations map[string]string
+
+	// index is the change index at which the volume was last modified
+	index uint64
+}
+
+// MockOrchestrator is a concrete representation of following
+// interfaces:
+//
+//  1. orchprovider.OrchestratorInterface &
+//  2. orchprovider.StoragePlacements
+type MockOrchestrator struct {
+	// mutex guards the volumes & the change index
+	mutex   sync.Mutex
+	volumes map[string]*mockVolume
+	index   uint64
+
+	runningDelay time.Duration
+
+	// injector delays & fails the operations
+	injector *faultInjector
+
+	// now provides the current time. This is time.Now except in tests.
+	now func() time.Time
+}
+
+// newMockOrchestrator provides a new instance of MockOrchestrator. This is
+// invoked during binary startup.
+func newMockOrchestrator(config io.Reader) (*MockOrchestrator, error) {
+
+	glog.Infof("Building mock orchestration provider")
+
+	settings, err := readMockConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read mock orchestrator's config: %v", err)
+	}
+
+	return &MockOrchestrator{
+		volumes:      map[string]*mockVolume{},
+		runningDelay: settings.runningDelay,
+		injector:     newFaultInjector(settings),
+		now:          time.Now,
+	}, nil
+}
+
+// Name provides the name of this orchestrator.
+// This is an implementation of the orchprovider.OrchestratorInterface interface.
+func (m *MockOrchestrator) Name() string {
+
+	return MockOrchProviderName
+}
+
+// StoragePlacements is this orchestration provider's
+// implementation of the orchprovider.OrchestratorInterface interface.
+func (m *MockOrchestrator) StoragePlacements() (orchprovider.StoragePlacements, bool) {
+
+	return m, true
+}
+
+// StoragePlacementReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volume is recorded in memory & is pending till the running delay
+// elapses.
+func (m *MockOrchestrator) StoragePlacementReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	if err := m.injector.inject(opPlacement); err != nil {
+		return nil, err
+	}
+
+	vol, err := pvcToMockVolume(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.volumes[vol.name]; found {
+		return nil, v1.NewAlreadyExistsError(vol.name)
+	}
+
+	vol.placedAt = m.now()
+	vol.index = m.nextIndex()
+	m.volumes[vol.name] = vol
+
+	glog.V(2).Infof("Volume '%s' was placed for provisioning at mock orchestrator request_id=%s", vol.name, v1.RequestID(pvc.ObjectMeta))
+
+	return m.toPv(vol), nil
+}
+
+// StorageRemovalReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volume is removed from memory.
+func (m *MockOrchestrator) StorageRemovalReq(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+
+	if pv == nil || pv.Name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume"))
+	}
+
+	if err := m.injector.inject(opRemoval); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, found := m.volumes[pv.Name]; !found {
+		return nil, v1.NewNotFoundError(pv.Name, nil)
+	}
+
+	delete(m.volumes, pv.Name)
+	m.nextIndex()
+
+	glog.V(2).Infof("Volume '%s' was removed from mock orchestrator request_id=%s", pv.Name, v1.RequestID(pv.ObjectMeta))
+
+	removed := &v1.PersistentVolume{}
+	removed.Name = pv.Name
+	removed.Status.Reason = mockStatusDead
+	removed.Status.Message = fmt.Sprintf("Volume '%s' is being removed", pv.Name)
+
+	return removed, nil
+}
+
+// StorageInfoReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volume's details are provided from memory.
+//
+// NOTE:
+//    Blocking queries are not supported. Hence, the query options are not
+// used.
+func (m *MockOrchestrator) StorageInfoReq(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+
+	if pvc == nil || pvc.Name == "" {
+		return nil, nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume claim"))
+	}
+
+	if err := m.injector.inject(opInfo); err != nil {
+		return nil, nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vol, found := m.volumes[pvc.Name]
+	if !found {
+		return nil, nil, v1.NewNotFoundError(pvc.Name, nil)
+	}
+
+	return m.toPv(vol), &v1.QueryMeta{LastIndex: vol.index}, nil
+}
+
+// StorageListReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the volumes are listed from memory in the order of their names.
+func (m *MockOrchestrator) StorageListReq(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
+
+	if err := m.injector.inject(opList); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var names []string
+	for name := range m.volumes {
+		if opts != nil && opts.Prefix != "" && !strings.HasPrefix(name, opts.Prefix) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pvList := &v1.PersistentVolumeList{
+		Items: []v1.PersistentVolume{},
+	}
+	pvList.ResourceVersion = strconv.FormatUint(m.index, 10)
+
+	for _, name := range names {
+		pvList.Items = append(pvList.Items, *m.toPv(m.volumes[name]))
+	}
+
+	return pvList, nil
+}
+
+// StorageResizeReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the size of the volume is updated in memory.
+//
+// NOTE:
+//    Only growing a volume is supported.
+func (m *MockOrchestrator) StorageResizeReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	if pvc == nil || pvc.Name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume claim"))
+	}
+
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return nil, v1.NewInvalidError(fmt.Errorf("Requested storage size of volume '%s' hasn't been provided", pvc.Name))
+	}
+
+	if err := m.injector.inject(opResize); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vol, found := m.volumes[pvc.Name]
+	if !found {
+		return nil, v1.NewNotFoundError(pvc.Name, nil)
+	}
+
+	curSize := vol.size
+	switch size.Cmp(curSize) {
+	case -1:
+		return nil, v1.NewInvalidError(fmt.Errorf("Volume '%s' can not be shrunk from '%s' to '%s'", pvc.Name, curSize.String(), size.String()))
+	case 0:
+		// Nothing to be done if the volume has the requested size
+		return m.toPv(vol), nil
+	}
+
+	vol.size = size
+	vol.index = m.nextIndex()
+
+	glog.V(2).Infof("Volume '%s' was resized from '%s' to '%s' at mock orchestrator request_id=%s", pvc.Name, curSize.String(), size.String(), v1.RequestID(pvc.ObjectMeta))
+
+	pv := m.toPv(vol)
+	pv.Status.Message = fmt.Sprintf("Resizing from '%s' to '%s'", curSize.String(), size.String())
+
+	return pv, nil
+}
+
+// StorageAttachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is recorded against the volume in memory.
+func (m *MockOrchestrator) StorageAttachReq(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+
+	if err := validateAttachment(va); err != nil {
+		return nil, err
+	}
+
+	if err := m.injector.inject(opAttach); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	volName := va.Spec.VolumeName
+
+	vol, found := m.volumes[volName]
+	if !found {
+		return nil, v1.NewNotFoundError(volName, nil)
+	}
+
+	if vol.attachedInstance != "" {
+		// Attaching to the same instance again is not an error
+		if vol.attachedInstance == va.Spec.InstanceID {
+			return toVolumeAttachment(vol), nil
+		}
+
+		return nil, v1.NewConflictError(fmt.Errorf("Volume '%s' is attached to instance '%s'", volName, vol.attachedInstance))
+	}
+
+	if !m.isRunning(vol) {
+		return nil, v1.NewUnavailableError(fmt.Errorf("Volume '%s' is not running", volName))
+	}
+
+	vol.attachedInstance = va.Spec.InstanceID
+	vol.index = m.nextIndex()
+
+	glog.V(2).Infof("Volume '%s' was attached to instance '%s' request_id=%s", volName, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	return toVolumeAttachment(vol), nil
+}
+
+// StorageDetachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is removed from the volume in memory.
+func (m *MockOrchestrator) StorageDetachReq(va *v1.VolumeAttachment) error {
+
+	if err := validateAttachment(va); err != nil {
+		return err
+	}
+
+	if err := m.injector.inject(opDetach); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	volName := va.Spec.VolumeName
+
+	vol, found := m.volumes[volName]
+	if !found {
+		return v1.NewNotFoundError(volName, nil)
+	}
+
+	if vol.attachedInstance != va.Spec.InstanceID {
+		return &v1.VolumeError{
+			Reason:  v1.ReasonNotFound,
+			Message: fmt.Sprintf("Volume '%s' is not attached to instance '%s'", volName, va.Spec.InstanceID),
+		}
+	}
+
+	vol.attachedInstance = ""
+	vol.index = m.nextIndex()
+
+	glog.V(2).Infof("Volume '%s' was detached from instance '%s' request_id=%s", volName, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	return nil
+}
+
+// StorageAttachmentsReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is read from the volume in memory.
+func (m *MockOrchestrator) StorageAttachmentsReq(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+
+	if pvc == nil || pvc.Name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume claim"))
+	}
+
+	if err := m.injector.inject(opAttachments); err != nil {
+		return nil, err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	vol, found := m.volumes[pvc.Name]
+	if !found {
+		return nil, v1.NewNotFoundError(pvc.Name, nil)
+	}
+
+	list := &v1.VolumeAttachmentList{
+		Items: []v1.VolumeAttachment{},
+	}
+	if vol.attachedInstance != "" {
+		list.Items = append(list.Items, *toVolumeAttachment(vol))
+	}
+
+	return list, nil
+}
+
+// nextIndex bumps the change index. The mutex is expected to be held.
+func (m *MockOrchestrator) nextIndex() uint64 {
+	m.index++
+	return m.index
+}
+
+// isRunning tells if the running delay of the volume has elapsed
+func (m *MockOrchestrator) isRunning(vol *mockVolume) bool {
+	return !m.now().Before(vol.placedAt.Add(m.runningDelay))
+}
+
+// toPv provides the persistent volume of a mock volume
+func (m *MockOrchestrator) toPv(vol *mockVolume) *v1.PersistentVolume {
+	status := mockStatusPending
+	if m.isRunning(vol) {
+		status = mockStatusRunning
+	}
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = vol.name
+	pv.ResourceVersion = strconv.FormatUint(vol.index, 10)
+	pv.Spec.Capacity = v1.ResourceList{
+		v1.ResourceStorage: vol.size,
+	}
+	pv.Status.Reason = status
+
+	if status == mockStatusRunning {
+		pv.Status.Message = fmt.Sprintf("%d of %d replicas are running", vol.replicas, vol.replicas)
+	} else {
+		pv.Status.Message = fmt.Sprintf("0 of %d replicas are running", vol.replicas)
+	}
+
+	annotations := map[string]string{}
+	for k, v := range vol.annotations {
+		annotations[k] = v
+	}
+	if status == mockStatusRunning && vol.ctlIP != "" {
+		annotations[pvTargetPortal] = vol.ctlIP + ":3260"
+		annotations[pvIQN] = "iqn.2016-09.com.openebs.jiva:" + vol.name
+	}
+	if len(annotations) != 0 {
+		pv.Annotations = annotations
+	}
+
+	for i := 0; i < vol.replicas; i++ {
+		rep := v1.VolumeReplicaStatus{
+			Name:   fmt.Sprintf("%s-rep-%d", vol.name, i+1),
+			NodeID: fmt.Sprintf("mock-node-%d", i+1),
+			Status: status,
+		}
+		if i < len(vol.repIPs) {
+			rep.Address = vol.repIPs[i]
+		}
+		pv.Status.Replicas = append(pv.Status.Replicas, rep)
+	}
+
+	return pv
+}
+
+// pvcToMockVolume provides the mock volume of a claim. The claim needs a
+// name & a size.
+func pvcToMockVolume(pvc *v1.PersistentVolumeClaim) (*mockVolume, error) {
+	if pvc == nil {
+		return nil, fmt.Errorf("Nil persistent volume claim provided")
+	}
+
+	if pvc.Name == "" {
+		return nil, fmt.Errorf("Missing name in persistent volume claim")
+	}
+
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return nil, fmt.Errorf("Requested storage size of volume '%s' hasn't been provided", pvc.Name)
+	}
+
+	// The replica count is set by the jiva volume plugin from its config
+	replicas, err := strconv.Atoi(pvc.Labels["jivareplicas"])
+	if err != nil || replicas < 1 {
+		return nil, fmt.Errorf("Invalid replica count '%s' of volume '%s'", pvc.Labels["jivareplicas"], pvc.Name)
+	}
+
+	vol := &mockVolume{
+		name:     pvc.Name,
+		size:     size,
+		replicas: replicas,
+		ctlIP:    pvc.Labels["jivafeip"],
+	}
+
+	if ips := pvc.Labels["jivabeip"]; ips != "" {
+		for _, ip := range strings.Split(ips, ",") {
+			vol.repIPs = append(vol.repIPs, strings.TrimSpace(ip))
+		}
+	}
+
+	if srcVol := pvc.Annotations[v1.CloneSourceVolumeAnnotation]; srcVol != "" {
+		vol.annotations = map[string]string{
+			v1.CloneSourceVolumeAnnotation:   srcVol,
+			v1.CloneSourceSnapshotAnnotation: pvc.Annotations[v1.CloneSourceSnapshotAnnotation],
+		}
+	}
+
+	return vol, nil
+}
+
+// toVolumeAttachment provides the attachment of a mock volume
+func toVolumeAttachment(vol *mockVolume) *v1.VolumeAttachment {
+	va := &v1.VolumeAttachment{}
+	va.Name = vol.name + "-" + vol.attachedInstance
+	va.Spec.VolumeName = vol.name
+	va.Spec.InstanceID = vol.attachedInstance
+	va.Status.IQN = "iqn.2016-09.com.openebs.jiva:" + vol.name
+	if vol.ctlIP != "" {
+		va.Status.TargetPortal = vol.ctlIP + ":3260"
+	}
+
+	return va
+}
+
+// validateAttachment verifies the attachment has a volume & an instance
+func validateAttachment(va *v1.VolumeAttachment) error {
+	if va == nil {
+		return v1.NewInvalidError(fmt.Errorf("Nil volume attachment provided"))
+	}
+
+	if va.Spec.VolumeName == "" {
+		return v1.NewInvalidError(fmt.Errorf("Missing name in persistent volume claim"))
+	}
+
+	if va.Spec.InstanceID == "" {
+		return v1.NewInvalidError(fmt.Errorf("Missing instance id in attachment of volume '%s'", va.Spec.VolumeName))
+	}
+
+	return nil
+}
@@ -0,0 +1,295 @@
+package mock
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// testClock is a clock of the tests that moves only when advanced
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time {
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.t = c.t.Add(d)
+}
+
+// testOrchestrator provides a mock orchestrator of the config along with
+// its clock. The injected latencies are recorded instead of slept.
+func testOrchestrator(t *testing.T, config string) (*MockOrchestrator, *testClock, *[]time.Duration) {
+	m, err := newMockOrchestrator(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	clock := &testClock{t: time.Unix(1500000000, 0)}
+	m.now = clock.now
+
+	var slept []time.Duration
+	m.injector.sleep = func(d time.Duration) {
+		slept = append(slept, d)
+	}
+
+	return m, clock, &slept
+}
+
+func testClaim(name, size string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = name
+	pvc.Labels = map[string]string{
+		"jivareplicas": "2",
+		"jivafeip":     "10.0.0.10",
+		"jivabeip":     "10.0.0.11,10.0.0.12",
+	}
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse(size),
+	}
+	return pvc
+}
+
+func TestStoragePlacementReq_PendingToRunning(t *testing.T) {
+	m, clock, _ := testOrchestrator(t, "[volume]\nrunning-delay = 10s\n")
+
+	pv, err := m.StoragePlacementReq(testClaim("myvol", "1Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Status.Reason != mockStatusPending {
+		t.Fatalf("bad status: %s", pv.Status.Reason)
+	}
+
+	if pv.Annotations[pvTargetPortal] != "" {
+		t.Fatalf("pending volume has a target portal: %v", pv.Annotations)
+	}
+
+	if _, err := m.StoragePlacementReq(testClaim("myvol", "1Gi")); !v1.IsAlreadyExists(err) {
+		t.Fatalf("expected already exists, got: %v", err)
+	}
+
+	clock.advance(10 * time.Second)
+
+	pv, qm, err := m.StorageInfoReq(testClaim("myvol", "1Gi"), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Status.Reason != mockStatusRunning {
+		t.Fatalf("bad status: %s", pv.Status.Reason)
+	}
+
+	if pv.Annotations[pvTargetPortal] != "10.0.0.10:3260" {
+		t.Fatalf("bad target portal: %v", pv.Annotations)
+	}
+
+	if len(pv.Status.Replicas) != 2 || pv.Status.Replicas[1].Address != "10.0.0.12" || pv.Status.Replicas[1].Status != mockStatusRunning {
+		t.Fatalf("bad replicas: %#v", pv.Status.Replicas)
+	}
+
+	if qm.LastIndex == 0 {
+		t.Fatalf("expected a change index")
+	}
+}
+
+func TestStoragePlacementReq_InvalidClaim(t *testing.T) {
+	m, _, _ := testOrchestrator(t, "")
+
+	pvc := testClaim("myvol", "1Gi")
+	pvc.Spec.Resources.Requests = nil
+
+	if _, err := m.StoragePlacementReq(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid, got: %v", err)
+	}
+}
+
+func TestStorageRemovalReq(t *testing.T) {
+	m, _, _ := testOrchestrator(t, "")
+
+	if _, err := m.StoragePlacementReq(testClaim("myvol", "1Gi")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = "myvol"
+
+	removed, err := m.StorageRemovalReq(pv)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if removed.Status.Reason != mockStatusDead {
+		t.Fatalf("bad status: %s", removed.Status.Reason)
+	}
+
+	if _, _, err := m.StorageInfoReq(testClaim("myvol", "1Gi"), nil); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+
+	if _, err := m.StorageRemovalReq(pv); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+}
+
+func TestStorageListReq(t *testing.T) {
+	m, _, _ := testOrchestrator(t, "")
+
+	for _, name := range []string{"vol-b", "vol-a", "other"} {
+		if _, err := m.StoragePlacementReq(testClaim(name, "1Gi")); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	list, err := m.StorageListReq(&v1.QueryOptions{Prefix: "vol-"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(list.Items) != 2 || list.Items[0].Name != "vol-a" || list.Items[1].Name != "vol-b" {
+		t.Fatalf("bad list: %#v", list.Items)
+	}
+}
+
+func TestStorageResizeReq(t *testing.T) {
+	m, _, _ := testOrchestrator(t, "")
+
+	if _, err := m.StoragePlacementReq(testClaim("myvol", "2Gi")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := m.StorageResizeReq(testClaim("myvol", "1Gi")); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid, got: %v", err)
+	}
+
+	pv, err := m.StorageResizeReq(testClaim("myvol", "4Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	size := pv.Spec.Capacity[v1.ResourceStorage]
+	if size.String() != "4Gi" {
+		t.Fatalf("bad size: %s", size.String())
+	}
+}
+
+func TestStorageAttachReq(t *testing.T) {
+	m, clock, _ := testOrchestrator(t, "[volume]\nrunning-delay = 5s\n")
+
+	if _, err := m.StoragePlacementReq(testClaim("myvol", "1Gi")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	va := &v1.VolumeAttachment{}
+	va.Spec.VolumeName = "myvol"
+	va.Spec.InstanceID = "i-1"
+
+	if _, err := m.StorageAttachReq(va); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable while pending, got: %v", err)
+	}
+
+	clock.advance(5 * time.Second)
+
+	attached, err := m.StorageAttachReq(va)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if attached.Status.TargetPortal != "10.0.0.10:3260" {
+		t.Fatalf("bad target portal: %s", attached.Status.TargetPortal)
+	}
+
+	other := &v1.VolumeAttachment{}
+	other.Spec.VolumeName = "myvol"
+	other.Spec.InstanceID = "i-2"
+
+	if _, err := m.StorageAttachReq(other); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict, got: %v", err)
+	}
+
+	list, err := m.StorageAttachmentsReq(testClaim("myvol", "1Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(list.Items) != 1 || list.Items[0].Spec.InstanceID != "i-1" {
+		t.Fatalf("bad attachments: %#v", list.Items)
+	}
+
+	if err := m.StorageDetachReq(other); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+
+	if err := m.StorageDetachReq(va); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestFaultInjection(t *testing.T) {
+	config := `
+[faults]
+latency = 100ms
+seed = 7
+
+[operation "placement"]
+error-rate = 1
+
+[operation "info"]
+latency = 1s
+latency-jitter = 500ms
+`
+	m, _, slept := testOrchestrator(t, config)
+
+	if _, err := m.StoragePlacementReq(testClaim("myvol", "1Gi")); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable, got: %v", err)
+	}
+
+	if _, err := m.StorageListReq(nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, err := m.StorageInfoReq(testClaim("myvol", "1Gi"), nil); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+
+	if len(*slept) != 3 {
+		t.Fatalf("expected 3 delays, got: %v", *slept)
+	}
+
+	if (*slept)[0] != 100*time.Millisecond || (*slept)[1] != 100*time.Millisecond {
+		t.Fatalf("bad delays: %v", *slept)
+	}
+
+	if d := (*slept)[2]; d < time.Second || d > 1500*time.Millisecond {
+		t.Fatalf("bad jittered delay: %v", d)
+	}
+}
+
+func TestReadMockConfig(t *testing.T) {
+	s, err := readMockConfig(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if s.runningDelay != 3*time.Second {
+		t.Fatalf("bad running delay: %v", s.runningDelay)
+	}
+
+	for _, config := range []string{
+		"[volume]\nrunning-delay = soon\n",
+		"[faults]\nerror-rate = 2\n",
+		"[faults]\nseed = abc\n",
+		"[operation \"unknown\"]\nerror-rate = 0.5\n",
+		"[operation \"attach\"]\nlatency = -1s\n",
+	} {
+		if _, err := readMockConfig(strings.NewReader(config)); err == nil {
+			t.Fatalf("expected an error for config: %q", config)
+		}
+	}
+}
@@ -5,6 +5,8 @@ package nomad
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/nomad/api"
 )
@@ -12,8 +14,10 @@ import (
 // Apis provides a means to communicate with Nomad Apis
 type Apis interface {
 
-	// This returns a client that can communicate with Nomad
-	Client() (NomadClient, error)
+	// This returns a client that can communicate with the Nomad servers of
+	// the named datacenter. An empty name refers to the Nomad servers that
+	// are not specific to a datacenter.
+	Client(datacenter string) (NomadClient, error)
 
 	// This returns a concrete implementation of StorageApis
 	StorageApis(nApiClient NomadClient) (StorageApis, error)
@@ -32,9 +36,21 @@ func newNomadApiProvider(nConfig *NomadConfig) *nomadApiProvider {
 }
 
 // Provides a concrete implementation of Nomad api client that
-// can invoke Nomad APIs
-func (nap *nomadApiProvider) Client() (NomadClient, error) {
-	return newNomadClientUtil(nap.nConfig)
+// can invoke Nomad APIs of the named datacenter
+func (nap *nomadApiProvider) Client(datacenter string) (NomadClient, error) {
+	if nap.nConfig == nil {
+		if datacenter != "" {
+			return nil, fmt.Errorf("Nomad datacenter '%s' is not configured", datacenter)
+		}
+		return newNomadClientUtil("", nil)
+	}
+
+	dcConf := nap.nConfig.datacenterConfig(datacenter)
+	if dcConf == nil {
+		return nil, fmt.Errorf("Nomad datacenter '%s' is not configured", datacenter)
+	}
+
+	return newNomadClientUtil(datacenter, dcConf)
 }
 
 // Returns an instance of StorageApis.
@@ -60,8 +76,27 @@ type StorageApis interface {
 	// Delete makes a request to Nomad to delete the storage resource
 	DeleteStorage(job *api.Job) (*api.Evaluation, error)
 
-	// Info provides the storage information w.r.t the provided job name
-	StorageInfo(jobName string) (*api.Job, error)
+	// Update makes a request to Nomad to update an existing storage
+	// resource
+	UpdateStorage(job *api.Job) (*api.Evaluation, error)
+
+	// Info provides the storage information w.r.t the provided job name.
+	// The query options can be used to block till the job changes.
+	StorageInfo(jobName string, opts *api.QueryOptions) (*api.Job, *api.QueryMeta, error)
+
+	// List provides the stubs of all the jobs that match the provided
+	// query options
+	StorageList(opts *api.QueryOptions) ([]*api.JobListStub, *api.QueryMeta, error)
+
+	// Allocations provides the stubs of the allocations of the storage
+	// resource w.r.t the provided job name
+	StorageAllocations(jobName string) ([]*api.AllocationListStub, error)
+
+	// SetAttachment records the instance the storage resource is attached
+	// to in the Meta of its job, if the job has not changed since it was
+	// read. An empty instance removes the record. Returns false if the job
+	// changed.
+	SetStorageAttachment(job *api.Job, instanceID string) (bool, error)
 }
 
 // nomadStorageApi is an implementation of the nomad.StorageApis interface
@@ -77,26 +112,94 @@ type nomadStorageApi struct {
 //    Nomad does not have persistent volume as its first class citizen.
 // Hence, this resource should exhibit storage characteristics. The validations
 // for this should have been done at the volume plugin implementation.
-func (nsApi *nomadStorageApi) StorageInfo(jobName string) (*api.Job, error) {
+func (nsApi *nomadStorageApi) StorageInfo(jobName string, opts *api.QueryOptions) (*api.Job, *api.QueryMeta, error) {
 
 	nApiClient := nsApi.nApiClient
 	if nApiClient == nil {
-		return nil, fmt.Errorf("nomad api client not initialized")
+		return nil, nil, errNoNomadClient
 	}
 
 	nApiHttpClient, err := nApiClient.Http()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	if opts == nil {
+		opts = &api.QueryOptions{}
 	}
 
 	// Fetch the job info
-	job, _, err := nApiHttpClient.Jobs().Info(jobName, &api.QueryOptions{})
+	//
+	// NOTE:
+	//    This is a blocking query if opts has a WaitIndex
+	start := time.Now()
+	job, qm, err := nApiHttpClient.Jobs().Info(jobName, opts)
+	observeNomadCall(callJobInfo, start, err)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return job, qm, nil
+}
+
+// List the resources in Nomad cluster.
+//
+// NOTE:
+//    Nomad does not have persistent volume as its first class citizen.
+// Hence, the jobs that do not exhibit storage characteristics should be
+// filtered by the caller.
+func (nsApi *nomadStorageApi) StorageList(opts *api.QueryOptions) ([]*api.JobListStub, *api.QueryMeta, error) {
+
+	nApiClient := nsApi.nApiClient
+	if nApiClient == nil {
+		return nil, nil, errNoNomadClient
+	}
+
+	nApiHttpClient, err := nApiClient.Http()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Fetch the job stubs
+	start := time.Now()
+	jobs, qm, err := nApiHttpClient.Jobs().List(opts)
+	observeNomadCall(callJobList, start, err)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return jobs, qm, nil
+}
+
+// List the allocations of a resource in Nomad cluster.
+//
+// NOTE:
+//    The allocations are sorted with the most recently created first. A
+// task group may have several allocations if it has been rescheduled.
+func (nsApi *nomadStorageApi) StorageAllocations(jobName string) ([]*api.AllocationListStub, error) {
+
+	nApiClient := nsApi.nApiClient
+	if nApiClient == nil {
+		return nil, errNoNomadClient
+	}
+
+	nApiHttpClient, err := nApiClient.Http()
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch the allocation stubs
+	start := time.Now()
+	allocs, _, err := nApiHttpClient.Jobs().Allocations(jobName, false, &api.QueryOptions{})
+	observeNomadCall(callJobAllocations, start, err)
 
 	if err != nil {
 		return nil, err
 	}
 
-	return job, nil
+	return allocs, nil
 }
 
 // Creates a resource in Nomad cluster.
@@ -109,7 +212,7 @@ func (nsApi *nomadStorageApi) CreateStorage(job *api.Job) (*api.Evaluation, erro
 
 	nApiClient := nsApi.nApiClient
 	if nApiClient == nil {
-		return nil, fmt.Errorf("nomad api client not initialized")
+		return nil, errNoNomadClient
 	}
 
 	nApiHttpClient, err := nApiClient.Http()
@@ -118,14 +221,61 @@ func (nsApi *nomadStorageApi) CreateStorage(job *api.Job) (*api.Evaluation, erro
 	}
 
 	// Register a job & get its evaluation id
+	start := time.Now()
 	evalID, _, err := nApiHttpClient.Jobs().Register(job, &api.WriteOptions{})
+	observeNomadCall(callJobRegister, start, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Get the evaluation details
+	start = time.Now()
+	eval, _, err := nApiHttpClient.Evaluations().Info(evalID, &api.QueryOptions{})
+	observeNomadCall(callEvalInfo, start, err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return eval, nil
+}
+
+// Updates an existing resource in Nomad cluster.
+//
+// NOTE:
+//    The job is registered only if it has not been modified since it was
+// read i.e. its modify index is enforced.
+func (nsApi *nomadStorageApi) UpdateStorage(job *api.Job) (*api.Evaluation, error) {
+
+	nApiClient := nsApi.nApiClient
+	if nApiClient == nil {
+		return nil, errNoNomadClient
+	}
+
+	nApiHttpClient, err := nApiClient.Http()
+	if err != nil {
+		return nil, err
+	}
+
+	var modifyIndex uint64
+	if job.JobModifyIndex != nil {
+		modifyIndex = *job.JobModifyIndex
+	}
+
+	// Register the updated job & get its evaluation id
+	start := time.Now()
+	evalID, _, err := nApiHttpClient.Jobs().EnforceRegister(job, modifyIndex, &api.WriteOptions{})
+	observeNomadCall(callJobRegister, start, err)
 
 	if err != nil {
 		return nil, err
 	}
 
 	// Get the evaluation details
+	start = time.Now()
 	eval, _, err := nApiHttpClient.Evaluations().Info(evalID, &api.QueryOptions{})
+	observeNomadCall(callEvalInfo, start, err)
 
 	if err != nil {
 		return nil, err
@@ -144,7 +294,7 @@ func (nsApi *nomadStorageApi) DeleteStorage(job *api.Job) (*api.Evaluation, erro
 
 	nApiClient := nsApi.nApiClient
 	if nApiClient == nil {
-		return nil, fmt.Errorf("nomad api client not initialized")
+		return nil, errNoNomadClient
 	}
 
 	nApiHttpClient, err := nApiClient.Http()
@@ -152,16 +302,60 @@ func (nsApi *nomadStorageApi) DeleteStorage(job *api.Job) (*api.Evaluation, erro
 		return nil, err
 	}
 
+	start := time.Now()
 	evalID, _, err := nApiHttpClient.Jobs().Deregister(*job.Name, &api.WriteOptions{})
+	observeNomadCall(callJobDeregister, start, err)
 
 	if err != nil {
 		return nil, err
 	}
 
+	start = time.Now()
 	eval, _, err := nApiHttpClient.Evaluations().Info(evalID, &api.QueryOptions{})
+	observeNomadCall(callEvalInfo, start, err)
 	if err != nil {
 		return nil, err
 	}
 
 	return eval, nil
 }
+
+// Record the attachment of a resource in Nomad cluster.
+//
+// NOTE:
+//    The attachment is recorded in the Meta of the resource's job. The job
+// is registered against the modify index it was read at, hence concurrent
+// attach requests for a resource can not both succeed.
+func (nsApi *nomadStorageApi) SetStorageAttachment(job *api.Job, instanceID string) (bool, error) {
+
+	nApiClient := nsApi.nApiClient
+	if nApiClient == nil {
+		return false, errNoNomadClient
+	}
+
+	nApiHttpClient, err := nApiClient.Http()
+	if err != nil {
+		return false, err
+	}
+
+	if job.JobModifyIndex == nil {
+		return false, fmt.Errorf("Job '%s' has no modify index", *job.Name)
+	}
+
+	SetJobAttachedInstance(job, instanceID)
+
+	start := time.Now()
+	_, _, err = nApiHttpClient.Jobs().EnforceRegister(job, *job.JobModifyIndex, &api.WriteOptions{})
+	observeNomadCall(callJobRegister, start, err)
+
+	// The job was changed by another request since it was read
+	if err != nil && strings.Contains(err.Error(), nomadIndexConflict) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -1,11 +1,16 @@
 package nomad
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/golang/glog"
+	"github.com/hashicorp/go-rootcerts"
 	"github.com/hashicorp/nomad/api"
 	gcfg "gopkg.in/gcfg.v1"
 )
@@ -15,6 +20,30 @@ const (
 	// of a Nomad deployment
 	EnvNomadAddress = "NOMAD_ADDR"
 	EnvNomadRegion  = "NOMAD_REGION"
+
+	// Names of environment variables used to supply the TLS settings, the
+	// ACL token & the request timeout of a Nomad deployment
+	EnvNomadCACert        = "NOMAD_CACERT"
+	EnvNomadCAPath        = "NOMAD_CAPATH"
+	EnvNomadClientCert    = "NOMAD_CLIENT_CERT"
+	EnvNomadClientKey     = "NOMAD_CLIENT_KEY"
+	EnvNomadSkipVerify    = "NOMAD_SKIP_VERIFY"
+	EnvNomadToken         = "NOMAD_TOKEN"
+	EnvNomadClientTimeout = "NOMAD_CLIENT_TIMEOUT"
+
+	// nomadTokenHeader is the http header that carries an ACL token
+	nomadTokenHeader = "X-Nomad-Token"
+)
+
+const (
+	// nomadMaxQueryTime is the longest wait time of a blocking query that
+	// is honoured by the Nomad servers. A longer wait time is clamped to it.
+	nomadMaxQueryTime = 5 * time.Minute
+
+	// maxBlockingQueryWait is the longest a blocking query may be held by
+	// the Nomad servers i.e. the max query time plus Nomad's jitter of
+	// 1/16th of the wait time
+	maxBlockingQueryWait = nomadMaxQueryTime + nomadMaxQueryTime/16
 )
 
 // NomadConfig provides the settings that has the coordinates of a
@@ -23,18 +52,118 @@ const (
 // A NomadConfig file has .INI extension.
 // Below is a sample:
 //
+// [nomad]
+// ca-cert = /etc/mayaserver/orchprovider/nomad-ca.pem
+// timeout = 6m
+// job-template = /etc/mayaserver/orchprovider/jiva-job.json.tmpl
+//
 // [datacenter "dc1"]
 // address = http://10.0.0.1:4646
 //
 // [datacenter "dc2"]
-// address = http://20.0.0.2:4646
+// address = https://20.0.0.2:4646
+// region = asia
+// ca-cert = /etc/mayaserver/orchprovider/nomad-dc2-ca.pem
+// client-cert = /etc/mayaserver/orchprovider/nomad-dc2-cli.pem
+// client-key = /etc/mayaserver/orchprovider/nomad-dc2-cli-key.pem
+// token = 3b5a36a2-5c56-4c2e-8a3b-1d2e5d2a4f77
 //
 // NOTE:
-//    This is as per gcfg lib's conventions
+//    This is as per gcfg lib's conventions. The [nomad] section provides
+// the defaults of all the datacenters. It provides the coordinates of the
+// Nomad servers if there is no datacenter section.
 type NomadConfig struct {
-	Datacenter map[string]*struct {
-		Address string
+	Nomad DatacenterConfig
+
+	Datacenter map[string]*DatacenterConfig
+}
+
+// DatacenterConfig provides the coordinates of the Nomad servers that
+// place the volumes of a datacenter.
+type DatacenterConfig struct {
+	// Address is the http(s) address of a Nomad server
+	Address string
+
+	// Region of the datacenter. Claims of this datacenter need to be of
+	// this region, if it is set.
+	Region string
+
+	// CACert is the CA certificate file that verifies the Nomad servers
+	CACert string `gcfg:"ca-cert"`
+
+	// CAPath is the directory of CA certificate files that verify the
+	// Nomad servers
+	CAPath string `gcfg:"ca-path"`
+
+	// ClientCert & ClientKey are the certificate & the key files that
+	// mayaserver presents to the Nomad servers
+	ClientCert string `gcfg:"client-cert"`
+	ClientKey  string `gcfg:"client-key"`
+
+	// TLSSkipVerify disables the verification of the Nomad servers'
+	// certificates
+	TLSSkipVerify bool `gcfg:"tls-skip-verify"`
+
+	// Token is the ACL token i.e. the secret id sent to the Nomad servers
+	Token string
+
+	// Timeout bounds each request sent to the Nomad servers e.g. 6m. It
+	// needs to exceed the wait time of the blocking queries.
+	Timeout string
+
+	// JobTemplate is the file of the jobspec template the jiva volumes of
+	// the datacenter are placed with. The built-in job is used if this is
+	// not set.
+	JobTemplate string `gcfg:"job-template"`
+}
+
+// withDefaults provides the datacenter config whose unset fields are set
+// from the defaults
+func (dc *DatacenterConfig) withDefaults(defaults *DatacenterConfig) *DatacenterConfig {
+	merged := *dc
+
+	setIfEmpty := func(field *string, def string) {
+		if *field == "" {
+			*field = def
+		}
 	}
+
+	setIfEmpty(&merged.Address, defaults.Address)
+	setIfEmpty(&merged.Region, defaults.Region)
+	setIfEmpty(&merged.CACert, defaults.CACert)
+	setIfEmpty(&merged.CAPath, defaults.CAPath)
+	setIfEmpty(&merged.ClientCert, defaults.ClientCert)
+	setIfEmpty(&merged.ClientKey, defaults.ClientKey)
+	setIfEmpty(&merged.Token, defaults.Token)
+	setIfEmpty(&merged.Timeout, defaults.Timeout)
+	setIfEmpty(&merged.JobTemplate, defaults.JobTemplate)
+	merged.TLSSkipVerify = merged.TLSSkipVerify || defaults.TLSSkipVerify
+
+	return &merged
+}
+
+// fromEnv provides the config that is set via env variables
+func fromEnv() (*DatacenterConfig, error) {
+	envConf := &DatacenterConfig{
+		Address:    os.Getenv(EnvNomadAddress),
+		Region:     os.Getenv(EnvNomadRegion),
+		CACert:     os.Getenv(EnvNomadCACert),
+		CAPath:     os.Getenv(EnvNomadCAPath),
+		ClientCert: os.Getenv(EnvNomadClientCert),
+		ClientKey:  os.Getenv(EnvNomadClientKey),
+		Token:      os.Getenv(EnvNomadToken),
+		Timeout:    os.Getenv(EnvNomadClientTimeout),
+	}
+
+	if v := os.Getenv(EnvNomadSkipVerify); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("env variable '%s' is not a boolean: %s", EnvNomadSkipVerify, v)
+		}
+		envConf.TLSSkipVerify = insecure
+	}
+
+	return envConf, nil
 }
 
 // NomadClient is an abstraction over various connection modes (http, rpc)
@@ -54,51 +183,111 @@ type NomadClient interface {
 // interface.
 type nomadClientUtil struct {
 
+	// The datacenter whose Nomad servers are reached. This is empty if the
+	// Nomad servers are not specific to a datacenter.
+	datacenter string
+
 	// The region to send API requests
 	region string
 
 	// Nomad server / cluster coordinates
-	nomadConf *NomadConfig
+	address string
 
 	caCert     string
 	caPath     string
 	clientCert string
 	clientKey  string
 	insecure   bool
-}
 
-// newNomadClientUtil provides a new instance of nomadClientUtil
-func newNomadClientUtil(nConfig *NomadConfig) (*nomadClientUtil, error) {
-	return &nomadClientUtil{
-		nomadConf: nConfig,
-	}, nil
+	// ACL token sent with every API request
+	token string
+
+	// timeout bounds every API request. There is no bound if this is 0.
+	timeout time.Duration
 }
 
-// Client is used to initialize and return a new API client capable
-// of calling Nomad APIs. It uses env vars.
-func (m *nomadClientUtil) Http() (*api.Client, error) {
-	// Nomad API client config
-	apiCConf := api.DefaultConfig()
+// newNomadClientUtil provides a new instance of nomadClientUtil that
+// reaches the Nomad servers of the datacenter. The settings of the config,
+// if any, override the ones set via env variables.
+//
+// NOTE:
+//    The TLS files are verified here s.t. a bad config is reported at
+// startup rather than at the first request.
+func newNomadClientUtil(dcName string, dcConf *DatacenterConfig) (*nomadClientUtil, error) {
+	conf, err := fromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if dcConf != nil {
+		conf = dcConf.withDefaults(conf)
+	}
 
-	// Set from environment variable
-	val, found := os.LookupEnv(EnvNomadAddress)
+	m := &nomadClientUtil{
+		datacenter: dcName,
+		region:     conf.Region,
+		address:    conf.Address,
+		caCert:     conf.CACert,
+		caPath:     conf.CAPath,
+		clientCert: conf.ClientCert,
+		clientKey:  conf.ClientKey,
+		insecure:   conf.TLSSkipVerify,
+		token:      conf.Token,
+	}
+
+	if conf.Timeout != "" {
+		m.timeout, err = time.ParseDuration(conf.Timeout)
+		if err != nil || m.timeout < 0 {
+			return nil, fmt.Errorf("timeout '%s' is not a valid duration", conf.Timeout)
+		}
+
+		// A blocking query would be cut short by the http client otherwise
+		if m.timeout != 0 && m.timeout <= maxBlockingQueryWait {
+			return nil, fmt.Errorf("timeout '%s' needs to exceed '%s', the max wait time of the blocking queries", conf.Timeout, maxBlockingQueryWait)
+		}
+	}
+
+	if err := m.validateTLS(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// validateTLS verifies that the TLS files can be loaded
+func (m *nomadClientUtil) validateTLS() error {
+	if m.caCert != "" || m.caPath != "" {
+		_, err := rootcerts.LoadCACerts(&rootcerts.Config{
+			CAFile: m.caCert,
+			CAPath: m.caPath,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to load Nomad CA certificates: %v", err)
+		}
+	}
 
-	if !found {
-		glog.V(2).Infof("Env variable '%s' is not set", EnvNomadAddress)
+	if (m.clientCert == "") != (m.clientKey == "") {
+		return fmt.Errorf("both Nomad client cert & client key need to be set")
 	}
 
-	if val != "" {
-		glog.V(2).Infof("Nomad address is set to '%s' via env var", val)
-		apiCConf.Address = val
+	if m.clientCert != "" {
+		if _, err := tls.LoadX509KeyPair(m.clientCert, m.clientKey); err != nil {
+			return fmt.Errorf("unable to load Nomad client cert & client key: %v", err)
+		}
 	}
 
-	// Override from conf structure
-	if m.nomadConf != nil && m.nomadConf.Datacenter != nil {
-		// TODO
-		// Derive the datacenter at runtime
-		// Remove the region & datacenter properties from Mayaconfig
-		glog.V(2).Infof("Nomad address is set to: '%s' via conf", m.nomadConf.Datacenter["dc1"].Address)
-		apiCConf.Address = m.nomadConf.Datacenter["dc1"].Address
+	return nil
+}
+
+// Client is used to initialize and return a new API client capable
+// of calling Nomad APIs.
+func (m *nomadClientUtil) Http() (*api.Client, error) {
+	// Nomad API client config
+	apiCConf := api.DefaultConfig()
+
+	if m.address != "" {
+		glog.V(2).Infof("Nomad address of datacenter '%s' is set to: '%s'", m.datacenter, m.address)
+		apiCConf.Address = m.address
 	}
 
 	if apiCConf.Address == "" {
@@ -107,29 +296,59 @@ func (m *nomadClientUtil) Http() (*api.Client, error) {
 
 	glog.V(2).Infof("Nomad will be reached at: '%s'", apiCConf.Address)
 
-	if v := os.Getenv(EnvNomadRegion); v != "" {
-		apiCConf.Region = v
+	apiCConf.Region = m.region
+
+	apiCConf.TLSConfig = &api.TLSConfig{
+		CACert:     m.caCert,
+		CAPath:     m.caPath,
+		ClientCert: m.clientCert,
+		ClientKey:  m.clientKey,
+		Insecure:   m.insecure,
 	}
 
-	if m.region != "" {
-		apiCConf.Region = m.region
+	apiCConf.HttpClient.Timeout = m.timeout
+
+	// This has the http address & authentication details
+	// required to invoke Nomad APIs
+	client, err := api.NewClient(apiCConf)
+	if err != nil {
+		return nil, err
 	}
 
-	// If we need custom TLS configuration, then set it
-	if m.caCert != "" || m.caPath != "" || m.clientCert != "" || m.clientKey != "" || m.insecure {
-		t := &api.TLSConfig{
-			CACert:     m.caCert,
-			CAPath:     m.caPath,
-			ClientCert: m.clientCert,
-			ClientKey:  m.clientKey,
-			Insecure:   m.insecure,
+	// The transport is wrapped only after the TLS settings are applied to
+	// it by api.NewClient
+	if m.token != "" {
+		apiCConf.HttpClient.Transport = &tokenTransport{
+			token: m.token,
+			base:  apiCConf.HttpClient.Transport,
 		}
-		apiCConf.TLSConfig = t
 	}
 
-	// This has the http address & authentication details
-	// required to invoke Nomad APIs
-	return api.NewClient(apiCConf)
+	return client, nil
+}
+
+// tokenTransport sets the ACL token against the requests sent to Nomad.
+//
+// NOTE:
+//    The vendored Nomad api package predates Nomad's ACLs. Hence, the token
+// is set as a http header.
+type tokenTransport struct {
+	token string
+	base  http.RoundTripper
+}
+
+// RoundTrip is an implementation of http.RoundTripper interface
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// A request is not to be modified by a RoundTripper
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	r.Header.Set(nomadTokenHeader, t.token)
+
+	return t.base.RoundTrip(r)
 }
 
 // readNomadConfig reads an instance of NomadConfig from config reader.
@@ -144,8 +363,27 @@ func readNomadConfig(config io.Reader) (*NomadConfig, error) {
 		}
 	}
 
-	// TODO
-	// validations w.r.t config
+	for name, dc := range nCfg.Datacenter {
+		if dc == nil || dc.withDefaults(&nCfg.Nomad).Address == "" {
+			return nil, fmt.Errorf("Nomad address of datacenter '%s' is not set", name)
+		}
+	}
 
 	return &nCfg, nil
 }
+
+// datacenterConfig provides the config of the named datacenter along with
+// the defaults of the [nomad] section. The [nomad] section is provided if
+// the name is empty. Returns nil if the datacenter is not configured.
+func (nCfg *NomadConfig) datacenterConfig(name string) *DatacenterConfig {
+	if name == "" {
+		return &nCfg.Nomad
+	}
+
+	dc := nCfg.Datacenter[name]
+	if dc == nil {
+		return nil
+	}
+
+	return dc.withDefaults(&nCfg.Nomad)
+}
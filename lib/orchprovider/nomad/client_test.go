@@ -3,3 +3,129 @@ package nomad
 // Test the creation of NomadConfig struct
 // Test if nomadClientUtil adheres to NomadClient interface
 // Invoke nomadClientUtil methods with all properties of nomadClientUtil as nil
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setEnv sets the env variables & provides a func that restores them
+func setEnv(t *testing.T, vars map[string]string) func() {
+	old := map[string]string{}
+	for k, v := range vars {
+		old[k] = os.Getenv(k)
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	return func() {
+		for k, v := range old {
+			os.Setenv(k, v)
+		}
+	}
+}
+
+func TestNewNomadClientUtil_Env(t *testing.T) {
+	defer setEnv(t, map[string]string{
+		EnvNomadAddress:       "http://10.0.0.1:4646",
+		EnvNomadToken:         "env-token",
+		EnvNomadClientTimeout: "6m",
+		EnvNomadSkipVerify:    "true",
+	})()
+
+	m, err := newNomadClientUtil("", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if m.address != "http://10.0.0.1:4646" || m.token != "env-token" || m.timeout != 6*time.Minute || !m.insecure {
+		t.Fatalf("bad client: %#v", m)
+	}
+
+	// The config overrides the env variables
+	m, err = newNomadClientUtil("dc1", &DatacenterConfig{Address: "http://20.0.0.2:4646", Token: "conf-token"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if m.address != "http://20.0.0.2:4646" || m.token != "conf-token" || m.timeout != 6*time.Minute {
+		t.Fatalf("bad client: %#v", m)
+	}
+}
+
+func TestNewNomadClientUtil_InvalidSettings(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nomad-tls")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	badCA := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(badCA, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]*DatacenterConfig{
+		"missing ca file":     &DatacenterConfig{CACert: filepath.Join(dir, "missing.pem")},
+		"bad ca file":         &DatacenterConfig{CACert: badCA},
+		"cert without key":    &DatacenterConfig{ClientCert: badCA},
+		"unloadable key":      &DatacenterConfig{ClientCert: badCA, ClientKey: badCA},
+		"bad timeout":         &DatacenterConfig{Timeout: "soon"},
+		"short timeout":       &DatacenterConfig{Timeout: "5m"},
+		"bad env skip verify": nil,
+	}
+
+	for name, dcConf := range cases {
+		vars := map[string]string{EnvNomadSkipVerify: ""}
+		if dcConf == nil {
+			vars[EnvNomadSkipVerify] = "perhaps"
+		}
+		restore := setEnv(t, vars)
+
+		_, err := newNomadClientUtil("dc1", dcConf)
+		restore()
+
+		if err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestReadNomadConfig_Defaults(t *testing.T) {
+	config := `
+[nomad]
+address = http://10.0.0.1:4646
+region = global
+token = default-token
+timeout = 6m
+
+[datacenter "dc1"]
+
+[datacenter "dc2"]
+address = http://20.0.0.2:4646
+region = asia
+`
+	nCfg, err := readNomadConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	dc1 := nCfg.datacenterConfig("dc1")
+	if dc1.Address != "http://10.0.0.1:4646" || dc1.Region != "global" || dc1.Token != "default-token" || dc1.Timeout != "6m" {
+		t.Fatalf("bad dc1 config: %#v", dc1)
+	}
+
+	dc2 := nCfg.datacenterConfig("dc2")
+	if dc2.Address != "http://20.0.0.2:4646" || dc2.Region != "asia" || dc2.Token != "default-token" {
+		t.Fatalf("bad dc2 config: %#v", dc2)
+	}
+
+	if nCfg.datacenterConfig("dc3") != nil {
+		t.Fatalf("expected no config for dc3")
+	}
+}
@@ -0,0 +1,357 @@
+package nomad
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang/glog"
+	"github.com/hashicorp/nomad/api"
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// Labels of a claim that select the Nomad datacenter of the volume
+const (
+	regionLabel     = "region"
+	datacenterLabel = "datacenter"
+)
+
+// nomadDatacenter is a Nomad datacenter where volumes are placed
+type nomadDatacenter struct {
+	// name of the datacenter. This is empty for the Nomad servers that are
+	// not specific to a datacenter.
+	name string
+
+	// region of the datacenter, if known
+	region string
+
+	// apis invoke the storage related APIs of the datacenter's Nomad
+	// servers
+	apis StorageApis
+
+	// jobTemplate renders the jobs of the datacenter's volumes. The
+	// built-in job is used if this is nil.
+	jobTemplate *jobTemplate
+}
+
+// newNomadDatacenters provides the datacenters of the config. The Nomad
+// servers of the [nomad] section or of the env variables are provided as
+// the only datacenter if the config does not have any.
+//
+// NOTE:
+//    A job template that is shared by the datacenters is loaded once.
+func newNomadDatacenters(apis Apis, nCfg *NomadConfig) (map[string]*nomadDatacenter, error) {
+	names := []string{""}
+	if nCfg != nil && len(nCfg.Datacenter) != 0 {
+		names = names[:0]
+		for name := range nCfg.Datacenter {
+			names = append(names, name)
+		}
+	}
+
+	dcs := map[string]*nomadDatacenter{}
+	templates := map[string]*jobTemplate{}
+
+	for _, name := range names {
+		nApiClient, err := apis.Client(name)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Nomad api client: %v", err)
+		}
+
+		nStorApis, err := apis.StorageApis(nApiClient)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Nomad storage operations instance: %v", err)
+		}
+
+		dc := &nomadDatacenter{
+			name: name,
+			apis: nStorApis,
+		}
+		if name != "" {
+			dc.region = nCfg.datacenterConfig(name).Region
+		}
+
+		if nCfg != nil {
+			if path := nCfg.datacenterConfig(name).JobTemplate; path != "" {
+				if templates[path] == nil {
+					templates[path], err = loadJobTemplate(path)
+					if err != nil {
+						return nil, err
+					}
+				}
+				dc.jobTemplate = templates[path]
+			}
+		}
+
+		dcs[name] = dc
+	}
+
+	return dcs, nil
+}
+
+// isDatacenterAgnostic tells if the Nomad servers that are not specific to a
+// datacenter place all the volumes
+func (n *NomadOrchestrator) isDatacenterAgnostic() bool {
+	_, found := n.datacenters[""]
+	return found && len(n.datacenters) == 1
+}
+
+// datacenterOf provides the datacenter that is named by the claim's labels.
+// The claim's region, if any, needs to be the datacenter's region.
+//
+// NOTE:
+//    The Nomad servers that are not specific to a datacenter place the
+// volumes of all the datacenters, if no datacenter is configured.
+func (n *NomadOrchestrator) datacenterOf(labels map[string]string) (*nomadDatacenter, error) {
+	if n.isDatacenterAgnostic() {
+		return n.datacenters[""], nil
+	}
+
+	name := labels[datacenterLabel]
+	if name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing datacenter in persistent volume claim"))
+	}
+
+	dc, found := n.datacenters[name]
+	if !found {
+		return nil, v1.NewInvalidError(fmt.Errorf("Nomad datacenter '%s' is not configured, configured datacenters are: %s", name, strings.Join(n.datacenterNames(), ", ")))
+	}
+
+	if region := labels[regionLabel]; region != "" && dc.region != "" && region != dc.region {
+		return nil, v1.NewInvalidError(fmt.Errorf("Nomad datacenter '%s' is in region '%s', not in region '%s'", name, dc.region, region))
+	}
+
+	return dc, nil
+}
+
+// candidateDatacenters provides the datacenters that may have a volume. The
+// datacenter named by the labels is the only candidate. Otherwise, all the
+// datacenters of the labels' region are candidates.
+func (n *NomadOrchestrator) candidateDatacenters(labels map[string]string) ([]*nomadDatacenter, error) {
+	if n.isDatacenterAgnostic() || labels[datacenterLabel] != "" {
+		dc, err := n.datacenterOf(labels)
+		if err != nil {
+			return nil, err
+		}
+		return []*nomadDatacenter{dc}, nil
+	}
+
+	region := labels[regionLabel]
+
+	var dcs []*nomadDatacenter
+	for _, name := range n.datacenterNames() {
+		dc := n.datacenters[name]
+		if region != "" && dc.region != "" && region != dc.region {
+			continue
+		}
+		dcs = append(dcs, dc)
+	}
+
+	if len(dcs) == 0 {
+		return nil, v1.NewInvalidError(fmt.Errorf("No Nomad datacenter is configured in region '%s'", region))
+	}
+
+	return dcs, nil
+}
+
+// datacenterNames provides the sorted names of the datacenters
+func (n *NomadOrchestrator) datacenterNames() []string {
+	var names []string
+	for name := range n.datacenters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// foundJob is a job that was found at a datacenter
+type foundJob struct {
+	dc  *nomadDatacenter
+	job *api.Job
+	qm  *api.QueryMeta
+}
+
+// lookupJob finds the job of the volume at the candidate datacenters of the
+// labels. A running job is preferred over a job that was stopped. The
+// errors of the datacenters are reported only if the job is not found.
+//
+// NOTE:
+//    The query options are used against the datacenter of the job. Hence,
+// a blocking query does not block at the datacenters that lack the job.
+func (n *NomadOrchestrator) lookupJob(labels map[string]string, jobName string, opts *api.QueryOptions) (*nomadDatacenter, *api.Job, *api.QueryMeta, error) {
+	dcs, err := n.candidateDatacenters(labels)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	if len(dcs) == 1 {
+		job, qm, err := dcs[0].apis.StorageInfo(jobName, opts)
+		if err != nil {
+			return nil, nil, nil, toVolumeError(err, jobName)
+		}
+		return dcs[0], job, qm, nil
+	}
+
+	var dead *foundJob
+	var lookupErr error
+
+	for _, dc := range dcs {
+		job, qm, err := dc.apis.StorageInfo(jobName, nil)
+		if err = toVolumeError(err, jobName); err != nil {
+			if !v1.IsNotFound(err) && lookupErr == nil {
+				lookupErr = err
+			}
+			continue
+		}
+
+		if !IsJobDead(job) {
+			return n.requery(&foundJob{dc, job, qm}, jobName, opts)
+		}
+
+		if dead == nil {
+			dead = &foundJob{dc, job, qm}
+		}
+	}
+
+	if dead != nil {
+		return n.requery(dead, jobName, opts)
+	}
+
+	if lookupErr != nil {
+		return nil, nil, nil, lookupErr
+	}
+
+	return nil, nil, nil, v1.NewNotFoundError(jobName, nil)
+}
+
+// runningJob finds a job of the volume, that was not stopped, at any of
+// the datacenters. The job is nil if none is found.
+//
+// NOTE:
+//    A datacenter other than the target one that can not be reached is
+// treated as not having the job. Hence, a datacenter that is down does not
+// fail the provisioning at the other datacenters.
+func (n *NomadOrchestrator) runningJob(target *nomadDatacenter, jobName, reqID string) (*api.Job, error) {
+	for _, name := range n.datacenterNames() {
+		dc := n.datacenters[name]
+
+		job, _, err := dc.apis.StorageInfo(jobName, nil)
+		err = toVolumeError(err, jobName)
+		if v1.IsNotFound(err) {
+			continue
+		}
+
+		if err != nil && dc != target {
+			glog.Warningf("Volume '%s' could not be looked up at datacenter '%s', assuming it is not there: %v request_id=%s", jobName, dc.name, err, reqID)
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !IsJobDead(job) {
+			return job, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// requery fetches the job again from its datacenter if the query options
+// block till the job changes. The job that was found is provided otherwise.
+func (n *NomadOrchestrator) requery(f *foundJob, jobName string, opts *api.QueryOptions) (*nomadDatacenter, *api.Job, *api.QueryMeta, error) {
+	if opts == nil || opts.WaitIndex == 0 {
+		return f.dc, f.job, f.qm, nil
+	}
+
+	job, qm, err := f.dc.apis.StorageInfo(jobName, opts)
+	if err != nil {
+		return nil, nil, nil, toVolumeError(err, jobName)
+	}
+	return f.dc, job, qm, nil
+}
+
+// listJobs lists the job stubs of the candidate datacenters of the labels.
+// The datacenters are queried concurrently. The stubs of a job are listed
+// once, even if several datacenters share the Nomad servers.
+//
+// NOTE:
+//    The indexes of independent Nomad clusters can not be compared. Hence,
+// a blocking query needs to be limited to a single datacenter.
+func (n *NomadOrchestrator) listJobs(labels map[string]string, opts *api.QueryOptions) ([]*api.JobListStub, *api.QueryMeta, error) {
+	dcs, err := n.candidateDatacenters(labels)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(dcs) > 1 && opts != nil && opts.WaitIndex != 0 {
+		return nil, nil, v1.NewInvalidError(fmt.Errorf("A blocking list needs a datacenter, configured datacenters are: %s", strings.Join(n.datacenterNames(), ", ")))
+	}
+
+	type listing struct {
+		stubs []*api.JobListStub
+		qm    *api.QueryMeta
+		err   error
+	}
+
+	listings := make([]listing, len(dcs))
+
+	var wg sync.WaitGroup
+	for i, dc := range dcs {
+		wg.Add(1)
+		go func(i int, dc *nomadDatacenter) {
+			defer wg.Done()
+			l := &listings[i]
+			l.stubs, l.qm, l.err = dc.apis.StorageList(opts)
+		}(i, dc)
+	}
+	wg.Wait()
+
+	seen := map[string]bool{}
+	var stubs []*api.JobListStub
+
+	for i, l := range listings {
+		if l.err != nil {
+			glog.Errorf("Nomad failed to list jobs of datacenter '%s': %v", dcs[i].name, l.err)
+			return nil, nil, l.err
+		}
+
+		for _, stub := range l.stubs {
+			if stub == nil || seen[stub.ID] {
+				continue
+			}
+			seen[stub.ID] = true
+			stubs = append(stubs, stub)
+		}
+	}
+
+	// The index of a single datacenter is the index of the list. There is
+	// no index otherwise.
+	if len(dcs) == 1 {
+		return stubs, listings[0].qm, nil
+	}
+
+	sort.Slice(stubs, func(i, j int) bool { return stubs[i].ID < stubs[j].ID })
+
+	return stubs, nil, nil
+}
+
+// queryLabels provides the region & the datacenter of the query options as
+// the labels that select the candidate datacenters
+func queryLabels(opts *v1.QueryOptions) map[string]string {
+	labels := map[string]string{}
+	if opts == nil {
+		return labels
+	}
+
+	if opts.Region != "" {
+		labels[regionLabel] = opts.Region
+	}
+	if opts.Datacenter != "" {
+		labels[datacenterLabel] = opts.Datacenter
+	}
+
+	return labels
+}
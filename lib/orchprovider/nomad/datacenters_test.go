@@ -0,0 +1,365 @@
+package nomad
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// dcStorageApis serves the jobs of a datacenter
+type dcStorageApis struct {
+	jobs    map[string]*api.Job
+	created []string
+
+	// down makes the datacenter's Nomad servers unreachable
+	down bool
+}
+
+func newDCStorageApis(names ...string) *dcStorageApis {
+	f := &dcStorageApis{jobs: map[string]*api.Job{}}
+	for _, name := range names {
+		job := runningJivaJob("5g")
+		job.Name = helper.StringToPtr(name)
+		f.jobs[name] = job
+	}
+	return f
+}
+
+// errDCDown is the error of the Nomad api client when Nomad can not be
+// reached
+var errDCDown = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func (f *dcStorageApis) CreateStorage(job *api.Job) (*api.Evaluation, error) {
+	if f.down {
+		return nil, errDCDown
+	}
+	f.created = append(f.created, *job.Name)
+	return &api.Evaluation{Status: "pending"}, nil
+}
+
+func (f *dcStorageApis) DeleteStorage(job *api.Job) (*api.Evaluation, error) {
+	return &api.Evaluation{Status: "pending"}, nil
+}
+
+func (f *dcStorageApis) UpdateStorage(job *api.Job) (*api.Evaluation, error) {
+	return &api.Evaluation{Status: "pending"}, nil
+}
+
+func (f *dcStorageApis) StorageInfo(jobName string, opts *api.QueryOptions) (*api.Job, *api.QueryMeta, error) {
+	if f.down {
+		return nil, nil, errDCDown
+	}
+	job, found := f.jobs[jobName]
+	if !found {
+		return nil, nil, fmt.Errorf("Unexpected response code: 404 (job not found)")
+	}
+	return job, &api.QueryMeta{LastIndex: 10}, nil
+}
+
+func (f *dcStorageApis) StorageList(opts *api.QueryOptions) ([]*api.JobListStub, *api.QueryMeta, error) {
+	var stubs []*api.JobListStub
+	for name := range f.jobs {
+		stubs = append(stubs, &api.JobListStub{
+			ID:     name,
+			Name:   name,
+			Status: "running",
+			JobSummary: &api.JobSummary{
+				Summary: map[string]api.TaskGroupSummary{jivaFeTaskGroup: {}},
+			},
+		})
+	}
+	return stubs, &api.QueryMeta{LastIndex: uint64(len(stubs))}, nil
+}
+
+func (f *dcStorageApis) StorageAllocations(jobName string) ([]*api.AllocationListStub, error) {
+	return nil, nil
+}
+
+func (f *dcStorageApis) SetStorageAttachment(job *api.Job, instanceID string) (bool, error) {
+	return true, nil
+}
+
+// dcOrchestrator provides a NomadOrchestrator of the datacenters dc1 in
+// region global & dc2 in region asia
+func dcOrchestrator(dc1, dc2 StorageApis) *NomadOrchestrator {
+	return &NomadOrchestrator{
+		datacenters: map[string]*nomadDatacenter{
+			"dc1": &nomadDatacenter{name: "dc1", region: "global", apis: dc1},
+			"dc2": &nomadDatacenter{name: "dc2", region: "asia", apis: dc2},
+		},
+	}
+}
+
+func dcClaim(name, region, dc string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = name
+	pvc.Labels = map[string]string{
+		"region":          region,
+		"datacenter":      dc,
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.101",
+		"jivabeip":        "172.28.128.102",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+	}
+	withJivaTaskLabels(pvc)
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("1Gi"),
+	}
+	return pvc
+}
+
+func TestStoragePlacementReq_Datacenters(t *testing.T) {
+	dc1, dc2 := newDCStorageApis(), newDCStorageApis()
+	n := dcOrchestrator(dc1, dc2)
+
+	if _, err := n.StoragePlacementReq(dcClaim("jivavol1", "asia", "dc2")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(dc1.created) != 0 || len(dc2.created) != 1 {
+		t.Fatalf("bad placement: dc1: %v, dc2: %v", dc1.created, dc2.created)
+	}
+
+	_, err := n.StoragePlacementReq(dcClaim("jivavol2", "global", "dc3"))
+	if !v1.IsInvalid(err) || !strings.Contains(err.Error(), "'dc3' is not configured") {
+		t.Fatalf("expected invalid error for unconfigured datacenter, got: %v", err)
+	}
+
+	_, err = n.StoragePlacementReq(dcClaim("jivavol2", "global", "dc2"))
+	if !v1.IsInvalid(err) || !strings.Contains(err.Error(), "region 'asia'") {
+		t.Fatalf("expected invalid error for region mismatch, got: %v", err)
+	}
+}
+
+func TestStoragePlacementReq_ExistsInOtherDatacenter(t *testing.T) {
+	dc1, dc2 := newDCStorageApis(), newDCStorageApis("jivavol1")
+	n := dcOrchestrator(dc1, dc2)
+
+	if _, err := n.StoragePlacementReq(dcClaim("jivavol1", "global", "dc1")); !v1.IsAlreadyExists(err) {
+		t.Fatalf("expected already exists error, got: %v", err)
+	}
+
+	if len(dc1.created) != 0 {
+		t.Fatalf("job should not be registered: %v", dc1.created)
+	}
+}
+
+func TestStoragePlacementReq_DatacenterDown(t *testing.T) {
+	dc1, dc2 := newDCStorageApis(), newDCStorageApis()
+	dc2.down = true
+	n := dcOrchestrator(dc1, dc2)
+
+	// The datacenter that is down does not fail the placement at the other
+	if _, err := n.StoragePlacementReq(dcClaim("jivavol1", "global", "dc1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(dc1.created) != 1 {
+		t.Fatalf("bad placement: dc1: %v", dc1.created)
+	}
+
+	if _, err := n.StoragePlacementReq(dcClaim("jivavol2", "asia", "dc2")); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+}
+
+func TestStorageInfoReq_LocatesDatacenter(t *testing.T) {
+	n := dcOrchestrator(newDCStorageApis(), newDCStorageApis("jivavol1"))
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+
+	pv, _, err := n.StorageInfoReq(pvc, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Name != "jivavol1" || pv.Status.Reason != "running" {
+		t.Fatalf("bad volume: %#v", pv)
+	}
+
+	pvc.Labels = map[string]string{"datacenter": "dc1"}
+	if _, _, err := n.StorageInfoReq(pvc, nil); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	pvc.Labels = map[string]string{"region": "global"}
+	if _, _, err := n.StorageInfoReq(pvc, nil); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+}
+
+func TestStorageListReq_Datacenters(t *testing.T) {
+	// The datacenters share jivavol2 as if they share the Nomad servers
+	n := dcOrchestrator(newDCStorageApis("jivavol3", "jivavol2"), newDCStorageApis("jivavol1", "jivavol2"))
+
+	list, err := n.StorageListReq(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var names []string
+	for _, pv := range list.Items {
+		names = append(names, pv.Name)
+	}
+
+	if strings.Join(names, ",") != "jivavol1,jivavol2,jivavol3" {
+		t.Fatalf("bad volumes: %v", names)
+	}
+
+	// The indexes of the datacenters can not be compared
+	if list.ResourceVersion != "" {
+		t.Fatalf("bad resource version: %s", list.ResourceVersion)
+	}
+
+	// A blocking list is limited to a single datacenter
+	if _, err := n.StorageListReq(&v1.QueryOptions{WaitIndex: 5}); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	list, err = n.StorageListReq(&v1.QueryOptions{Datacenter: "dc2", WaitIndex: 5})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(list.Items) != 2 || list.ResourceVersion != "2" {
+		t.Fatalf("bad list of dc2: %d volumes, resource version %s", len(list.Items), list.ResourceVersion)
+	}
+
+	// The region of the query selects its datacenters
+	list, err = n.StorageListReq(&v1.QueryOptions{Region: "global"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	names = nil
+	for _, pv := range list.Items {
+		names = append(names, pv.Name)
+	}
+
+	if strings.Join(names, ",") != "jivavol3,jivavol2" && strings.Join(names, ",") != "jivavol2,jivavol3" {
+		t.Fatalf("bad volumes of region global: %v", names)
+	}
+
+	if _, err := n.StorageListReq(&v1.QueryOptions{Datacenter: "dc3"}); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+}
+
+func TestReadNomadConfig_Datacenters(t *testing.T) {
+	config := `
+[datacenter "dc1"]
+address = http://10.0.0.1:4646
+
+[datacenter "dc2"]
+address = https://20.0.0.2:4646
+region = asia
+ca-cert = /etc/nomad/ca.pem
+tls-skip-verify = true
+token = secret
+`
+	nCfg, err := readNomadConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	dc2 := nCfg.Datacenter["dc2"]
+	if dc2 == nil || dc2.Region != "asia" || dc2.CACert != "/etc/nomad/ca.pem" || !dc2.TLSSkipVerify || dc2.Token != "secret" {
+		t.Fatalf("bad datacenter config: %#v", dc2)
+	}
+
+	if _, err := readNomadConfig(strings.NewReader("[datacenter \"dc1\"]\nregion = global\n")); err == nil {
+		t.Fatalf("expected an error for missing address")
+	}
+}
+
+func TestNomadClient_Token(t *testing.T) {
+	var token string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.Header.Get(nomadTokenHeader)
+		w.Header().Set("X-Nomad-Index", "1")
+		w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+
+	nApiClient, err := newNomadClientUtil("dc1", &DatacenterConfig{Address: srv.URL, Token: "secret"})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	client, err := nApiClient.Http()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, _, err := client.Jobs().List(nil); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if token != "secret" {
+		t.Fatalf("bad token: %q", token)
+	}
+}
+
+func TestNomadStorageApi_SetStorageAttachment(t *testing.T) {
+	var registered api.RegisterJobRequest
+	var jobIndex uint64 = 7
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/jobs" {
+			w.Header().Set("X-Nomad-Index", "1")
+			w.Write([]byte("{}"))
+			return
+		}
+
+		json.NewDecoder(r.Body).Decode(&registered)
+		if registered.JobModifyIndex != jobIndex {
+			w.WriteHeader(500)
+			fmt.Fprintf(w, "Enforcing job modify index %d: job exists with conflicting job modify index: %d", registered.JobModifyIndex, jobIndex)
+			return
+		}
+
+		jobIndex++
+		w.Header().Set("X-Nomad-Index", fmt.Sprint(jobIndex))
+		w.Write([]byte(`{"EvalID":"eval-1"}`))
+	}))
+	defer srv.Close()
+
+	nApiClient, err := newNomadClientUtil("dc1", &DatacenterConfig{Address: srv.URL})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	apis := &nomadStorageApi{nApiClient: nApiClient}
+
+	job := runningJivaJob("5g")
+	job.JobModifyIndex = helper.Uint64ToPtr(7)
+
+	if ok, err := apis.SetStorageAttachment(job, "i-1"); err != nil || !ok {
+		t.Fatalf("expected attachment to be recorded, got: %v %v", ok, err)
+	}
+
+	if !registered.EnforceIndex || registered.Job.Meta[jobMetaAttachedInstance] != "i-1" {
+		t.Fatalf("bad registration: %#v", registered)
+	}
+
+	// A job that changed since it was read is not overwritten
+	stale := runningJivaJob("5g")
+	stale.JobModifyIndex = helper.Uint64ToPtr(7)
+
+	if ok, err := apis.SetStorageAttachment(stale, "i-2"); err != nil || ok {
+		t.Fatalf("expected the registration to be rejected, got: %v %v", ok, err)
+	}
+}
@@ -0,0 +1,69 @@
+package nomad
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// errNoNomadClient is returned when Nomad's api client is not set
+var errNoNomadClient = errors.New("nomad api client not initialized")
+
+// nomadRespCodePrefix is the prefix of errors returned by Nomad's api client
+// when Nomad responds with a non 200 status code e.g.
+// "Unexpected response code: 404 (job not found)"
+const nomadRespCodePrefix = "Unexpected response code: "
+
+// nomadIndexConflict is part of the error returned by Nomad when a job is
+// registered against a modify index that is not its latest
+const nomadIndexConflict = "conflicting job modify index"
+
+// toVolumeError classifies an error returned by Nomad as a v1.VolumeError.
+// The name is of the volume that was being operated upon. Errors that can
+// not be classified are returned as-is.
+//
+// NOTE:
+//    Nomad's api client does not expose typed errors. Hence, its error
+// messages & the network errors of its http client are used to classify.
+func toVolumeError(err error, name string) error {
+	if err == nil {
+		return nil
+	}
+
+	if _, ok := err.(*v1.VolumeError); ok {
+		return err
+	}
+
+	if err == errNoNomadClient {
+		return v1.NewUnavailableError(err)
+	}
+
+	// The http client of Nomad's api client could not reach Nomad
+	if _, ok := err.(net.Error); ok {
+		return v1.NewUnavailableError(err)
+	}
+
+	msg := err.Error()
+	if !strings.HasPrefix(msg, nomadRespCodePrefix) {
+		return err
+	}
+
+	// The job was updated by another request in the meantime
+	if strings.Contains(msg, nomadIndexConflict) {
+		return v1.NewConflictError(err)
+	}
+
+	code := strings.TrimPrefix(msg, nomadRespCodePrefix)
+	switch {
+	case strings.HasPrefix(code, "404"):
+		return v1.NewNotFoundError(name, nil)
+	case strings.HasPrefix(code, "400"):
+		return v1.NewInvalidError(err)
+	case strings.HasPrefix(code, "5"):
+		return v1.NewUnavailableError(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,35 @@
+package nomad
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+func TestToVolumeError(t *testing.T) {
+	cases := []struct {
+		err    error
+		reason v1.ErrorReason
+	}{
+		{fmt.Errorf("Unexpected response code: 404 (job not found)"), v1.ReasonNotFound},
+		{fmt.Errorf("Unexpected response code: 400 (invalid job)"), v1.ReasonInvalid},
+		{fmt.Errorf("Unexpected response code: 500 (rpc error: No cluster leader)"), v1.ReasonUnavailable},
+		{fmt.Errorf("Unexpected response code: 500 (Enforcing job modify index 10: job exists with conflicting job modify index: 12)"), v1.ReasonConflict},
+		{&net.OpError{Op: "dial", Net: "tcp", Err: fmt.Errorf("connection refused")}, v1.ReasonUnavailable},
+		{errNoNomadClient, v1.ReasonUnavailable},
+		{v1.NewAlreadyExistsError("myvol"), v1.ReasonAlreadyExists},
+		{fmt.Errorf("some other error"), v1.ReasonUnknown},
+	}
+
+	for _, c := range cases {
+		if reason := v1.ReasonForError(toVolumeError(c.err, "myvol")); reason != c.reason {
+			t.Fatalf("bad reason for '%v': expected: %s, got: %s", c.err, c.reason, reason)
+		}
+	}
+
+	if toVolumeError(nil, "myvol") != nil {
+		t.Fatalf("expected nil error")
+	}
+}
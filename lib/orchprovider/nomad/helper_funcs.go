@@ -2,15 +2,68 @@ package nomad
 
 import (
 	"fmt"
+	"net/url"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/nomad/helper"
 	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
+const (
+	// Names of the task groups that constitute a jiva volume
+	// placed as a Nomad job
+	jivaFeTaskGroup = "fepod"
+	jivaBeTaskGroup = "bepod"
+
+	// Env variables of the jiva tasks that carry the volume size
+	jivaCtlVolSizeEnv = "JIVA_CTL_VOLSIZE"
+	jivaRepVolSizeEnv = "JIVA_REP_VOLSIZE"
+
+	// jivaRepIPEnv is the env variable of the jiva replica task that carries
+	// the replica's ip
+	jivaRepIPEnv = "JIVA_REP_IP"
+
+	// jivaReplicaPending is the status of a jiva replica that is yet to be
+	// allocated
+	jivaReplicaPending = "pending"
+
+	// Keys of the job's Meta that carry the iSCSI target of a jiva volume
+	jobMetaTargetPortal = "targetportal"
+	jobMetaIQN          = "iqn"
+
+	// jobMetaAttachedInstance is the key of the job's Meta that records the
+	// instance the jiva volume is attached to
+	jobMetaAttachedInstance = "attachedinstance"
+
+	// Keys of the job's Meta that record the source of a cloned jiva volume
+	jobMetaCloneSourceVolume   = "clonesourcevolume"
+	jobMetaCloneSourceSnapshot = "clonesourcesnapshot"
+
+	// Env variables of the jiva replica task that seed a cloned volume from
+	// the source volume's controller
+	jivaRepCloneIPEnv   = "JIVA_REP_CLONE_IP"
+	jivaRepCloneSnapEnv = "JIVA_REP_CLONE_SNAPSHOT"
+)
+
+// jivaSizeUnits are the binary units understood by jiva for volume sizes
+var jivaSizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"p", 1 << 50},
+	{"t", 1 << 40},
+	{"g", 1 << 30},
+	{"m", 1 << 20},
+	{"k", 1 << 10},
+}
+
 // Get the job name from a persistent volume claim
 func PvcToJobName(pvc *v1.PersistentVolumeClaim) (string, error) {
 
@@ -25,8 +78,67 @@ func PvcToJobName(pvc *v1.PersistentVolumeClaim) (string, error) {
 	return pvc.Name, nil
 }
 
-// Transform a PersistentVolumeClaim type to Nomad job type
-func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
+// jivaJobSpec has the settings of a jiva volume's job that are derived from
+// the volume's claim. These are the variables of a job template.
+type jivaJobSpec struct {
+	// Name of the volume & its job
+	Name string
+
+	// Region & Datacenter of the job
+	Region     string
+	Datacenter string
+
+	// Size of the volume as understood by jiva e.g. 5g
+	Size string
+
+	// Settings of the jiva frontend i.e. the controller
+	FeVersion   string
+	FeNetwork   string
+	FeIP        string
+	FeSubnet    string
+	FeInterface string
+
+	// Replicas of the volume, each with its own task group
+	Replicas []jivaReplicaSpec
+
+	// Settings of the jiva tasks that can be tuned via labels
+	CtlArtifact     string
+	RepArtifact     string
+	RepStoreRoot    string
+	CtlCPU          int
+	CtlMemoryMB     int
+	RepCPU          int
+	RepMemoryMB     int
+	RestartAttempts int
+	RestartInterval time.Duration
+	RestartDelay    time.Duration
+	RestartMode     string
+
+	// Source of a cloned volume. These are empty if the volume is not a
+	// clone.
+	CloneSourceVolume   string
+	CloneSourceSnapshot string
+	CloneCtlIP          string
+
+	// CloneRepArtifact launches the replica of a clone s.t. it is seeded
+	// from the source snapshot. The replicas of a clone can not be launched
+	// via RepArtifact since these would start empty.
+	CloneRepArtifact string
+
+	// Labels & Annotations of the claim
+	Labels      map[string]string
+	Annotations map[string]string
+}
+
+// jivaReplicaSpec has the settings of a jiva replica
+type jivaReplicaSpec struct {
+	TaskGroup string
+	Task      string
+	IP        string
+}
+
+// Get the settings of a jiva volume's job from the volume's claim
+func pvcToJivaJobSpec(pvc *v1.PersistentVolumeClaim) (*jivaJobSpec, error) {
 
 	if pvc == nil {
 		return nil, fmt.Errorf("Nil persistent volume claim provided")
@@ -72,28 +184,216 @@ func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
 		return nil, fmt.Errorf("Missing jiva fe interface in persistent volume claim")
 	}
 
+	jivaVolSize, err := PvcToJivaVolSize(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := &jivaJobSpec{
+		Name:        pvc.Name,
+		Region:      pvc.Labels["region"],
+		Datacenter:  pvc.Labels["datacenter"],
+		Size:        jivaVolSize,
+		FeVersion:   pvc.Labels["jivafeversion"],
+		FeNetwork:   pvc.Labels["jivafenetwork"],
+		FeIP:        pvc.Labels["jivafeip"],
+		FeSubnet:    pvc.Labels["jivafesubnet"],
+		FeInterface: pvc.Labels["jivafeinterface"],
+		Labels:      pvc.Labels,
+		Annotations: pvc.Annotations,
+	}
+
+	// Each replica has its own ip
+	replicas, err := intLabel(pvc, "jivareplicas")
+	if err != nil {
+		return nil, err
+	}
+
+	if replicas < 1 {
+		return nil, fmt.Errorf("Invalid jivareplicas '%d' in persistent volume claim", replicas)
+	}
+
+	jivaBeIPs := strings.Split(pvc.Labels["jivabeip"], ",")
+	if len(jivaBeIPs) != replicas {
+		return nil, fmt.Errorf("Expected %d jiva be ips in persistent volume claim, got %d", replicas, len(jivaBeIPs))
+	}
+
+	for i, jivaBeIP := range jivaBeIPs {
+		spec.Replicas = append(spec.Replicas, jivaReplicaSpec{
+			TaskGroup: jivaBeTaskGroupName(i + 1),
+			Task:      fmt.Sprintf("be%d", i+1),
+			IP:        strings.TrimSpace(jivaBeIP),
+		})
+	}
+
+	spec.CloneSourceVolume = pvc.Annotations[v1.CloneSourceVolumeAnnotation]
+	spec.CloneSourceSnapshot = pvc.Annotations[v1.CloneSourceSnapshotAnnotation]
+	spec.CloneCtlIP = pvc.Labels["jivaclonectlip"]
+
+	if spec.CloneSourceVolume != "" && (spec.CloneSourceSnapshot == "" || spec.CloneCtlIP == "") {
+		return nil, fmt.Errorf("Missing source snapshot or jiva clone ctl ip of clone '%s'", pvc.Name)
+	}
+
+	// The jiva tasks are tuned via labels. These are set by the jiva volume
+	// plugin from its config if the claim does not set them.
+	var missing []string
+	required := func(label string) string {
+		v := pvc.Labels[label]
+		if v == "" {
+			missing = append(missing, label)
+		}
+		return v
+	}
+
+	spec.CtlArtifact = required("jivafeartifact")
+	spec.RepArtifact = required("jivarepartifact")
+	spec.CloneRepArtifact = pvc.Labels["jivarepcloneartifact"]
+	spec.RepStoreRoot = strings.TrimSuffix(required("jivarepstoreroot"), "/")
+	spec.RestartMode = required("jivarestartmode")
+
+	ints := map[string]*int{
+		"jivafecpu":           &spec.CtlCPU,
+		"jivafememory":        &spec.CtlMemoryMB,
+		"jivarepcpu":          &spec.RepCPU,
+		"jivarepmemory":       &spec.RepMemoryMB,
+		"jivarestartattempts": &spec.RestartAttempts,
+	}
+	for label, field := range ints {
+		if v := required(label); v != "" {
+			if *field, err = strconv.Atoi(v); err != nil {
+				return nil, v1.NewInvalidError(fmt.Errorf("Invalid %s '%s' in persistent volume claim", label, v))
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"jivarestartinterval": &spec.RestartInterval,
+		"jivarestartdelay":    &spec.RestartDelay,
+	}
+	for label, field := range durations {
+		if v := required(label); v != "" {
+			if *field, err = time.ParseDuration(v); err != nil {
+				return nil, v1.NewInvalidError(fmt.Errorf("Invalid %s '%s' in persistent volume claim", label, v))
+			}
+		}
+	}
+
+	if len(missing) != 0 {
+		sort.Strings(missing)
+		return nil, v1.NewInvalidError(fmt.Errorf("Missing %s in persistent volume claim", strings.Join(missing, ", ")))
+	}
+
+	return spec, nil
+}
+
+// Transform a PersistentVolumeClaim type to Nomad job type
+//
+// NOTE:
+//    This is the built-in job of a jiva volume. It is used if the
+// datacenter of the volume is not configured with a job template.
+func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
+
+	spec, err := pvcToJivaJobSpec(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkCloneRepArtifact(spec); err != nil {
+		return nil, err
+	}
+
+	// The replicas of a clone are launched by an artifact that seeds them
+	// from the source snapshot. The default artifact would start them empty.
+	repArtifact := spec.RepArtifact
+	repCommand := "launch-jiva-rep-with-ip"
+	if spec.CloneSourceVolume != "" {
+		repArtifact = spec.CloneRepArtifact
+		repCommand, err = artifactCommand(spec.CloneRepArtifact)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// TODO
 	// ID is same as Name currently
 	// Do we need to think on it ?
-	jobName := helper.StringToPtr(pvc.Name)
-	region := helper.StringToPtr(pvc.Labels["region"])
-	dc := pvc.Labels["datacenter"]
+	jobName := helper.StringToPtr(spec.Name)
+	region := helper.StringToPtr(spec.Region)
+	dc := spec.Datacenter
 
-	jivaGroupName := "pod"
-	jivaVolName := pvc.Name
-	jivaVolSize := "5g"
+	jivaVolName := spec.Name
+	jivaVolSize := spec.Size
 
-	feTaskGroup := "fe" + jivaGroupName
+	feTaskGroup := jivaFeTaskGroup
 	feTaskName := "fe1"
-	beTaskGroup := "be" + jivaGroupName
-	beTaskName := "be1"
 
-	jivaFeVersion := pvc.Labels["jivafeversion"]
-	jivaFeNetwork := pvc.Labels["jivafenetwork"]
-	jivaFeIP := pvc.Labels["jivafeip"]
-	jivaBeIP := pvc.Labels["jivabeip"]
-	jivaFeSubnet := pvc.Labels["jivafesubnet"]
-	jivaFeInterface := pvc.Labels["jivafeinterface"]
+	jivaFeVersion := spec.FeVersion
+	jivaFeNetwork := spec.FeNetwork
+	jivaFeIP := spec.FeIP
+	jivaFeSubnet := spec.FeSubnet
+	jivaFeInterface := spec.FeInterface
+
+	restartPolicy := func() *api.RestartPolicy {
+		return &api.RestartPolicy{
+			Attempts: helper.IntToPtr(spec.RestartAttempts),
+			Interval: helper.TimeToPtr(spec.RestartInterval),
+			Delay:    helper.TimeToPtr(spec.RestartDelay),
+			Mode:     helper.StringToPtr(spec.RestartMode),
+		}
+	}
+
+	// replicaTaskGroup provides the task group of the jiva replica. Each
+	// replica has its own store.
+	replicaTaskGroup := func(rep jivaReplicaSpec) *api.TaskGroup {
+		beTaskGroup := rep.TaskGroup
+		beTaskName := rep.Task
+
+		return &api.TaskGroup{
+			Name:          helper.StringToPtr(beTaskGroup),
+			Count:         helper.IntToPtr(1),
+			RestartPolicy: restartPolicy(),
+			Tasks: []*api.Task{
+				&api.Task{
+					Name:   beTaskName,
+					Driver: "raw_exec",
+					Resources: &api.Resources{
+						CPU:      helper.IntToPtr(spec.RepCPU),
+						MemoryMB: helper.IntToPtr(spec.RepMemoryMB),
+						Networks: []*api.NetworkResource{
+							&api.NetworkResource{
+								MBits: helper.IntToPtr(400),
+							},
+						},
+					},
+					Env: map[string]string{
+						"JIVA_REP_NAME":     pvc.Name + "-" + beTaskGroup + "-" + beTaskName,
+						"JIVA_CTL_IP":       jivaFeIP,
+						"JIVA_REP_VOLNAME":  jivaVolName,
+						"JIVA_REP_VOLSIZE":  jivaVolSize,
+						"JIVA_REP_VOLSTORE": spec.RepStoreRoot + "/" + pvc.Name + beTaskGroup + "/" + beTaskName,
+						"JIVA_REP_VERSION":  jivaFeVersion,
+						"JIVA_REP_NETWORK":  jivaFeNetwork,
+						"JIVA_REP_IFACE":    jivaFeInterface,
+						jivaRepIPEnv:        rep.IP,
+						"JIVA_REP_SUBNET":   jivaFeSubnet,
+					},
+					Artifacts: []*api.TaskArtifact{
+						&api.TaskArtifact{
+							GetterSource: helper.StringToPtr(repArtifact),
+							RelativeDest: helper.StringToPtr("local/"),
+						},
+					},
+					Config: map[string]interface{}{
+						"command": repCommand,
+					},
+					LogConfig: &api.LogConfig{
+						MaxFiles:      helper.IntToPtr(3),
+						MaxFileSizeMB: helper.IntToPtr(1),
+					},
+				},
+			},
+		}
+	}
 
 	// TODO
 	// Transformation from pvc or pv to nomad types & vice-versa:
@@ -108,7 +408,7 @@ func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
 	// Hardcoded logic all the way
 	// Nomad specific defaults, hardcoding is OK.
 	// However, volume plugin specific stuff is BAD
-	return &api.Job{
+	job := &api.Job{
 		Region:      region,
 		Name:        jobName,
 		ID:          jobName,
@@ -121,27 +421,22 @@ func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
 		// Meta information will be used to pass on the metadata from
 		// nomad to clients of mayaserver.
 		Meta: map[string]string{
-			"targetportal": jivaFeIP + ":3260",
-			"iqn":          "iqn.2016-09.com.openebs.jiva:" + jivaVolName,
+			jobMetaTargetPortal: jivaFeIP + ":3260",
+			jobMetaIQN:          "iqn.2016-09.com.openebs.jiva:" + jivaVolName,
 		},
 		TaskGroups: []*api.TaskGroup{
 			// jiva frontend
 			&api.TaskGroup{
-				Name:  helper.StringToPtr(feTaskGroup),
-				Count: helper.IntToPtr(1),
-				RestartPolicy: &api.RestartPolicy{
-					Attempts: helper.IntToPtr(3),
-					Interval: helper.TimeToPtr(5 * time.Minute),
-					Delay:    helper.TimeToPtr(25 * time.Second),
-					Mode:     helper.StringToPtr("delay"),
-				},
+				Name:          helper.StringToPtr(feTaskGroup),
+				Count:         helper.IntToPtr(1),
+				RestartPolicy: restartPolicy(),
 				Tasks: []*api.Task{
 					&api.Task{
 						Name:   feTaskName,
 						Driver: "raw_exec",
 						Resources: &api.Resources{
-							CPU:      helper.IntToPtr(500),
-							MemoryMB: helper.IntToPtr(256),
+							CPU:      helper.IntToPtr(spec.CtlCPU),
+							MemoryMB: helper.IntToPtr(spec.CtlMemoryMB),
 							Networks: []*api.NetworkResource{
 								&api.NetworkResource{
 									MBits: helper.IntToPtr(400),
@@ -159,7 +454,7 @@ func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
 						},
 						Artifacts: []*api.TaskArtifact{
 							&api.TaskArtifact{
-								GetterSource: helper.StringToPtr("https://raw.githubusercontent.com/openebs/jiva/master/scripts/launch-jiva-ctl-with-ip"),
+								GetterSource: helper.StringToPtr(spec.CtlArtifact),
 								RelativeDest: helper.StringToPtr("local/"),
 							},
 						},
@@ -173,59 +468,24 @@ func PvcToJob(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
 					},
 				},
 			},
-			// jiva replica
-			&api.TaskGroup{
-				Name:  helper.StringToPtr(beTaskGroup),
-				Count: helper.IntToPtr(1),
-				RestartPolicy: &api.RestartPolicy{
-					Attempts: helper.IntToPtr(3),
-					Interval: helper.TimeToPtr(5 * time.Minute),
-					Delay:    helper.TimeToPtr(25 * time.Second),
-					Mode:     helper.StringToPtr("delay"),
-				},
-				Tasks: []*api.Task{
-					&api.Task{
-						Name:   beTaskName,
-						Driver: "raw_exec",
-						Resources: &api.Resources{
-							CPU:      helper.IntToPtr(500),
-							MemoryMB: helper.IntToPtr(256),
-							Networks: []*api.NetworkResource{
-								&api.NetworkResource{
-									MBits: helper.IntToPtr(400),
-								},
-							},
-						},
-						Env: map[string]string{
-							"JIVA_REP_NAME":     pvc.Name + "-" + beTaskGroup + "-" + beTaskName,
-							"JIVA_CTL_IP":       jivaFeIP,
-							"JIVA_REP_VOLNAME":  jivaVolName,
-							"JIVA_REP_VOLSIZE":  jivaVolSize,
-							"JIVA_REP_VOLSTORE": "/tmp/jiva/" + pvc.Name + beTaskGroup + "/" + beTaskName,
-							"JIVA_REP_VERSION":  jivaFeVersion,
-							"JIVA_REP_NETWORK":  jivaFeNetwork,
-							"JIVA_REP_IFACE":    jivaFeInterface,
-							"JIVA_REP_IP":       jivaBeIP,
-							"JIVA_REP_SUBNET":   jivaFeSubnet,
-						},
-						Artifacts: []*api.TaskArtifact{
-							&api.TaskArtifact{
-								GetterSource: helper.StringToPtr("https://raw.githubusercontent.com/openebs/jiva/master/scripts/launch-jiva-rep-with-ip"),
-								RelativeDest: helper.StringToPtr("local/"),
-							},
-						},
-						Config: map[string]interface{}{
-							"command": "launch-jiva-rep-with-ip",
-						},
-						LogConfig: &api.LogConfig{
-							MaxFiles:      helper.IntToPtr(3),
-							MaxFileSizeMB: helper.IntToPtr(1),
-						},
-					},
-				},
-			},
 		},
-	}, nil
+	}
+
+	// jiva replicas
+	for _, rep := range spec.Replicas {
+		job.TaskGroups = append(job.TaskGroups, replicaTaskGroup(rep))
+	}
+
+	// The replicas of a volume are placed on different nodes
+	if len(spec.Replicas) > 1 {
+		job.Constraints = append(job.Constraints, api.NewConstraint("", structs.ConstraintDistinctHosts, "true"))
+	}
+
+	if spec.CloneSourceVolume != "" {
+		SetJobCloneSource(job, spec.CloneSourceVolume, spec.CloneSourceSnapshot, spec.CloneCtlIP)
+	}
+
+	return job, nil
 }
 
 // TODO
@@ -309,9 +569,392 @@ func JobToPv(job *api.Job) (*v1.PersistentVolume, error) {
 	}
 	pv.Status = pvs
 
+	pv.Spec.Capacity = JobToCapacity(job)
+
 	if *job.Status == structs.JobStatusRunning {
 		pv.Annotations = job.Meta
 	}
 
+	// The provenance of a clone is known irrespective of the job's status
+	SetPvCloneSource(pv, job)
+
 	return pv, nil
 }
+
+// Set the source of a cloned jiva volume, if any, from the job's Meta
+// against the volume's annotations
+func SetPvCloneSource(pv *v1.PersistentVolume, job *api.Job) {
+	srcVol := job.Meta[jobMetaCloneSourceVolume]
+	if srcVol == "" {
+		return
+	}
+
+	annotations := map[string]string{}
+	for k, v := range pv.Annotations {
+		annotations[k] = v
+	}
+	annotations[v1.CloneSourceVolumeAnnotation] = srcVol
+	annotations[v1.CloneSourceSnapshotAnnotation] = job.Meta[jobMetaCloneSourceSnapshot]
+	pv.Annotations = annotations
+}
+
+// Verify if the job has been stopped i.e. deregistered but not yet garbage
+// collected by Nomad.
+func IsJobDead(job *api.Job) bool {
+	return job != nil && job.Status != nil && *job.Status == structs.JobStatusDead
+}
+
+// Verify if the job stub represents a jiva volume.
+//
+// NOTE:
+//    A job stub does not carry the job's meta information. Hence, the
+// presence of jiva frontend task group in the job's summary is used.
+func IsJivaJobStub(stub *api.JobListStub) bool {
+	if stub == nil || stub.JobSummary == nil {
+		return false
+	}
+
+	_, found := stub.JobSummary.Summary[jivaFeTaskGroup]
+	return found
+}
+
+// Transform a Nomad job stub to a PersistentVolume
+func JobStubToPv(stub *api.JobListStub) (*v1.PersistentVolume, error) {
+	if stub == nil {
+		return nil, fmt.Errorf("Nil job stub provided")
+	}
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = stub.Name
+
+	pvs := v1.PersistentVolumeStatus{
+		Message: stub.StatusDescription,
+		Reason:  stub.Status,
+	}
+	pv.Status = pvs
+
+	return pv, nil
+}
+
+// Transform the volume query options to Nomad's query options
+func QueryOptionsToNomad(opts *v1.QueryOptions) *api.QueryOptions {
+	qOpts := &api.QueryOptions{}
+	if opts == nil {
+		return qOpts
+	}
+
+	qOpts.Region = opts.Region
+	qOpts.Prefix = opts.Prefix
+	qOpts.AllowStale = opts.AllowStale
+	qOpts.WaitIndex = opts.WaitIndex
+	qOpts.WaitTime = opts.WaitTime
+
+	// The timeout of the Nomad client is verified against this bound
+	if qOpts.WaitTime > nomadMaxQueryTime {
+		qOpts.WaitTime = nomadMaxQueryTime
+	}
+
+	return qOpts
+}
+
+// Transform Nomad's query meta to the volume query meta
+func QueryMetaFromNomad(qm *api.QueryMeta) *v1.QueryMeta {
+	if qm == nil {
+		return &v1.QueryMeta{}
+	}
+
+	return &v1.QueryMeta{
+		LastIndex:   qm.LastIndex,
+		LastContact: qm.LastContact,
+	}
+}
+
+// Transform a list of Nomad job stubs to a PersistentVolumeList. Job stubs
+// that do not represent a jiva volume are skipped.
+func JobStubsToPvList(stubs []*api.JobListStub, qm *api.QueryMeta) (*v1.PersistentVolumeList, error) {
+
+	pvList := &v1.PersistentVolumeList{
+		Items: []v1.PersistentVolume{},
+	}
+
+	if qm != nil {
+		pvList.ResourceVersion = strconv.FormatUint(qm.LastIndex, 10)
+	}
+
+	for _, stub := range stubs {
+		if !IsJivaJobStub(stub) {
+			continue
+		}
+
+		pv, err := JobStubToPv(stub)
+		if err != nil {
+			return nil, err
+		}
+
+		pvList.Items = append(pvList.Items, *pv)
+	}
+
+	return pvList, nil
+}
+
+// Get the jiva volume size requested by the claim. The size is set by the
+// jiva volume plugin from its config if the claim does not request any.
+func PvcToJivaVolSize(pvc *v1.PersistentVolumeClaim) (string, error) {
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return "", fmt.Errorf("Missing storage size in persistent volume claim")
+	}
+
+	return QuantityToJivaVolSize(size)
+}
+
+// Transform a storage quantity to the volume size understood by jiva e.g.
+// 10Gi is transformed to 10g. The size is provided in bytes if it is not a
+// multiple of any unit.
+func QuantityToJivaVolSize(size resource.Quantity) (string, error) {
+	bytes := size.Value()
+	if bytes <= 0 {
+		return "", fmt.Errorf("Invalid volume size '%s'", size.String())
+	}
+
+	for _, unit := range jivaSizeUnits {
+		if bytes%unit.bytes == 0 {
+			return strconv.FormatInt(bytes/unit.bytes, 10) + unit.suffix, nil
+		}
+	}
+
+	return strconv.FormatInt(bytes, 10), nil
+}
+
+// Transform a jiva volume size e.g. 5g to bytes
+func JivaVolSizeToBytes(size string) (int64, error) {
+	num := strings.ToLower(strings.TrimSpace(size))
+	multiplier := int64(1)
+
+	for _, unit := range jivaSizeUnits {
+		if strings.HasSuffix(num, unit.suffix) {
+			num = strings.TrimSuffix(num, unit.suffix)
+			multiplier = unit.bytes
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Invalid jiva volume size '%s'", size)
+	}
+
+	return n * multiplier, nil
+}
+
+// Get the jiva volume size from the job's jiva frontend task
+func JobJivaVolSize(job *api.Job) (string, bool) {
+	for _, tg := range job.TaskGroups {
+		if tg.Name == nil || *tg.Name != jivaFeTaskGroup {
+			continue
+		}
+
+		for _, task := range tg.Tasks {
+			if size := task.Env[jivaCtlVolSizeEnv]; size != "" {
+				return size, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+// Get the capacity of the jiva volume from the job's jiva frontend task.
+// Returns nil if the size is not known.
+func JobToCapacity(job *api.Job) v1.ResourceList {
+	size, found := JobJivaVolSize(job)
+	if !found {
+		return nil
+	}
+
+	bytes, err := JivaVolSizeToBytes(size)
+	if err != nil {
+		return nil
+	}
+
+	return v1.ResourceList{
+		v1.ResourceStorage: *resource.NewQuantity(bytes, resource.BinarySI),
+	}
+}
+
+// Set the jiva volume size against the job's jiva frontend & replica tasks
+func SetJobJivaVolSize(job *api.Job, size string) {
+	for _, tg := range job.TaskGroups {
+		for _, task := range tg.Tasks {
+			if _, found := task.Env[jivaCtlVolSizeEnv]; found {
+				task.Env[jivaCtlVolSizeEnv] = size
+			}
+			if _, found := task.Env[jivaRepVolSizeEnv]; found {
+				task.Env[jivaRepVolSizeEnv] = size
+			}
+		}
+	}
+}
+
+// Get the name of the task group of the jiva replica at the 1-based index.
+// The first replica retains the name of a single replica volume's task group.
+func jivaBeTaskGroupName(i int) string {
+	if i == 1 {
+		return jivaBeTaskGroup
+	}
+
+	return fmt.Sprintf("%s%d", jivaBeTaskGroup, i)
+}
+
+// Get the status of each jiva replica of the job from the job's allocations.
+// A replica is reported by its most recent allocation & is pending if it
+// has none.
+func JobAllocsToReplicaStatus(job *api.Job, allocs []*api.AllocationListStub) []v1.VolumeReplicaStatus {
+	var replicas []v1.VolumeReplicaStatus
+
+	for _, tg := range job.TaskGroups {
+		if tg.Name == nil || !strings.HasPrefix(*tg.Name, jivaBeTaskGroup) {
+			continue
+		}
+
+		rs := v1.VolumeReplicaStatus{
+			Name:   *tg.Name,
+			Status: jivaReplicaPending,
+		}
+
+		for _, task := range tg.Tasks {
+			if ip := task.Env[jivaRepIPEnv]; ip != "" {
+				rs.Address = ip
+			}
+		}
+
+		var latest *api.AllocationListStub
+		for _, alloc := range allocs {
+			if alloc.TaskGroup == *tg.Name && (latest == nil || alloc.CreateIndex > latest.CreateIndex) {
+				latest = alloc
+			}
+		}
+
+		if latest != nil {
+			rs.NodeID = latest.NodeID
+			rs.Status = latest.ClientStatus
+		}
+
+		replicas = append(replicas, rs)
+	}
+
+	return replicas
+}
+
+// Get the volume attachment from the job's Meta. Returns false if the
+// volume is not attached to any instance.
+func JobToVolumeAttachment(job *api.Job) (*v1.VolumeAttachment, bool) {
+	instanceID := job.Meta[jobMetaAttachedInstance]
+	if instanceID == "" {
+		return nil, false
+	}
+
+	va := &v1.VolumeAttachment{}
+	va.Name = *job.Name + "-" + instanceID
+	va.Spec.VolumeName = *job.Name
+	va.Spec.InstanceID = instanceID
+	va.Status.TargetPortal = job.Meta[jobMetaTargetPortal]
+	va.Status.IQN = job.Meta[jobMetaIQN]
+
+	return va, true
+}
+
+// Record the instance the jiva volume is attached to against the job's
+// Meta. An empty instance id removes the record.
+func SetJobAttachedInstance(job *api.Job, instanceID string) {
+	if instanceID == "" {
+		delete(job.Meta, jobMetaAttachedInstance)
+		return
+	}
+
+	if job.Meta == nil {
+		job.Meta = map[string]string{}
+	}
+	job.Meta[jobMetaAttachedInstance] = instanceID
+}
+
+// Get the claim of the attachment's volume
+func VolumeAttachmentToPvc(va *v1.VolumeAttachment) (*v1.PersistentVolumeClaim, error) {
+
+	if va == nil {
+		return nil, fmt.Errorf("Nil volume attachment provided")
+	}
+
+	if va.Spec.InstanceID == "" {
+		return nil, fmt.Errorf("Missing instance id in attachment of volume '%s'", va.Spec.VolumeName)
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = va.Spec.VolumeName
+	pvc.Labels = va.Labels
+	pvc.Annotations = va.Annotations
+
+	return pvc, nil
+}
+
+// Seed the job's jiva replica tasks from the snapshot of the source volume
+// that is served by the controller at the ip. The source is recorded in the
+// job's Meta.
+func SetJobCloneSource(job *api.Job, srcVol, srcSnap, ctlIP string) {
+	for _, tg := range job.TaskGroups {
+		for _, task := range tg.Tasks {
+			if _, found := task.Env[jivaRepVolSizeEnv]; found {
+				task.Env[jivaRepCloneIPEnv] = ctlIP
+				task.Env[jivaRepCloneSnapEnv] = srcSnap
+			}
+		}
+	}
+
+	if job.Meta == nil {
+		job.Meta = map[string]string{}
+	}
+	job.Meta[jobMetaCloneSourceVolume] = srcVol
+	job.Meta[jobMetaCloneSourceSnapshot] = srcSnap
+}
+
+// Check that a clone has the jiva replica artifact that seeds its replicas
+// from the source snapshot
+func checkCloneRepArtifact(spec *jivaJobSpec) error {
+	if spec.CloneSourceVolume != "" && spec.CloneRepArtifact == "" {
+		return fmt.Errorf("Clone '%s' needs a jiva replica artifact that seeds it from snapshot '%s' of volume '%s', none is configured", spec.Name, spec.CloneSourceSnapshot, spec.CloneSourceVolume)
+	}
+
+	return nil
+}
+
+// Get the command that is run from the artifact i.e. the name of the file
+// the artifact is downloaded as
+func artifactCommand(artifact string) (string, error) {
+	u, err := url.Parse(artifact)
+	if err != nil {
+		return "", fmt.Errorf("Invalid artifact '%s': %v", artifact, err)
+	}
+
+	command := path.Base(u.Path)
+	if command == "." || command == "/" {
+		return "", fmt.Errorf("Invalid artifact '%s': missing file name", artifact)
+	}
+
+	return command, nil
+}
+
+// Get the integer value of the claim's label. The label is set by the jiva
+// volume plugin from its config if the claim does not have it.
+func intLabel(pvc *v1.PersistentVolumeClaim, label string) (int, error) {
+	v := pvc.Labels[label]
+	if v == "" {
+		return 0, fmt.Errorf("Missing %s in persistent volume claim", label)
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s '%s' in persistent volume claim", label, v)
+	}
+
+	return n, nil
+}
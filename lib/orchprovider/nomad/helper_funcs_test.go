@@ -0,0 +1,396 @@
+package nomad
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestJobStubsToPvList(t *testing.T) {
+	stubs := []*api.JobListStub{
+		&api.JobListStub{
+			Name:   "jivavol1",
+			Status: "running",
+			JobSummary: &api.JobSummary{
+				Summary: map[string]api.TaskGroupSummary{
+					jivaFeTaskGroup: api.TaskGroupSummary{Running: 1},
+					jivaBeTaskGroup: api.TaskGroupSummary{Running: 1},
+				},
+			},
+		},
+		// not a jiva volume
+		&api.JobListStub{
+			Name:   "webapp",
+			Status: "running",
+			JobSummary: &api.JobSummary{
+				Summary: map[string]api.TaskGroupSummary{
+					"web": api.TaskGroupSummary{Running: 1},
+				},
+			},
+		},
+		// no summary
+		&api.JobListStub{
+			Name: "batch",
+		},
+	}
+
+	pvList, err := JobStubsToPvList(stubs, &api.QueryMeta{LastIndex: 42})
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(pvList.Items) != 1 {
+		t.Fatalf("expected 1 volume, got: %d", len(pvList.Items))
+	}
+
+	if pvList.Items[0].Name != "jivavol1" {
+		t.Fatalf("bad volume name: %s", pvList.Items[0].Name)
+	}
+
+	if pvList.Items[0].Status.Reason != "running" {
+		t.Fatalf("bad volume status: %v", pvList.Items[0].Status)
+	}
+
+	if pvList.ResourceVersion != "42" {
+		t.Fatalf("bad resource version: %s", pvList.ResourceVersion)
+	}
+}
+
+func TestJobStubsToPvList_Empty(t *testing.T) {
+	pvList, err := JobStubsToPvList(nil, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pvList.Items == nil || len(pvList.Items) != 0 {
+		t.Fatalf("expected an empty list, got: %v", pvList.Items)
+	}
+}
+
+func TestQueryOptionsToNomad(t *testing.T) {
+	qOpts := QueryOptionsToNomad(&v1.QueryOptions{
+		Region:    "global",
+		WaitIndex: 42,
+		WaitTime:  30 * time.Second,
+	})
+
+	if qOpts.Region != "global" || qOpts.WaitIndex != 42 || qOpts.WaitTime != 30*time.Second {
+		t.Fatalf("bad query options: %#v", qOpts)
+	}
+
+	if qOpts := QueryOptionsToNomad(nil); qOpts == nil || qOpts.WaitIndex != 0 {
+		t.Fatalf("bad query options: %#v", qOpts)
+	}
+
+	// The wait time is bounded s.t. the client's timeout exceeds it
+	qOpts = QueryOptionsToNomad(&v1.QueryOptions{WaitIndex: 42, WaitTime: time.Hour})
+	if qOpts.WaitTime != nomadMaxQueryTime {
+		t.Fatalf("bad wait time: %v", qOpts.WaitTime)
+	}
+}
+
+func TestJivaVolSize(t *testing.T) {
+	cases := map[string]string{
+		"10Gi":       "10g",
+		"1536Mi":     "1536m",
+		"2Ti":        "2t",
+		"1000":       "1000",
+		"3221225472": "3g",
+	}
+
+	for quantity, expected := range cases {
+		size, err := QuantityToJivaVolSize(resource.MustParse(quantity))
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		if size != expected {
+			t.Fatalf("bad jiva size for '%s': expected: %s, got: %s", quantity, expected, size)
+		}
+
+		bytes, err := JivaVolSizeToBytes(size)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+
+		q := resource.MustParse(quantity)
+		if bytes != q.Value() {
+			t.Fatalf("bad bytes for '%s': expected: %d, got: %d", size, q.Value(), bytes)
+		}
+	}
+
+	if _, err := QuantityToJivaVolSize(resource.MustParse("0")); err == nil {
+		t.Fatalf("expected error for zero size")
+	}
+
+	for _, invalid := range []string{"", "g", "-5g", "5x"} {
+		if _, err := JivaVolSizeToBytes(invalid); err == nil {
+			t.Fatalf("expected error for '%s'", invalid)
+		}
+	}
+}
+
+// withJivaTaskLabels sets the labels that tune the jiva tasks, the replica
+// count & the storage size, if not set, as done by the jiva volume plugin
+func withJivaTaskLabels(pvc *v1.PersistentVolumeClaim) {
+	for k, v := range sampleJivaTaskLabels {
+		if _, found := pvc.Labels[k]; !found {
+			pvc.Labels[k] = v
+		}
+	}
+
+	if _, found := pvc.Labels["jivareplicas"]; !found {
+		pvc.Labels["jivareplicas"] = strconv.Itoa(len(strings.Split(pvc.Labels["jivabeip"], ",")))
+	}
+
+	if _, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]; !found {
+		pvc.Spec.Resources.Requests = v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse("5Gi"),
+		}
+	}
+}
+
+func TestPvcToJob_VolSize(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.101",
+		"jivabeip":        "172.28.128.102",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+	}
+	withJivaTaskLabels(pvc)
+
+	// The size is set by the jiva volume plugin; there is no default
+	delete(pvc.Spec.Resources.Requests, v1.ResourceStorage)
+	if _, err := PvcToJob(pvc); err == nil || !strings.Contains(err.Error(), "Missing storage size") {
+		t.Fatalf("expected missing size error, got: %v", err)
+	}
+
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("10Gi"),
+	}
+
+	job, err := PvcToJob(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if size, _ := JobJivaVolSize(job); size != "10g" {
+		t.Fatalf("expected 10g size, got: %s", size)
+	}
+
+	// The replica count is set by the jiva volume plugin; there is no default
+	replicas := pvc.Labels["jivareplicas"]
+	delete(pvc.Labels, "jivareplicas")
+	if _, err := PvcToJob(pvc); err == nil || !strings.Contains(err.Error(), "Missing jivareplicas") {
+		t.Fatalf("expected missing jivareplicas error, got: %v", err)
+	}
+	pvc.Labels["jivareplicas"] = replicas
+
+	SetJobJivaVolSize(job, "20g")
+	for _, tg := range job.TaskGroups {
+		for _, task := range tg.Tasks {
+			size := task.Env[jivaCtlVolSizeEnv] + task.Env[jivaRepVolSizeEnv]
+			if size != "20g" {
+				t.Fatalf("bad size of task '%s': %v", task.Name, task.Env)
+			}
+		}
+	}
+}
+
+func TestPvcToJob_Clone(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol2"
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.103",
+		"jivabeip":        "172.28.128.104",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+	}
+	withJivaTaskLabels(pvc)
+	pvc.Annotations = map[string]string{
+		v1.CloneSourceVolumeAnnotation: "jivavol1",
+	}
+
+	if _, err := PvcToJob(pvc); err == nil {
+		t.Fatalf("expected error for a clone without source snapshot")
+	}
+
+	pvc.Annotations[v1.CloneSourceSnapshotAnnotation] = "snap1"
+	pvc.Labels["jivaclonectlip"] = "172.28.128.101"
+
+	// The default replica artifact would start the clone empty
+	if _, err := PvcToJob(pvc); err == nil {
+		t.Fatalf("expected error for a clone without a clone replica artifact")
+	}
+
+	pvc.Labels["jivarepcloneartifact"] = "https://example.com/scripts/launch-jiva-clone-rep?ref=v1"
+
+	job, err := PvcToJob(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, tg := range job.TaskGroups {
+		for _, task := range tg.Tasks {
+			if _, isReplica := task.Env[jivaRepVolSizeEnv]; !isReplica {
+				continue
+			}
+			if task.Env[jivaRepCloneIPEnv] != "172.28.128.101" || task.Env[jivaRepCloneSnapEnv] != "snap1" {
+				t.Fatalf("replica is not seeded from the source: %v", task.Env)
+			}
+			if *task.Artifacts[0].GetterSource != pvc.Labels["jivarepcloneartifact"] || task.Config["command"] != "launch-jiva-clone-rep" {
+				t.Fatalf("replica is not launched by the clone artifact: %s %v", *task.Artifacts[0].GetterSource, task.Config)
+			}
+		}
+	}
+
+	// The provenance is known even if the job is not running
+	job.Status = helper.StringToPtr("pending")
+	job.StatusDescription = helper.StringToPtr("")
+
+	pv, err := JobToPv(job)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Annotations[v1.CloneSourceVolumeAnnotation] != "jivavol1" || pv.Annotations[v1.CloneSourceSnapshotAnnotation] != "snap1" {
+		t.Fatalf("bad provenance: %v", pv.Annotations)
+	}
+}
+
+func TestPvcToJob_TaskLabels(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Labels = map[string]string{
+		"region":              "global",
+		"datacenter":          "dc1",
+		"jivafeversion":       "openebs/jiva:latest",
+		"jivafenetwork":       "host",
+		"jivafeip":            "172.28.128.101",
+		"jivabeip":            "172.28.128.102",
+		"jivafesubnet":        "24",
+		"jivafeinterface":     "enp0s8",
+		"jivarepcpu":          "1000",
+		"jivarepmemory":       "512",
+		"jivarepstoreroot":    "/var/openebs/",
+		"jivarestartattempts": "5",
+		"jivarestartdelay":    "1m",
+	}
+	withJivaTaskLabels(pvc)
+
+	job, err := PvcToJob(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, tg := range job.TaskGroups {
+		if *tg.RestartPolicy.Attempts != 5 || *tg.RestartPolicy.Delay != time.Minute || *tg.RestartPolicy.Interval != 5*time.Minute {
+			t.Fatalf("bad restart policy of '%s': %+v", *tg.Name, tg.RestartPolicy)
+		}
+
+		task := tg.Tasks[0]
+		if *tg.Name == jivaFeTaskGroup && (*task.Resources.CPU != 500 || *task.Resources.MemoryMB != 256) {
+			t.Fatalf("bad resources of '%s': %+v", task.Name, task.Resources)
+		}
+
+		if *tg.Name == jivaBeTaskGroup {
+			if *task.Resources.CPU != 1000 || *task.Resources.MemoryMB != 512 {
+				t.Fatalf("bad resources of '%s': %+v", task.Name, task.Resources)
+			}
+			if task.Env["JIVA_REP_VOLSTORE"] != "/var/openebs/jivavol1bepod/be1" {
+				t.Fatalf("bad store of '%s': %s", task.Name, task.Env["JIVA_REP_VOLSTORE"])
+			}
+		}
+	}
+
+	pvc.Labels["jivafecpu"] = "lots"
+	if _, err := PvcToJob(pvc); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for an invalid cpu, got: %v", err)
+	}
+
+	// The labels are set by the jiva volume plugin. The orchestrator has no
+	// defaults of its own.
+	pvc.Labels["jivafecpu"] = "500"
+	delete(pvc.Labels, "jivarepstoreroot")
+	delete(pvc.Labels, "jivafeartifact")
+	_, err = PvcToJob(pvc)
+	if !v1.IsInvalid(err) || !strings.Contains(err.Error(), "jivafeartifact, jivarepstoreroot") {
+		t.Fatalf("expected invalid error for missing labels, got: %v", err)
+	}
+}
+
+func TestPvcToJob_Replicas(t *testing.T) {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.101",
+		"jivabeip":        "172.28.128.102, 172.28.128.103,172.28.128.104",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+		"jivareplicas":    "3",
+	}
+	withJivaTaskLabels(pvc)
+
+	job, err := PvcToJob(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(job.TaskGroups) != 4 {
+		t.Fatalf("expected a frontend & 3 replica task groups, got %d", len(job.TaskGroups))
+	}
+
+	stores := map[string]bool{}
+	for i, tg := range job.TaskGroups[1:] {
+		task := tg.Tasks[0]
+
+		if *tg.Name != jivaBeTaskGroupName(i+1) || task.Name != fmt.Sprintf("be%d", i+1) {
+			t.Fatalf("bad replica %d: %s/%s", i+1, *tg.Name, task.Name)
+		}
+
+		if ip := fmt.Sprintf("172.28.128.%d", 102+i); task.Env[jivaRepIPEnv] != ip {
+			t.Fatalf("expected ip '%s' of replica %d, got '%s'", ip, i+1, task.Env[jivaRepIPEnv])
+		}
+
+		stores[task.Env["JIVA_REP_VOLSTORE"]] = true
+	}
+
+	if len(stores) != 3 {
+		t.Fatalf("replicas share their stores: %v", stores)
+	}
+
+	distinct := false
+	for _, c := range job.Constraints {
+		distinct = distinct || c.Operand == structs.ConstraintDistinctHosts
+	}
+	if !distinct {
+		t.Fatalf("expected a distinct hosts constraint: %v", job.Constraints)
+	}
+
+	pvc.Labels["jivareplicas"] = "2"
+	if _, err := PvcToJob(pvc); err == nil {
+		t.Fatalf("expected error for a mismatch of replicas & ips")
+	}
+}
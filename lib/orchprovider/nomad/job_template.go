@@ -0,0 +1,244 @@
+package nomad
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"text/template"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// jobTemplateFuncs are the functions available to a job template in
+// addition to the built-in functions of text/template
+var jobTemplateFuncs = template.FuncMap{
+	// json provides the JSON encoding of the value. A string is quoted &
+	// escaped s.t. labels can be placed verbatim in a JSON jobspec.
+	"json": func(v interface{}) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+}
+
+// jobTemplate is an operator supplied jobspec of the jiva volumes. It is
+// rendered with the jivaJobSpec of each claim.
+//
+// NOTE:
+//    The rendered jobspec is either HCL as accepted by `nomad run` or JSON
+// as accepted by Nomad's jobs API i.e. the job or the job wrapped in a
+// "Job" key.
+type jobTemplate struct {
+	// path of the template file
+	path string
+
+	tmpl *template.Template
+}
+
+// loadJobTemplate reads the job template at the path. The template is
+// validated by rendering it against sample claims s.t. a bad template is
+// reported at startup rather than at the first placement.
+func loadJobTemplate(path string) (*jobTemplate, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read Nomad job template: %v", err)
+	}
+
+	tmpl, err := template.New(filepath.Base(path)).
+		Funcs(jobTemplateFuncs).
+		Option("missingkey=zero").
+		Parse(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse Nomad job template '%s': %v", path, err)
+	}
+
+	jt := &jobTemplate{
+		path: path,
+		tmpl: tmpl,
+	}
+
+	for _, pvc := range sampleJobTemplateClaims() {
+		if _, err := jt.render(pvc); err != nil {
+			return nil, fmt.Errorf("invalid Nomad job template: %v", err)
+		}
+	}
+
+	return jt, nil
+}
+
+// render provides the job of the claim's jiva volume. The job is given the
+// volume's name, region & datacenter if the template does not set them.
+func (jt *jobTemplate) render(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
+	spec, err := pvcToJivaJobSpec(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	// A template seeds a clone via .CloneRepArtifact as the built-in job does
+	if err := checkCloneRepArtifact(spec); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := jt.tmpl.Execute(&buf, spec); err != nil {
+		return nil, fmt.Errorf("unable to render Nomad job template '%s' for claim '%s': %v", jt.path, pvc.Name, err)
+	}
+
+	job, err := decodeJobspec(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("Nomad job template '%s' rendered an invalid jobspec for claim '%s': %v", jt.path, pvc.Name, err)
+	}
+
+	if err := completeTemplatedJob(job, spec); err != nil {
+		return nil, fmt.Errorf("Nomad job template '%s' rendered an invalid job for claim '%s': %v", jt.path, pvc.Name, err)
+	}
+
+	return job, nil
+}
+
+// decodeJobspec decodes the HCL or JSON jobspec. A JSON job can be wrapped
+// in a "Job" key as done by `nomad run -output`.
+func decodeJobspec(b []byte) (*api.Job, error) {
+	trimmed := bytes.TrimSpace(b)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return parseHCLJobspec(trimmed)
+	}
+
+	var wrapped struct {
+		Job *api.Job
+	}
+	if err := json.Unmarshal(trimmed, &wrapped); err != nil {
+		return nil, err
+	}
+
+	if wrapped.Job != nil {
+		return wrapped.Job, nil
+	}
+
+	job := &api.Job{}
+	if err := json.Unmarshal(trimmed, job); err != nil {
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// completeTemplatedJob verifies that the job can be understood as a jiva
+// volume & sets what the template left out.
+//
+// NOTE:
+//    The volume's capacity, listing & resize rely on the jiva frontend
+// task group & its volume size env variable. The iSCSI target is recorded
+// in the job's Meta as done by the built-in job.
+func completeTemplatedJob(job *api.Job, spec *jivaJobSpec) error {
+	if job.Name == nil || *job.Name == "" {
+		job.Name = helper.StringToPtr(spec.Name)
+	}
+
+	if job.ID == nil || *job.ID == "" {
+		job.ID = helper.StringToPtr(*job.Name)
+	}
+
+	if *job.Name != spec.Name || *job.ID != spec.Name {
+		return fmt.Errorf("job needs to be named '%s', got name '%s' & id '%s'", spec.Name, *job.Name, *job.ID)
+	}
+
+	if job.Region == nil || *job.Region == "" {
+		job.Region = helper.StringToPtr(spec.Region)
+	}
+
+	if len(job.Datacenters) == 0 {
+		job.Datacenters = []string{spec.Datacenter}
+	}
+
+	if _, found := JobJivaVolSize(job); !found {
+		return fmt.Errorf("missing env variable '%s' in task group '%s'", jivaCtlVolSizeEnv, jivaFeTaskGroup)
+	}
+
+	if job.Meta == nil {
+		job.Meta = map[string]string{}
+	}
+
+	if job.Meta[jobMetaTargetPortal] == "" {
+		job.Meta[jobMetaTargetPortal] = spec.FeIP + ":3260"
+	}
+
+	if job.Meta[jobMetaIQN] == "" {
+		job.Meta[jobMetaIQN] = "iqn.2016-09.com.openebs.jiva:" + spec.Name
+	}
+
+	if spec.CloneSourceVolume != "" {
+		SetJobCloneSource(job, spec.CloneSourceVolume, spec.CloneSourceSnapshot, spec.CloneCtlIP)
+	}
+
+	return nil
+}
+
+// sampleJivaTaskLabels tune the jiva tasks of the sample claims as done by
+// the jiva volume plugin
+var sampleJivaTaskLabels = map[string]string{
+	"jivafeartifact":      "https://example.com/launch-jiva-ctl-with-ip",
+	"jivafecpu":           "500",
+	"jivafememory":        "256",
+	"jivarepartifact":     "https://example.com/launch-jiva-rep-with-ip",
+	"jivarepcpu":          "500",
+	"jivarepmemory":       "256",
+	"jivarepstoreroot":    "/tmp/jiva",
+	"jivarestartattempts": "3",
+	"jivarestartinterval": "5m",
+	"jivarestartdelay":    "25s",
+	"jivarestartmode":     "delay",
+}
+
+// sampleJobTemplateClaims provides the claims a job template is validated
+// against i.e. a volume with two replicas & a clone of it
+func sampleJobTemplateClaims() []*v1.PersistentVolumeClaim {
+	sample := func(name string) *v1.PersistentVolumeClaim {
+		pvc := &v1.PersistentVolumeClaim{}
+		pvc.Name = name
+		pvc.Labels = map[string]string{
+			"region":          "global",
+			"datacenter":      "dc1",
+			"jivafeversion":   "openebs/jiva:latest",
+			"jivafenetwork":   "host",
+			"jivafeip":        "172.28.128.101",
+			"jivabeip":        "172.28.128.102,172.28.128.103",
+			"jivareplicas":    "2",
+			"jivafesubnet":    "24",
+			"jivafeinterface": "enp0s8",
+		}
+		for k, v := range sampleJivaTaskLabels {
+			pvc.Labels[k] = v
+		}
+		pvc.Spec.Resources.Requests = v1.ResourceList{
+			v1.ResourceStorage: resource.MustParse("1Gi"),
+		}
+		return pvc
+	}
+
+	vol := sample("samplevol")
+
+	clone := sample("sampleclone")
+	clone.Labels["jivaclonectlip"] = "172.28.128.101"
+	clone.Labels["jivarepcloneartifact"] = "https://example.com/launch-jiva-clone-rep"
+	clone.Annotations = map[string]string{
+		v1.CloneSourceVolumeAnnotation:   "samplevol",
+		v1.CloneSourceSnapshotAnnotation: "samplesnap",
+	}
+
+	return []*v1.PersistentVolumeClaim{vol, clone}
+}
+
+// jobOf provides the job of the claim's jiva volume at the datacenter. The
+// built-in job is provided if the datacenter has no job template.
+func (dc *nomadDatacenter) jobOf(pvc *v1.PersistentVolumeClaim) (*api.Job, error) {
+	if dc.jobTemplate == nil {
+		return PvcToJob(pvc)
+	}
+
+	return dc.jobTemplate.render(pvc)
+}
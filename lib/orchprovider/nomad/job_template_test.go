@@ -0,0 +1,294 @@
+package nomad
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+const testJobTemplate = `{
+  "Job": {
+    "Type": "service",
+    "Meta": {"owner": {{json (index .Labels "owner")}}},
+    "TaskGroups": [
+      {
+        "Name": "fepod",
+        "Tasks": [
+          {
+            "Name": "fe1",
+            "Driver": "docker",
+            "Config": {"image": {{json .FeVersion}}},
+            "Env": {
+              "JIVA_CTL_VOLSIZE": {{json .Size}},
+              "JIVA_CTL_IP": {{json .FeIP}},
+              "JIVA_REP_IPS": {{json .Labels.jivabeip}}
+            }
+          }
+        ]
+      }{{range .Replicas}},
+      {
+        "Name": {{json .TaskGroup}},
+        "Tasks": [
+          {
+            "Name": {{json .Task}},
+            "Driver": "docker",
+            "Env": {
+              "JIVA_REP_VOLSIZE": {{json $.Size}},
+              "JIVA_REP_IP": {{json .IP}}
+            }
+          }
+        ]
+      }{{end}}
+    ]
+  }
+}
+`
+
+const testHCLJobTemplate = `job "{{.Name}}" {
+  type = "service"
+
+  meta {
+    owner = {{json (index .Labels "owner")}}
+  }
+
+  group "fepod" {
+    restart {
+      attempts = 3
+      delay    = "25s"
+    }
+
+    task "fe1" {
+      driver = "docker"
+
+      config {
+        image = {{json .FeVersion}}
+      }
+
+      env {
+        JIVA_CTL_VOLSIZE = {{json .Size}}
+        JIVA_CTL_IP      = {{json .FeIP}}
+      }
+
+      resources {
+        cpu = 500
+
+        network {
+          mbits = 50
+          port "iscsi" {
+            static = 3260
+          }
+        }
+      }
+    }
+  }
+{{range .Replicas}}
+  group {{json .TaskGroup}} {
+    task {{json .Task}} {
+      driver = "docker"
+
+      env {
+        JIVA_REP_VOLSIZE = {{json $.Size}}
+        JIVA_REP_IP      = {{json .IP}}
+      }
+    }
+  }
+{{end}}
+}
+`
+
+// writeJobTemplate writes the template to a temporary file & provides a
+// func that removes the file
+func writeJobTemplate(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "jobtemplate")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	path := filepath.Join(dir, "jiva.json.tmpl")
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestJobTemplate_Render(t *testing.T) {
+	path, cleanup := writeJobTemplate(t, testJobTemplate)
+	defer cleanup()
+
+	jt, err := loadJobTemplate(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pvc := dcClaim("jivavol1", "global", "dc1")
+	pvc.Labels["owner"] = `team "a"`
+	pvc.Labels["jivareplicas"] = "2"
+	pvc.Labels["jivabeip"] = "172.28.128.102,172.28.128.103"
+
+	job, err := jt.render(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if *job.Name != "jivavol1" || *job.ID != "jivavol1" || *job.Region != "global" || job.Datacenters[0] != "dc1" {
+		t.Fatalf("bad job: %+v", job)
+	}
+
+	if job.Meta["owner"] != `team "a"` || job.Meta[jobMetaTargetPortal] != "172.28.128.101:3260" || job.Meta[jobMetaIQN] == "" {
+		t.Fatalf("bad meta: %v", job.Meta)
+	}
+
+	if size, _ := JobJivaVolSize(job); size != "1g" {
+		t.Fatalf("bad size: %s", size)
+	}
+
+	if len(job.TaskGroups) != 3 || *job.TaskGroups[2].Name != "bepod2" || job.TaskGroups[2].Tasks[0].Env[jivaRepIPEnv] != "172.28.128.103" {
+		t.Fatalf("bad replicas: %+v", job.TaskGroups)
+	}
+
+	pvc.Annotations = map[string]string{
+		v1.CloneSourceVolumeAnnotation:   "jivavol0",
+		v1.CloneSourceSnapshotAnnotation: "snap1",
+	}
+	pvc.Labels["jivaclonectlip"] = "172.28.128.100"
+
+	// A clone is not rendered unless its replicas can be seeded
+	if _, err := jt.render(pvc); err == nil || !strings.Contains(err.Error(), "needs a jiva replica artifact") {
+		t.Fatalf("expected a missing clone artifact error, got: %v", err)
+	}
+
+	pvc.Labels["jivarepcloneartifact"] = "https://example.com/launch-jiva-clone-rep"
+
+	job, err = jt.render(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if job.Meta[jobMetaCloneSourceVolume] != "jivavol0" || job.TaskGroups[1].Tasks[0].Env[jivaRepCloneSnapEnv] != "snap1" {
+		t.Fatalf("clone is not seeded from the source: %+v", job)
+	}
+}
+
+func TestJobTemplate_Invalid(t *testing.T) {
+	for name, content := range map[string]string{
+		"unknown func":     `{"Job": {"Name": {{split .Name}}}}`,
+		"unknown field":    `{"Job": {"Name": {{json .Volume}}}}`,
+		"hcl vol size":     `job "{{.Name}}" { datacenters = ["{{.Datacenter}}"] }`,
+		"hcl invalid key":  `job "{{.Name}}" { replicas = 2 }`,
+		"bad json":         `{"Job": {"Name": {{.Name}}}}`,
+		"missing vol size": `{"Job": {"TaskGroups": [{"Name": "fepod", "Tasks": [{"Name": "fe1"}]}]}}`,
+		"fixed name":       `{"Job": {"Name": "jivavol", "TaskGroups": [{"Name": "fepod", "Tasks": [{"Name": "fe1", "Env": {"JIVA_CTL_VOLSIZE": "{{.Size}}"}}]}]}}`,
+	} {
+		path, cleanup := writeJobTemplate(t, content)
+		_, err := loadJobTemplate(path)
+		cleanup()
+
+		if err == nil {
+			t.Fatalf("expected an error for template with %s", name)
+		}
+	}
+
+	if _, err := loadJobTemplate("/nonexistent/jiva.json.tmpl"); err == nil {
+		t.Fatalf("expected an error for a missing template")
+	}
+}
+
+func TestJobTemplate_RenderHCL(t *testing.T) {
+	path, cleanup := writeJobTemplate(t, testHCLJobTemplate)
+	defer cleanup()
+
+	jt, err := loadJobTemplate(path)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pvc := dcClaim("jivavol1", "global", "dc1")
+	pvc.Labels["owner"] = `team "a"`
+	pvc.Labels["jivareplicas"] = "2"
+	pvc.Labels["jivabeip"] = "172.28.128.102,172.28.128.103"
+
+	job, err := jt.render(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if *job.Name != "jivavol1" || *job.ID != "jivavol1" || *job.Region != "global" || job.Datacenters[0] != "dc1" {
+		t.Fatalf("bad job: %+v", job)
+	}
+
+	if job.Meta["owner"] != `team "a"` || job.Meta[jobMetaTargetPortal] != "172.28.128.101:3260" {
+		t.Fatalf("bad meta: %v", job.Meta)
+	}
+
+	if size, _ := JobJivaVolSize(job); size != "1g" {
+		t.Fatalf("bad size: %s", size)
+	}
+
+	fe := job.TaskGroups[0]
+	if *fe.RestartPolicy.Delay != 25*time.Second || fe.Tasks[0].Config["image"] != "openebs/jiva:latest" {
+		t.Fatalf("bad frontend: %+v", fe)
+	}
+
+	if ports := fe.Tasks[0].Resources.Networks[0].ReservedPorts; len(ports) != 1 || ports[0].Value != 3260 {
+		t.Fatalf("bad frontend ports: %+v", ports)
+	}
+
+	if len(job.TaskGroups) != 3 || *job.TaskGroups[2].Name != "bepod2" || job.TaskGroups[2].Tasks[0].Env[jivaRepIPEnv] != "172.28.128.103" {
+		t.Fatalf("bad replicas: %+v", job.TaskGroups)
+	}
+}
+
+func TestDecodeJobspec_HCL(t *testing.T) {
+	job, err := decodeJobspec([]byte(`job "jivavol1" { datacenters = ["dc1"] }`))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if *job.ID != "jivavol1" || job.Datacenters[0] != "dc1" {
+		t.Fatalf("bad job: %+v", job)
+	}
+
+	if _, err := decodeJobspec([]byte(`not a jobspec`)); err == nil {
+		t.Fatalf("expected an error for an invalid jobspec")
+	}
+}
+
+func TestStoragePlacementReq_JobTemplate(t *testing.T) {
+	path, cleanup := writeJobTemplate(t, `{"TaskGroups": [{"Name": "fepod", "Tasks": [{"Name": "fe1", "Env": {"JIVA_CTL_VOLSIZE": {{json .Size}}}}]}]}`)
+	defer cleanup()
+
+	nCfg, err := readNomadConfig(strings.NewReader("[datacenter \"dc1\"]\naddress = http://10.0.0.1:4646\n\n[datacenter \"dc2\"]\naddress = http://20.0.0.2:4646\njob-template = " + path + "\n"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	dcs, err := newNomadDatacenters(newNomadApiProvider(nCfg), nCfg)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if dcs["dc1"].jobTemplate != nil || dcs["dc2"].jobTemplate == nil {
+		t.Fatalf("bad job templates: dc1: %v, dc2: %v", dcs["dc1"].jobTemplate, dcs["dc2"].jobTemplate)
+	}
+
+	dc1, dc2 := newDCStorageApis(), newDCStorageApis()
+	n := dcOrchestrator(dc1, dc2)
+	n.datacenters["dc2"].jobTemplate = dcs["dc2"].jobTemplate
+
+	pvc := dcClaim("jivavol1", "asia", "dc2")
+	pv, err := n.StoragePlacementReq(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	if len(dc2.created) != 1 || capacity.Value() != 1<<30 {
+		t.Fatalf("bad placement: %v, capacity: %s", dc2.created, capacity.String())
+	}
+}
@@ -0,0 +1,1057 @@
+package nomad
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/hashicorp/nomad/nomad/structs"
+	"github.com/mitchellh/mapstructure"
+)
+
+// reDynamicPorts matches the labels of a task's network ports
+var reDynamicPorts = regexp.MustCompile("^[a-zA-Z0-9_]+$")
+
+// errPortLabel is reported for a port label that does not match
+// reDynamicPorts
+var errPortLabel = fmt.Errorf("Port label does not conform to naming requirements %s", reDynamicPorts.String())
+
+// parseHCLJobspec decodes the HCL jobspec into a job.
+//
+// NOTE:
+//    The jobspec is the one accepted by `nomad run` i.e. a single job block
+// with its groups, tasks & their stanzas. The blocks are decoded as done by
+// Nomad's jobspec parser, including its defaults for artifacts, templates &
+// vault. The other defaults are left to the job's Canonicalize as is the
+// case with a JSON jobspec.
+func parseHCLJobspec(b []byte) (*api.Job, error) {
+	root, err := hcl.ParseBytes(b)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing: %s", err)
+	}
+
+	list, ok := root.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, fmt.Errorf("error parsing: root should be an object")
+	}
+
+	if err := checkHCLKeys(list, []string{"job"}); err != nil {
+		return nil, err
+	}
+
+	matches := list.Filter("job")
+	if len(matches.Items) == 0 {
+		return nil, fmt.Errorf("'job' block is missing")
+	}
+
+	job := &api.Job{}
+	if err := parseJob(job, matches); err != nil {
+		return nil, fmt.Errorf("error parsing 'job': %s", err)
+	}
+
+	return job, nil
+}
+
+func parseJob(result *api.Job, list *ast.ObjectList) error {
+	if len(list.Items) != 1 {
+		return fmt.Errorf("only one 'job' block allowed")
+	}
+
+	list = list.Children()
+	if len(list.Items) != 1 {
+		return fmt.Errorf("'job' block missing name")
+	}
+
+	obj := list.Items[0]
+
+	// Decode the full thing into a map[string]interface for ease
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, obj.Val); err != nil {
+		return err
+	}
+	delete(m, "constraint")
+	delete(m, "group")
+	delete(m, "meta")
+	delete(m, "parameterized")
+	delete(m, "periodic")
+	delete(m, "task")
+	delete(m, "update")
+	delete(m, "vault")
+
+	// The ID & name of the job are its key unless set in the block
+	result.ID = helper.StringToPtr(obj.Keys[0].Token.Value().(string))
+	result.Name = helper.StringToPtr(*result.ID)
+
+	if err := mapstructure.WeakDecode(m, result); err != nil {
+		return err
+	}
+
+	var listVal *ast.ObjectList
+	if ot, ok := obj.Val.(*ast.ObjectType); ok {
+		listVal = ot.List
+	} else {
+		return fmt.Errorf("job '%s' value: should be an object", *result.ID)
+	}
+
+	valid := []string{
+		"all_at_once",
+		"constraint",
+		"datacenters",
+		"group",
+		"id",
+		"meta",
+		"name",
+		"parameterized",
+		"periodic",
+		"priority",
+		"region",
+		"task",
+		"type",
+		"update",
+		"vault",
+		"vault_token",
+	}
+	if err := checkHCLKeys(listVal, valid); err != nil {
+		return multierror.Prefix(err, "job:")
+	}
+
+	if o := listVal.Filter("constraint"); len(o.Items) > 0 {
+		if err := parseConstraints(&result.Constraints, o); err != nil {
+			return multierror.Prefix(err, "constraint ->")
+		}
+	}
+
+	if o := listVal.Filter("update"); len(o.Items) > 0 {
+		if err := parseUpdate(&result.Update, o); err != nil {
+			return multierror.Prefix(err, "update ->")
+		}
+	}
+
+	if o := listVal.Filter("periodic"); len(o.Items) > 0 {
+		if err := parsePeriodic(&result.Periodic, o); err != nil {
+			return multierror.Prefix(err, "periodic ->")
+		}
+	}
+
+	if o := listVal.Filter("parameterized"); len(o.Items) > 0 {
+		if err := parseParameterizedJob(&result.ParameterizedJob, o); err != nil {
+			return multierror.Prefix(err, "parameterized ->")
+		}
+	}
+
+	if o := listVal.Filter("meta"); len(o.Items) > 0 {
+		if err := parseStringMap(&result.Meta, o); err != nil {
+			return multierror.Prefix(err, "meta ->")
+		}
+	}
+
+	// A task outside of a group is placed in a group of its own
+	if o := listVal.Filter("task"); len(o.Items) > 0 {
+		var tasks []*api.Task
+		if err := parseTasks(&tasks, o); err != nil {
+			return multierror.Prefix(err, "task:")
+		}
+
+		for _, t := range tasks {
+			result.TaskGroups = append(result.TaskGroups, &api.TaskGroup{
+				Name:  helper.StringToPtr(t.Name),
+				Tasks: []*api.Task{t},
+			})
+		}
+	}
+
+	if o := listVal.Filter("group"); len(o.Items) > 0 {
+		if err := parseGroups(result, o); err != nil {
+			return multierror.Prefix(err, "group:")
+		}
+	}
+
+	// The job's vault block applies to the tasks without one
+	if o := listVal.Filter("vault"); len(o.Items) > 0 {
+		jobVault := newVault()
+		if err := parseVault(jobVault, o); err != nil {
+			return multierror.Prefix(err, "vault ->")
+		}
+
+		for _, tg := range result.TaskGroups {
+			setTasksVault(tg.Tasks, jobVault)
+		}
+	}
+
+	return nil
+}
+
+func parseGroups(result *api.Job, list *ast.ObjectList) error {
+	list = list.Children()
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	for _, item := range list.Items {
+		n := item.Keys[0].Token.Value().(string)
+
+		if _, ok := seen[n]; ok {
+			return fmt.Errorf("group '%s' defined more than once", n)
+		}
+		seen[n] = struct{}{}
+
+		var listVal *ast.ObjectList
+		if ot, ok := item.Val.(*ast.ObjectType); ok {
+			listVal = ot.List
+		} else {
+			return fmt.Errorf("group '%s': should be an object", n)
+		}
+
+		valid := []string{
+			"constraint",
+			"count",
+			"ephemeral_disk",
+			"meta",
+			"restart",
+			"task",
+			"vault",
+		}
+		if err := checkHCLKeys(listVal, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", n))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		delete(m, "constraint")
+		delete(m, "ephemeral_disk")
+		delete(m, "meta")
+		delete(m, "restart")
+		delete(m, "task")
+		delete(m, "vault")
+
+		g := &api.TaskGroup{
+			Name: helper.StringToPtr(n),
+		}
+		if err := mapstructure.WeakDecode(m, g); err != nil {
+			return err
+		}
+
+		if o := listVal.Filter("constraint"); len(o.Items) > 0 {
+			if err := parseConstraints(&g.Constraints, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', constraint ->", n))
+			}
+		}
+
+		if o := listVal.Filter("restart"); len(o.Items) > 0 {
+			if err := parseRestartPolicy(&g.RestartPolicy, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', restart ->", n))
+			}
+		}
+
+		if o := listVal.Filter("ephemeral_disk"); len(o.Items) > 0 {
+			if err := parseEphemeralDisk(&g.EphemeralDisk, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', ephemeral_disk ->", n))
+			}
+		}
+
+		if o := listVal.Filter("meta"); len(o.Items) > 0 {
+			if err := parseStringMap(&g.Meta, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', meta ->", n))
+			}
+		}
+
+		if o := listVal.Filter("task"); len(o.Items) > 0 {
+			if err := parseTasks(&g.Tasks, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', task:", n))
+			}
+		}
+
+		// The group's vault block applies to its tasks without one
+		if o := listVal.Filter("vault"); len(o.Items) > 0 {
+			tgVault := newVault()
+			if err := parseVault(tgVault, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', vault ->", n))
+			}
+
+			setTasksVault(g.Tasks, tgVault)
+		}
+
+		result.TaskGroups = append(result.TaskGroups, g)
+	}
+
+	return nil
+}
+
+func parseTasks(result *[]*api.Task, list *ast.ObjectList) error {
+	list = list.Children()
+	if len(list.Items) == 0 {
+		return nil
+	}
+
+	seen := make(map[string]struct{})
+	for _, item := range list.Items {
+		n := item.Keys[0].Token.Value().(string)
+
+		if _, ok := seen[n]; ok {
+			return fmt.Errorf("task '%s' defined more than once", n)
+		}
+		seen[n] = struct{}{}
+
+		var listVal *ast.ObjectList
+		if ot, ok := item.Val.(*ast.ObjectType); ok {
+			listVal = ot.List
+		} else {
+			return fmt.Errorf("task '%s': should be an object", n)
+		}
+
+		valid := []string{
+			"artifact",
+			"config",
+			"constraint",
+			"dispatch_payload",
+			"driver",
+			"env",
+			"kill_timeout",
+			"leader",
+			"logs",
+			"meta",
+			"resources",
+			"service",
+			"template",
+			"user",
+			"vault",
+		}
+		if err := checkHCLKeys(listVal, valid); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", n))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, item.Val); err != nil {
+			return err
+		}
+		delete(m, "artifact")
+		delete(m, "config")
+		delete(m, "constraint")
+		delete(m, "dispatch_payload")
+		delete(m, "env")
+		delete(m, "logs")
+		delete(m, "meta")
+		delete(m, "resources")
+		delete(m, "service")
+		delete(m, "template")
+		delete(m, "vault")
+
+		t := &api.Task{
+			Name: n,
+		}
+		if err := decodeWithDurations(m, t); err != nil {
+			return err
+		}
+
+		if o := listVal.Filter("config"); len(o.Items) > 0 {
+			for _, co := range o.Elem().Items {
+				var m map[string]interface{}
+				if err := hcl.DecodeObject(&m, co.Val); err != nil {
+					return err
+				}
+				if err := mapstructure.WeakDecode(m, &t.Config); err != nil {
+					return err
+				}
+			}
+		}
+
+		if o := listVal.Filter("env"); len(o.Items) > 0 {
+			if err := parseStringMap(&t.Env, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', env ->", n))
+			}
+		}
+
+		if o := listVal.Filter("meta"); len(o.Items) > 0 {
+			if err := parseStringMap(&t.Meta, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', meta ->", n))
+			}
+		}
+
+		if o := listVal.Filter("constraint"); len(o.Items) > 0 {
+			if err := parseConstraints(&t.Constraints, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', constraint ->", n))
+			}
+		}
+
+		if o := listVal.Filter("service"); len(o.Items) > 0 {
+			if err := parseServices(t, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s',", n))
+			}
+		}
+
+		if o := listVal.Filter("resources"); len(o.Items) > 0 {
+			var r api.Resources
+			if err := parseResources(&r, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s',", n))
+			}
+			t.Resources = &r
+		}
+
+		if o := listVal.Filter("logs"); len(o.Items) > 0 {
+			if err := parseLogs(&t.LogConfig, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', logs ->", n))
+			}
+		}
+
+		if o := listVal.Filter("artifact"); len(o.Items) > 0 {
+			if err := parseArtifacts(&t.Artifacts, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', artifact ->", n))
+			}
+		}
+
+		if o := listVal.Filter("template"); len(o.Items) > 0 {
+			if err := parseTemplates(&t.Templates, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', template ->", n))
+			}
+		}
+
+		if o := listVal.Filter("vault"); len(o.Items) > 0 {
+			v := newVault()
+			if err := parseVault(v, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', vault ->", n))
+			}
+			t.Vault = v
+		}
+
+		if o := listVal.Filter("dispatch_payload"); len(o.Items) > 0 {
+			if err := parseDispatchPayload(&t.DispatchPayload, o); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("'%s', dispatch_payload ->", n))
+			}
+		}
+
+		*result = append(*result, t)
+	}
+
+	return nil
+}
+
+func parseConstraints(result *[]*api.Constraint, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		valid := []string{
+			"attribute",
+			structs.ConstraintDistinctHosts,
+			"operator",
+			structs.ConstraintRegex,
+			structs.ConstraintSetContains,
+			"value",
+			structs.ConstraintVersion,
+		}
+		if err := checkHCLKeys(o.Val, valid); err != nil {
+			return err
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		m["LTarget"] = m["attribute"]
+		m["RTarget"] = m["value"]
+		m["Operand"] = m["operator"]
+
+		// The version, regexp & set_contains keys are short for the operand
+		// with the key's value as the target
+		for _, op := range []string{structs.ConstraintVersion, structs.ConstraintRegex, structs.ConstraintSetContains} {
+			if target, ok := m[op]; ok {
+				m["Operand"] = op
+				m["RTarget"] = target
+			}
+		}
+
+		if value, ok := m[structs.ConstraintDistinctHosts]; ok {
+			enabled, err := parseBool(value)
+			if err != nil {
+				return fmt.Errorf("distinct_hosts should be set to true or false; %v", err)
+			}
+
+			// A disabled distinct_hosts is no constraint at all
+			if !enabled {
+				continue
+			}
+
+			m["Operand"] = structs.ConstraintDistinctHosts
+		}
+
+		var c api.Constraint
+		if err := mapstructure.WeakDecode(m, &c); err != nil {
+			return err
+		}
+		if c.Operand == "" {
+			c.Operand = "="
+		}
+
+		*result = append(*result, &c)
+	}
+
+	return nil
+}
+
+func parseUpdate(result **api.UpdateStrategy, list *ast.ObjectList) error {
+	o, err := singleBlock("update", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"max_parallel", "stagger"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var u api.UpdateStrategy
+	if err := decodeWithDurations(m, &u); err != nil {
+		return err
+	}
+
+	*result = &u
+	return nil
+}
+
+func parsePeriodic(result **api.PeriodicConfig, list *ast.ObjectList) error {
+	o, err := singleBlock("periodic", list)
+	if err != nil {
+		return err
+	}
+
+	valid := []string{
+		"cron",
+		"enabled",
+		"prohibit_overlap",
+		"time_zone",
+	}
+	if err := checkHCLKeys(o.Val, valid); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	if value, ok := m["enabled"]; ok {
+		enabled, err := parseBool(value)
+		if err != nil {
+			return fmt.Errorf("periodic.enabled should be set to true or false; %v", err)
+		}
+		m["Enabled"] = enabled
+	}
+
+	// The cron key is short for a cron spec
+	if cron, ok := m["cron"]; ok {
+		m["SpecType"] = structs.PeriodicSpecCron
+		m["Spec"] = cron
+	}
+
+	var p api.PeriodicConfig
+	if err := mapstructure.WeakDecode(m, &p); err != nil {
+		return err
+	}
+
+	*result = &p
+	return nil
+}
+
+func parseParameterizedJob(result **api.ParameterizedJobConfig, list *ast.ObjectList) error {
+	o, err := singleBlock("parameterized", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"meta_optional", "meta_required", "payload"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var d api.ParameterizedJobConfig
+	if err := mapstructure.WeakDecode(m, &d); err != nil {
+		return err
+	}
+
+	*result = &d
+	return nil
+}
+
+func parseRestartPolicy(result **api.RestartPolicy, list *ast.ObjectList) error {
+	o, err := singleBlock("restart", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"attempts", "delay", "interval", "mode"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var r api.RestartPolicy
+	if err := decodeWithDurations(m, &r); err != nil {
+		return err
+	}
+
+	*result = &r
+	return nil
+}
+
+func parseEphemeralDisk(result **api.EphemeralDisk, list *ast.ObjectList) error {
+	o, err := singleBlock("ephemeral_disk", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"migrate", "size", "sticky"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var d api.EphemeralDisk
+	if err := mapstructure.WeakDecode(m, &d); err != nil {
+		return err
+	}
+
+	*result = &d
+	return nil
+}
+
+func parseServices(t *api.Task, list *ast.ObjectList) error {
+	for idx, o := range list.Items {
+		if err := checkHCLKeys(o.Val, []string{"check", "name", "port", "tags"}); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("service (%d) ->", idx))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+		delete(m, "check")
+
+		var s api.Service
+		if err := mapstructure.WeakDecode(m, &s); err != nil {
+			return err
+		}
+
+		var checkList *ast.ObjectList
+		if ot, ok := o.Val.(*ast.ObjectType); ok {
+			checkList = ot.List
+		} else {
+			return fmt.Errorf("service '%s': should be an object", s.Name)
+		}
+
+		if co := checkList.Filter("check"); len(co.Items) > 0 {
+			if err := parseChecks(&s, co); err != nil {
+				return multierror.Prefix(err, fmt.Sprintf("service: '%s',", s.Name))
+			}
+		}
+
+		t.Services = append(t.Services, s)
+	}
+
+	return nil
+}
+
+func parseChecks(s *api.Service, list *ast.ObjectList) error {
+	for _, o := range list.Items {
+		valid := []string{
+			"args",
+			"command",
+			"initial_status",
+			"interval",
+			"name",
+			"path",
+			"port",
+			"protocol",
+			"timeout",
+			"type",
+		}
+		if err := checkHCLKeys(o.Val, valid); err != nil {
+			return multierror.Prefix(err, "check ->")
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		var c api.ServiceCheck
+		if err := decodeWithDurations(m, &c); err != nil {
+			return err
+		}
+
+		s.Checks = append(s.Checks, c)
+	}
+
+	return nil
+}
+
+func parseResources(result *api.Resources, list *ast.ObjectList) error {
+	o, err := singleBlock("resources", list)
+	if err != nil {
+		return err
+	}
+
+	var listVal *ast.ObjectList
+	if ot, ok := o.Val.(*ast.ObjectType); ok {
+		listVal = ot.List
+	} else {
+		return fmt.Errorf("resources: should be an object")
+	}
+
+	if err := checkHCLKeys(listVal, []string{"cpu", "disk", "iops", "memory", "network"}); err != nil {
+		return multierror.Prefix(err, "resources ->")
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+	delete(m, "network")
+
+	if err := mapstructure.WeakDecode(m, result); err != nil {
+		return err
+	}
+
+	if o := listVal.Filter("network"); len(o.Items) > 0 {
+		if len(o.Items) > 1 {
+			return fmt.Errorf("only one 'network' resource allowed")
+		}
+
+		var networkObj *ast.ObjectList
+		if ot, ok := o.Items[0].Val.(*ast.ObjectType); ok {
+			networkObj = ot.List
+		} else {
+			return fmt.Errorf("resources, network: should be an object")
+		}
+
+		if err := checkHCLKeys(networkObj, []string{"mbits", "port"}); err != nil {
+			return multierror.Prefix(err, "resources, network ->")
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Items[0].Val); err != nil {
+			return err
+		}
+		delete(m, "port")
+
+		var r api.NetworkResource
+		if err := mapstructure.WeakDecode(m, &r); err != nil {
+			return err
+		}
+
+		if err := parsePorts(networkObj, &r); err != nil {
+			return multierror.Prefix(err, "resources, network, ports ->")
+		}
+
+		result.Networks = []*api.NetworkResource{&r}
+	}
+
+	return nil
+}
+
+// parsePorts sets the labelled ports of the network. A port with a static
+// value is reserved, the rest are dynamic.
+func parsePorts(networkObj *ast.ObjectList, nw *api.NetworkResource) error {
+	seen := make(map[string]struct{})
+	for _, port := range networkObj.Filter("port").Items {
+		if len(port.Keys) == 0 {
+			return fmt.Errorf("ports must be named")
+		}
+
+		label := port.Keys[0].Token.Value().(string)
+		if !reDynamicPorts.MatchString(label) {
+			return errPortLabel
+		}
+
+		l := strings.ToLower(label)
+		if _, ok := seen[l]; ok {
+			return fmt.Errorf("found a port label collision: %s", label)
+		}
+		seen[l] = struct{}{}
+
+		if err := checkHCLKeys(port.Val, []string{"static"}); err != nil {
+			return multierror.Prefix(err, fmt.Sprintf("'%s' ->", label))
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, port.Val); err != nil {
+			return err
+		}
+
+		var p api.Port
+		if err := mapstructure.WeakDecode(m, &p); err != nil {
+			return err
+		}
+		p.Label = label
+
+		if p.Value > 0 {
+			nw.ReservedPorts = append(nw.ReservedPorts, p)
+		} else {
+			nw.DynamicPorts = append(nw.DynamicPorts, p)
+		}
+	}
+
+	return nil
+}
+
+func parseLogs(result **api.LogConfig, list *ast.ObjectList) error {
+	o, err := singleBlock("logs", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"max_file_size", "max_files"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var l api.LogConfig
+	if err := mapstructure.WeakDecode(m, &l); err != nil {
+		return err
+	}
+
+	*result = &l
+	return nil
+}
+
+func parseArtifacts(result *[]*api.TaskArtifact, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var optionList *ast.ObjectList
+		if ot, ok := o.Val.(*ast.ObjectType); ok {
+			optionList = ot.List
+		} else {
+			return fmt.Errorf("artifact should be an object")
+		}
+
+		if err := checkHCLKeys(optionList, []string{"destination", "options", "source"}); err != nil {
+			return err
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+		delete(m, "options")
+
+		// An artifact is downloaded to the task's local directory by default
+		if _, ok := m["destination"]; !ok {
+			m["destination"] = "local/"
+		}
+
+		var ta api.TaskArtifact
+		if err := mapstructure.WeakDecode(m, &ta); err != nil {
+			return err
+		}
+
+		if oo := optionList.Filter("options"); len(oo.Items) > 0 {
+			if err := parseStringMap(&ta.GetterOptions, oo); err != nil {
+				return multierror.Prefix(err, "options:")
+			}
+		}
+
+		*result = append(*result, &ta)
+	}
+
+	return nil
+}
+
+func parseTemplates(result *[]*api.Template, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		valid := []string{
+			"change_mode",
+			"change_signal",
+			"data",
+			"destination",
+			"left_delimiter",
+			"perms",
+			"right_delimiter",
+			"source",
+			"splay",
+		}
+		if err := checkHCLKeys(o.Val, valid); err != nil {
+			return err
+		}
+
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+
+		templ := &api.Template{
+			ChangeMode: helper.StringToPtr("restart"),
+			Splay:      helper.TimeToPtr(5 * time.Second),
+			Perms:      helper.StringToPtr("0644"),
+		}
+		if err := decodeWithDurations(m, templ); err != nil {
+			return err
+		}
+
+		*result = append(*result, templ)
+	}
+
+	return nil
+}
+
+func parseDispatchPayload(result **api.DispatchPayloadConfig, list *ast.ObjectList) error {
+	o, err := singleBlock("dispatch_payload", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"file"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	var d api.DispatchPayloadConfig
+	if err := mapstructure.WeakDecode(m, &d); err != nil {
+		return err
+	}
+
+	*result = &d
+	return nil
+}
+
+// newVault provides a vault block with the defaults of `nomad run`
+func newVault() *api.Vault {
+	return &api.Vault{
+		Env:        helper.BoolToPtr(true),
+		ChangeMode: helper.StringToPtr("restart"),
+	}
+}
+
+func parseVault(result *api.Vault, list *ast.ObjectList) error {
+	o, err := singleBlock("vault", list)
+	if err != nil {
+		return err
+	}
+
+	if err := checkHCLKeys(o.Val, []string{"change_mode", "change_signal", "env", "policies"}); err != nil {
+		return err
+	}
+
+	var m map[string]interface{}
+	if err := hcl.DecodeObject(&m, o.Val); err != nil {
+		return err
+	}
+
+	return mapstructure.WeakDecode(m, result)
+}
+
+// setTasksVault sets the vault block of the tasks that do not have one
+func setTasksVault(tasks []*api.Task, v *api.Vault) {
+	for _, t := range tasks {
+		if t.Vault == nil {
+			t.Vault = v
+		}
+	}
+}
+
+// parseStringMap merges the blocks of a key e.g. meta or env into the map
+func parseStringMap(result *map[string]string, list *ast.ObjectList) error {
+	for _, o := range list.Elem().Items {
+		var m map[string]interface{}
+		if err := hcl.DecodeObject(&m, o.Val); err != nil {
+			return err
+		}
+		if err := mapstructure.WeakDecode(m, result); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// singleBlock provides the block of the key & errors if the key is
+// repeated
+func singleBlock(key string, list *ast.ObjectList) (*ast.ObjectItem, error) {
+	list = list.Elem()
+	if len(list.Items) != 1 {
+		return nil, fmt.Errorf("only one '%s' block allowed", key)
+	}
+
+	return list.Items[0], nil
+}
+
+// decodeWithDurations decodes the map into the result with durations given
+// as strings e.g. "25s" or as nanoseconds
+func decodeWithDurations(m map[string]interface{}, result interface{}) error {
+	dec, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           result,
+	})
+	if err != nil {
+		return err
+	}
+
+	return dec.Decode(m)
+}
+
+func parseBool(value interface{}) (bool, error) {
+	switch v := value.(type) {
+	case string:
+		return strconv.ParseBool(v)
+	case bool:
+		return v, nil
+	default:
+		return false, fmt.Errorf("%v couldn't be converted to boolean value", value)
+	}
+}
+
+func checkHCLKeys(node ast.Node, valid []string) error {
+	var list *ast.ObjectList
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list = n
+	case *ast.ObjectType:
+		list = n.List
+	default:
+		return fmt.Errorf("cannot check HCL keys of type %T", n)
+	}
+
+	validMap := make(map[string]struct{}, len(valid))
+	for _, v := range valid {
+		validMap[v] = struct{}{}
+	}
+
+	var result error
+	for _, item := range list.Items {
+		key := item.Keys[0].Token.Value().(string)
+		if _, ok := validMap[key]; !ok {
+			result = multierror.Append(result, fmt.Errorf(
+				"invalid key: %s", key))
+		}
+	}
+
+	return result
+}
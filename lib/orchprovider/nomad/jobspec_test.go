@@ -0,0 +1,167 @@
+package nomad
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testHCLJobspec = `
+job "jivavol1" {
+  region      = "global"
+  datacenters = ["dc1", "dc2"]
+  priority    = 60
+
+  constraint {
+    attribute = "${attr.kernel.name}"
+    value     = "linux"
+  }
+
+  constraint {
+    distinct_hosts = true
+  }
+
+  update {
+    stagger      = "10s"
+    max_parallel = 1
+  }
+
+  vault {
+    policies = ["jiva"]
+  }
+
+  task "monitor" {
+    driver = "exec"
+
+    config {
+      command = "/bin/monitor"
+    }
+  }
+
+  group "fepod" {
+    count = 1
+
+    ephemeral_disk {
+      size = 500
+    }
+
+    task "fe1" {
+      driver       = "docker"
+      kill_timeout = "20s"
+
+      artifact {
+        source = "https://example.com/launch-jiva-ctl-with-ip"
+
+        options {
+          checksum = "md5:abc"
+        }
+      }
+
+      service {
+        name = "jiva-ctl"
+        port = "iscsi"
+
+        check {
+          type     = "tcp"
+          interval = "10s"
+          timeout  = "2s"
+        }
+      }
+
+      logs {
+        max_files = 3
+      }
+
+      resources {
+        memory = 256
+
+        network {
+          port "iscsi" {
+            static = 3260
+          }
+
+          port "api" {}
+        }
+      }
+
+      vault {
+        policies = ["jiva-ctl"]
+        env      = false
+      }
+    }
+  }
+}
+`
+
+func TestParseHCLJobspec(t *testing.T) {
+	job, err := parseHCLJobspec([]byte(testHCLJobspec))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if *job.ID != "jivavol1" || *job.Name != "jivavol1" || *job.Region != "global" || len(job.Datacenters) != 2 || *job.Priority != 60 {
+		t.Fatalf("bad job: %+v", job)
+	}
+
+	if len(job.Constraints) != 2 || job.Constraints[0].Operand != "=" || job.Constraints[0].RTarget != "linux" || job.Constraints[1].Operand != "distinct_hosts" {
+		t.Fatalf("bad constraints: %+v", job.Constraints)
+	}
+
+	if job.Update.Stagger != 10*time.Second || job.Update.MaxParallel != 1 {
+		t.Fatalf("bad update: %+v", job.Update)
+	}
+
+	// A task outside of a group gets a group of its own
+	if len(job.TaskGroups) != 2 || *job.TaskGroups[0].Name != "monitor" || job.TaskGroups[0].Tasks[0].Config["command"] != "/bin/monitor" {
+		t.Fatalf("bad task groups: %+v", job.TaskGroups)
+	}
+
+	// The job's vault block applies to the tasks without one
+	if v := job.TaskGroups[0].Tasks[0].Vault; v == nil || v.Policies[0] != "jiva" || !*v.Env {
+		t.Fatalf("bad job vault: %+v", v)
+	}
+
+	tg := job.TaskGroups[1]
+	if *tg.Count != 1 || *tg.EphemeralDisk.SizeMB != 500 {
+		t.Fatalf("bad group: %+v", tg)
+	}
+
+	fe := tg.Tasks[0]
+	if *fe.KillTimeout != 20*time.Second || *fe.LogConfig.MaxFiles != 3 || fe.Vault.Policies[0] != "jiva-ctl" || *fe.Vault.Env {
+		t.Fatalf("bad task: %+v", fe)
+	}
+
+	if len(fe.Artifacts) != 1 || *fe.Artifacts[0].RelativeDest != "local/" || fe.Artifacts[0].GetterOptions["checksum"] != "md5:abc" {
+		t.Fatalf("bad artifacts: %+v", fe.Artifacts)
+	}
+
+	if len(fe.Services) != 1 || fe.Services[0].PortLabel != "iscsi" || fe.Services[0].Checks[0].Interval != 10*time.Second {
+		t.Fatalf("bad services: %+v", fe.Services)
+	}
+
+	nw := fe.Resources.Networks[0]
+	if *fe.Resources.MemoryMB != 256 || len(nw.ReservedPorts) != 1 || nw.ReservedPorts[0].Label != "iscsi" || len(nw.DynamicPorts) != 1 || nw.DynamicPorts[0].Label != "api" {
+		t.Fatalf("bad resources: %+v", fe.Resources)
+	}
+}
+
+func TestParseHCLJobspec_Invalid(t *testing.T) {
+	for name, tc := range map[string]struct {
+		jobspec string
+		err     string
+	}{
+		"no job":          {`group "fepod" {}`, "invalid key: group"},
+		"unnamed job":     {`job { datacenters = ["dc1"] }`, "missing name"},
+		"two jobs":        {`job "a" {} job "b" {}`, "only one 'job' block"},
+		"job key":         {`job "a" { replicas = 2 }`, "invalid key: replicas"},
+		"task key":        {`job "a" { group "g" { task "t" { image = "jiva" } } }`, "invalid key: image"},
+		"duplicate group": {`job "a" { group "g" {} group "g" {} }`, "defined more than once"},
+		"port label":      {`job "a" { task "t" { resources { network { port "i-scsi" {} } } } }`, "naming requirements"},
+		"not hcl":         {`not a jobspec`, "error parsing"},
+	} {
+		_, err := parseHCLJobspec([]byte(tc.jobspec))
+		if err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Fatalf("%s: expected an error with '%s', got: %v", name, tc.err, err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package nomad
+
+import (
+	"time"
+
+	"github.com/openebs/mayaserver/lib/telemetry"
+)
+
+// Names of the Nomad API calls that are measured
+const (
+	callJobInfo        = "job_info"
+	callJobList        = "job_list"
+	callJobAllocations = "job_allocations"
+	callJobRegister    = "job_register"
+	callJobDeregister  = "job_deregister"
+	callEvalInfo       = "eval_info"
+)
+
+var (
+	// nomadCallDuration measures the latency of the Nomad API calls
+	nomadCallDuration = telemetry.NewHistogramVec(
+		"maya_nomad_api_duration_seconds",
+		"Latency of the Nomad API calls made by mayaserver.",
+		nil,
+		"call",
+	)
+
+	// nomadCallErrors counts the failed Nomad API calls
+	nomadCallErrors = telemetry.NewCounterVec(
+		"maya_nomad_api_errors_total",
+		"Number of Nomad API calls made by mayaserver that failed.",
+		"call",
+	)
+)
+
+func init() {
+	telemetry.MustRegister(nomadCallDuration, nomadCallErrors)
+}
+
+// observeNomadCall records the latency & the outcome of a Nomad API call
+// that started at the provided time
+func observeNomadCall(call string, start time.Time, err error) {
+	nomadCallDuration.Observe(time.Since(start).Seconds(), call)
+	if err != nil {
+		nomadCallErrors.Inc(call)
+	}
+}
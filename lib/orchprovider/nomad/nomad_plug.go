@@ -7,9 +7,11 @@ package nomad
 import (
 	"fmt"
 	"io"
+	"strconv"
 
 	"github.com/golang/glog"
-	//"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/nomad/structs"
 	"github.com/openebs/mayaserver/lib/api/v1"
 	"github.com/openebs/mayaserver/lib/orchprovider"
 )
@@ -44,9 +46,9 @@ func init() {
 //  1. orchprovider.OrchestratorInterface &
 //  2. orchprovider.StoragePlacements
 type NomadOrchestrator struct {
-	// nStorApis represents an instance capable of invoking
-	// storage related APIs
-	nStorApis StorageApis
+	// datacenters are mapped against their names. Each datacenter can
+	// invoke the storage related APIs of its Nomad servers.
+	datacenters map[string]*nomadDatacenter
 
 	// nApiClient represents an instance that can make connection &
 	// invoke Nomad APIs
@@ -77,21 +79,15 @@ func newNomadOrchestrator(config io.Reader) (*NomadOrchestrator, error) {
 	// Get a new instance of Nomad API Provider
 	apis := newNomadApiProvider(nCfg)
 
-	// Get the Nomad api client
-	nApiClient, err := apis.Client()
+	// Get the Nomad api clients of each datacenter
+	datacenters, err := newNomadDatacenters(apis, nCfg)
 	if err != nil {
-		return nil, fmt.Errorf("error creating Nomad api client: %v", err)
-	}
-
-	// Get Nomad's storage specific API provider
-	nStorApis, err := apis.StorageApis(nApiClient)
-	if err != nil {
-		return nil, fmt.Errorf("error creating Nomad storage operations instance: %v", err)
+		return nil, err
 	}
 
 	// build the orchestrator instance
 	nOrch := &NomadOrchestrator{
-		nStorApis: nStorApis,
+		datacenters: datacenters,
 		//nApiClient: nApiClient,
 		nConfig: nCfg,
 		//region:   regionName,
@@ -122,19 +118,57 @@ func (n *NomadOrchestrator) StoragePlacements() (orchprovider.StoragePlacements,
 //    Nomad does not have persistent volume as its first class citizen.
 // Hence, this resource should exhibit storage characteristics. The validations
 // for this should have been done at the volume plugin implementation.
-func (n *NomadOrchestrator) StorageInfoReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+func (n *NomadOrchestrator) StorageInfoReq(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
 
 	jobName, err := PvcToJobName(pvc)
 	if err != nil {
-		return nil, err
+		return nil, nil, v1.NewInvalidError(err)
 	}
 
-	job, err := n.nStorApis.StorageInfo(jobName)
+	dc, job, qm, err := n.lookupJob(pvc.Labels, jobName, QueryOptionsToNomad(opts))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	pv, err := JobToPv(job)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The status of each replica comes from the job's allocations
+	if !IsJobDead(job) {
+		allocs, err := dc.apis.StorageAllocations(jobName)
+		if err != nil {
+			return nil, nil, toVolumeError(err, jobName)
+		}
+
+		pv.Status.Replicas = JobAllocsToReplicaStatus(job, allocs)
 	}
 
-	return JobToPv(job)
+	if qm != nil {
+		pv.ResourceVersion = strconv.FormatUint(qm.LastIndex, 10)
+	}
+
+	return pv, QueryMetaFromNomad(qm), nil
+}
+
+// StorageListReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the details of all the storage resources will be fetched from a Nomad
+// deployment.
+//
+// NOTE:
+//    Nomad does not have persistent volume as its first class citizen.
+// Hence, only those jobs that exhibit storage characteristics are
+// considered.
+func (n *NomadOrchestrator) StorageListReq(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
+
+	jobs, qm, err := n.listJobs(queryLabels(opts), QueryOptionsToNomad(opts))
+	if err != nil {
+		return nil, toVolumeError(err, "")
+	}
+
+	return JobStubsToPvList(jobs, qm)
 }
 
 // StoragePlacementReq is a contract method implementation of
@@ -147,34 +181,52 @@ func (n *NomadOrchestrator) StorageInfoReq(pvc *v1.PersistentVolumeClaim) (*v1.P
 // for this should have been done at the volume plugin implementation.
 func (n *NomadOrchestrator) StoragePlacementReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
 
-	// TODO
-	// Check for the presence of region
-	// If region is present then:
-	//    1. fetch the NomadConfig applicable for this region
-	//    2. build the StorageApiClient
-	//    3. add these to a map in NomadOrchestrator struct
-	// If region is not present, then use the available region set against this
-	// NomadOrchestrator instance
-	// Set the pvc's Label with the region property
+	if _, err := PvcToJobName(pvc); err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
 
-	// TODO
-	// Check for the presence of datacenter
-	// If datacenter is present then fetch its StorageApiClient
-	// Set the pvc's Label with the datacenter property
+	// The job is registered at the datacenter of the claim
+	dc, err := n.datacenterOf(pvc.Labels)
+	if err != nil {
+		return nil, err
+	}
 
-	job, err := PvcToJob(pvc)
+	job, err := dc.jobOf(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	// A job that was stopped earlier can be registered again. The name of
+	// a volume is unique across the datacenters.
+	existing, err := n.runningJob(dc, *job.Name, v1.RequestID(pvc.ObjectMeta))
 	if err != nil {
 		return nil, err
 	}
 
-	eval, err := n.nStorApis.CreateStorage(job)
+	if existing != nil {
+		return nil, v1.NewAlreadyExistsError(*job.Name)
+	}
+
+	eval, err := dc.apis.CreateStorage(job)
+	if err != nil {
+		glog.Errorf("Nomad failed to register job '%s': %v request_id=%s", *job.Name, err, v1.RequestID(pvc.ObjectMeta))
+		return nil, toVolumeError(err, *job.Name)
+	}
+
+	glog.V(2).Infof("Volume '%s' was placed for provisioning with eval '%v' request_id=%s", *job.Name, eval, v1.RequestID(pvc.ObjectMeta))
+
+	pv, err := JobEvalToPv(*job.Name, eval)
 	if err != nil {
 		return nil, err
 	}
 
-	glog.V(2).Infof("Volume '%s' was placed for provisioning with eval '%v'", *job.Name, eval)
+	// The capacity is what has been placed rather than what was requested
+	pv.Spec.Capacity = JobToCapacity(job)
 
-	return JobEvalToPv(*job.Name, eval)
+	// A clone is reported with its source only if its job seeds it
+	SetPvCloneSource(pv, job)
+
+	return pv, nil
 }
 
 // StorageRemovalReq is a contract method implementation of
@@ -188,17 +240,236 @@ func (n *NomadOrchestrator) StoragePlacementReq(pvc *v1.PersistentVolumeClaim) (
 func (n *NomadOrchestrator) StorageRemovalReq(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
 
 	job, err := PvToJob(pv)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	// A job that was stopped earlier is as good as a missing volume
+	dc, existing, _, err := n.lookupJob(pv.Labels, *job.Name, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	eval, err := n.nStorApis.DeleteStorage(job)
+	if IsJobDead(existing) {
+		return nil, v1.NewNotFoundError(*job.Name, nil)
+	}
+
+	eval, err := dc.apis.DeleteStorage(job)
 
 	if err != nil {
-		return nil, err
+		glog.Errorf("Nomad failed to deregister job '%s': %v request_id=%s", *job.Name, err, v1.RequestID(pv.ObjectMeta))
+		return nil, toVolumeError(err, *job.Name)
 	}
 
-	glog.V(2).Infof("Volume '%s' was placed for removal with eval '%v'", pv.Name, eval)
+	glog.V(2).Infof("Volume '%s' was placed for removal with eval '%v' request_id=%s", pv.Name, eval, v1.RequestID(pv.ObjectMeta))
 
 	return JobEvalToPv(*job.Name, eval)
 }
+
+// StorageResizeReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the resource's job is updated at the Nomad deployment with the new size.
+//
+// NOTE:
+//    The jiva tasks are restarted by Nomad with the new size. The progress
+// of the update is reported in the status of the returned volume. Only
+// growing a volume is supported.
+func (n *NomadOrchestrator) StorageResizeReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	jobName, err := PvcToJobName(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return nil, v1.NewInvalidError(fmt.Errorf("Requested storage size of volume '%s' hasn't been provided", jobName))
+	}
+
+	newSize, err := QuantityToJivaVolSize(size)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	dc, job, _, err := n.lookupJob(pvc.Labels, jobName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsJobDead(job) {
+		return nil, v1.NewNotFoundError(jobName, nil)
+	}
+
+	curSize, found := JobJivaVolSize(job)
+	if !found {
+		return nil, v1.NewInvalidError(fmt.Errorf("Size of volume '%s' is not known", jobName))
+	}
+
+	curBytes, err := JivaVolSizeToBytes(curSize)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	if size.Value() < curBytes {
+		return nil, v1.NewInvalidError(fmt.Errorf("Volume '%s' can not be shrunk from '%s' to '%s'", jobName, curSize, newSize))
+	}
+
+	// Nothing to be done if the volume has the requested size
+	if size.Value() == curBytes {
+		return JobToPv(job)
+	}
+
+	SetJobJivaVolSize(job, newSize)
+
+	eval, err := dc.apis.UpdateStorage(job)
+	if err != nil {
+		glog.Errorf("Nomad failed to update job '%s': %v request_id=%s", jobName, err, v1.RequestID(pvc.ObjectMeta))
+		return nil, toVolumeError(err, jobName)
+	}
+
+	glog.V(2).Infof("Volume '%s' was placed for resize from '%s' to '%s' with eval '%v' request_id=%s", jobName, curSize, newSize, eval, v1.RequestID(pvc.ObjectMeta))
+
+	pv, err := JobEvalToPv(jobName, eval)
+	if err != nil {
+		return nil, err
+	}
+
+	pv.Spec.Capacity = v1.ResourceList{
+		v1.ResourceStorage: size,
+	}
+	pv.Status.Message = fmt.Sprintf("Resizing from '%s' to '%s': %s", curSize, newSize, eval.StatusDescription)
+
+	return pv, nil
+}
+
+// StorageAttachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is recorded in the Meta of the resource's job.
+//
+// NOTE:
+//    Nomad is the only store shared by all maya servers. The job is updated
+// against its modify index, hence concurrent attach requests for a volume
+// can not both succeed. A volume is attached to one instance at a time.
+func (n *NomadOrchestrator) StorageAttachReq(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+
+	dc, job, err := n.attachableJob(va)
+	if err != nil {
+		return nil, err
+	}
+
+	if cur, attached := JobToVolumeAttachment(job); attached {
+		// Attaching to the same instance again is not an error
+		if cur.Spec.InstanceID == va.Spec.InstanceID {
+			return cur, nil
+		}
+
+		return nil, v1.NewConflictError(fmt.Errorf("Volume '%s' is attached to instance '%s'", *job.Name, cur.Spec.InstanceID))
+	}
+
+	if *job.Status != structs.JobStatusRunning {
+		return nil, v1.NewUnavailableError(fmt.Errorf("Volume '%s' is not running", *job.Name))
+	}
+
+	ok, err := dc.apis.SetStorageAttachment(job, va.Spec.InstanceID)
+	if err != nil {
+		glog.Errorf("Nomad failed to update job '%s': %v request_id=%s", *job.Name, err, v1.RequestID(va.ObjectMeta))
+		return nil, toVolumeError(err, *job.Name)
+	}
+
+	if !ok {
+		return nil, v1.NewConflictError(fmt.Errorf("Volume '%s' was changed by another request", *job.Name))
+	}
+
+	glog.V(2).Infof("Volume '%s' was attached to instance '%s' request_id=%s", *job.Name, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	attached, _ := JobToVolumeAttachment(job)
+	return attached, nil
+}
+
+// StorageDetachReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is removed from the Meta of the resource's job.
+func (n *NomadOrchestrator) StorageDetachReq(va *v1.VolumeAttachment) error {
+
+	dc, job, err := n.attachableJob(va)
+	if err != nil {
+		return err
+	}
+
+	cur, attached := JobToVolumeAttachment(job)
+	if !attached || cur.Spec.InstanceID != va.Spec.InstanceID {
+		return &v1.VolumeError{
+			Reason:  v1.ReasonNotFound,
+			Message: fmt.Sprintf("Volume '%s' is not attached to instance '%s'", *job.Name, va.Spec.InstanceID),
+		}
+	}
+
+	ok, err := dc.apis.SetStorageAttachment(job, "")
+	if err != nil {
+		glog.Errorf("Nomad failed to update job '%s': %v request_id=%s", *job.Name, err, v1.RequestID(va.ObjectMeta))
+		return toVolumeError(err, *job.Name)
+	}
+
+	if !ok {
+		return v1.NewConflictError(fmt.Errorf("Volume '%s' was changed by another request", *job.Name))
+	}
+
+	glog.V(2).Infof("Volume '%s' was detached from instance '%s' request_id=%s", *job.Name, va.Spec.InstanceID, v1.RequestID(va.ObjectMeta))
+
+	return nil
+}
+
+// StorageAttachmentsReq is a contract method implementation of
+// orchprovider.StoragePlacements interface. In this implementation,
+// the attachment is read from the Meta of the resource's job.
+func (n *NomadOrchestrator) StorageAttachmentsReq(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+
+	jobName, err := PvcToJobName(pvc)
+	if err != nil {
+		return nil, v1.NewInvalidError(err)
+	}
+
+	_, job, _, err := n.lookupJob(pvc.Labels, jobName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if IsJobDead(job) {
+		return nil, v1.NewNotFoundError(jobName, nil)
+	}
+
+	list := &v1.VolumeAttachmentList{
+		Items: []v1.VolumeAttachment{},
+	}
+	if va, attached := JobToVolumeAttachment(job); attached {
+		list.Items = append(list.Items, *va)
+	}
+
+	return list, nil
+}
+
+// attachableJob provides the job of the attachment's volume along with its
+// datacenter. A job that was stopped is as good as a missing volume.
+func (n *NomadOrchestrator) attachableJob(va *v1.VolumeAttachment) (*nomadDatacenter, *api.Job, error) {
+
+	pvc, err := VolumeAttachmentToPvc(va)
+	if err != nil {
+		return nil, nil, v1.NewInvalidError(err)
+	}
+
+	jobName, err := PvcToJobName(pvc)
+	if err != nil {
+		return nil, nil, v1.NewInvalidError(err)
+	}
+
+	dc, job, _, err := n.lookupJob(pvc.Labels, jobName, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if IsJobDead(job) {
+		return nil, nil, v1.NewNotFoundError(jobName, nil)
+	}
+
+	return dc, job, nil
+}
@@ -0,0 +1,313 @@
+package nomad
+
+import (
+	"testing"
+
+	"github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// fakeStorageApis serves a single job & its allocations & remembers the
+// updated job
+type fakeStorageApis struct {
+	job     *api.Job
+	allocs  []*api.AllocationListStub
+	updated *api.Job
+
+	// changed makes the job look changed by another request when its
+	// attachment is recorded
+	changed bool
+}
+
+func (f *fakeStorageApis) CreateStorage(job *api.Job) (*api.Evaluation, error) {
+	return &api.Evaluation{Status: "pending"}, nil
+}
+
+func (f *fakeStorageApis) DeleteStorage(job *api.Job) (*api.Evaluation, error) {
+	return &api.Evaluation{Status: "pending"}, nil
+}
+
+func (f *fakeStorageApis) UpdateStorage(job *api.Job) (*api.Evaluation, error) {
+	f.updated = job
+	return &api.Evaluation{Status: "pending", StatusDescription: "placing"}, nil
+}
+
+func (f *fakeStorageApis) StorageInfo(jobName string, opts *api.QueryOptions) (*api.Job, *api.QueryMeta, error) {
+	return f.job, &api.QueryMeta{}, nil
+}
+
+func (f *fakeStorageApis) StorageList(opts *api.QueryOptions) ([]*api.JobListStub, *api.QueryMeta, error) {
+	return nil, &api.QueryMeta{}, nil
+}
+
+func (f *fakeStorageApis) StorageAllocations(jobName string) ([]*api.AllocationListStub, error) {
+	return f.allocs, nil
+}
+
+func (f *fakeStorageApis) SetStorageAttachment(job *api.Job, instanceID string) (bool, error) {
+	if f.changed {
+		return false, nil
+	}
+
+	SetJobAttachedInstance(job, instanceID)
+	f.updated = job
+	return true, nil
+}
+
+// envOrchestrator provides a NomadOrchestrator whose volumes are placed by
+// the Nomad servers that are not specific to a datacenter
+func envOrchestrator(apis StorageApis) *NomadOrchestrator {
+	return &NomadOrchestrator{
+		datacenters: map[string]*nomadDatacenter{
+			"": &nomadDatacenter{apis: apis},
+		},
+	}
+}
+
+func resizeClaim(size string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse(size),
+	}
+	return pvc
+}
+
+func runningJivaJob(size string) *api.Job {
+	return &api.Job{
+		Name:              helper.StringToPtr("jivavol1"),
+		Status:            helper.StringToPtr("running"),
+		StatusDescription: helper.StringToPtr(""),
+		Meta: map[string]string{
+			jobMetaTargetPortal: "10.0.0.10:3260",
+			jobMetaIQN:          "iqn.2016-09.com.openebs.jiva:jivavol1",
+		},
+		TaskGroups: []*api.TaskGroup{
+			&api.TaskGroup{
+				Name: helper.StringToPtr(jivaFeTaskGroup),
+				Tasks: []*api.Task{
+					&api.Task{Name: "fe1", Env: map[string]string{jivaCtlVolSizeEnv: size}},
+				},
+			},
+			&api.TaskGroup{
+				Name: helper.StringToPtr(jivaBeTaskGroup),
+				Tasks: []*api.Task{
+					&api.Task{Name: "be1", Env: map[string]string{jivaRepVolSizeEnv: size}},
+				},
+			},
+		},
+	}
+}
+
+func TestStorageResizeReq(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g")}
+	n := envOrchestrator(fake)
+
+	pv, err := n.StorageResizeReq(resizeClaim("10Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if fake.updated == nil {
+		t.Fatalf("expected the job to be updated")
+	}
+
+	if size, _ := JobJivaVolSize(fake.updated); size != "10g" {
+		t.Fatalf("bad updated size: %s", size)
+	}
+
+	if be := fake.updated.TaskGroups[1].Tasks[0].Env[jivaRepVolSizeEnv]; be != "10g" {
+		t.Fatalf("bad updated replica size: %s", be)
+	}
+
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	if capacity.String() != "10Gi" || pv.Status.Reason != "pending" {
+		t.Fatalf("bad resized volume: %#v", pv)
+	}
+}
+
+func TestStorageResizeReq_Shrink(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g")}
+	n := envOrchestrator(fake)
+
+	_, err := n.StorageResizeReq(resizeClaim("1Gi"))
+	if !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	if fake.updated != nil {
+		t.Fatalf("job should not be updated on shrink")
+	}
+}
+
+func TestStorageResizeReq_SameSize(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g")}
+	n := envOrchestrator(fake)
+
+	pv, err := n.StorageResizeReq(resizeClaim("5Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if fake.updated != nil {
+		t.Fatalf("job should not be updated for the same size")
+	}
+
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	if capacity.Value() != 5<<30 {
+		t.Fatalf("bad capacity: %s", capacity.String())
+	}
+}
+
+func attachment(instanceID string) *v1.VolumeAttachment {
+	va := &v1.VolumeAttachment{}
+	va.Spec.VolumeName = "jivavol1"
+	va.Spec.InstanceID = instanceID
+	return va
+}
+
+func TestStorageAttachReq(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g")}
+	n := envOrchestrator(fake)
+
+	va, err := n.StorageAttachReq(attachment("i-1"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if va.Status.TargetPortal != "10.0.0.10:3260" || va.Status.IQN != "iqn.2016-09.com.openebs.jiva:jivavol1" {
+		t.Fatalf("bad iSCSI target: %+v", va.Status)
+	}
+
+	if fake.updated == nil || fake.updated.Meta[jobMetaAttachedInstance] != "i-1" {
+		t.Fatalf("attachment was not recorded in the job")
+	}
+
+	// Attaching to the same instance again is a no-op
+	fake.updated = nil
+	if _, err := n.StorageAttachReq(attachment("i-1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if fake.updated != nil {
+		t.Fatalf("job should not be updated for the same instance")
+	}
+
+	// A volume is attached to one instance at a time
+	if _, err := n.StorageAttachReq(attachment("i-2")); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict, got: %v", err)
+	}
+
+	list, err := n.StorageAttachmentsReq(resizeClaim("5Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(list.Items) != 1 || list.Items[0].Spec.InstanceID != "i-1" {
+		t.Fatalf("bad attachments: %+v", list.Items)
+	}
+}
+
+func TestStorageAttachReq_Concurrent(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g"), changed: true}
+	n := envOrchestrator(fake)
+
+	if _, err := n.StorageAttachReq(attachment("i-1")); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict, got: %v", err)
+	}
+}
+
+func TestStorageDetachReq(t *testing.T) {
+	fake := &fakeStorageApis{job: runningJivaJob("5g")}
+	fake.job.Meta[jobMetaAttachedInstance] = "i-1"
+	n := envOrchestrator(fake)
+
+	if err := n.StorageDetachReq(attachment("i-2")); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found, got: %v", err)
+	}
+
+	if err := n.StorageDetachReq(attachment("i-1")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, found := fake.updated.Meta[jobMetaAttachedInstance]; found {
+		t.Fatalf("attachment was not removed from the job")
+	}
+
+	list, err := n.StorageAttachmentsReq(resizeClaim("5Gi"))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(list.Items) != 0 {
+		t.Fatalf("expected no attachments, got: %+v", list.Items)
+	}
+}
+
+func TestStorageInfoReq_Replicas(t *testing.T) {
+	job := runningJivaJob("5g")
+	job.TaskGroups[1].Tasks[0].Env[jivaRepIPEnv] = "10.0.0.11"
+	job.TaskGroups = append(job.TaskGroups, &api.TaskGroup{
+		Name: helper.StringToPtr(jivaBeTaskGroupName(2)),
+		Tasks: []*api.Task{
+			&api.Task{Name: "be2", Env: map[string]string{jivaRepVolSizeEnv: "5g", jivaRepIPEnv: "10.0.0.12"}},
+		},
+	})
+
+	fake := &fakeStorageApis{
+		job: job,
+		allocs: []*api.AllocationListStub{
+			&api.AllocationListStub{TaskGroup: jivaFeTaskGroup, NodeID: "node1", ClientStatus: "running", CreateIndex: 10},
+			&api.AllocationListStub{TaskGroup: jivaBeTaskGroup, NodeID: "node2", ClientStatus: "failed", CreateIndex: 10},
+			&api.AllocationListStub{TaskGroup: jivaBeTaskGroup, NodeID: "node3", ClientStatus: "running", CreateIndex: 20},
+		},
+	}
+	n := envOrchestrator(fake)
+
+	pv, _, err := n.StorageInfoReq(resizeClaim("5Gi"), nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := []v1.VolumeReplicaStatus{
+		{Name: "bepod", Address: "10.0.0.11", NodeID: "node3", Status: "running"},
+		{Name: "bepod2", Address: "10.0.0.12", Status: "pending"},
+	}
+
+	if len(pv.Status.Replicas) != len(expected) {
+		t.Fatalf("bad replicas: %+v", pv.Status.Replicas)
+	}
+
+	for i, rs := range expected {
+		if pv.Status.Replicas[i] != rs {
+			t.Fatalf("bad replica %d: expected %+v, got %+v", i, rs, pv.Status.Replicas[i])
+		}
+	}
+}
+
+func TestStoragePlacementReq_Capacity(t *testing.T) {
+	fake := &fakeStorageApis{job: &api.Job{Status: helper.StringToPtr("dead")}}
+	n := envOrchestrator(fake)
+
+	pvc := resizeClaim("3Gi")
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.101",
+		"jivabeip":        "172.28.128.102",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+	}
+	withJivaTaskLabels(pvc)
+
+	pv, err := n.StoragePlacementReq(pvc)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	if capacity.Value() != 3<<30 {
+		t.Fatalf("bad capacity: %s", capacity.String())
+	}
+}
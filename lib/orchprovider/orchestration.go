@@ -35,6 +35,27 @@ type StoragePlacements interface {
 	StorageRemovalReq(pv *v1.PersistentVolume) (*v1.PersistentVolume, error)
 
 	// StorageInfoReq will try to fetch the details of a particular storage
-	// resource
-	StorageInfoReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+	// resource. The query options, if provided, can block this call till
+	// the storage resource changes.
+	StorageInfoReq(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error)
+
+	// StorageListReq will try to fetch the details of all the storage
+	// resources that match the provided query options
+	StorageListReq(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error)
+
+	// StorageResizeReq will try to grow the storage resource(s) to the
+	// size requested by the claim
+	StorageResizeReq(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+
+	// StorageAttachReq will try to record the storage resource(s) as
+	// attached to the instance named in the attachment
+	StorageAttachReq(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error)
+
+	// StorageDetachReq will try to remove the record of the storage
+	// resource(s) attached to the instance named in the attachment
+	StorageDetachReq(va *v1.VolumeAttachment) error
+
+	// StorageAttachmentsReq will try to fetch the current attachments of
+	// the storage resource(s)
+	StorageAttachmentsReq(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error)
 }
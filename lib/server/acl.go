@@ -0,0 +1,133 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+const (
+	// TokenHeader is the request header that carries the secret of an
+	// ACL token
+	TokenHeader = "X-Maya-Token"
+)
+
+// aclCapability is an operation that is guarded by the ACLs
+type aclCapability string
+
+const (
+	aclCapMetadataRead aclCapability = "metadata-read"
+	aclCapVolumeRead   aclCapability = "volume-read"
+	aclCapVolumeWrite  aclCapability = "volume-write"
+)
+
+// aclPolicyCapabilities maps the ACL policies to the capabilities they
+// grant
+var aclPolicyCapabilities = map[string][]aclCapability{
+	config.ACLPolicyMetadataOnly: []aclCapability{aclCapMetadataRead},
+	config.ACLPolicyReadOnly:     []aclCapability{aclCapMetadataRead, aclCapVolumeRead},
+	config.ACLPolicyVolumeWrite:  []aclCapability{aclCapMetadataRead, aclCapVolumeRead, aclCapVolumeWrite},
+}
+
+// checkACL verifies if the request's token grants the capability that is
+// required by the request. A nil error is returned if ACLs are disabled.
+//
+// NOTE:
+//    A missing or an unknown token results in a 401, whereas a token
+// whose policy does not grant the required capability results in a 403.
+// An EC2 request that is signed via AWS Signature Version 4 is granted the
+// policy of the token with the signing access key id.
+func (s *HTTPServer) checkACL(req *http.Request) error {
+	acl := s.maya.config.ACL
+	if acl == nil || !acl.Enabled {
+		return nil
+	}
+
+	var token *config.ACLToken
+
+	// EC2 clients sign their requests rather than sending the token
+	if secret := req.Header.Get(TokenHeader); secret != "" {
+		token = findACLToken(acl, secret)
+		if token == nil {
+			return CodedError(401, "Invalid ACL token")
+		}
+	} else if isSigV4Request(req) {
+		var err error
+		token, err = findSigV4Token(acl, req, time.Now())
+		if err != nil {
+			return CodedError(401, err.Error())
+		}
+	} else {
+		return CodedError(401, fmt.Sprintf("Missing ACL token in %s header", TokenHeader))
+	}
+
+	capability := requiredCapability(req)
+	if telemetry := s.maya.config.Telemetry; telemetry != nil && telemetry.PrometheusMetrics && req.URL.Path == metricsPath(telemetry) {
+		capability = aclCapVolumeRead
+	}
+	for _, granted := range aclPolicyCapabilities[token.Policy] {
+		if granted == capability {
+			return nil
+		}
+	}
+
+	return CodedError(403, fmt.Sprintf("ACL token '%s' with policy '%s' does not permit %s", token.Name, token.Policy, capability))
+}
+
+// findACLToken provides the token that matches the secret
+func findACLToken(acl *config.ACLConfig, secret string) *config.ACLToken {
+	var match *config.ACLToken
+
+	// Compare against every token in constant time to avoid leaking the
+	// secrets via timing
+	for _, token := range acl.Tokens {
+		if subtle.ConstantTimeCompare([]byte(token.Secret), []byte(secret)) == 1 {
+			match = token
+		}
+	}
+
+	return match
+}
+
+// requiredCapability derives the capability that is required to serve
+// the request from its path & method. Requests that are not known to be
+// read only require the volume-write capability.
+func requiredCapability(req *http.Request) aclCapability {
+	path := req.URL.Path
+
+	switch {
+	case strings.HasPrefix(path, "/latest/meta-data/"):
+		return aclCapMetadataRead
+
+	case strings.HasPrefix(path, "/latest/volumes/"), strings.HasPrefix(path, "/latest/snapshots/"),
+		strings.HasPrefix(path, "/latest/storageclasses/"):
+		if req.Method == "GET" {
+			return aclCapVolumeRead
+		}
+		return aclCapVolumeWrite
+
+	case strings.HasPrefix(path, "/latest/volume/info/"):
+		return aclCapVolumeRead
+
+	case strings.HasPrefix(path, "/latest/volume/") && req.Method == "GET":
+		// The attachments of a volume can be listed with read access
+		if _, _, ok := parseAttachmentsPath(strings.TrimPrefix(path, "/latest/volume/")); ok {
+			return aclCapVolumeRead
+		}
+		return aclCapVolumeWrite
+
+	case path == "/":
+		// EC2 Query API requests carry their action as a parameter
+		if err := req.ParseForm(); err == nil && req.Form.Get("Action") == EC2ActionDescribeVolumes {
+			return aclCapVolumeRead
+		}
+		return aclCapVolumeWrite
+
+	default:
+		return aclCapVolumeWrite
+	}
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+func makeACLTestServer(t *testing.T) *TestServer {
+	return makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.ACL = &config.ACLConfig{
+			Enabled: true,
+			Tokens: map[string]*config.ACLToken{
+				"ops": &config.ACLToken{
+					Name:        "ops",
+					Secret:      "ops-secret",
+					Policy:      config.ACLPolicyVolumeWrite,
+					AccessKeyID: "AKIDOPS",
+				},
+				"monitor": &config.ACLToken{
+					Name:        "monitor",
+					Secret:      "monitor-secret",
+					Policy:      config.ACLPolicyReadOnly,
+					AccessKeyID: "AKIDMONITOR",
+				},
+				"vm": &config.ACLToken{
+					Name:   "vm",
+					Secret: "vm-secret",
+					Policy: config.ACLPolicyMetadataOnly,
+				},
+			},
+		}
+	})
+}
+
+func TestACL_Disabled(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, _ := http.NewRequest("DELETE", "/latest/volumes/myvol", nil)
+	if err := s.Server.checkACL(req); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestACL_Check(t *testing.T) {
+	s := makeACLTestServer(t)
+	defer s.Cleanup()
+
+	cases := []struct {
+		method string
+		path   string
+		secret string
+		code   int
+	}{
+		{"GET", "/latest/meta-data/instance-id", "", 401},
+		{"GET", "/latest/meta-data/instance-id", "bogus", 401},
+		{"GET", "/latest/meta-data/instance-id", "vm-secret", 0},
+		{"GET", "/latest/volumes/myvol", "vm-secret", 403},
+		{"GET", "/latest/volumes/myvol", "monitor-secret", 0},
+		{"GET", "/latest/volume/info/myvol", "monitor-secret", 0},
+		{"GET", "/latest/volume/delete/myvol", "monitor-secret", 403},
+		{"GET", "/latest/volume/myvol/attachments", "monitor-secret", 0},
+		{"PUT", "/latest/volume/myvol/attachments", "monitor-secret", 403},
+		{"DELETE", "/latest/volume/myvol/attachments/i-1", "ops-secret", 0},
+		{"DELETE", "/latest/volumes/myvol", "monitor-secret", 403},
+		{"PUT", "/latest/volumes/", "monitor-secret", 403},
+		{"DELETE", "/latest/volumes/myvol", "ops-secret", 0},
+		{"GET", "/latest/snapshots/myvol", "monitor-secret", 0},
+		{"PUT", "/latest/snapshots/", "monitor-secret", 403},
+		{"DELETE", "/latest/snapshots/myvol/snap1", "ops-secret", 0},
+		{"GET", "/?Action=DescribeVolumes", "monitor-secret", 0},
+		{"GET", "/?Action=DeleteVolume&VolumeId=myvol", "monitor-secret", 403},
+		{"GET", "/?Action=DeleteVolume&VolumeId=myvol", "ops-secret", 0},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		if c.secret != "" {
+			req.Header.Set(TokenHeader, c.secret)
+		}
+
+		err := s.Server.checkACL(req)
+		if c.code == 0 {
+			if err != nil {
+				t.Fatalf("%s %s with '%s': err: %v", c.method, c.path, c.secret, err)
+			}
+			continue
+		}
+
+		coded, ok := err.(HTTPCodedError)
+		if !ok || coded.Code() != c.code {
+			t.Fatalf("%s %s with '%s': expected: %d, got: %v", c.method, c.path, c.secret, c.code, err)
+		}
+	}
+}
+
+func TestACL_CheckEC2Signed(t *testing.T) {
+	s := makeACLTestServer(t)
+	defer s.Cleanup()
+
+	now := time.Now()
+
+	cases := []struct {
+		desc        string
+		form        string
+		accessKeyID string
+		secret      string
+		at          time.Time
+		code        int
+	}{
+		{"read", "Action=DescribeVolumes&Version=2016-11-15", "AKIDMONITOR", "monitor-secret", now, 0},
+		{"write with read-only", "Action=DeleteVolume&VolumeId=myvol", "AKIDMONITOR", "monitor-secret", now, 403},
+		{"write", "Action=DeleteVolume&VolumeId=myvol", "AKIDOPS", "ops-secret", now, 0},
+		{"wrong secret", "Action=DescribeVolumes", "AKIDOPS", "monitor-secret", now, 401},
+		{"unknown key", "Action=DescribeVolumes", "AKIDBOGUS", "ops-secret", now, 401},
+		{"expired", "Action=DescribeVolumes", "AKIDOPS", "ops-secret", now.Add(-time.Hour), 401},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", "http://ec2.example.com/", strings.NewReader(c.form))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+		signEC2Request(req, c.accessKeyID, c.secret, c.form, c.at)
+
+		err := s.Server.checkACL(req)
+		if c.code == 0 {
+			if err != nil {
+				t.Fatalf("%s: err: %v", c.desc, err)
+			}
+
+			// The form can still be read by the handler
+			if err := req.ParseForm(); err != nil || req.Form.Get("Action") == "" {
+				t.Fatalf("%s: form is not readable: %v", c.desc, err)
+			}
+			continue
+		}
+
+		coded, ok := err.(HTTPCodedError)
+		if !ok || coded.Code() != c.code {
+			t.Fatalf("%s: expected: %d, got: %v", c.desc, c.code, err)
+		}
+	}
+
+	// A tampered body does not match the signature
+	req, _ := http.NewRequest("POST", "http://ec2.example.com/", strings.NewReader("Action=DeleteVolume&VolumeId=other"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signEC2Request(req, "AKIDOPS", "ops-secret", "Action=DeleteVolume&VolumeId=myvol", now)
+
+	if coded, ok := s.Server.checkACL(req).(HTTPCodedError); !ok || coded.Code() != 401 {
+		t.Fatalf("expected 401 for a tampered request")
+	}
+}
+
+func TestACL_ViaWrap(t *testing.T) {
+	s := makeACLTestServer(t)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/latest/meta-data/instance-id", nil)
+	s.Server.wrap(s.Server.MetaSpecificRequest)(resp, req)
+
+	if resp.Code != 401 {
+		t.Fatalf("bad http code: expected: 401, got: %v", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/latest/meta-data/instance-id", nil)
+	req.Header.Set(TokenHeader, "vm-secret")
+	s.Server.wrap(s.Server.MetaSpecificRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v", resp.Code)
+	}
+}
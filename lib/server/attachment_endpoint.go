@@ -0,0 +1,145 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// attachmentsSegment is the path segment of a volume's attachments i.e.
+// {name}/attachments or {name}/attachments/{instance-id}
+const attachmentsSegment = "attachments"
+
+// parseAttachmentsPath parses the path of a volume's attachments that is
+// relative to the volume's collection. Returns false if the path is not of
+// a volume's attachments.
+func parseAttachmentsPath(path string) (volName, instanceID string, ok bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] != attachmentsSegment {
+		return "", "", false
+	}
+
+	if len(parts) == 3 {
+		if parts[2] == "" {
+			return "", "", false
+		}
+		instanceID = parts[2]
+	}
+
+	return parts[0], instanceID, true
+}
+
+// volumeAttachmentsRequest caters to the attachments of a volume i.e.
+// /latest/volume/{name}/attachments & /latest/volumes/{name}/attachments.
+// The attachments can be listed & a volume can be attached to an instance.
+// A DELETE on /attachments/{instance-id} detaches the volume from the
+// instance.
+func (s *HTTPServer) volumeAttachmentsRequest(resp http.ResponseWriter, req *http.Request, volName, instanceID string) (interface{}, error) {
+
+	if instanceID != "" {
+		if req.Method != "DELETE" {
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+		return s.volumeDetach(resp, req, volName, instanceID)
+	}
+
+	switch req.Method {
+	case "GET":
+		return s.volumeAttachments(resp, req, volName)
+	case "PUT", "POST":
+		return s.volumeAttach(resp, req, volName)
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+// volumeAttach attaches the volume to the instance named in the request's
+// body. The returned attachment carries the iSCSI target of the volume.
+func (s *HTTPServer) volumeAttach(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
+
+	va := v1.VolumeAttachment{}
+	if err := decodeBody(req, &va); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	if va.Spec.VolumeName != "" && va.Spec.VolumeName != volName {
+		return nil, CodedError(400, fmt.Sprintf("Volume name '%s' does not match the path '%s'", va.Spec.VolumeName, volName))
+	}
+	va.Spec.VolumeName = volName
+
+	if va.Spec.InstanceID == "" {
+		return nil, CodedError(400, fmt.Sprintf("Instance id to attach volume '%s' hasn't been provided", volName))
+	}
+
+	query := claimFromQuery(req, volName)
+	volPlugin, err := s.resolveVolumePlugin(query, requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	attacher, ok := volPlugin.Attacher()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume attachment is not supported by '%s'", volPlugin.Name()))
+	}
+
+	// The query params locate the volume if the body does not
+	if va.Labels == nil {
+		va.Labels = query.Labels
+	}
+
+	attached, err := attacher.Attach(&va)
+	if err != nil {
+		return nil, err
+	}
+
+	return attached, nil
+}
+
+// volumeAttachments lists the attachments of the volume
+func (s *HTTPServer) volumeAttachments(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
+
+	volPlugin, err := s.resolveVolumePlugin(claimFromQuery(req, volName), requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	attacher, ok := volPlugin.Attacher()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume attachment is not supported by '%s'", volPlugin.Name()))
+	}
+
+	list, err := attacher.Attachments(claimFromQuery(req, volName))
+	if err != nil {
+		return nil, err
+	}
+
+	return list, nil
+}
+
+// volumeDetach detaches the volume from the instance
+func (s *HTTPServer) volumeDetach(resp http.ResponseWriter, req *http.Request, volName, instanceID string) (interface{}, error) {
+
+	query := claimFromQuery(req, volName)
+	volPlugin, err := s.resolveVolumePlugin(query, requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	detacher, ok := volPlugin.Detacher()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume detachment is not supported by '%s'", volPlugin.Name()))
+	}
+
+	va := &v1.VolumeAttachment{}
+	va.Spec.VolumeName = volName
+	va.Spec.InstanceID = instanceID
+	va.Labels = query.Labels
+
+	if err := detacher.Detach(va); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+func TestParseAttachmentsPath(t *testing.T) {
+	cases := []struct {
+		path       string
+		volName    string
+		instanceID string
+		ok         bool
+	}{
+		{"myvol/attachments", "myvol", "", true},
+		{"myvol/attachments/i-1", "myvol", "i-1", true},
+		{"myvol", "", "", false},
+		{"/attachments", "", "", false},
+		{"myvol/attachments/", "", "", false},
+		{"myvol/attachments/i-1/nested", "", "", false},
+		{"info/myvol", "", "", false},
+	}
+
+	for _, c := range cases {
+		volName, instanceID, ok := parseAttachmentsPath(c.path)
+		if ok != c.ok || volName != c.volName || instanceID != c.instanceID {
+			t.Fatalf("%s: bad parse: '%s' '%s' %v", c.path, volName, instanceID, ok)
+		}
+	}
+}
+
+func TestVolumeAttachmentsRequest_InvalidRequests(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"DELETE", "/latest/volume/myvol/attachments", 405},
+		{"GET", "/latest/volume/myvol/attachments/i-1", 405},
+		{"DELETE", "/latest/volumes/myvol/attachments", 405},
+		{"PUT", "/latest/volumes/myvol/attachments/i-1", 405},
+		{"GET", "/latest/volume/myvol/attachments?storage-class=bogus", 400},
+		{"GET", "/latest/volumes/myvol/attachments?storage-class=bogus", 400},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		resp := httptest.NewRecorder()
+		s.Server.mux.ServeHTTP(resp, req)
+
+		if resp.Code != c.code {
+			t.Fatalf("%s %s: expected: %d, got: %d", c.method, c.path, c.code, resp.Code)
+		}
+	}
+}
+
+func TestVolumeAttach_MissingInstance(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	buf, _ := json.Marshal(v1.VolumeAttachment{})
+	req, _ := http.NewRequest("PUT", "/latest/volume/myvol/attachments", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.mux.ServeHTTP(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), "Instance id to attach volume 'myvol'") {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
@@ -0,0 +1,624 @@
+// This file exposes a front-end that understands EC2 Query API requests
+// related to volumes. This makes OpenEBS volumes available to EBS clients
+// e.g. aws-cli & AWS SDKs when these are pointed to mayaserver via their
+// endpoint url settings.
+//
+// NOTE:
+//    The EC2 requests are translated to mayaserver's volume claims & are
+// then handled by the volume plugins.
+package server
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/volume"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+const (
+	// EC2APIVersion is the version of EC2 API whose response shapes
+	// are served by mayaserver
+	EC2APIVersion = "2016-11-15"
+
+	// ec2XMLNS is the XML namespace of EC2 responses
+	ec2XMLNS = "http://ec2.amazonaws.com/doc/" + EC2APIVersion + "/"
+
+	// EC2 actions supported by mayaserver
+	EC2ActionCreateVolume    = "CreateVolume"
+	EC2ActionDeleteVolume    = "DeleteVolume"
+	EC2ActionDescribeVolumes = "DescribeVolumes"
+
+	// EC2 error codes returned by mayaserver
+	EC2ErrInvalidAction         = "InvalidAction"
+	EC2ErrMissingAction         = "MissingAction"
+	EC2ErrMissingParameter      = "MissingParameter"
+	EC2ErrInvalidParameterValue = "InvalidParameterValue"
+	EC2ErrInvalidVolumeNotFound = "InvalidVolume.NotFound"
+	EC2ErrUnsupportedOperation  = "UnsupportedOperation"
+	EC2ErrUnavailable           = "Unavailable"
+	EC2ErrInternalError         = "InternalError"
+
+	// EC2 volume states
+	EC2VolumeCreating  = "creating"
+	EC2VolumeAvailable = "available"
+	EC2VolumeDeleting  = "deleting"
+	EC2VolumeError     = "error"
+
+	// ec2VolumeType is the only volume type reported by mayaserver
+	ec2VolumeType = "standard"
+
+	// ec2NameTag is the tag key whose value is used as the volume name
+	ec2NameTag = "Name"
+)
+
+// ec2Error is an error that is rendered as an EC2 error response
+type ec2Error struct {
+	// status is the http status code
+	status int
+
+	// code is the EC2 error code
+	code string
+
+	msg string
+}
+
+func (e *ec2Error) Error() string {
+	return e.code + ": " + e.msg
+}
+
+func newEC2Error(status int, code string, msg string) *ec2Error {
+	return &ec2Error{
+		status: status,
+		code:   code,
+		msg:    msg,
+	}
+}
+
+// ec2Tag represents a key value pair attached to a volume
+type ec2Tag struct {
+	Key   string `xml:"key"`
+	Value string `xml:"value"`
+}
+
+// ec2Volume represents a volume in EC2 response shape
+type ec2Volume struct {
+	VolumeID         string   `xml:"volumeId"`
+	Size             int64    `xml:"size"`
+	SnapshotID       string   `xml:"snapshotId"`
+	AvailabilityZone string   `xml:"availabilityZone"`
+	Status           string   `xml:"status"`
+	CreateTime       string   `xml:"createTime,omitempty"`
+	VolumeType       string   `xml:"volumeType"`
+	Encrypted        bool     `xml:"encrypted"`
+	Tags             []ec2Tag `xml:"tagSet>item,omitempty"`
+}
+
+type ec2CreateVolumeResponse struct {
+	XMLName   xml.Name `xml:"CreateVolumeResponse"`
+	XMLNS     string   `xml:"xmlns,attr"`
+	RequestID string   `xml:"requestId"`
+	ec2Volume
+}
+
+type ec2DeleteVolumeResponse struct {
+	XMLName   xml.Name `xml:"DeleteVolumeResponse"`
+	XMLNS     string   `xml:"xmlns,attr"`
+	RequestID string   `xml:"requestId"`
+	Return    bool     `xml:"return"`
+}
+
+type ec2DescribeVolumesResponse struct {
+	XMLName   xml.Name    `xml:"DescribeVolumesResponse"`
+	XMLNS     string      `xml:"xmlns,attr"`
+	RequestID string      `xml:"requestId"`
+	Volumes   []ec2Volume `xml:"volumeSet>item"`
+}
+
+type ec2ErrorItem struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+type ec2ErrorResponse struct {
+	XMLName   xml.Name       `xml:"Response"`
+	Errors    []ec2ErrorItem `xml:"Errors>Error"`
+	RequestID string         `xml:"RequestID"`
+}
+
+// EC2Request is a http handler implementation that caters to EC2 Query API
+// requests. The EC2 action is derived from the Action parameter that is
+// sent either as a query parameter or as a form encoded body.
+//
+// NOTE:
+//    The responses are written as XML by this handler itself. Hence, it
+// always returns a nil object & a nil error to its wrapper.
+func (s *HTTPServer) EC2Request(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	// This handler is registered at root & hence gets all the
+	// unmatched paths
+	if req.URL.Path != "/" {
+		return nil, CodedError(404, fmt.Sprintf("Invalid path '%s'", req.URL.Path))
+	}
+
+	if req.Method != "GET" && req.Method != "POST" {
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+
+	reqID := requestID(req)
+
+	var out interface{}
+	var eErr *ec2Error
+
+	if err := req.ParseForm(); err != nil {
+		eErr = newEC2Error(400, EC2ErrInvalidParameterValue, err.Error())
+	} else {
+		switch action := req.Form.Get("Action"); action {
+		case EC2ActionCreateVolume:
+			out, eErr = s.ec2CreateVolume(req.Form, reqID)
+		case EC2ActionDeleteVolume:
+			out, eErr = s.ec2DeleteVolume(req.Form, reqID)
+		case EC2ActionDescribeVolumes:
+			out, eErr = s.ec2DescribeVolumes(req.Form, reqID)
+		case "":
+			eErr = newEC2Error(400, EC2ErrMissingAction, "The request must contain the parameter Action")
+		default:
+			eErr = newEC2Error(400, EC2ErrInvalidAction, fmt.Sprintf("The action %s is not valid for this web service", action))
+		}
+	}
+
+	if eErr != nil {
+		s.logger.Printf("[ERR] http: EC2 request %v, error: %v request_id=%s", req.URL, eErr, reqID)
+		out = &ec2ErrorResponse{
+			Errors: []ec2ErrorItem{
+				ec2ErrorItem{
+					Code:    eErr.code,
+					Message: eErr.msg,
+				},
+			},
+			RequestID: reqID,
+		}
+		writeEC2Response(resp, eErr.status, out)
+		return nil, nil
+	}
+
+	writeEC2Response(resp, 200, out)
+	return nil, nil
+}
+
+// ec2CreateVolume provisions a volume out of a CreateVolume request
+func (s *HTTPServer) ec2CreateVolume(form url.Values, reqID string) (interface{}, *ec2Error) {
+
+	// EC2 snapshot ids do not name the source volume of a clone
+	if snapID := form.Get("SnapshotId"); snapID != "" {
+		return nil, newEC2Error(400, EC2ErrInvalidParameterValue, fmt.Sprintf("Creating a volume from snapshot '%s' is not supported", snapID))
+	}
+
+	pvc, eErr := s.ec2FormToClaim(form)
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	volPlug, eErr := s.ec2VolumePlugin(pvc, reqID)
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	prov, ok := volPlug.Provisioner()
+	if !ok {
+		return nil, newEC2Error(400, EC2ErrUnsupportedOperation, fmt.Sprintf("Volume provisioning not supported by '%s'", volPlug.Name()))
+	}
+
+	if validator, ok := volPlug.Validator(); ok {
+		if err := validator.Validate(pvc); err != nil {
+			return nil, toEC2Error(err, pvc.Name)
+		}
+	}
+
+	if _, err := prov.Provision(pvc); err != nil {
+		return nil, toEC2Error(err, pvc.Name)
+	}
+
+	size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+	return &ec2CreateVolumeResponse{
+		XMLNS:     ec2XMLNS,
+		RequestID: reqID,
+		ec2Volume: ec2Volume{
+			VolumeID:         pvc.Name,
+			Size:             size.Value() >> 30,
+			AvailabilityZone: form.Get("AvailabilityZone"),
+			Status:           EC2VolumeCreating,
+			CreateTime:       time.Now().UTC().Format(time.RFC3339),
+			VolumeType:       ec2VolumeType,
+			Tags:             ec2LabelsToTags(pvc.Labels),
+		},
+	}, nil
+}
+
+// ec2DeleteVolume deletes a volume out of a DeleteVolume request
+func (s *HTTPServer) ec2DeleteVolume(form url.Values, reqID string) (interface{}, *ec2Error) {
+
+	volID := form.Get("VolumeId")
+	if volID == "" {
+		return nil, newEC2Error(400, EC2ErrMissingParameter, "The request must contain the parameter VolumeId")
+	}
+
+	// EC2 reports deletion of an unknown volume as an error
+	volPlug, _, eErr := s.ec2LocateVolume(volID, reqID)
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	del, ok := volPlug.Deleter()
+	if !ok {
+		return nil, newEC2Error(400, EC2ErrUnsupportedOperation, fmt.Sprintf("Deleting volume is not supported by '%s'", volPlug.Name()))
+	}
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = volID
+
+	if _, err := del.Delete(pv); err != nil {
+		return nil, toEC2Error(err, volID)
+	}
+
+	return &ec2DeleteVolumeResponse{
+		XMLNS:     ec2XMLNS,
+		RequestID: reqID,
+		Return:    true,
+	}, nil
+}
+
+// ec2DescribeVolumes fetches the volumes out of a DescribeVolumes request.
+// All the volumes are described if no volume ids are provided.
+func (s *HTTPServer) ec2DescribeVolumes(form url.Values, reqID string) (interface{}, *ec2Error) {
+
+	volIDs := ec2ListParam(form, "VolumeId")
+	volumes := []ec2Volume{}
+
+	if len(volIDs) == 0 {
+		volPlugs, eErr := s.ec2VolumePlugins(reqID)
+		if eErr != nil {
+			return nil, eErr
+		}
+
+		// A volume is listed once even if several plugins reach it
+		listed := map[string]bool{}
+
+		for _, volPlug := range volPlugs {
+			lister, ok := volPlug.Lister()
+			if !ok {
+				continue
+			}
+
+			pvList, err := lister.List(&v1.QueryOptions{
+				Region: s.maya.config.Region,
+			})
+			if err != nil {
+				return nil, toEC2Error(err, "")
+			}
+
+			for i := range pvList.Items {
+				if listed[pvList.Items[i].Name] {
+					continue
+				}
+				listed[pvList.Items[i].Name] = true
+				volumes = append(volumes, pvToEC2Volume(&pvList.Items[i]))
+			}
+		}
+	}
+
+	for _, volID := range volIDs {
+		_, pv, eErr := s.ec2LocateVolume(volID, reqID)
+		if eErr != nil {
+			return nil, eErr
+		}
+
+		volumes = append(volumes, pvToEC2Volume(pv))
+	}
+
+	return &ec2DescribeVolumesResponse{
+		XMLNS:     ec2XMLNS,
+		RequestID: reqID,
+		Volumes:   volumes,
+	}, nil
+}
+
+// ec2VolumeInfo fetches the details of a particular volume
+func (s *HTTPServer) ec2VolumeInfo(volPlug volume.VolumeInterface, volID string) (*v1.PersistentVolume, *ec2Error) {
+
+	informer, ok := volPlug.Informer()
+	if !ok {
+		return nil, newEC2Error(400, EC2ErrUnsupportedOperation, fmt.Sprintf("Volume information is not supported by '%s'", volPlug.Name()))
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = volID
+
+	pv, _, err := informer.Info(pvc, nil)
+	if err != nil {
+		return nil, toEC2Error(err, volID)
+	}
+
+	return pv, nil
+}
+
+// ec2VolumePlugin provides the volume plugin that caters to the claim of a
+// CreateVolume request
+func (s *HTTPServer) ec2VolumePlugin(pvc *v1.PersistentVolumeClaim, reqID string) (volume.VolumeInterface, *ec2Error) {
+
+	volPlug, err := s.resolveVolumePlugin(pvc, reqID)
+	if err != nil {
+		return nil, newEC2Error(400, EC2ErrInvalidParameterValue, err.Error())
+	}
+
+	return volPlug, nil
+}
+
+// ec2VolumePlugins provides the volume plugins that may have the volumes of
+// the EC2 requests i.e. the default one & the ones of the storage classes.
+// The default volume plugin is the first one.
+//
+// NOTE:
+//    EC2 requests other than CreateVolume do not carry the storage class of
+// the volume. Hence, a volume is looked up at each of these.
+func (s *HTTPServer) ec2VolumePlugins(reqID string) ([]volume.VolumeInterface, *ec2Error) {
+
+	defPlug, eErr := s.ec2VolumePlugin(nil, reqID)
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	volPlugs := []volume.VolumeInterface{defPlug}
+	seen := map[volPluginKey]bool{
+		{s.maya.config.DefaultVolumePlugin, s.maya.config.DefaultOrchProvider}: true,
+	}
+
+	for _, class := range s.maya.storageClasses.List().Items {
+		plugName, found := storageClassToVolumePlugin(class.Provisioner)
+		if !found {
+			continue
+		}
+
+		orchName := class.Orchestrator
+		if orchName == "" {
+			orchName = s.maya.config.DefaultOrchProvider
+		}
+
+		key := volPluginKey{plugName, orchName}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		// The orchestrator of the class may not have been initialized
+		volPlug, err := s.GetVolumePlugin(plugName, orchName)
+		if err != nil {
+			continue
+		}
+
+		volPlugs = append(volPlugs, &requestVolume{volPlug, reqID})
+	}
+
+	return volPlugs, nil
+}
+
+// ec2LocateVolume provides the volume along with the volume plugin that has
+// it. The errors of the volume plugins are reported only if the volume is
+// not found.
+func (s *HTTPServer) ec2LocateVolume(volID string, reqID string) (volume.VolumeInterface, *v1.PersistentVolume, *ec2Error) {
+
+	volPlugs, eErr := s.ec2VolumePlugins(reqID)
+	if eErr != nil {
+		return nil, nil, eErr
+	}
+
+	var lookupErr *ec2Error
+	for _, volPlug := range volPlugs {
+		pv, eErr := s.ec2VolumeInfo(volPlug, volID)
+		if eErr == nil {
+			return volPlug, pv, nil
+		}
+
+		if eErr.code != EC2ErrInvalidVolumeNotFound && lookupErr == nil {
+			lookupErr = eErr
+		}
+	}
+
+	if lookupErr != nil {
+		return nil, nil, lookupErr
+	}
+
+	return nil, nil, newEC2Error(400, EC2ErrInvalidVolumeNotFound, fmt.Sprintf("The volume '%s' does not exist.", volID))
+}
+
+// ec2FormToClaim transforms the parameters of a CreateVolume request to a
+// persistent volume claim.
+//
+// NOTE:
+//    The tags of the volume are set as the claim's labels. Hence, the jiva
+// specific settings e.g. jivafeip, jivabeip, etc. are provided as tags. A
+// tag with Name as its key is used as the name of the volume.
+func (s *HTTPServer) ec2FormToClaim(form url.Values) (*v1.PersistentVolumeClaim, *ec2Error) {
+
+	sizeStr := form.Get("Size")
+	if sizeStr == "" {
+		return nil, newEC2Error(400, EC2ErrMissingParameter, "The request must contain the parameter Size")
+	}
+
+	size, err := strconv.ParseInt(sizeStr, 10, 64)
+	if err != nil || size <= 0 {
+		return nil, newEC2Error(400, EC2ErrInvalidParameterValue, fmt.Sprintf("Invalid value '%s' for Size", sizeStr))
+	}
+
+	zone := form.Get("AvailabilityZone")
+	if zone == "" {
+		return nil, newEC2Error(400, EC2ErrMissingParameter, "The request must contain the parameter AvailabilityZone")
+	}
+
+	labels, eErr := ec2TagsToLabels(form)
+	if eErr != nil {
+		return nil, eErr
+	}
+
+	if labels["region"] == "" {
+		labels["region"] = s.maya.config.Region
+	}
+
+	if labels["datacenter"] == "" {
+		labels["datacenter"] = zone
+		if zone == AnyZone {
+			labels["datacenter"] = s.maya.config.Datacenter
+		}
+	}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = labels[ec2NameTag]
+	if pvc.Name == "" {
+		pvc.Name = newEC2VolumeID()
+	}
+	delete(labels, ec2NameTag)
+
+	pvc.Labels = labels
+	pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse(strconv.FormatInt(size, 10) + "Gi"),
+	}
+
+	return pvc, nil
+}
+
+// ec2TagsToLabels extracts the tags of a CreateVolume request. Tags are
+// provided as below:
+//
+//    TagSpecification.N.ResourceType=volume
+//    TagSpecification.N.Tag.M.Key=key
+//    TagSpecification.N.Tag.M.Value=value
+func ec2TagsToLabels(form url.Values) (map[string]string, *ec2Error) {
+	labels := map[string]string{}
+
+	for n := 1; ; n++ {
+		spec := "TagSpecification." + strconv.Itoa(n)
+		rType, found := form[spec+".ResourceType"]
+		if !found {
+			break
+		}
+
+		if len(rType) == 0 || rType[0] != "volume" {
+			return nil, newEC2Error(400, EC2ErrInvalidParameterValue, fmt.Sprintf("Unsupported resource type '%v' in %s", rType, spec))
+		}
+
+		for m := 1; ; m++ {
+			tag := spec + ".Tag." + strconv.Itoa(m)
+			key := form.Get(tag + ".Key")
+			if key == "" {
+				break
+			}
+			labels[key] = form.Get(tag + ".Value")
+		}
+	}
+
+	return labels, nil
+}
+
+// ec2LabelsToTags transforms the labels of a volume to EC2 tags
+func ec2LabelsToTags(labels map[string]string) []ec2Tag {
+	tags := []ec2Tag{}
+	for k, v := range labels {
+		tags = append(tags, ec2Tag{Key: k, Value: v})
+	}
+	return tags
+}
+
+// ec2ListParam extracts the values of a list parameter i.e. one that is
+// provided as Name.1, Name.2, etc.
+func ec2ListParam(form url.Values, name string) []string {
+	var vals []string
+	for n := 1; ; n++ {
+		val := form.Get(name + "." + strconv.Itoa(n))
+		if val == "" {
+			break
+		}
+		vals = append(vals, val)
+	}
+	return vals
+}
+
+// pvToEC2Volume transforms a persistent volume to its EC2 equivalent
+func pvToEC2Volume(pv *v1.PersistentVolume) ec2Volume {
+	size := pv.Spec.Capacity[v1.ResourceStorage]
+
+	vol := ec2Volume{
+		VolumeID:         pv.Name,
+		Size:             size.Value() >> 30,
+		AvailabilityZone: AnyZone,
+		Status:           pvToEC2VolumeStatus(pv),
+		VolumeType:       ec2VolumeType,
+		Tags:             ec2LabelsToTags(pv.Labels),
+	}
+
+	if !pv.CreationTimestamp.IsZero() {
+		vol.CreateTime = pv.CreationTimestamp.UTC().Format(time.RFC3339)
+	}
+
+	return vol
+}
+
+// pvToEC2VolumeStatus maps the status of a persistent volume to an EC2
+// volume state
+func pvToEC2VolumeStatus(pv *v1.PersistentVolume) string {
+	switch strings.ToLower(pv.Status.Reason) {
+	case "running":
+		return EC2VolumeAvailable
+	case "pending":
+		return EC2VolumeCreating
+	case "dead":
+		return EC2VolumeDeleting
+	default:
+		return EC2VolumeError
+	}
+}
+
+// toEC2Error transforms an error returned by a volume plugin to an EC2
+// error
+func toEC2Error(err error, volID string) *ec2Error {
+	switch v1.ReasonForError(err) {
+	case v1.ReasonNotFound:
+		return newEC2Error(400, EC2ErrInvalidVolumeNotFound, fmt.Sprintf("The volume '%s' does not exist.", volID))
+	case v1.ReasonAlreadyExists, v1.ReasonInvalid:
+		return newEC2Error(400, EC2ErrInvalidParameterValue, err.Error())
+	case v1.ReasonUnsupported:
+		return newEC2Error(400, EC2ErrUnsupportedOperation, err.Error())
+	case v1.ReasonUnavailable:
+		return newEC2Error(503, EC2ErrUnavailable, err.Error())
+	default:
+		return newEC2Error(500, EC2ErrInternalError, err.Error())
+	}
+}
+
+// writeEC2Response writes the EC2 response as XML
+func writeEC2Response(resp http.ResponseWriter, status int, out interface{}) {
+	buf, err := xml.Marshal(out)
+	if err != nil {
+		resp.WriteHeader(500)
+		resp.Write([]byte(err.Error()))
+		return
+	}
+
+	resp.Header().Set("Content-Type", "text/xml;charset=UTF-8")
+	resp.WriteHeader(status)
+	resp.Write([]byte(xml.Header))
+	resp.Write(buf)
+}
+
+// newEC2VolumeID provides a random id in the format of EC2 volume ids
+func newEC2VolumeID() string {
+	return "vol-" + randomHex(9)[:17]
+}
+
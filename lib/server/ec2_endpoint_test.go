@@ -0,0 +1,245 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+func TestEC2InvalidAction(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?Action=RunInstances", nil)
+	s.Server.wrap(s.Server.EC2Request)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	var out ec2ErrorResponse
+	if err := xml.Unmarshal(resp.Body.Bytes(), &out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(out.Errors) != 1 || out.Errors[0].Code != EC2ErrInvalidAction {
+		t.Fatalf("bad error response: %v", out)
+	}
+
+	if out.RequestID == "" {
+		t.Fatalf("expected a request id")
+	}
+}
+
+func TestEC2MissingAction(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("Version="+EC2APIVersion))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	s.Server.wrap(s.Server.EC2Request)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), EC2ErrMissingAction) {
+		t.Fatalf("bad error response: %s", resp.Body.String())
+	}
+}
+
+func TestEC2CreateVolumeMissingSize(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?Action=CreateVolume&AvailabilityZone=dc1", nil)
+	s.Server.wrap(s.Server.EC2Request)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), EC2ErrMissingParameter) {
+		t.Fatalf("bad error response: %s", resp.Body.String())
+	}
+}
+
+func TestEC2FormToClaim(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	form := url.Values{}
+	form.Set("Size", "3")
+	form.Set("AvailabilityZone", AnyZone)
+	form.Set("TagSpecification.1.ResourceType", "volume")
+	form.Set("TagSpecification.1.Tag.1.Key", "Name")
+	form.Set("TagSpecification.1.Tag.1.Value", "myjivavol")
+	form.Set("TagSpecification.1.Tag.2.Key", "jivafeip")
+	form.Set("TagSpecification.1.Tag.2.Value", "172.28.128.101")
+
+	pvc, eErr := s.Server.ec2FormToClaim(form)
+	if eErr != nil {
+		t.Fatalf("err: %v", eErr)
+	}
+
+	if pvc.Name != "myjivavol" {
+		t.Fatalf("bad claim name: %s", pvc.Name)
+	}
+
+	if _, found := pvc.Labels["Name"]; found {
+		t.Fatalf("name tag must not be a label: %v", pvc.Labels)
+	}
+
+	if pvc.Labels["jivafeip"] != "172.28.128.101" {
+		t.Fatalf("bad claim labels: %v", pvc.Labels)
+	}
+
+	// defaults are derived from mayaserver's config
+	if pvc.Labels["region"] != "global" || pvc.Labels["datacenter"] != "dc1" {
+		t.Fatalf("bad claim labels: %v", pvc.Labels)
+	}
+
+	size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if size.Value() != 3<<30 {
+		t.Fatalf("bad claim size: %v", size.String())
+	}
+}
+
+func TestEC2FormToClaim_GeneratedName(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	form := url.Values{}
+	form.Set("Size", "1")
+	form.Set("AvailabilityZone", "dc2")
+
+	pvc, eErr := s.Server.ec2FormToClaim(form)
+	if eErr != nil {
+		t.Fatalf("err: %v", eErr)
+	}
+
+	if !strings.HasPrefix(pvc.Name, "vol-") || len(pvc.Name) != 21 {
+		t.Fatalf("bad generated volume id: %s", pvc.Name)
+	}
+
+	if pvc.Labels["datacenter"] != "dc2" {
+		t.Fatalf("bad claim labels: %v", pvc.Labels)
+	}
+}
+
+func TestEC2CreateVolumeResponseShape(t *testing.T) {
+	out := &ec2CreateVolumeResponse{
+		XMLNS:     ec2XMLNS,
+		RequestID: "req-1",
+		ec2Volume: ec2Volume{
+			VolumeID: "vol-1",
+			Size:     3,
+			Status:   EC2VolumeCreating,
+		},
+	}
+
+	buf, err := xml.Marshal(out)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	for _, expected := range []string{
+		`<CreateVolumeResponse xmlns="` + ec2XMLNS + `">`,
+		`<requestId>req-1</requestId>`,
+		`<volumeId>vol-1</volumeId>`,
+		`<size>3</size>`,
+		`<status>creating</status>`,
+	} {
+		if !strings.Contains(string(buf), expected) {
+			t.Fatalf("expected: %s, got: %s", expected, string(buf))
+		}
+	}
+}
+
+func TestEC2CreateVolume_SnapshotId(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/?Action=CreateVolume&Size=1&AvailabilityZone=dc1&SnapshotId=snap-1", nil)
+	s.Server.wrap(s.Server.EC2Request)(resp, req)
+
+	if resp.Code != 400 || !strings.Contains(resp.Body.String(), EC2ErrInvalidParameterValue) {
+		t.Fatalf("bad response: %v: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestEC2Volumes_StorageClass(t *testing.T) {
+	// The default orchestrator i.e. Nomad does not have the volume
+	nomad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "job not found", 404)
+	}))
+	defer nomad.Close()
+
+	defer os.Setenv("NOMAD_ADDR", os.Getenv("NOMAD_ADDR"))
+	os.Setenv("NOMAD_ADDR", nomad.URL)
+
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.StorageClasses = map[string]*config.StorageClass{
+			"dev": &config.StorageClass{
+				Provisioner:  "jiva",
+				Orchestrator: "mock",
+			},
+		}
+	})
+	defer s.Cleanup()
+
+	// The volume is not placed by the default orchestrator
+	class := "dev"
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Spec.StorageClassName = &class
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeip":        "10.0.0.10",
+		"jivabeip":        "10.0.0.11",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "eth0",
+	}
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v: %s", resp.Code, resp.Body.String())
+	}
+
+	for _, c := range []struct {
+		query    string
+		code     int
+		expected string
+	}{
+		{"Action=DescribeVolumes&VolumeId.1=myvol", 200, "<volumeId>myvol</volumeId>"},
+		{"Action=DeleteVolume&VolumeId=myvol", 200, "<return>true</return>"},
+		// Neither the default orchestrator nor the class's one has it now
+		{"Action=DescribeVolumes&VolumeId.1=myvol", 400, "<Code>" + EC2ErrInvalidVolumeNotFound + "</Code>"},
+	} {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/?"+c.query, nil)
+		s.Server.wrap(s.Server.EC2Request)(resp, req)
+
+		if resp.Code != c.code || !strings.Contains(resp.Body.String(), c.expected) {
+			t.Fatalf("%s: expected: %d %s, got: %v: %s", c.query, c.code, c.expected, resp.Code, resp.Body.String())
+		}
+	}
+}
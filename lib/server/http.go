@@ -3,6 +3,10 @@ package server
 // This is an adaptation of Hashicorp's Nomad library.
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -21,6 +25,7 @@ import (
 	//"gopkg.in/yaml.v2"
 	"github.com/ghodss/yaml"
 
+	"github.com/openebs/mayaserver/lib/api/v1"
 	"github.com/openebs/mayaserver/lib/volume"
 )
 
@@ -29,6 +34,25 @@ const (
 	ErrInvalidMethod     = "Invalid method"
 	ErrGetMethodRequired = "GET method required"
 	ErrPutMethodRequired = "PUT/POST method required"
+
+	// ErrDeleteMethodRequired is used if a request that deletes a resource
+	// is not sent as DELETE or POST
+	ErrDeleteMethodRequired = "DELETE/POST method required"
+
+	// RequestIDHeader is the header that carries the id of the request. An
+	// id sent by the client is propagated, else a new one is generated.
+	RequestIDHeader = "X-Request-ID"
+
+	// maxRequestIDLen is the maximum length of an id sent by the client
+	maxRequestIDLen = 128
+)
+
+// ctxKey is the type of the keys of values set in a request's context
+type ctxKey int
+
+const (
+	// requestIDCtxKey is the context key of the request id
+	requestIDCtxKey ctxKey = iota
 )
 
 var (
@@ -49,6 +73,9 @@ type HTTPServer struct {
 	listener net.Listener
 	logger   *log.Logger
 	addr     string
+
+	// tls is set if the http api is served over TLS
+	tls *tlsReloader
 }
 
 // NewHTTPServer starts new HTTP server over Maya server
@@ -64,21 +91,15 @@ func NewHTTPServer(maya *MayaServer, config *config.MayaConfig, logOutput io.Wri
 	}
 
 	// If TLS is enabled, wrap the listener with a TLS listener
-	//if config.TLSConfig.EnableHTTP {
-	//	tlsConf := &tlsutil.Config{
-	//		VerifyIncoming:       false,
-	//		VerifyOutgoing:       true,
-	//		VerifyServerHostname: config.TLSConfig.VerifyServerHostname,
-	//		CAFile:               config.TLSConfig.CAFile,
-	//		CertFile:             config.TLSConfig.CertFile,
-	//		KeyFile:              config.TLSConfig.KeyFile,
-	//	}
-	//	tlsConfig, err := tlsConf.IncomingTLSConfig()
-	//	if err != nil {
-	//		return nil, err
-	//	}
-	//	ln = tls.NewListener(tcpKeepAliveListener{ln.(*net.TCPListener)}, tlsConfig)
-	//}
+	var reloader *tlsReloader
+	if config.TLS != nil && config.TLS.Enabled {
+		reloader, err = newTLSReloader(config.TLS)
+		if err != nil {
+			ln.Close()
+			return nil, err
+		}
+		ln = tls.NewListener(tcpKeepAliveListener{ln.(*net.TCPListener)}, reloader.Config())
+	}
 
 	// Create the mux
 	mux := http.NewServeMux()
@@ -90,8 +111,10 @@ func NewHTTPServer(maya *MayaServer, config *config.MayaConfig, logOutput io.Wri
 		listener: ln,
 		logger:   maya.logger,
 		addr:     ln.Addr().String(),
+		tls:      reloader,
 	}
 	srv.registerHandlers(config.ServiceProvider, config.EnableDebug)
+	srv.registerMetricsHandler(config.Telemetry)
 
 	// Start the server
 	go http.Serve(ln, gziphandler.GzipHandler(mux))
@@ -115,6 +138,28 @@ func (ln tcpKeepAliveListener) Accept() (c net.Conn, err error) {
 	return tc, nil
 }
 
+// ReloadTLS reads the TLS certificates again without closing the listener.
+// TLS can not be enabled or disabled via a reload.
+func (s *HTTPServer) ReloadTLS(conf *config.TLSConfig) error {
+	if s.tls == nil {
+		if conf != nil && conf.Enabled {
+			return fmt.Errorf("tls can not be enabled without a restart")
+		}
+		return nil
+	}
+
+	if conf == nil || !conf.Enabled {
+		return fmt.Errorf("tls can not be disabled without a restart")
+	}
+
+	if err := s.tls.Reload(conf); err != nil {
+		return err
+	}
+
+	s.logger.Printf("[INFO] http: Reloaded the tls certificates")
+	return nil
+}
+
 // Shutdown is used to shutdown the HTTP server
 func (s *HTTPServer) Shutdown() {
 	if s != nil {
@@ -130,18 +175,54 @@ func (s *HTTPServer) registerHandlers(serviceProvider string, enableDebug bool)
 	// NOTE - The original handler is passed as a func to the wrap method
 	s.mux.HandleFunc("/latest/meta-data/", s.wrap(s.MetaSpecificRequest))
 
-	// Can be a GET, PUT, or POST.
+	// Can be a GET, PUT, or POST on the collection.
+	// Can be a GET, PUT, or DELETE on a particular volume.
+	// Can be a PUT or POST on a particular volume's resize i.e. {name}/resize.
+	// Can be a GET, PUT or POST on a particular volume's attachments i.e.
+	// {name}/attachments & a DELETE on {name}/attachments/{instance-id}.
 	// Handler has the intelligence to cater to various http methods.
 	s.mux.HandleFunc("/latest/volumes/", s.wrap(s.VolumesRequest))
 
+	// Can be a PUT or POST on the collection to take a snapshot.
+	// Can be a GET on a volume's snapshots i.e. /latest/snapshots/{volume}.
+	// Can be a DELETE on a particular snapshot.
+	s.mux.HandleFunc("/latest/snapshots/", s.wrap(s.SnapshotsRequest))
+
+	// Can be a GET, PUT, or POST on the collection.
+	// Can be a GET, PUT, or DELETE on a particular storage class.
+	s.mux.HandleFunc("/latest/storageclasses/", s.wrap(s.StorageClassesRequest))
+
 	// A particular volume specific request is handled here
+	// NOTE - These are deprecated & are served only if enabled via config
+	// NOTE - {name}/attachments is not deprecated & is always served
 	s.mux.HandleFunc("/latest/volume/", s.wrap(s.VolumeSpecificRequest))
+
+	// EC2 Query API requests e.g. from aws-cli are handled here
+	s.mux.HandleFunc("/", s.wrap(s.EC2Request))
+}
+
+// registerMetricsHandler exposes the metrics if enabled via the telemetry
+// config
+func (s *HTTPServer) registerMetricsHandler(telemetry *config.Telemetry) {
+	if telemetry == nil || !telemetry.PrometheusMetrics {
+		return
+	}
+
+	s.mux.HandleFunc(metricsPath(telemetry), s.wrap(s.MetricsRequest))
+}
+
+// metricsPath provides the http path at which the metrics are exposed
+func metricsPath(telemetry *config.Telemetry) string {
+	if telemetry == nil || telemetry.MetricsPath == "" {
+		return "/metrics"
+	}
+	return telemetry.MetricsPath
 }
 
 // GetVolumePlugin is a pass through function that provides a particular
-// volume plugin
-func (s *HTTPServer) GetVolumePlugin(name string) (volume.VolumeInterface, error) {
-	return s.maya.GetVolumePlugin(name)
+// volume plugin linked with the named orchestrator
+func (s *HTTPServer) GetVolumePlugin(name string, orchName string) (volume.VolumeInterface, error) {
+	return s.maya.GetVolumePlugin(name, orchName)
 }
 
 // HTTPCodedError is used to provide the HTTP error code
@@ -167,6 +248,112 @@ func (e *codedError) Code() int {
 	return e.code
 }
 
+// errorToResponse transforms an error to the http status code & the
+// error envelope that is sent to the client. The typed errors of volume
+// plugins are mapped to their http equivalents.
+func errorToResponse(err error, reqID string) (int, *v1.ErrorResponse) {
+	code := 500
+	reason := v1.ReasonForError(err)
+
+	if http, ok := err.(HTTPCodedError); ok {
+		code = http.Code()
+		reason = codeToReason(code)
+	} else {
+		switch reason {
+		case v1.ReasonNotFound:
+			code = 404
+		case v1.ReasonAlreadyExists, v1.ReasonConflict:
+			code = 409
+		case v1.ReasonInvalid:
+			code = 400
+		case v1.ReasonUnsupported:
+			code = 501
+		case v1.ReasonUnavailable:
+			code = 503
+		}
+	}
+
+	errResp := &v1.ErrorResponse{
+		Code:      code,
+		Reason:    reason,
+		Message:   err.Error(),
+		RequestID: reqID,
+	}
+
+	if vErr, ok := err.(*v1.VolumeError); ok {
+		errResp.Causes = vErr.Causes
+		errResp.Replicas = vErr.Replicas
+	}
+
+	return code, errResp
+}
+
+// codeToReason provides the reason of a coded error
+func codeToReason(code int) v1.ErrorReason {
+	switch code {
+	case 400:
+		return v1.ReasonInvalid
+	case 404:
+		return v1.ReasonNotFound
+	case 401:
+		return v1.ReasonUnauthorized
+	case 403:
+		return v1.ReasonForbidden
+	case 405:
+		return v1.ReasonMethodNotAllowed
+	case 409:
+		return v1.ReasonAlreadyExists
+	case 501:
+		return v1.ReasonUnsupported
+	case 503:
+		return v1.ReasonUnavailable
+	default:
+		return v1.ReasonUnknown
+	}
+}
+
+// newRequestID provides a random id in the format of a UUID
+func newRequestID() string {
+	b := randomHex(16)
+	return fmt.Sprintf("%s-%s-%s-%s-%s", b[0:8], b[8:12], b[12:16], b[16:20], b[20:32])
+}
+
+// inboundRequestID provides the id sent by the client if it is fit to be
+// logged, else a new id is generated
+func inboundRequestID(req *http.Request) string {
+	id := req.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestIDLen {
+		return newRequestID()
+	}
+
+	// Only printable ascii characters without spaces are accepted
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return newRequestID()
+		}
+	}
+
+	return id
+}
+
+// requestID provides the id that was set against the request by wrap
+func requestID(req *http.Request) string {
+	if id, ok := req.Context().Value(requestIDCtxKey).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// randomHex provides a hex encoded string of n random bytes
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		// Fallback to current time; this is not expected to happen
+		return fmt.Sprintf("%0*x", n*2, time.Now().UnixNano())[:n*2]
+	}
+	return hex.EncodeToString(b)
+}
+
 // wrap is a convenient method used to wrap the handler function &
 // return this handler curried with common logic.
 func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Request) (interface{}, error)) func(resp http.ResponseWriter, req *http.Request) {
@@ -176,24 +363,49 @@ func (s *HTTPServer) wrap(handler func(resp http.ResponseWriter, req *http.Reque
 		setHeaders(resp, s.maya.config.HTTPAPIResponseHeaders)
 		reqURL := req.URL.String()
 		start := time.Now()
+
+		// Every request is identified by an id that is sent back to the
+		// client & is set against every log line of the request
+		reqID := inboundRequestID(req)
+		req = req.WithContext(context.WithValue(req.Context(), requestIDCtxKey, reqID))
+		resp.Header().Set(RequestIDHeader, reqID)
+
+		// The route i.e. the mux pattern is used as the metrics label as
+		// it has a bounded set of values unlike the request path
+		_, route := s.mux.Handler(req)
+		rec := &statusRecorder{ResponseWriter: resp}
+		resp = rec
 		defer func() {
-			s.logger.Printf("[DEBUG] http: Request %v (%v)", reqURL, time.Now().Sub(start))
+			// Access log of the request
+			s.logger.Printf("[INFO] http: method=%s path=%s status=%d bytes=%d duration=%v remote=%s request_id=%s",
+				req.Method, req.URL.Path, rec.status(), rec.bytes, time.Now().Sub(start), req.RemoteAddr, reqID)
+			observeRequest(route, req.Method, rec.status(), start)
 		}()
 
-		// Original handler is invoked
-		obj, err := handler(resp, req)
+		// Original handler is invoked if the request is permitted
+		var obj interface{}
+		err := s.checkACL(req)
+		if err == nil {
+			obj, err = handler(resp, req)
+		}
 
 		// Check for an error & set it as an http error
 		// Below err block for re-usability
 	HAS_ERR:
 		if err != nil {
-			s.logger.Printf("[ERR] http: Request %v, error: %v", reqURL, err)
-			code := 500
-			if http, ok := err.(HTTPCodedError); ok {
-				code = http.Code()
+			s.logger.Printf("[ERR] http: Request %v, error: %v request_id=%s", reqURL, err, reqID)
+			code, errResp := errorToResponse(err, reqID)
+
+			var buf bytes.Buffer
+			if encErr := codec.NewEncoder(&buf, jsonHandle).Encode(errResp); encErr != nil {
+				resp.WriteHeader(code)
+				resp.Write([]byte(err.Error()))
+				return
 			}
+
+			resp.Header().Set("Content-Type", "application/json")
 			resp.WriteHeader(code)
-			resp.Write([]byte(err.Error()))
+			resp.Write(buf.Bytes())
 			return
 		}
 
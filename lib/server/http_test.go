@@ -10,9 +10,11 @@ import (
 	"net/http/httptest"
 	"os"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/openebs/mayaserver/lib/api/v1"
 	"github.com/openebs/mayaserver/lib/config"
 	"github.com/openebs/mayaserver/structs"
 	"github.com/ugorji/go/codec"
@@ -422,3 +424,134 @@ func encodeReq(obj interface{}) io.ReadCloser {
 	enc.Encode(obj)
 	return ioutil.NopCloser(buf)
 }
+
+func TestErrorToResponse(t *testing.T) {
+	cases := []struct {
+		err    error
+		code   int
+		reason v1.ErrorReason
+	}{
+		{v1.NewNotFoundError("myvol", nil), 404, v1.ReasonNotFound},
+		{v1.NewAlreadyExistsError("myvol"), 409, v1.ReasonAlreadyExists},
+		{v1.NewInvalidError(fmt.Errorf("bad claim")), 400, v1.ReasonInvalid},
+		{v1.NewUnavailableError(fmt.Errorf("nomad down")), 503, v1.ReasonUnavailable},
+		{v1.NewUnsupportedError(fmt.Errorf("no coalesce")), 501, v1.ReasonUnsupported},
+		{CodedError(405, ErrInvalidMethod), 405, v1.ReasonMethodNotAllowed},
+		{fmt.Errorf("boom"), 500, v1.ReasonUnknown},
+	}
+
+	for _, c := range cases {
+		code, errResp := errorToResponse(c.err, "req-1")
+		if code != c.code || errResp.Code != c.code || errResp.Reason != c.reason {
+			t.Fatalf("bad response for '%v': %d %#v", c.err, code, errResp)
+		}
+
+		if errResp.Message != c.err.Error() || errResp.RequestID != "req-1" {
+			t.Fatalf("bad response for '%v': %#v", c.err, errResp)
+		}
+	}
+}
+
+func TestErrorToResponse_Replicas(t *testing.T) {
+	err := &v1.VolumeError{
+		Reason:  v1.ReasonUnavailable,
+		Message: "Snapshot 'snap1' was removed from 1 of 2 jiva replicas",
+		Replicas: []v1.ReplicaStatus{
+			v1.ReplicaStatus{Address: "tcp://10.0.0.2:9502", Status: v1.ReplicaDone},
+			v1.ReplicaStatus{Address: "tcp://10.0.0.3:9502", Status: v1.ReplicaFailed, Message: "disk is busy"},
+		},
+	}
+
+	code, errResp := errorToResponse(err, "req-1")
+	if code != 503 || len(errResp.Replicas) != 2 || errResp.Replicas[1].Status != v1.ReplicaFailed {
+		t.Fatalf("bad response: %d %#v", code, errResp)
+	}
+}
+
+func TestWrapErrorEnvelope(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		return nil, v1.NewNotFoundError("myvol", nil)
+	}
+
+	req, _ := http.NewRequest("GET", "/latest/volumes/myvol", nil)
+	s.Server.wrap(handler)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v", resp.Code)
+	}
+
+	var out v1.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if out.Reason != v1.ReasonNotFound || out.RequestID == "" {
+		t.Fatalf("bad error envelope: %#v", out)
+	}
+
+	if out.RequestID != resp.Header().Get(RequestIDHeader) {
+		t.Fatalf("bad request id: expected: %s, got: %s", resp.Header().Get(RequestIDHeader), out.RequestID)
+	}
+}
+
+func TestWrapErrorEnvelope_MethodNotAllowed(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	resp := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/latest/volumes/myvol", nil)
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 405 {
+		t.Fatalf("bad http code: expected: 405, got: %v", resp.Code)
+	}
+
+	var out v1.ErrorResponse
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if out.Code != 405 || out.Reason != v1.ReasonMethodNotAllowed {
+		t.Fatalf("bad error envelope: %#v", out)
+	}
+}
+
+func TestWrapRequestID(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	var handled string
+	handler := func(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+		handled = requestID(req)
+		return nil, nil
+	}
+
+	cases := map[string]bool{
+		"client-req-1":            true,
+		"":                        false,
+		"has space":               false,
+		strings.Repeat("a", 129):  false,
+		"1c4e8f0a-27c2-4bde-ae41": true,
+		"bad\nline":               false,
+	}
+
+	for inbound, propagated := range cases {
+		resp := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/latest/meta-data/instance-id", nil)
+		req.Header.Set(RequestIDHeader, inbound)
+		s.Server.wrap(handler)(resp, req)
+
+		out := resp.Header().Get(RequestIDHeader)
+		if out == "" || out != handled {
+			t.Fatalf("bad request id for '%s': header: %s, handler: %s", inbound, out, handled)
+		}
+
+		if propagated != (out == inbound) {
+			t.Fatalf("bad request id for '%s': %s", inbound, out)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	"github.com/openebs/mayaserver/lib/api/v1"
 	"github.com/ugorji/go/codec"
 )
 
@@ -137,8 +138,8 @@ func TestInvalidReqPathMetaViaWrap1(t *testing.T) {
 
 	contentType := resp.Header().Get("Content-Type")
 
-	if len(contentType) != 0 {
-		t.Fatalf("err content type, expected: nil, got: %s", contentType)
+	if contentType != "application/json" {
+		t.Fatalf("err content type, expected: application/json, got: %s", contentType)
 	}
 
 	// This should be an invalid path/method error
@@ -147,14 +148,14 @@ func TestInvalidReqPathMetaViaWrap1(t *testing.T) {
 	}
 
 	// actuals
-	actual, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	var actual v1.ErrorResponse
+	if err := codec.NewDecoder(resp.Body, jsonHandle).Decode(&actual); err != nil {
 		t.Fatalf("err reading response: %v", err)
 	}
 
 	// compare expectations with actuals
-	if !bytes.Equal([]byte(ErrInvalidMethod), actual) {
-		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", ErrInvalidMethod, string(actual))
+	if actual.Message != ErrInvalidMethod || actual.Code != 405 {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%#v", ErrInvalidMethod, actual)
 	}
 }
 
@@ -183,8 +184,8 @@ func TestInvalidReqPathMetaViaWrap2(t *testing.T) {
 
 	contentType := resp.Header().Get("Content-Type")
 
-	if len(contentType) != 0 {
-		t.Fatalf("err content type, expected: nil, got: %s", contentType)
+	if contentType != "application/json" {
+		t.Fatalf("err content type, expected: application/json, got: %s", contentType)
 	}
 
 	// This should be an invalid path/method error
@@ -193,14 +194,14 @@ func TestInvalidReqPathMetaViaWrap2(t *testing.T) {
 	}
 
 	// actuals
-	actual, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	var actual v1.ErrorResponse
+	if err := codec.NewDecoder(resp.Body, jsonHandle).Decode(&actual); err != nil {
 		t.Fatalf("err reading response: %v", err)
 	}
 
 	// compare expectations with actuals
-	if !bytes.Equal([]byte(ErrInvalidMethod), actual) {
-		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", ErrInvalidMethod, string(actual))
+	if actual.Message != ErrInvalidMethod || actual.Code != 405 {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%#v", ErrInvalidMethod, actual)
 	}
 }
 
@@ -276,8 +277,8 @@ func TestInvalidReqMetaAvailZoneViaWrap(t *testing.T) {
 
 	contentType := resp.Header().Get("Content-Type")
 
-	if len(contentType) != 0 {
-		t.Fatalf("err content type, expected: nil, got: %s", contentType)
+	if contentType != "application/json" {
+		t.Fatalf("err content type, expected: application/json, got: %s", contentType)
 	}
 
 	// This should be an invalid path/method error
@@ -286,14 +287,14 @@ func TestInvalidReqMetaAvailZoneViaWrap(t *testing.T) {
 	}
 
 	// actuals
-	actual, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	var actual v1.ErrorResponse
+	if err := codec.NewDecoder(resp.Body, jsonHandle).Decode(&actual); err != nil {
 		t.Fatalf("err reading response: %v", err)
 	}
 
 	// compare expectations with actuals
-	if !bytes.Equal([]byte(ErrInvalidMethod), actual) {
-		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", ErrInvalidMethod, string(actual))
+	if actual.Message != ErrInvalidMethod || actual.Code != 405 {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%#v", ErrInvalidMethod, actual)
 	}
 }
 
@@ -369,8 +370,8 @@ func TestInvalidReqMetaInstanceIDViaWrap(t *testing.T) {
 
 	contentType := resp.Header().Get("Content-Type")
 
-	if len(contentType) != 0 {
-		t.Fatalf("err content type, expected: nil, got: %s", contentType)
+	if contentType != "application/json" {
+		t.Fatalf("err content type, expected: application/json, got: %s", contentType)
 	}
 
 	// This should be an invalid path/method error
@@ -379,13 +380,13 @@ func TestInvalidReqMetaInstanceIDViaWrap(t *testing.T) {
 	}
 
 	// actuals
-	actual, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
+	var actual v1.ErrorResponse
+	if err := codec.NewDecoder(resp.Body, jsonHandle).Decode(&actual); err != nil {
 		t.Fatalf("err reading response: %v", err)
 	}
 
 	// compare expectations with actuals
-	if !bytes.Equal([]byte(ErrInvalidMethod), actual) {
-		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%q", ErrInvalidMethod, string(actual))
+	if actual.Message != ErrInvalidMethod || actual.Code != 405 {
+		t.Fatalf("bad:\nexpected:\t%q\n\nactual:\t\t%#v", ErrInvalidMethod, actual)
 	}
 }
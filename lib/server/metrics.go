@@ -0,0 +1,332 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/telemetry"
+	"github.com/openebs/mayaserver/lib/volume"
+)
+
+var (
+	// httpRequests counts the http requests served by mayaserver
+	httpRequests = telemetry.NewCounterVec(
+		"maya_http_requests_total",
+		"Number of http requests served by mayaserver.",
+		"route", "method", "code",
+	)
+
+	// httpRequestDuration measures the latency of the http requests
+	httpRequestDuration = telemetry.NewHistogramVec(
+		"maya_http_request_duration_seconds",
+		"Latency of the http requests served by mayaserver.",
+		nil,
+		"route", "method", "code",
+	)
+
+	// volumePluginOperations counts the outcomes of the operations invoked
+	// on the volume plugins
+	volumePluginOperations = telemetry.NewCounterVec(
+		"maya_volume_plugin_operations_total",
+		"Number of volume plugin operations by their outcome.",
+		"plugin", "orchestrator", "operation", "outcome",
+	)
+
+	// volumesByPhase is the number of volumes per phase. This is computed
+	// whenever the metrics are scraped.
+	volumesByPhase = telemetry.NewGaugeVec(
+		"maya_volumes",
+		"Number of volumes known to the volume plugins by their phase.",
+		"plugin", "orchestrator", "phase",
+	)
+
+	// volumesByPhaseMutex serializes the computation of volumesByPhase
+	// across concurrent scrapes
+	volumesByPhaseMutex sync.Mutex
+)
+
+func init() {
+	telemetry.MustRegister(httpRequests, httpRequestDuration, volumePluginOperations, volumesByPhase)
+}
+
+// statusRecorder is a http.ResponseWriter that remembers the status code
+// & the number of bytes written by the handler
+type statusRecorder struct {
+	http.ResponseWriter
+	code  int
+	bytes int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// status provides the status code sent to the client
+func (r *statusRecorder) status() int {
+	if r.code == 0 {
+		return http.StatusOK
+	}
+	return r.code
+}
+
+// observeRequest records the count & the latency of a http request that
+// was served by the route
+func observeRequest(route, method string, code int, start time.Time) {
+	status := strconv.Itoa(code)
+	httpRequests.Inc(route, method, status)
+	httpRequestDuration.Observe(time.Since(start).Seconds(), route, method, status)
+}
+
+// MetricsRequest renders the metrics of mayaserver in Prometheus text
+// format
+func (s *HTTPServer) MetricsRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+	if req.Method != "GET" {
+		return nil, CodedError(405, ErrGetMethodRequired)
+	}
+
+	if tc := s.maya.config.Telemetry; tc == nil || !tc.DisableVolumeGauges {
+		s.maya.updateVolumeGauges()
+	}
+
+	var buf bytes.Buffer
+	if err := telemetry.Default.WriteText(&buf); err != nil {
+		return nil, err
+	}
+
+	resp.Header().Set("Content-Type", telemetry.ContentType)
+	resp.Write(buf.Bytes())
+	return nil, nil
+}
+
+// updateVolumeGauges lists the volumes of every volume plugin & sets the
+// number of volumes per phase
+//
+// NOTE:
+//    A plugin whose volumes can not be listed is skipped, as the
+// orchestrator may not be reachable.
+func (ms *MayaServer) updateVolumeGauges() {
+	ms.pluginsMutex.Lock()
+	plugins := make(map[volPluginKey]volume.VolumeInterface, len(ms.volPlugins))
+	for key, plugin := range ms.volPlugins {
+		plugins[key] = plugin
+	}
+	ms.pluginsMutex.Unlock()
+
+	volumesByPhaseMutex.Lock()
+	defer volumesByPhaseMutex.Unlock()
+
+	volumesByPhase.Reset()
+
+	for key, plugin := range plugins {
+		lister, ok := plugin.Lister()
+		if !ok {
+			continue
+		}
+
+		pvs, err := lister.List(nil)
+		if err != nil {
+			ms.logger.Printf("[DEBUG] mayaserver: skipping volume gauges of '%s' with '%s': %v", key.plugin, key.orchestrator, err)
+			continue
+		}
+
+		counts := make(map[string]float64)
+		for _, pv := range pvs.Items {
+			counts[volumePhase(&pv)]++
+		}
+
+		for phase, count := range counts {
+			volumesByPhase.Set(count, key.plugin, key.orchestrator, phase)
+		}
+	}
+}
+
+// volumePhase provides the phase of the volume. The status reason set by
+// the orchestrator is used if the phase is not set.
+func volumePhase(pv *v1.PersistentVolume) string {
+	if pv.Status.Phase != "" {
+		return string(pv.Status.Phase)
+	}
+	if pv.Status.Reason != "" {
+		return pv.Status.Reason
+	}
+	return "Unknown"
+}
+
+// operationOutcome provides the outcome of a volume plugin operation
+// i.e. success or the reason of failure
+func operationOutcome(err error) string {
+	if err == nil {
+		return "success"
+	}
+	return string(v1.ReasonForError(err))
+}
+
+// instrumentedVolume is a volume plugin whose operations are counted by
+// their outcome
+type instrumentedVolume struct {
+	volume.VolumeInterface
+
+	plugin       string
+	orchestrator string
+}
+
+// newInstrumentedVolume wraps the volume plugin that is linked with the
+// named orchestrator
+func newInstrumentedVolume(plugin volume.VolumeInterface, plugName, orchName string) *instrumentedVolume {
+	return &instrumentedVolume{
+		VolumeInterface: plugin,
+		plugin:          plugName,
+		orchestrator:    orchName,
+	}
+}
+
+func (v *instrumentedVolume) observe(operation string, err error) {
+	volumePluginOperations.Inc(v.plugin, v.orchestrator, operation, operationOutcome(err))
+}
+
+func (v *instrumentedVolume) Provisioner() (volume.Provisioner, bool) {
+	p, ok := v.VolumeInterface.Provisioner()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedProvisioner{p, v}, true
+}
+
+func (v *instrumentedVolume) Deleter() (volume.Deleter, bool) {
+	d, ok := v.VolumeInterface.Deleter()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedDeleter{d, v}, true
+}
+
+func (v *instrumentedVolume) Informer() (volume.Informer, bool) {
+	i, ok := v.VolumeInterface.Informer()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedInformer{i, v}, true
+}
+
+func (v *instrumentedVolume) Resizer() (volume.Resizer, bool) {
+	r, ok := v.VolumeInterface.Resizer()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedResizer{r, v}, true
+}
+
+func (v *instrumentedVolume) Attacher() (volume.Attacher, bool) {
+	a, ok := v.VolumeInterface.Attacher()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedAttacher{a, v}, true
+}
+
+func (v *instrumentedVolume) Detacher() (volume.Detacher, bool) {
+	d, ok := v.VolumeInterface.Detacher()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedDetacher{d, v}, true
+}
+
+func (v *instrumentedVolume) Validator() (volume.Validator, bool) {
+	val, ok := v.VolumeInterface.Validator()
+	if !ok {
+		return nil, false
+	}
+	return &instrumentedValidator{val, v}, true
+}
+
+type instrumentedProvisioner struct {
+	volume.Provisioner
+	v *instrumentedVolume
+}
+
+func (p *instrumentedProvisioner) Provision(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	pv, err := p.Provisioner.Provision(pvc)
+	p.v.observe("provision", err)
+	return pv, err
+}
+
+type instrumentedDeleter struct {
+	volume.Deleter
+	v *instrumentedVolume
+}
+
+func (d *instrumentedDeleter) Delete(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+	dPV, err := d.Deleter.Delete(pv)
+	d.v.observe("delete", err)
+	return dPV, err
+}
+
+type instrumentedInformer struct {
+	volume.Informer
+	v *instrumentedVolume
+}
+
+func (i *instrumentedInformer) Info(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+	pv, qm, err := i.Informer.Info(pvc, opts)
+	i.v.observe("info", err)
+	return pv, qm, err
+}
+
+type instrumentedResizer struct {
+	volume.Resizer
+	v *instrumentedVolume
+}
+
+func (r *instrumentedResizer) Resize(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	pv, err := r.Resizer.Resize(pvc)
+	r.v.observe("resize", err)
+	return pv, err
+}
+
+type instrumentedAttacher struct {
+	volume.Attacher
+	v *instrumentedVolume
+}
+
+func (a *instrumentedAttacher) Attach(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+	attached, err := a.Attacher.Attach(va)
+	a.v.observe("attach", err)
+	return attached, err
+}
+
+type instrumentedDetacher struct {
+	volume.Detacher
+	v *instrumentedVolume
+}
+
+func (d *instrumentedDetacher) Detach(va *v1.VolumeAttachment) error {
+	err := d.Detacher.Detach(va)
+	d.v.observe("detach", err)
+	return err
+}
+
+type instrumentedValidator struct {
+	volume.Validator
+	v *instrumentedVolume
+}
+
+func (val *instrumentedValidator) Validate(pvc *v1.PersistentVolumeClaim) error {
+	err := val.Validator.Validate(pvc)
+	val.v.observe("validate", err)
+	return err
+}
@@ -0,0 +1,91 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/config"
+	"github.com/openebs/mayaserver/lib/telemetry"
+)
+
+func TestMetricsRequest(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.Telemetry = &config.Telemetry{
+			PrometheusMetrics:   true,
+			MetricsPath:         "/v1/metrics",
+			DisableVolumeGauges: true,
+		}
+	})
+	defer s.Cleanup()
+
+	req, err := http.NewRequest("GET", "/latest/meta-data/instance-id", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	s.Server.mux.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, err = http.NewRequest("GET", "/v1/metrics", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := httptest.NewRecorder()
+	s.Server.mux.ServeHTTP(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("expected 200, got %d: %s", resp.Code, resp.Body.String())
+	}
+
+	if ct := resp.HeaderMap.Get("Content-Type"); ct != telemetry.ContentType {
+		t.Fatalf("bad content type: %s", ct)
+	}
+
+	body := resp.Body.String()
+	for _, expected := range []string{
+		"# TYPE maya_http_requests_total counter",
+		`maya_http_requests_total{route="/latest/meta-data/",method="GET",code="200"}`,
+		"# TYPE maya_http_request_duration_seconds histogram",
+		"# TYPE maya_volume_plugin_operations_total counter",
+		"# TYPE maya_nomad_api_duration_seconds histogram",
+		"# TYPE maya_volumes gauge",
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected '%s' in metrics:\n%s", expected, body)
+		}
+	}
+}
+
+func TestMetricsRequest_Disabled(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	resp := httptest.NewRecorder()
+	s.Server.mux.ServeHTTP(resp, req)
+
+	if ct := resp.HeaderMap.Get("Content-Type"); ct == telemetry.ContentType {
+		t.Fatalf("expected metrics to be disabled")
+	}
+}
+
+func TestOperationOutcome(t *testing.T) {
+	cases := map[string]error{
+		"success":       nil,
+		"NotFound":      v1.NewNotFoundError("vol", nil),
+		"Unavailable":   v1.NewUnavailableError(fmt.Errorf("down")),
+		"Unknown":       fmt.Errorf("oops"),
+		"AlreadyExists": v1.NewAlreadyExistsError("vol"),
+	}
+
+	for expected, err := range cases {
+		if outcome := operationOutcome(err); outcome != expected {
+			t.Fatalf("expected outcome '%s', got '%s'", expected, outcome)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/orchprovider"
+	"github.com/openebs/mayaserver/lib/volume"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Query params that select the storage class & the orchestrator class
+	// of a request that does not carry a volume claim in its body
+	StorageClassQueryParam = "storage-class"
+	OrchClassQueryParam    = "orchestrator-class"
+
+	// Query params that locate the volume of a request that does not carry
+	// a volume claim in its body. These are set as the claim's labels.
+	RegionQueryParam     = "region"
+	DatacenterQueryParam = "datacenter"
+)
+
+// resolveVolumePlugin provides the volume plugin that caters to the
+// provided claim. The volume plugin is selected from the claim's storage
+// class, while the orchestrator is selected from the claim's orchestrator
+// class annotation. Mayaserver's configured defaults are used for the ones
+// that are not set in the claim.
+//
+// NOTE:
+//    The provided volume plugin passes the request id to the volume plugin
+// & the orchestrator for logging.
+//
+// NOTE:
+//    A storage class of the registry selects its provisioner & its
+// orchestrator. Otherwise the storage class is expected to name a volume
+// plugin.
+func (s *HTTPServer) resolveVolumePlugin(pvc *v1.PersistentVolumeClaim, reqID string) (volume.VolumeInterface, error) {
+
+	plugName := s.maya.config.DefaultVolumePlugin
+	orchName := s.maya.config.DefaultOrchProvider
+	if pvc != nil && pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName != "" {
+		className := *pvc.Spec.StorageClassName
+		if class, found := s.maya.storageClasses.Get(className); found {
+			className = class.Provisioner
+			if class.Orchestrator != "" {
+				orchName = class.Orchestrator
+			}
+		}
+
+		name, found := storageClassToVolumePlugin(className)
+		if !found {
+			return nil, CodedError(400, fmt.Sprintf("Unsupported storage class '%s'", *pvc.Spec.StorageClassName))
+		}
+		plugName = name
+	}
+
+	if pvc != nil && pvc.Annotations[v1.OrchClassAnnotation] != "" {
+		orchName = pvc.Annotations[v1.OrchClassAnnotation]
+		if !orchprovider.IsOrchProvider(orchName) {
+			return nil, CodedError(400, fmt.Sprintf("Unsupported orchestrator class '%s'", orchName))
+		}
+	}
+
+	volPlugin, err := s.GetVolumePlugin(plugName, orchName)
+	if err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	return &requestVolume{volPlugin, reqID}, nil
+}
+
+// storageClassToVolumePlugin maps a storage class to the name of a
+// registered volume plugin. A storage class can either be the namespaced
+// name of the volume plugin e.g. openebs.io/jiva or its short name
+// e.g. jiva.
+func storageClassToVolumePlugin(class string) (string, bool) {
+	if volume.IsVolumePlugin(class) {
+		return class, true
+	}
+
+	for _, name := range volume.VolumePlugins() {
+		if path.Base(name) == class {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+// claimFromQuery builds a volume claim for the requests that do not carry
+// a claim in their body. The storage class, the orchestrator class, the
+// region & the datacenter are set from the request's query params.
+func claimFromQuery(req *http.Request, volName string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = volName
+
+	query := req.URL.Query()
+
+	if class := query.Get(StorageClassQueryParam); class != "" {
+		pvc.Spec.StorageClassName = &class
+	}
+
+	if orchClass := query.Get(OrchClassQueryParam); orchClass != "" {
+		pvc.Annotations = map[string]string{
+			v1.OrchClassAnnotation: orchClass,
+		}
+	}
+
+	for _, param := range []string{RegionQueryParam, DatacenterQueryParam} {
+		if v := query.Get(param); v != "" {
+			if pvc.Labels == nil {
+				pvc.Labels = map[string]string{}
+			}
+			pvc.Labels[param] = v
+		}
+	}
+
+	return pvc
+}
+
+// requestVolume is a volume plugin that is scoped to a http request. It
+// sets the request id against the claims, volumes & query options that are
+// passed to the volume plugin.
+type requestVolume struct {
+	volume.VolumeInterface
+
+	reqID string
+}
+
+func (v *requestVolume) Provisioner() (volume.Provisioner, bool) {
+	p, ok := v.VolumeInterface.Provisioner()
+	if !ok {
+		return nil, false
+	}
+	return &requestProvisioner{p, v.reqID}, true
+}
+
+func (v *requestVolume) Deleter() (volume.Deleter, bool) {
+	d, ok := v.VolumeInterface.Deleter()
+	if !ok {
+		return nil, false
+	}
+	return &requestDeleter{d, v.reqID}, true
+}
+
+func (v *requestVolume) Informer() (volume.Informer, bool) {
+	i, ok := v.VolumeInterface.Informer()
+	if !ok {
+		return nil, false
+	}
+	return &requestInformer{i, v.reqID}, true
+}
+
+func (v *requestVolume) Lister() (volume.Lister, bool) {
+	l, ok := v.VolumeInterface.Lister()
+	if !ok {
+		return nil, false
+	}
+	return &requestLister{l, v.reqID}, true
+}
+
+func (v *requestVolume) Snapshotter() (volume.Snapshotter, bool) {
+	s, ok := v.VolumeInterface.Snapshotter()
+	if !ok {
+		return nil, false
+	}
+	return &requestSnapshotter{s, v.reqID}, true
+}
+
+func (v *requestVolume) Resizer() (volume.Resizer, bool) {
+	r, ok := v.VolumeInterface.Resizer()
+	if !ok {
+		return nil, false
+	}
+	return &requestResizer{r, v.reqID}, true
+}
+
+func (v *requestVolume) Attacher() (volume.Attacher, bool) {
+	a, ok := v.VolumeInterface.Attacher()
+	if !ok {
+		return nil, false
+	}
+	return &requestAttacher{a, v.reqID}, true
+}
+
+func (v *requestVolume) Detacher() (volume.Detacher, bool) {
+	d, ok := v.VolumeInterface.Detacher()
+	if !ok {
+		return nil, false
+	}
+	return &requestDetacher{d, v.reqID}, true
+}
+
+func (v *requestVolume) Validator() (volume.Validator, bool) {
+	val, ok := v.VolumeInterface.Validator()
+	if !ok {
+		return nil, false
+	}
+	return &requestValidator{val, v.reqID}, true
+}
+
+type requestProvisioner struct {
+	volume.Provisioner
+	reqID string
+}
+
+func (p *requestProvisioner) Provision(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	setRequestIDAnnotation(&pvc.ObjectMeta, p.reqID)
+	return p.Provisioner.Provision(pvc)
+}
+
+type requestDeleter struct {
+	volume.Deleter
+	reqID string
+}
+
+func (d *requestDeleter) Delete(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+	setRequestIDAnnotation(&pv.ObjectMeta, d.reqID)
+	return d.Deleter.Delete(pv)
+}
+
+type requestInformer struct {
+	volume.Informer
+	reqID string
+}
+
+func (i *requestInformer) Info(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+	setRequestIDAnnotation(&pvc.ObjectMeta, i.reqID)
+	return i.Informer.Info(pvc, withRequestID(opts, i.reqID))
+}
+
+type requestLister struct {
+	volume.Lister
+	reqID string
+}
+
+func (l *requestLister) List(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
+	return l.Lister.List(withRequestID(opts, l.reqID))
+}
+
+type requestResizer struct {
+	volume.Resizer
+	reqID string
+}
+
+func (r *requestResizer) Resize(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	setRequestIDAnnotation(&pvc.ObjectMeta, r.reqID)
+	return r.Resizer.Resize(pvc)
+}
+
+type requestSnapshotter struct {
+	volume.Snapshotter
+	reqID string
+}
+
+func (s *requestSnapshotter) CreateSnapshot(snap *v1.VolumeSnapshot) (*v1.VolumeSnapshot, error) {
+	setRequestIDAnnotation(&snap.ObjectMeta, s.reqID)
+	return s.Snapshotter.CreateSnapshot(snap)
+}
+
+func (s *requestSnapshotter) DeleteSnapshot(ctx context.Context, snap *v1.VolumeSnapshot) error {
+	setRequestIDAnnotation(&snap.ObjectMeta, s.reqID)
+	return s.Snapshotter.DeleteSnapshot(ctx, snap)
+}
+
+func (s *requestSnapshotter) ListSnapshots(pvc *v1.PersistentVolumeClaim) (*v1.VolumeSnapshotList, error) {
+	setRequestIDAnnotation(&pvc.ObjectMeta, s.reqID)
+	return s.Snapshotter.ListSnapshots(pvc)
+}
+
+type requestAttacher struct {
+	volume.Attacher
+	reqID string
+}
+
+func (a *requestAttacher) Attach(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+	setRequestIDAnnotation(&va.ObjectMeta, a.reqID)
+	return a.Attacher.Attach(va)
+}
+
+func (a *requestAttacher) Attachments(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+	setRequestIDAnnotation(&pvc.ObjectMeta, a.reqID)
+	return a.Attacher.Attachments(pvc)
+}
+
+type requestDetacher struct {
+	volume.Detacher
+	reqID string
+}
+
+func (d *requestDetacher) Detach(va *v1.VolumeAttachment) error {
+	setRequestIDAnnotation(&va.ObjectMeta, d.reqID)
+	return d.Detacher.Detach(va)
+}
+
+type requestValidator struct {
+	volume.Validator
+	reqID string
+}
+
+func (v *requestValidator) Validate(pvc *v1.PersistentVolumeClaim) error {
+	setRequestIDAnnotation(&pvc.ObjectMeta, v.reqID)
+	return v.Validator.Validate(pvc)
+}
+
+// setRequestIDAnnotation sets the request id against the object
+func setRequestIDAnnotation(meta *metav1.ObjectMeta, reqID string) {
+	if reqID == "" {
+		return
+	}
+
+	if meta.Annotations == nil {
+		meta.Annotations = map[string]string{}
+	}
+	meta.Annotations[v1.RequestIDAnnotation] = reqID
+}
+
+// withRequestID provides a copy of the query options with the request id
+func withRequestID(opts *v1.QueryOptions, reqID string) *v1.QueryOptions {
+	if opts == nil && reqID == "" {
+		return nil
+	}
+
+	o := v1.QueryOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	o.RequestID = reqID
+
+	return &o
+}
@@ -4,13 +4,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"path"
 	"sync"
 
 	"github.com/openebs/mayaserver/lib/config"
+	"github.com/openebs/mayaserver/lib/ipam"
 	"github.com/openebs/mayaserver/lib/orchprovider"
-	"github.com/openebs/mayaserver/lib/orchprovider/nomad"
+	"github.com/openebs/mayaserver/lib/orchprovider/mock"
 	"github.com/openebs/mayaserver/lib/volume"
-	"github.com/openebs/mayaserver/lib/volume/jiva"
+
+	// Register the orchestration providers & volume plugins
+	_ "github.com/openebs/mayaserver/lib/orchprovider/kubernetes"
+	_ "github.com/openebs/mayaserver/lib/orchprovider/nomad"
+	_ "github.com/openebs/mayaserver/lib/volume/jiva"
 )
 
 // MayaServer is a long running stateless daemon that runs
@@ -18,13 +24,16 @@ import (
 type MayaServer struct {
 	config       *config.MayaConfig
 	pluginsMutex sync.Mutex
-	volPlugins   map[string]volume.VolumeInterface
+	volPlugins   map[volPluginKey]volume.VolumeInterface
 	logger       *log.Logger
 	logOutput    io.Writer
 
 	shutdown     bool
 	shutdownCh   chan struct{}
 	shutdownLock sync.Mutex
+
+	// storageClasses are the storage classes that the claims refer to
+	storageClasses *storageClassRegistry
 }
 
 // NewMayaServer is used to create a new maya server
@@ -32,7 +41,7 @@ type MayaServer struct {
 func NewMayaServer(config *config.MayaConfig, logOutput io.Writer) (*MayaServer, error) {
 	ms := &MayaServer{
 		config:     config,
-		volPlugins: make(map[string]volume.VolumeInterface),
+		volPlugins: make(map[volPluginKey]volume.VolumeInterface),
 		logger:     log.New(logOutput, "", log.LstdFlags|log.Lmicroseconds),
 		logOutput:  logOutput,
 		shutdownCh: make(chan struct{}),
@@ -43,9 +52,39 @@ func NewMayaServer(config *config.MayaConfig, logOutput io.Writer) (*MayaServer,
 		return nil, err
 	}
 
+	storageClasses, err := newStorageClassRegistry(config.StorageClasses, config.DataDir, ms.logger)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init storage classes: %v", err)
+	}
+	ms.storageClasses = storageClasses
+
 	return ms, nil
 }
 
+// volPluginKey identifies an initialized volume plugin instance. A volume
+// plugin is initialized once per orchestration provider.
+type volPluginKey struct {
+	plugin       string
+	orchestrator string
+}
+
+// orchProviderConfigured returns true if the config names the orchestrator
+// either as the default orchestrator or as the orchestrator of a storage
+// class
+func (ms *MayaServer) orchProviderConfigured(orchName string) bool {
+	if ms.config.DefaultOrchProvider == orchName {
+		return true
+	}
+
+	for _, class := range ms.config.StorageClasses {
+		if class != nil && class.Orchestrator == orchName {
+			return true
+		}
+	}
+
+	return false
+}
+
 // TODO
 // Create a Bootstrap interface that facilitates initialization
 // Create another Bootstraped interface that provides the initialized instances
@@ -57,15 +96,12 @@ func NewMayaServer(config *config.MayaConfig, logOutput io.Writer) (*MayaServer,
 //    The current implementation is tightly coupled & cannot be unit tested.
 func (ms *MayaServer) BootstrapPlugins() error {
 
-	// TODO
-	// Use MayaConfig
-	// Fetch the names of volume plugins to be initialized
-	// Iterate over the volumes:
+	// Iterate over the registered orchestrators:
 	//  0. Fetch the config file location of orchestrator
-	//  1. Initialize volume plugin's orchestrator
+	//  1. Initialize the orchestrator
 	//  2. Build an aspect that points to above orchestrator
 	//  3. Fetch the config file location of volume plugin
-	//  4. Initialize volume plugin
+	//  4. Initialize each registered volume plugin with above aspect
 
 	ms.pluginsMutex.Lock()
 	defer ms.pluginsMutex.Unlock()
@@ -75,41 +111,100 @@ func (ms *MayaServer) BootstrapPlugins() error {
 	// Get a default path if ms.config does not provide it
 	orchConfPath := "/etc/mayaserver/orchprovider/"
 
+	// The volume plugin conf file is named after the plugin e.g. jiva.INI
+	// for openebs.io/jiva
+	volConfPath := "/etc/mayaserver/volumeplugin/"
+
 	// TODO
 	// Get the region from ms.config or http query params, or the default
 	// ms.config should not have a region for mayaserver, it should have a region
 	// for the orchestrator providers
-	// Fetch the region specific NomadConfig file
+	// Fetch the region specific orchestrator config file
 	region := "global"
 
-	nConfFile := orchConfPath + "nomad_" + region + ".INI"
-	orchestrator, err := orchprovider.InitOrchProvider(nomad.NomadOrchProviderName, nConfFile)
+	// The ip address management is shared by all the volume plugins
+	volIPAM, err := ms.newIPAM()
 	if err != nil {
 		return err
 	}
 
-	jivaAspect := &jiva.JivaStorNomadAspect{
-		Nomad: orchestrator,
+	for _, orchName := range orchprovider.OrchProviders() {
+
+		// The mock orchestrator keeps the volumes in memory & is hence
+		// initialized only if the config asks for it
+		if orchName == mock.MockOrchProviderName && !ms.orchProviderConfigured(orchName) {
+			continue
+		}
+
+		orchConfFile := orchConfPath + orchName + "_" + region + ".INI"
+		orchestrator, err := orchprovider.InitOrchProvider(orchName, orchConfFile)
+		if err != nil {
+			// The default orchestrator is a must
+			if orchName == ms.config.DefaultOrchProvider {
+				return err
+			}
+
+			ms.logger.Printf("[WARN] mayaserver: skipping orchestrator '%s': %v", orchName, err)
+			continue
+		}
+
+		aspect := &volume.OrchProviderAspect{
+			Orchestrator: orchestrator,
+			IPAM:         volIPAM,
+		}
+
+		for _, plugName := range volume.VolumePlugins() {
+			volConfFile := volConfPath + path.Base(plugName) + ".INI"
+			volPlugin, err := volume.InitVolumePlugin(plugName, volConfFile, aspect)
+			if err != nil {
+				return err
+			}
+
+			// The operations of the plugin are measured
+			ms.volPlugins[volPluginKey{plugName, orchName}] = newInstrumentedVolume(volPlugin, plugName, orchName)
+		}
 	}
 
-	jivaStor, err := volume.InitVolumePlugin(jiva.JivaStorPluginName, "", jivaAspect)
-	if err != nil {
-		return err
+	if !volume.IsVolumePlugin(ms.config.DefaultVolumePlugin) {
+		return fmt.Errorf("Default volume plugin '%s' is not registered", ms.config.DefaultVolumePlugin)
+	}
+
+	if !orchprovider.IsOrchProvider(ms.config.DefaultOrchProvider) {
+		return fmt.Errorf("Default orchestrator '%s' is not registered", ms.config.DefaultOrchProvider)
 	}
 
-	ms.volPlugins[jiva.JivaStorPluginName] = jivaStor
 	return nil
 }
 
+// newIPAM provides the ip address management of the volumes if it has been
+// configured. The allocations are persisted under the data dir.
+func (ms *MayaServer) newIPAM() (ipam.IPAM, error) {
+	if ms.config.IPAM == nil {
+		return nil, nil
+	}
+
+	if ms.config.DataDir == "" {
+		ms.logger.Printf("[WARN] mayaserver: data_dir is not set, ip address allocations will not be persisted")
+	}
+
+	volIPAM, err := ipam.NewIPAM(ms.config.IPAM.Pools, ms.config.DataDir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to init ipam: %v", err)
+	}
+
+	return volIPAM, nil
+}
+
 // GetVolumePlugin is an accessor that fetches a volume.VolumeInterface instance
-// The volume.VolumeInterface should have been initialized earlier.
-func (ms *MayaServer) GetVolumePlugin(name string) (volume.VolumeInterface, error) {
+// that is linked with the named orchestrator. The volume.VolumeInterface
+// should have been initialized earlier.
+func (ms *MayaServer) GetVolumePlugin(name string, orchName string) (volume.VolumeInterface, error) {
 	ms.pluginsMutex.Lock()
 	defer ms.pluginsMutex.Unlock()
 
-	storage, found := ms.volPlugins[name]
+	storage, found := ms.volPlugins[volPluginKey{name, orchName}]
 	if !found {
-		return nil, fmt.Errorf("Volume plugin '%s' not found", name)
+		return nil, fmt.Errorf("Volume plugin '%s' with orchestrator '%s' not found", name, orchName)
 	}
 
 	return storage, nil
@@ -123,3 +123,35 @@ func TestMayaServerConfig(t *testing.T) {
 	}
 
 }
+
+func TestBootstrapPlugins_MockOrchestrator(t *testing.T) {
+	dir, maya := makeMayaServer(t, nil)
+	defer os.RemoveAll(dir)
+
+	if _, err := maya.GetVolumePlugin("openebs.io/jiva", "mock"); err == nil {
+		t.Fatalf("expected the mock orchestrator to be skipped if not configured")
+	}
+
+	dir, maya = makeMayaServer(t, func(mc *config.MayaConfig) {
+		mc.StorageClasses = map[string]*config.StorageClass{
+			"dev": &config.StorageClass{
+				Provisioner:  "jiva",
+				Orchestrator: "mock",
+			},
+		}
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := maya.GetVolumePlugin("openebs.io/jiva", "mock"); err != nil {
+		t.Fatalf("expected the mock orchestrator of a storage class, got: %v", err)
+	}
+
+	dir, maya = makeMayaServer(t, func(mc *config.MayaConfig) {
+		mc.DefaultOrchProvider = "mock"
+	})
+	defer os.RemoveAll(dir)
+
+	if _, err := maya.GetVolumePlugin("openebs.io/jiva", "mock"); err != nil {
+		t.Fatalf("expected the default mock orchestrator, got: %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+const (
+	// sigV4Algorithm is the algorithm of AWS Signature Version 4 that is
+	// used by aws-cli & the AWS SDKs to sign their requests
+	sigV4Algorithm = "AWS4-HMAC-SHA256"
+
+	// sigV4DateFormat is the format of the X-Amz-Date header
+	sigV4DateFormat = "20060102T150405Z"
+
+	// sigV4MaxSkew bounds the difference between the signing time of a
+	// request & the server's clock
+	sigV4MaxSkew = 15 * time.Minute
+
+	// sigV4MaxBody bounds the body that is read to verify its hash
+	sigV4MaxBody = 1 << 20
+)
+
+// sigV4Auth is the parsed Authorization header of a signed request
+type sigV4Auth struct {
+	accessKeyID   string
+	date          string
+	region        string
+	service       string
+	signedHeaders []string
+	signature     string
+}
+
+// isSigV4Request returns true if the request is signed via AWS Signature
+// Version 4 in its Authorization header
+func isSigV4Request(req *http.Request) bool {
+	return strings.HasPrefix(req.Header.Get("Authorization"), sigV4Algorithm+" ")
+}
+
+// findSigV4Token provides the token whose access key id & secret signed
+// the request.
+//
+// NOTE:
+//    Only the EC2 Query API i.e. the requests at "/" are verified. The
+// presigned URLs are not supported. The body of the request is read to
+// verify its hash & is restored thereafter.
+func findSigV4Token(acl *config.ACLConfig, req *http.Request, now time.Time) (*config.ACLToken, error) {
+	if req.URL.Path != "/" {
+		return nil, fmt.Errorf("AWS signatures are accepted for EC2 requests only")
+	}
+
+	auth, err := parseSigV4Auth(req.Header.Get("Authorization"))
+	if err != nil {
+		return nil, err
+	}
+
+	amzDate := req.Header.Get("X-Amz-Date")
+	signedAt, err := time.Parse(sigV4DateFormat, amzDate)
+	if err != nil {
+		return nil, fmt.Errorf("Missing or invalid X-Amz-Date header")
+	}
+
+	if skew := now.Sub(signedAt); skew > sigV4MaxSkew || skew < -sigV4MaxSkew {
+		return nil, fmt.Errorf("Signature has expired")
+	}
+
+	if !strings.HasPrefix(amzDate, auth.date) {
+		return nil, fmt.Errorf("Credential date does not match X-Amz-Date")
+	}
+
+	var token *config.ACLToken
+	for _, t := range acl.Tokens {
+		if t.AccessKeyID != "" && t.AccessKeyID == auth.accessKeyID {
+			token = t
+		}
+	}
+	if token == nil {
+		return nil, fmt.Errorf("Invalid access key id")
+	}
+
+	var body []byte
+	if req.Body != nil {
+		body, err = ioutil.ReadAll(io.LimitReader(req.Body, sigV4MaxBody+1))
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > sigV4MaxBody {
+			return nil, fmt.Errorf("Request body of a signed request exceeds %d bytes", sigV4MaxBody)
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := sigV4Signature(token.Secret, auth, req, amzDate, body)
+	if !hmac.Equal([]byte(expected), []byte(auth.signature)) {
+		return nil, fmt.Errorf("Invalid AWS signature")
+	}
+
+	return token, nil
+}
+
+// parseSigV4Auth parses an Authorization header of the form:
+//
+// AWS4-HMAC-SHA256 Credential=<key id>/<date>/<region>/<service>/aws4_request,
+// SignedHeaders=<header>;<header>, Signature=<hex>
+func parseSigV4Auth(header string) (*sigV4Auth, error) {
+	fields := map[string]string{}
+	for _, kv := range strings.Split(strings.TrimPrefix(header, sigV4Algorithm+" "), ",") {
+		parts := strings.SplitN(strings.TrimSpace(kv), "=", 2)
+		if len(parts) == 2 {
+			fields[parts[0]] = parts[1]
+		}
+	}
+
+	scope := strings.Split(fields["Credential"], "/")
+	if len(scope) != 5 || scope[4] != "aws4_request" || fields["SignedHeaders"] == "" || fields["Signature"] == "" {
+		return nil, fmt.Errorf("Malformed AWS signature")
+	}
+
+	return &sigV4Auth{
+		accessKeyID:   scope[0],
+		date:          scope[1],
+		region:        scope[2],
+		service:       scope[3],
+		signedHeaders: strings.Split(fields["SignedHeaders"], ";"),
+		signature:     fields["Signature"],
+	}, nil
+}
+
+// sigV4Signature computes the signature of the request as done by the AWS
+// signers
+func sigV4Signature(secret string, auth *sigV4Auth, req *http.Request, amzDate string, body []byte) string {
+	var headers []string
+	for _, name := range auth.signedHeaders {
+		value := strings.Join(req.Header[http.CanonicalHeaderKey(name)], ",")
+		if name == "host" {
+			value = req.Host
+		}
+		headers = append(headers, name+":"+strings.Join(strings.Fields(value), " ")+"\n")
+	}
+
+	payloadHash := sha256.Sum256(body)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		"/",
+		sigV4CanonicalQuery(req.URL.Query()),
+		strings.Join(headers, ""),
+		strings.Join(auth.signedHeaders, ";"),
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := strings.Join([]string{auth.date, auth.region, auth.service, "aws4_request"}, "/")
+
+	stringToSign := strings.Join([]string{
+		sigV4Algorithm,
+		amzDate,
+		scope,
+		hex.EncodeToString(requestHash[:]),
+	}, "\n")
+
+	key := []byte("AWS4" + secret)
+	for _, part := range []string{auth.date, auth.region, auth.service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// sigV4CanonicalQuery provides the query params sorted by their names &
+// values, each encoded as per RFC 3986
+func sigV4CanonicalQuery(query url.Values) string {
+	var params [][2]string
+	for name, values := range query {
+		for _, value := range values {
+			params = append(params, [2]string{sigV4Escape(name), sigV4Escape(value)})
+		}
+	}
+
+	sort.Slice(params, func(i, j int) bool {
+		if params[i][0] != params[j][0] {
+			return params[i][0] < params[j][0]
+		}
+		return params[i][1] < params[j][1]
+	})
+
+	var encoded []string
+	for _, p := range params {
+		encoded = append(encoded, p[0]+"="+p[1])
+	}
+
+	return strings.Join(encoded, "&")
+}
+
+// sigV4Escape encodes all the characters other than the unreserved ones of
+// RFC 3986
+func sigV4Escape(s string) string {
+	var buf bytes.Buffer
+	for _, c := range []byte(s) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&buf, "%%%02X", c)
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
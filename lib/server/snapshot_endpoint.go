@@ -0,0 +1,130 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/volume"
+)
+
+// SnapshotsRequest is a http handler implementation. It caters to the
+// snapshots collection i.e. /latest/snapshots/, the snapshots of a volume
+// i.e. /latest/snapshots/{volume} & a particular snapshot i.e.
+// /latest/snapshots/{volume}/{snapshot}.
+func (s *HTTPServer) SnapshotsRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	path := strings.TrimPrefix(req.URL.Path, "/latest/snapshots/")
+	parts := strings.Split(path, "/")
+
+	switch {
+	case path == "":
+		if req.Method != "PUT" && req.Method != "POST" {
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+		return s.snapshotCreate(resp, req)
+
+	case len(parts) == 1:
+		if req.Method != "GET" {
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+		return s.snapshotList(resp, req, parts[0])
+
+	case len(parts) == 2 && parts[0] != "" && parts[1] != "":
+		if req.Method != "DELETE" {
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+		return s.snapshotDelete(resp, req, parts[0], parts[1])
+
+	default:
+		return nil, CodedError(404, fmt.Sprintf("Invalid snapshot path '%s'", req.URL.Path))
+	}
+}
+
+// snapshotCreate takes a snapshot of the volume named in the request's
+// body
+func (s *HTTPServer) snapshotCreate(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	snap := v1.VolumeSnapshot{}
+	if err := decodeBody(req, &snap); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	if snap.Name == "" {
+		return nil, CodedError(400, "Snapshot name hasn't been provided")
+	}
+
+	if snap.Spec.VolumeName == "" {
+		return nil, CodedError(400, fmt.Sprintf("Volume name of snapshot '%s' hasn't been provided", snap.Name))
+	}
+
+	snapshotter, err := s.resolveSnapshotter(req, snap.Spec.VolumeName)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := snapshotter.CreateSnapshot(&snap)
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// snapshotList lists the snapshots of a volume
+func (s *HTTPServer) snapshotList(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
+
+	if volName == "" {
+		return nil, CodedError(400, "Volume name missing")
+	}
+
+	snapshotter, err := s.resolveSnapshotter(req, volName)
+	if err != nil {
+		return nil, err
+	}
+
+	snaps, err := snapshotter.ListSnapshots(claimFromQuery(req, volName))
+	if err != nil {
+		return nil, err
+	}
+
+	return snaps, nil
+}
+
+// snapshotDelete removes a snapshot of a volume
+func (s *HTTPServer) snapshotDelete(resp http.ResponseWriter, req *http.Request, volName, snapName string) (interface{}, error) {
+
+	snapshotter, err := s.resolveSnapshotter(req, volName)
+	if err != nil {
+		return nil, err
+	}
+
+	snap := &v1.VolumeSnapshot{}
+	snap.Name = snapName
+	snap.Spec.VolumeName = volName
+
+	// The removal is abandoned if the client goes away
+	if err := snapshotter.DeleteSnapshot(req.Context(), snap); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// resolveSnapshotter provides the snapshotter of the volume plugin that is
+// selected by the request's query params
+func (s *HTTPServer) resolveSnapshotter(req *http.Request, volName string) (volume.Snapshotter, error) {
+
+	volPlugin, err := s.resolveVolumePlugin(claimFromQuery(req, volName), requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshotter, ok := volPlugin.Snapshotter()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume snapshots are not supported by '%s'", volPlugin.Name()))
+	}
+
+	return snapshotter, nil
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+func TestSnapshotsRequest_InvalidRequests(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	cases := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{"GET", "/latest/snapshots/", 405},
+		{"DELETE", "/latest/snapshots/myvol", 405},
+		{"GET", "/latest/snapshots/myvol/snap1", 405},
+		{"DELETE", "/latest/snapshots/myvol/", 404},
+		{"DELETE", "/latest/snapshots/myvol/snap1/nested", 404},
+		{"GET", "/latest/snapshots/myvol?storage-class=bogus", 400},
+	}
+
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.path, nil)
+		resp := httptest.NewRecorder()
+		s.Server.wrap(s.Server.SnapshotsRequest)(resp, req)
+
+		if resp.Code != c.code {
+			t.Fatalf("%s %s: expected: %d, got: %d", c.method, c.path, c.code, resp.Code)
+		}
+	}
+}
+
+func TestSnapshotCreate_MissingVolume(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	snap := v1.VolumeSnapshot{}
+	snap.Name = "snap1"
+
+	buf, _ := json.Marshal(snap)
+	req, _ := http.NewRequest("POST", "/latest/snapshots/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.SnapshotsRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), "Volume name of snapshot 'snap1'") {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
@@ -0,0 +1,286 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/config"
+	"github.com/openebs/mayaserver/lib/orchprovider"
+)
+
+// storageClassesFile is the file under the data dir that persists the
+// storage classes that were created via the http api
+const storageClassesFile = "storageclasses.json"
+
+// storageClassRegistry holds the storage classes known to mayaserver.
+//
+// NOTE:
+//    The storage classes defined in the config are read only. The ones that
+// are created via the http api are persisted under the data dir, if it is
+// set.
+type storageClassRegistry struct {
+	sync.Mutex
+
+	// classes are the storage classes mapped against their names
+	classes map[string]*v1.StorageClass
+
+	// fromConfig are the names of the classes that are defined in the config
+	fromConfig map[string]bool
+
+	// stateDir persists the classes created via the http api
+	stateDir string
+
+	// logger reports the persisted classes that are dropped on restore
+	logger *log.Logger
+}
+
+// newStorageClassRegistry provides a registry with the storage classes of
+// the config & the ones that were persisted earlier under the state dir
+func newStorageClassRegistry(classes map[string]*config.StorageClass, stateDir string, logger *log.Logger) (*storageClassRegistry, error) {
+	r := &storageClassRegistry{
+		classes:    make(map[string]*v1.StorageClass),
+		fromConfig: make(map[string]bool),
+		stateDir:   stateDir,
+		logger:     logger,
+	}
+
+	if err := r.restore(); err != nil {
+		return nil, err
+	}
+
+	for name, c := range classes {
+		class := &v1.StorageClass{
+			Provisioner:  c.Provisioner,
+			Orchestrator: c.Orchestrator,
+			Parameters:   c.Parameters,
+		}
+		class.Name = name
+
+		if err := validateStorageClass(class); err != nil {
+			return nil, err
+		}
+
+		// A class of the config takes precedence over a persisted one
+		r.classes[name] = class
+		r.fromConfig[name] = true
+	}
+
+	return r, nil
+}
+
+// Get provides the named storage class
+func (r *storageClassRegistry) Get(name string) (*v1.StorageClass, bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	class, found := r.classes[name]
+	return class, found
+}
+
+// List provides the storage classes sorted by their names
+func (r *storageClassRegistry) List() *v1.StorageClassList {
+	r.Lock()
+	defer r.Unlock()
+
+	names := make([]string, 0, len(r.classes))
+	for name := range r.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &v1.StorageClassList{
+		Items: make([]v1.StorageClass, 0, len(names)),
+	}
+	for _, name := range names {
+		list.Items = append(list.Items, *r.classes[name])
+	}
+
+	return list
+}
+
+// Put creates the storage class or replaces the one with the same name.
+// An existing class is not replaced if create is set.
+func (r *storageClassRegistry) Put(class *v1.StorageClass, create bool) error {
+	if err := validateStorageClass(class); err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	if r.fromConfig[class.Name] {
+		return CodedError(409, fmt.Sprintf("Storage class '%s' is defined in the config & can not be modified", class.Name))
+	}
+
+	existing, found := r.classes[class.Name]
+	if found && create {
+		return CodedError(409, fmt.Sprintf("Storage class '%s' already exists", class.Name))
+	}
+
+	r.classes[class.Name] = class
+	if err := r.persist(); err != nil {
+		if found {
+			r.classes[class.Name] = existing
+		} else {
+			delete(r.classes, class.Name)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// Delete removes the named storage class
+func (r *storageClassRegistry) Delete(name string) error {
+	r.Lock()
+	defer r.Unlock()
+
+	existing, found := r.classes[name]
+	if !found {
+		return CodedError(404, fmt.Sprintf("Storage class '%s' not found", name))
+	}
+
+	if r.fromConfig[name] {
+		return CodedError(409, fmt.Sprintf("Storage class '%s' is defined in the config & can not be deleted", name))
+	}
+
+	delete(r.classes, name)
+	if err := r.persist(); err != nil {
+		r.classes[name] = existing
+		return err
+	}
+
+	return nil
+}
+
+// persist writes the classes that were created via the http api to the
+// state dir. The file is replaced atomically s.t. a crash does not leave a
+// partial file.
+func (r *storageClassRegistry) persist() error {
+	if r.stateDir == "" {
+		return nil
+	}
+
+	created := make(map[string]*v1.StorageClass)
+	for name, class := range r.classes {
+		if !r.fromConfig[name] {
+			created[name] = class
+		}
+	}
+
+	data, err := json.MarshalIndent(created, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(r.stateDir, 0755); err != nil {
+		return fmt.Errorf("unable to create storage class state dir: %v", err)
+	}
+
+	tmp, err := ioutil.TempFile(r.stateDir, storageClassesFile)
+	if err != nil {
+		return fmt.Errorf("unable to persist storage classes: %v", err)
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to persist storage classes: %v", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("unable to persist storage classes: %v", err)
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(r.stateDir, storageClassesFile))
+}
+
+// restore reads the classes from the state dir, if any.
+//
+// NOTE:
+//    A persisted class that is no longer valid, e.g. its volume plugin or
+// orchestrator is not supported by this build, is dropped with a warning
+// rather than failing the startup.
+func (r *storageClassRegistry) restore() error {
+	if r.stateDir == "" {
+		return nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(r.stateDir, storageClassesFile))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read storage classes: %v", err)
+	}
+
+	var classes map[string]*v1.StorageClass
+	if err := json.Unmarshal(data, &classes); err != nil {
+		return fmt.Errorf("unable to decode storage classes: %v", err)
+	}
+
+	for name, class := range classes {
+		if class == nil || class.Name != name {
+			r.logger.Printf("[WARN] mayaserver: dropping persisted storage class '%s': name does not match", name)
+			continue
+		}
+
+		if err := validateStorageClass(class); err != nil {
+			r.logger.Printf("[WARN] mayaserver: dropping persisted storage class '%s': %v", name, err)
+			continue
+		}
+
+		r.classes[name] = class
+	}
+
+	return nil
+}
+
+// validateStorageClass verifies if the class names a registered volume
+// plugin & orchestrator
+func validateStorageClass(class *v1.StorageClass) error {
+	if class.Name == "" {
+		return CodedError(400, "Storage class name hasn't been provided")
+	}
+
+	if _, found := storageClassToVolumePlugin(class.Provisioner); !found {
+		return CodedError(400, fmt.Sprintf("Storage class '%s' has an unsupported provisioner '%s'", class.Name, class.Provisioner))
+	}
+
+	if class.Orchestrator != "" && !orchprovider.IsOrchProvider(class.Orchestrator) {
+		return CodedError(400, fmt.Sprintf("Storage class '%s' has an unsupported orchestrator '%s'", class.Name, class.Orchestrator))
+	}
+
+	return nil
+}
+
+// applyStorageClass sets the parameters of the claim's storage class as the
+// claim's labels. The labels that are set in the claim take precedence.
+func (s *HTTPServer) applyStorageClass(pvc *v1.PersistentVolumeClaim) {
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		return
+	}
+
+	class, found := s.maya.storageClasses.Get(*pvc.Spec.StorageClassName)
+	if !found || len(class.Parameters) == 0 {
+		return
+	}
+
+	if pvc.Labels == nil {
+		pvc.Labels = make(map[string]string)
+	}
+
+	for k, v := range class.Parameters {
+		if _, set := pvc.Labels[k]; !set {
+			pvc.Labels[k] = v
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// StorageClassesRequest is a http handler implementation. It caters to the
+// storage classes collection i.e. /latest/storageclasses/ & a particular
+// storage class i.e. /latest/storageclasses/{name}.
+func (s *HTTPServer) StorageClassesRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	name := strings.TrimPrefix(req.URL.Path, "/latest/storageclasses/")
+
+	switch {
+	case name == "":
+		switch req.Method {
+		case "GET":
+			return s.maya.storageClasses.List(), nil
+		case "PUT", "POST":
+			return s.storageClassUpdate(resp, req, "")
+		default:
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+
+	case strings.Contains(name, "/"):
+		return nil, CodedError(404, fmt.Sprintf("Invalid storage class path '%s'", req.URL.Path))
+
+	default:
+		switch req.Method {
+		case "GET":
+			return s.storageClassInfo(resp, req, name)
+		case "PUT":
+			return s.storageClassUpdate(resp, req, name)
+		case "DELETE":
+			return s.storageClassDelete(resp, req, name)
+		default:
+			return nil, CodedError(405, ErrInvalidMethod)
+		}
+	}
+}
+
+// storageClassUpdate creates the storage class that is sent as the
+// request's body. A storage class that is named in the path is replaced if
+// it exists, whereas the one that is posted to the collection is not.
+func (s *HTTPServer) storageClassUpdate(resp http.ResponseWriter, req *http.Request, name string) (interface{}, error) {
+
+	class := v1.StorageClass{}
+	if err := decodeBody(req, &class); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	// The storage class name in the path takes precedence
+	if name != "" {
+		if class.Name != "" && class.Name != name {
+			return nil, CodedError(400, fmt.Sprintf("Storage class name '%s' does not match the path '%s'", class.Name, name))
+		}
+		class.Name = name
+	}
+
+	if err := s.maya.storageClasses.Put(&class, name == ""); err != nil {
+		return nil, err
+	}
+
+	return &class, nil
+}
+
+func (s *HTTPServer) storageClassInfo(resp http.ResponseWriter, req *http.Request, name string) (interface{}, error) {
+
+	class, found := s.maya.storageClasses.Get(name)
+	if !found {
+		return nil, CodedError(404, fmt.Sprintf("Storage class '%s' not found", name))
+	}
+
+	return class, nil
+}
+
+func (s *HTTPServer) storageClassDelete(resp http.ResponseWriter, req *http.Request, name string) (interface{}, error) {
+
+	if err := s.maya.storageClasses.Delete(name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,217 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+func storageClassRequest(s *TestServer, method, path string, class *v1.StorageClass) *httptest.ResponseRecorder {
+	var body *bytes.Reader
+	if class != nil {
+		buf, _ := json.Marshal(class)
+		body = bytes.NewReader(buf)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, _ := http.NewRequest(method, path, body)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.StorageClassesRequest)(resp, req)
+	return resp
+}
+
+func TestStorageClassesRequest(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.StorageClasses = map[string]*config.StorageClass{
+			"silver": &config.StorageClass{
+				Name:        "silver",
+				Provisioner: "jiva",
+			},
+		}
+	})
+	defer s.Cleanup()
+
+	gold := &v1.StorageClass{
+		Provisioner: "openebs.io/jiva",
+		Parameters:  map[string]string{"jivareplicas": "2"},
+	}
+	gold.Name = "gold"
+
+	silver := &v1.StorageClass{Provisioner: "jiva"}
+	silver.Name = "silver"
+
+	cases := []struct {
+		method string
+		path   string
+		class  *v1.StorageClass
+		code   int
+	}{
+		{"POST", "/latest/storageclasses/", gold, 200},
+		{"POST", "/latest/storageclasses/", gold, 409},
+		{"PUT", "/latest/storageclasses/gold", gold, 200},
+		{"PUT", "/latest/storageclasses/bronze", gold, 400},
+		{"GET", "/latest/storageclasses/gold", nil, 200},
+		{"GET", "/latest/storageclasses/silver", nil, 200},
+		{"PUT", "/latest/storageclasses/silver", silver, 409},
+		{"DELETE", "/latest/storageclasses/silver", nil, 409},
+		{"DELETE", "/latest/storageclasses/gold", nil, 200},
+		{"GET", "/latest/storageclasses/gold", nil, 404},
+		{"DELETE", "/latest/storageclasses/gold", nil, 404},
+		{"POST", "/latest/storageclasses/gold", nil, 405},
+		{"GET", "/latest/storageclasses/gold/nested", nil, 404},
+	}
+
+	for _, c := range cases {
+		resp := storageClassRequest(s, c.method, c.path, c.class)
+		if resp.Code != c.code {
+			t.Fatalf("%s %s: expected: %d, got: %d: %s", c.method, c.path, c.code, resp.Code, resp.Body.String())
+		}
+	}
+
+	resp := storageClassRequest(s, "GET", "/latest/storageclasses/", nil)
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: %d", resp.Code)
+	}
+
+	list := v1.StorageClassList{}
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(list.Items) != 1 || list.Items[0].Name != "silver" {
+		t.Fatalf("bad storage classes: %v", list.Items)
+	}
+}
+
+func TestStorageClassesRequest_Invalid(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	cases := map[string]*v1.StorageClass{
+		"missing name":         &v1.StorageClass{Provisioner: "jiva"},
+		"invalid provisioner":  &v1.StorageClass{Provisioner: "bogus"},
+		"invalid orchestrator": &v1.StorageClass{Provisioner: "jiva", Orchestrator: "bogus"},
+		"missing provisioner":  &v1.StorageClass{},
+	}
+
+	for desc, class := range cases {
+		if desc != "missing name" {
+			class.Name = "gold"
+		}
+
+		resp := storageClassRequest(s, "POST", "/latest/storageclasses/", class)
+		if resp.Code != 400 {
+			t.Fatalf("%s: expected: 400, got: %d", desc, resp.Code)
+		}
+	}
+}
+
+func TestStorageClassRegistry_Persisted(t *testing.T) {
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	r, err := newStorageClassRegistry(nil, dir, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	class := &v1.StorageClass{Provisioner: "jiva"}
+	class.Name = "gold"
+	if err := r.Put(class, true); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// The classes survive a restart
+	r, err = newStorageClassRegistry(nil, dir, log.New(ioutil.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, found := r.Get("gold"); !found {
+		t.Fatalf("expected storage class 'gold' to be restored")
+	}
+}
+
+func TestStorageClassRegistry_RestoreInvalid(t *testing.T) {
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	data := `{
+		"gold": {"metadata": {"name": "gold"}, "provisioner": "jiva"},
+		"bronze": {"metadata": {"name": "bronze"}, "provisioner": "unknown"},
+		"silver": {"metadata": {"name": "silver"}, "provisioner": "jiva", "orchestrator": "unknown"}
+	}`
+	if err := ioutil.WriteFile(filepath.Join(dir, storageClassesFile), []byte(data), 0600); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	var buf bytes.Buffer
+	r, err := newStorageClassRegistry(nil, dir, log.New(&buf, "", 0))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, found := r.Get("gold"); !found {
+		t.Fatalf("expected storage class 'gold' to be restored")
+	}
+
+	for _, name := range []string{"bronze", "silver"} {
+		if _, found := r.Get(name); found {
+			t.Fatalf("expected storage class '%s' to be dropped", name)
+		}
+
+		if !strings.Contains(buf.String(), "'"+name+"'") {
+			t.Fatalf("expected a warning for storage class '%s', got: %s", name, buf.String())
+		}
+	}
+}
+
+func TestApplyStorageClass(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.StorageClasses = map[string]*config.StorageClass{
+			"gold": &config.StorageClass{
+				Name:         "gold",
+				Provisioner:  "jiva",
+				Orchestrator: "nomad",
+				Parameters: map[string]string{
+					"jivareplicas": "2",
+					"region":       "global",
+				},
+			},
+		}
+	})
+	defer s.Cleanup()
+
+	class := "gold"
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Spec.StorageClassName = &class
+	pvc.Labels = map[string]string{"region": "us-east"}
+
+	s.Server.applyStorageClass(pvc)
+
+	// The claim's labels take precedence
+	if pvc.Labels["jivareplicas"] != "2" || pvc.Labels["region"] != "us-east" {
+		t.Fatalf("bad labels: %v", pvc.Labels)
+	}
+
+	volPlugin, err := s.Server.resolveVolumePlugin(pvc, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if volPlugin.Name() != "openebs.io/jiva" {
+		t.Fatalf("bad volume plugin: %s", volPlugin.Name())
+	}
+}
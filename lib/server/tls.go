@@ -0,0 +1,105 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"sync"
+
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+// tlsReloader holds the certificates that are served by the http listener.
+// The certificates can be reloaded without closing the listener, as every
+// TLS handshake fetches the latest ones.
+type tlsReloader struct {
+	mu sync.RWMutex
+
+	// cert is the certificate of the server
+	cert *tls.Certificate
+
+	// clientCAs is used to verify the certificates of the clients
+	clientCAs *x509.CertPool
+
+	// verifyClient requires the clients to present a certificate
+	verifyClient bool
+}
+
+// newTLSReloader provides a tlsReloader after loading the certificates
+// from the provided config
+func newTLSReloader(conf *config.TLSConfig) (*tlsReloader, error) {
+	r := &tlsReloader{}
+	if err := r.Reload(conf); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload reads the certificates again. The existing certificates are kept
+// as-is if the new ones can not be loaded.
+func (r *tlsReloader) Reload(conf *config.TLSConfig) error {
+	if conf == nil || !conf.Enabled {
+		return fmt.Errorf("tls is not enabled")
+	}
+
+	if err := conf.Validate(); err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(conf.CertFile, conf.KeyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load the tls certificate: %v", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if conf.CAFile != "" {
+		pem, err := ioutil.ReadFile(conf.CAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read the tls ca file: %v", err)
+		}
+
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("failed to parse the tls ca file '%s'", conf.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.verifyClient = conf.VerifyClient
+
+	return nil
+}
+
+// Config provides the TLS config that is used by the http listener
+func (r *tlsReloader) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		GetConfigForClient: r.getConfigForClient,
+	}
+}
+
+// getConfigForClient is invoked for every TLS handshake & provides the
+// latest certificates
+func (r *tlsReloader) getConfigForClient(*tls.ClientHelloInfo) (*tls.Config, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conf := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{*r.cert},
+		ClientCAs:    r.clientCAs,
+		ClientAuth:   tls.NoClientCert,
+	}
+
+	if r.verifyClient {
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return conf, nil
+}
@@ -0,0 +1,204 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/config"
+)
+
+// testCert is a PEM encoded certificate & key pair
+type testCert struct {
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// makeTestCert creates a certificate signed by the parent. A self signed
+// CA certificate is created if parent is nil.
+func makeTestCert(t *testing.T, cn string, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	return &testCert{
+		cert:    cert,
+		key:     key,
+		certPEM: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		keyPEM:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+	}
+}
+
+// writeTestTLSFiles writes the server's certificate, key & the CA to the
+// directory
+func writeTestTLSFiles(t *testing.T, dir string, ca, server *testCert) *config.TLSConfig {
+	conf := &config.TLSConfig{
+		Enabled:  true,
+		CertFile: filepath.Join(dir, "server.pem"),
+		KeyFile:  filepath.Join(dir, "server-key.pem"),
+		CAFile:   filepath.Join(dir, "ca.pem"),
+	}
+
+	for file, data := range map[string][]byte{
+		conf.CertFile: server.certPEM,
+		conf.KeyFile:  server.keyPEM,
+		conf.CAFile:   ca.certPEM,
+	} {
+		if err := ioutil.WriteFile(file, data, 0600); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+	}
+
+	return conf
+}
+
+// tlsGet makes a https request to the test server & provides the
+// certificate presented by the server
+func tlsGet(s *TestServer, ca, client *testCert) (*x509.Certificate, error) {
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	tlsConf := &tls.Config{RootCAs: roots}
+	if client != nil {
+		pair, err := tls.X509KeyPair(client.certPEM, client.keyPEM)
+		if err != nil {
+			return nil, err
+		}
+		tlsConf.Certificates = []tls.Certificate{pair}
+	}
+
+	httpClient := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tlsConf},
+	}
+
+	resp, err := httpClient.Get("https://" + s.Server.addr + "/latest/meta-data/instance-id")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0], nil
+}
+
+func TestHTTPServer_TLS(t *testing.T) {
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	ca := makeTestCert(t, "ca", nil)
+	server := makeTestCert(t, "server", ca)
+	tlsConf := writeTestTLSFiles(t, dir, ca, server)
+
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.TLS = tlsConf
+	})
+	defer s.Cleanup()
+
+	peer, err := tlsGet(s, ca, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if peer.SerialNumber.Cmp(server.cert.SerialNumber) != 0 {
+		t.Fatalf("bad server certificate: %v", peer.Subject)
+	}
+
+	// Reload with a new certificate
+	renewed := makeTestCert(t, "server-renewed", ca)
+	tlsConf = writeTestTLSFiles(t, dir, ca, renewed)
+	if err := s.Server.ReloadTLS(tlsConf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	peer, err = tlsGet(s, ca, nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if peer.SerialNumber.Cmp(renewed.cert.SerialNumber) != 0 {
+		t.Fatalf("expected the reloaded certificate, got: %v", peer.Subject)
+	}
+
+	// TLS can not be disabled via reload
+	if err := s.Server.ReloadTLS(nil); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestHTTPServer_MutualTLS(t *testing.T) {
+	dir := tmpDir(t)
+	defer os.RemoveAll(dir)
+
+	ca := makeTestCert(t, "ca", nil)
+	server := makeTestCert(t, "server", ca)
+	tlsConf := writeTestTLSFiles(t, dir, ca, server)
+	tlsConf.VerifyClient = true
+
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.TLS = tlsConf
+	})
+	defer s.Cleanup()
+
+	if _, err := tlsGet(s, ca, nil); err == nil {
+		t.Fatalf("expected error for a client without certificate")
+	}
+
+	// A client certificate that is not signed by the CA
+	other := makeTestCert(t, "other-ca", nil)
+	if _, err := tlsGet(s, ca, makeTestCert(t, "client", other)); err == nil {
+		t.Fatalf("expected error for a client with untrusted certificate")
+	}
+
+	if _, err := tlsGet(s, ca, makeTestCert(t, "client", ca)); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
@@ -3,13 +3,23 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/openebs/mayaserver/lib/api/v1"
-	"github.com/openebs/mayaserver/lib/volume/jiva"
+	"github.com/openebs/mayaserver/structs"
 )
 
+// VolumesRequest is a http handler implementation. It caters to the
+// volumes collection i.e. /latest/volumes/ as well as to a particular
+// volume resource i.e. /latest/volumes/{name}.
 func (s *HTTPServer) VolumesRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
+
+	volName := strings.TrimPrefix(req.URL.Path, "/latest/volumes/")
+	if volName != "" {
+		return s.volumeResourceRequest(resp, req, volName)
+	}
+
 	switch req.Method {
 	case "GET":
 		return s.volumeListRequest(resp, req)
@@ -20,19 +30,108 @@ func (s *HTTPServer) VolumesRequest(resp http.ResponseWriter, req *http.Request)
 	}
 }
 
-// TODO
-// Not yet implemented
+// volumeResourceRequest caters to the requests on a particular volume
+// resource. The http method decides the operation on the volume.
+func (s *HTTPServer) volumeResourceRequest(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
+
+	// Attachments are a sub resource of the volume
+	if name, instanceID, ok := parseAttachmentsPath(volName); ok {
+		return s.volumeAttachmentsRequest(resp, req, name, instanceID)
+	}
+
+	// A resize is a sub resource of the volume
+	if strings.HasSuffix(volName, "/resize") {
+		volName = strings.TrimSuffix(volName, "/resize")
+		if volName == "" || strings.Contains(volName, "/") {
+			return nil, CodedError(404, fmt.Sprintf("Invalid volume path '%s'", req.URL.Path))
+		}
+
+		if req.Method != "PUT" && req.Method != "POST" {
+			return nil, CodedError(405, ErrPutMethodRequired)
+		}
+		return s.volumeResize(resp, req, volName)
+	}
+
+	// Volume names are not nested
+	if strings.Contains(volName, "/") {
+		return nil, CodedError(404, fmt.Sprintf("Invalid volume path '%s'", req.URL.Path))
+	}
+
+	switch req.Method {
+	case "GET":
+		return s.volumeInfo(resp, req, volName)
+	case "PUT":
+		return s.volumeUpdate(resp, req, volName)
+	case "DELETE":
+		return s.volumeDelete(resp, req, volName)
+	default:
+		return nil, CodedError(405, ErrInvalidMethod)
+	}
+}
+
+// volumeListRequest lists the volumes. The volumes can be filtered by
+// ?prefix, ?region & ?datacenter query params. This is a blocking query if
+// ?index query param is provided.
 func (s *HTTPServer) volumeListRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
-	return nil, CodedError(405, "Volume list not yet implemented")
+
+	args := structs.QueryOptions{}
+	if s.parse(resp, req, &args.Region, &args) {
+		return nil, nil
+	}
+
+	// Get the volume plugin that is selected by the request
+	volPlugin, err := s.resolveVolumePlugin(claimFromQuery(req, ""), requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	lister, ok := volPlugin.Lister()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume listing is not supported by '%s'", volPlugin.Name()))
+	}
+
+	opts := toVolumeQueryOptions(&args)
+	opts.Datacenter = req.URL.Query().Get(DatacenterQueryParam)
+
+	pvList, err := lister.List(opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if index, err := strconv.ParseUint(pvList.ResourceVersion, 10, 64); err == nil {
+		setIndex(resp, index)
+	}
+
+	return pvList, nil
 }
 
 // VolumeSpecificRequest is a http handler implementation.
 // The URL path is parsed to match specific implementations.
 //
+// NOTE:
+//    These routes are deprecated in favour of /latest/volumes/{name} &
+// are served only if enable_legacy_volume_api is set in mayaserver's
+// config. The attachments of a volume i.e. /latest/volume/{name}/attachments
+// are not deprecated & are always served. The legacy routes take precedence
+// when enabled e.g. /latest/volume/info/attachments is the info of a volume
+// named attachments.
+//
 // TODO
 //    Should it return specific types than interface{} ?
 func (s *HTTPServer) VolumeSpecificRequest(resp http.ResponseWriter, req *http.Request) (interface{}, error) {
 
+	legacy := s.maya.config.EnableLegacyVolumeAPI &&
+		(strings.HasPrefix(req.URL.Path, "/latest/volume/info/") || strings.HasPrefix(req.URL.Path, "/latest/volume/delete/"))
+
+	if volName, instanceID, ok := parseAttachmentsPath(strings.TrimPrefix(req.URL.Path, "/latest/volume/")); ok && !legacy {
+		return s.volumeAttachmentsRequest(resp, req, volName, instanceID)
+	}
+
+	if !s.maya.config.EnableLegacyVolumeAPI {
+		return nil, CodedError(404, fmt.Sprintf("Deprecated path '%s', use /latest/volumes/{name} instead", req.URL.Path))
+	}
+
 	path := strings.TrimPrefix(req.URL.Path, "/latest/volume")
 
 	// Is req valid ?
@@ -40,12 +139,18 @@ func (s *HTTPServer) VolumeSpecificRequest(resp http.ResponseWriter, req *http.R
 		return nil, CodedError(405, ErrInvalidMethod)
 	}
 
+	s.logger.Printf("[WARN] http: Request %v uses a deprecated path, use /latest/volumes/{name} instead request_id=%s", req.URL, requestID(req))
+
 	switch {
 
-	case strings.Contains(path, "/delete/"):
+	case strings.HasPrefix(path, "/delete/"):
+		// A GET must not delete a volume e.g. when a link is prefetched
+		if req.Method != "DELETE" && req.Method != "POST" {
+			return nil, CodedError(405, ErrDeleteMethodRequired)
+		}
 		volName := strings.TrimPrefix(path, "/delete/")
 		return s.volumeDelete(resp, req, volName)
-	case strings.Contains(path, "/info/"):
+	case strings.HasPrefix(path, "/info/"):
 		volName := strings.TrimPrefix(path, "/info/")
 		return s.volumeInfo(resp, req, volName)
 	default:
@@ -65,32 +170,45 @@ func (s *HTTPServer) volumeUpdate(resp http.ResponseWriter, req *http.Request, v
 	//	return nil, CodedError(400, "Empty or Invalid volume claim request")
 	//}
 
+	// The volume name in the path takes precedence
+	if volName != "" {
+		if pvc.Name != "" && pvc.Name != volName {
+			return nil, CodedError(400, fmt.Sprintf("Volume name '%s' does not match the path '%s'", pvc.Name, volName))
+		}
+		pvc.Name = volName
+	}
+
 	if pvc.Name == "" {
 		return nil, CodedError(400, fmt.Sprintf("Volume name hasn't been provided: '%v'", pvc))
 	}
 
+	// The parameters of the storage class are the defaults of the labels
+	s.applyStorageClass(&pvc)
+
 	if pvc.Labels == nil {
 		return nil, CodedError(400, fmt.Sprintf("Volume labels hasn't been provided: '%v'", pvc))
 	}
 
-	// TODO
-	// Get the type of volume plugin from:
-	//  1. http request parameters,
-	//  2. Mayaconfig etc.
-	//
-	// We shall hardcode to jiva now
-	volPlugName := jiva.JivaStorPluginName
-
-	// Get jiva storage plugin
-	jivaStor, err := s.GetVolumePlugin(volPlugName)
+	// Get the volume plugin that is selected by the claim
+	volPlugin, err := s.resolveVolumePlugin(&pvc, requestID(req))
+	if err != nil {
+		return nil, err
+	}
 
-	// Get jiva volume provisioner
-	jivaProv, ok := jivaStor.Provisioner()
+	// Get the volume provisioner
+	prov, ok := volPlugin.Provisioner()
 	if !ok {
-		return nil, fmt.Errorf("Volume provisioning not supported by '%s'", volPlugName)
+		return nil, CodedError(400, fmt.Sprintf("Volume provisioning not supported by '%s'", volPlugin.Name()))
+	}
+
+	// Report all the problems of the claim before provisioning
+	if validator, ok := volPlugin.Validator(); ok {
+		if err := validator.Validate(&pvc); err != nil {
+			return nil, err
+		}
 	}
 
-	pv, err := jivaProv.Provision(&pvc)
+	pv, err := prov.Provision(&pvc)
 
 	if err != nil {
 		return nil, err
@@ -102,30 +220,28 @@ func (s *HTTPServer) volumeUpdate(resp http.ResponseWriter, req *http.Request, v
 func (s *HTTPServer) volumeDelete(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
 
 	if volName == "" {
-		return nil, fmt.Errorf("Volume name missing for deletion")
+		return nil, CodedError(400, "Volume name missing for deletion")
 	}
 
-	// TODO
-	// Get the type of volume plugin from:
-	//  1. http request parameters,
-	//  2. Mayaconfig etc.
-	// We shall hardcode to jiva now
-	volPlugName := jiva.JivaStorPluginName
-
-	// Get jiva storage plugin
-	jivaStor, err := s.GetVolumePlugin(volPlugName)
+	// Get the volume plugin that is selected by the request
+	query := claimFromQuery(req, volName)
+	volPlugin, err := s.resolveVolumePlugin(query, requestID(req))
+	if err != nil {
+		return nil, err
+	}
 
-	// Get jiva volume deleter
-	jivaDel, ok := jivaStor.Deleter()
+	// Get the volume deleter
+	deleter, ok := volPlugin.Deleter()
 	if !ok {
-		return nil, fmt.Errorf("Deleting volume is not supported by '%s'", volPlugName)
+		return nil, CodedError(400, fmt.Sprintf("Deleting volume is not supported by '%s'", volPlugin.Name()))
 	}
 
-	// Delete a jiva volume
+	// Delete the volume
 	pv := &v1.PersistentVolume{}
 	pv.Name = volName
+	pv.Labels = query.Labels
 
-	dPV, err := jivaDel.Delete(pv)
+	dPV, err := deleter.Delete(pv)
 
 	if err != nil {
 		return nil, err
@@ -134,35 +250,98 @@ func (s *HTTPServer) volumeDelete(resp http.ResponseWriter, req *http.Request, v
 	return dPV, nil
 }
 
+// volumeResize grows a volume to the storage size requested in the claim
+// that is sent as the request's body. The status of the returned volume
+// reports the progress of the resize.
+func (s *HTTPServer) volumeResize(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
+
+	pvc := v1.PersistentVolumeClaim{}
+	if err := decodeBody(req, &pvc); err != nil {
+		return nil, CodedError(400, err.Error())
+	}
+
+	if pvc.Name != "" && pvc.Name != volName {
+		return nil, CodedError(400, fmt.Sprintf("Volume name '%s' does not match the path '%s'", pvc.Name, volName))
+	}
+	pvc.Name = volName
+
+	if _, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]; !found {
+		return nil, CodedError(400, fmt.Sprintf("Requested storage size of volume '%s' hasn't been provided", volName))
+	}
+
+	// The query params select the volume plugin if the body does not
+	if pvc.Spec.StorageClassName == nil && pvc.Annotations[v1.OrchClassAnnotation] == "" {
+		query := claimFromQuery(req, volName)
+		pvc.Spec.StorageClassName = query.Spec.StorageClassName
+		pvc.Annotations = query.Annotations
+	}
+
+	volPlugin, err := s.resolveVolumePlugin(&pvc, requestID(req))
+	if err != nil {
+		return nil, err
+	}
+
+	resizer, ok := volPlugin.Resizer()
+	if !ok {
+		return nil, CodedError(400, fmt.Sprintf("Volume resize is not supported by '%s'", volPlugin.Name()))
+	}
+
+	pv, err := resizer.Resize(&pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	return pv, nil
+}
+
+// volumeInfo fetches the details of a volume. This is a blocking query if
+// ?index query param is provided. The query waits till the volume's index
+// exceeds the provided index or till ?wait duration elapses.
 func (s *HTTPServer) volumeInfo(resp http.ResponseWriter, req *http.Request, volName string) (interface{}, error) {
 
 	if volName == "" {
-		return nil, fmt.Errorf("Volume name missing")
+		return nil, CodedError(400, "Volume name missing")
 	}
 
-	// TODO
-	// Get the type of volume plugin from:
-	//  1. http request parameters,
-	//  2. Mayaconfig etc.
-	// We shall hardcode to jiva now
-	volPlugName := jiva.JivaStorPluginName
+	args := structs.QueryOptions{}
+	if s.parse(resp, req, &args.Region, &args) {
+		return nil, nil
+	}
 
-	// Get jiva storage plugin
-	jivaStor, err := s.GetVolumePlugin(volPlugName)
+	// Get the volume plugin that is selected by the request
+	pvc := claimFromQuery(req, volName)
+	volPlugin, err := s.resolveVolumePlugin(pvc, requestID(req))
+	if err != nil {
+		return nil, err
+	}
 
-	jivaInfo, ok := jivaStor.Informer()
+	informer, ok := volPlugin.Informer()
 	if !ok {
-		return nil, fmt.Errorf("Volume information is not supported by '%s'", volPlugName)
+		return nil, CodedError(400, fmt.Sprintf("Volume information is not supported by '%s'", volPlugin.Name()))
 	}
 
-	pvc := &v1.PersistentVolumeClaim{}
-	pvc.Name = volName
-
-	info, err := jivaInfo.Info(pvc)
+	info, qm, err := informer.Info(pvc, toVolumeQueryOptions(&args))
 
 	if err != nil {
 		return nil, err
 	}
 
+	if qm != nil {
+		setIndex(resp, qm.LastIndex)
+		setLastContact(resp, qm.LastContact)
+	}
+
 	return info, nil
 }
+
+// toVolumeQueryOptions transforms the parsed query options of a http
+// request to the query options understood by the volume plugins
+func toVolumeQueryOptions(args *structs.QueryOptions) *v1.QueryOptions {
+	return &v1.QueryOptions{
+		Region:     args.Region,
+		Prefix:     args.Prefix,
+		AllowStale: args.AllowStale,
+		WaitIndex:  args.MinQueryIndex,
+		WaitTime:   args.MaxQueryTime,
+	}
+}
@@ -1 +1,431 @@
 package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/config"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestResolveVolumePlugin_Defaults(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	volPlugin, err := s.Server.resolveVolumePlugin(nil, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if volPlugin.Name() != s.Maya.config.DefaultVolumePlugin {
+		t.Fatalf("bad volume plugin: expected: %s, got: %s", s.Maya.config.DefaultVolumePlugin, volPlugin.Name())
+	}
+}
+
+func TestResolveVolumePlugin_ShortStorageClass(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	class := "jiva"
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Spec.StorageClassName = &class
+
+	volPlugin, err := s.Server.resolveVolumePlugin(pvc, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if volPlugin.Name() != "openebs.io/jiva" {
+		t.Fatalf("bad volume plugin: %s", volPlugin.Name())
+	}
+}
+
+func TestVolumeUpdate_UnknownStorageClass(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	class := "unknown"
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Labels = map[string]string{"region": "global"}
+	pvc.Spec.StorageClassName = &class
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), "Unsupported storage class") {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
+
+func TestVolumeUpdate_UnknownOrchClass(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Labels = map[string]string{"region": "global"}
+	pvc.Annotations = map[string]string{
+		v1.OrchClassAnnotation: "unknown",
+	}
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), "Unsupported orchestrator class") {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
+
+func TestVolumeUpdate_InvalidClaim(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Labels = map[string]string{
+		"region":   "global",
+		"jivafeip": "bogus",
+	}
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	errResp := v1.ErrorResponse{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &errResp); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// All the missing labels & the bad ip are reported together. The
+	// image & the network are defaulted by the jiva config.
+	if errResp.Reason != v1.ReasonInvalid || len(errResp.Causes) != 5 {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
+
+func TestVolumeInfo_UnknownStorageClass(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, _ := http.NewRequest("GET", "/latest/volumes/myvol?storage-class=bogus", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+}
+
+func TestVolumeInfo_InvalidWait(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, _ := http.NewRequest("GET", "/latest/volumes/myvol?index=10&wait=bogus", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+
+	if !strings.Contains(resp.Body.String(), "Invalid wait time") {
+		t.Fatalf("bad response: %s", resp.Body.String())
+	}
+}
+
+func TestVolumeResource_InvalidMethod(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	for _, method := range []string{"POST", "PATCH"} {
+		req, _ := http.NewRequest(method, "/latest/volumes/myvol", nil)
+		resp := httptest.NewRecorder()
+		s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+		if resp.Code != 405 {
+			t.Fatalf("bad http code for %s: expected: 405, got: %v", method, resp.Code)
+		}
+	}
+}
+
+func TestVolumeResource_NestedPath(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, _ := http.NewRequest("DELETE", "/latest/volumes/myvol/info", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v", resp.Code)
+	}
+}
+
+func TestVolumeUpdate_NameMismatch(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "othervol"
+	pvc.Labels = map[string]string{"region": "global"}
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/myvol", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 400 {
+		t.Fatalf("bad http code: expected: 400, got: %v", resp.Code)
+	}
+}
+
+func TestVolumeSpecificRequest_LegacyDisabled(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	req, _ := http.NewRequest("GET", "/latest/volume/delete/myvol", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v", resp.Code)
+	}
+}
+
+func TestVolumeSpecificRequest_LegacyPathParsing(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.EnableLegacyVolumeAPI = true
+	})
+	defer s.Cleanup()
+
+	// A volume whose name contains delete must be routed to info. Only the
+	// info route parses the wait param.
+	req, _ := http.NewRequest("GET", "/latest/volume/info/delete-me?index=1&wait=bogus", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+	if resp.Code != 400 || !strings.Contains(resp.Body.String(), "Invalid wait time") {
+		t.Fatalf("bad response: %v %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVolumeSpecificRequest_LegacyDeleteMethod(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.EnableLegacyVolumeAPI = true
+		mc.DefaultOrchProvider = "mock"
+	})
+	defer s.Cleanup()
+
+	for _, method := range []string{"GET", "HEAD", "PUT"} {
+		req, _ := http.NewRequest(method, "/latest/volume/delete/myvol", nil)
+		resp := httptest.NewRecorder()
+		s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+		if resp.Code != 405 {
+			t.Fatalf("%s: bad http code: expected: 405, got: %v", method, resp.Code)
+		}
+	}
+
+	// The volume is not found rather than the method being rejected
+	req, _ := http.NewRequest("DELETE", "/latest/volume/delete/myvol", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v: %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVolumeSpecificRequest_LegacyInfoOfAttachments(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.EnableLegacyVolumeAPI = true
+		mc.DefaultOrchProvider = "mock"
+	})
+	defer s.Cleanup()
+
+	// The volume named attachments is not found rather than the
+	// attachments of a volume named info being listed
+	req, _ := http.NewRequest("GET", "/latest/volume/info/attachments", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+	if resp.Code != 404 || !strings.Contains(resp.Body.String(), "Volume 'attachments' not found") {
+		t.Fatalf("bad response: %v %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestVolumeSpecificRequest_LegacyDeleteOfAttachments(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.EnableLegacyVolumeAPI = true
+		mc.DefaultOrchProvider = "mock"
+	})
+	defer s.Cleanup()
+
+	// The volume named attachments is not found rather than the method
+	// being rejected by the attachments of a volume named delete
+	req, _ := http.NewRequest("DELETE", "/latest/volume/delete/attachments", nil)
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumeSpecificRequest)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v: %s", resp.Code, resp.Body.String())
+	}
+}
+
+// fakeProvisioner remembers the claim it was asked to provision
+type fakeProvisioner struct {
+	pvc *v1.PersistentVolumeClaim
+}
+
+func (f *fakeProvisioner) Provision(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	f.pvc = pvc
+	return &v1.PersistentVolume{}, nil
+}
+
+func TestRequestVolume_SetsRequestID(t *testing.T) {
+	fake := &fakeProvisioner{}
+	p := &requestProvisioner{fake, "req-1"}
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	if _, err := p.Provision(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if v1.RequestID(fake.pvc.ObjectMeta) != "req-1" {
+		t.Fatalf("bad request id annotation: %v", fake.pvc.Annotations)
+	}
+
+	opts := &v1.QueryOptions{Prefix: "my"}
+	withID := withRequestID(opts, "req-1")
+	if withID.RequestID != "req-1" || withID.Prefix != "my" {
+		t.Fatalf("bad query options: %#v", withID)
+	}
+
+	if opts.RequestID != "" {
+		t.Fatalf("query options of the caller should not be modified")
+	}
+
+	if withRequestID(nil, "") != nil {
+		t.Fatalf("expected nil query options")
+	}
+}
+
+func TestVolumeResize_InvalidRequests(t *testing.T) {
+	s := makeHTTPTestServer(t, nil)
+	defer s.Cleanup()
+
+	sized := v1.PersistentVolumeClaim{}
+	sized.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("10Gi"),
+	}
+	mismatch := sized
+	mismatch.Name = "othervol"
+
+	cases := []struct {
+		method string
+		path   string
+		pvc    v1.PersistentVolumeClaim
+		code   int
+	}{
+		{"GET", "/latest/volumes/myvol/resize", sized, 405},
+		{"PUT", "/latest/volumes/myvol/nested/resize", sized, 404},
+		{"PUT", "/latest/volumes/myvol/resize", v1.PersistentVolumeClaim{}, 400},
+		{"PUT", "/latest/volumes/myvol/resize", mismatch, 400},
+		{"POST", "/latest/volumes/myvol/resize?storage-class=bogus", sized, 400},
+	}
+
+	for _, c := range cases {
+		buf, _ := json.Marshal(c.pvc)
+		req, _ := http.NewRequest(c.method, c.path, bytes.NewReader(buf))
+		resp := httptest.NewRecorder()
+		s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+		if resp.Code != c.code {
+			t.Fatalf("%s %s: expected: %d, got: %d: %s", c.method, c.path, c.code, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestVolumeLifecycle_MockOrchestrator(t *testing.T) {
+	s := makeHTTPTestServer(t, func(mc *config.MayaConfig) {
+		mc.DefaultOrchProvider = "mock"
+	})
+	defer s.Cleanup()
+
+	pvc := v1.PersistentVolumeClaim{}
+	pvc.Name = "myvol"
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeip":        "10.0.0.10",
+		"jivabeip":        "10.0.0.11",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "eth0",
+	}
+
+	buf, _ := json.Marshal(pvc)
+	req, _ := http.NewRequest("PUT", "/latest/volumes/", bytes.NewReader(buf))
+	resp := httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v: %s", resp.Code, resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/latest/volumes/myvol", nil)
+	resp = httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v: %s", resp.Code, resp.Body.String())
+	}
+
+	pv := v1.PersistentVolume{}
+	if err := json.Unmarshal(resp.Body.Bytes(), &pv); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if pv.Name != "myvol" || pv.Status.Reason != "pending" {
+		t.Fatalf("bad volume: %s", resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("DELETE", "/latest/volumes/myvol", nil)
+	resp = httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 200 {
+		t.Fatalf("bad http code: expected: 200, got: %v: %s", resp.Code, resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("GET", "/latest/volumes/myvol", nil)
+	resp = httptest.NewRecorder()
+	s.Server.wrap(s.Server.VolumesRequest)(resp, req)
+
+	if resp.Code != 404 {
+		t.Fatalf("bad http code: expected: 404, got: %v: %s", resp.Code, resp.Body.String())
+	}
+}
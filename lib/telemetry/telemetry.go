@@ -0,0 +1,372 @@
+// Package telemetry provides the metrics of mayaserver in Prometheus text
+// exposition format.
+//
+// NOTE:
+//    This is a minimal implementation of counters, gauges & histograms with
+// labels. The metrics are registered against a registry which renders them
+// when /metrics is scraped.
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the content type of the Prometheus text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds, in seconds, of the histogram buckets
+// that suit the latencies of http requests & orchestrator calls
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30}
+
+// Collector is a metric that can render itself in Prometheus text format
+type Collector interface {
+	// Name is the unique name of the metric
+	Name() string
+
+	// WriteText renders the metric with all its series
+	WriteText(w io.Writer) error
+}
+
+// Registry holds the collectors that are rendered together
+type Registry struct {
+	mu         sync.Mutex
+	collectors map[string]Collector
+}
+
+// NewRegistry provides a new instance of Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		collectors: make(map[string]Collector),
+	}
+}
+
+// Register adds a collector to the registry. A collector can be registered
+// only once.
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.collectors[c.Name()]; found {
+		return fmt.Errorf("metric '%s' is already registered", c.Name())
+	}
+
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// MustRegister adds the collectors to the registry & panics on failure
+func (r *Registry) MustRegister(cs ...Collector) {
+	for _, c := range cs {
+		if err := r.Register(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// WriteText renders all the collectors sorted by their names
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	collectors := make([]Collector, 0, len(names))
+	for _, name := range names {
+		collectors = append(collectors, r.collectors[name])
+	}
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		if err := c.WriteText(w); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Default is the registry that is rendered by mayaserver's /metrics
+var Default = NewRegistry()
+
+// MustRegister adds the collectors to the default registry
+func MustRegister(cs ...Collector) {
+	Default.MustRegister(cs...)
+}
+
+// metricVec holds the label names & the description of a metric
+type metricVec struct {
+	name       string
+	help       string
+	typ        string
+	labelNames []string
+}
+
+func (v *metricVec) Name() string {
+	return v.name
+}
+
+// key provides the series key of the label values
+func (v *metricVec) key(labelValues []string) string {
+	if len(labelValues) != len(v.labelNames) {
+		panic(fmt.Sprintf("metric '%s' expects %d label values, got %d", v.name, len(v.labelNames), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\x00")
+}
+
+// writeHeader renders the HELP & TYPE lines
+func (v *metricVec) writeHeader(buf *bytes.Buffer) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", v.name, v.typ)
+}
+
+// labels renders the label pairs of a series along with any extra pair
+// e.g. le of a histogram bucket
+func (v *metricVec) labels(labelValues []string, extra ...string) string {
+	pairs := make([]string, 0, len(labelValues)+1)
+	for i, name := range v.labelNames {
+		pairs = append(pairs, name+`="`+escapeLabelValue(labelValues[i])+`"`)
+	}
+	if len(extra) == 2 {
+		pairs = append(pairs, extra[0]+`="`+escapeLabelValue(extra[1])+`"`)
+	}
+
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// series is the value of a metric for a set of label values
+type series struct {
+	labelValues []string
+	value       float64
+}
+
+// valueVec is the common implementation of counters & gauges
+type valueVec struct {
+	metricVec
+	mu     sync.Mutex
+	series map[string]*series
+}
+
+func newValueVec(typ, name, help string, labelNames []string) valueVec {
+	return valueVec{
+		metricVec: metricVec{
+			name:       name,
+			help:       help,
+			typ:        typ,
+			labelNames: labelNames,
+		},
+		series: make(map[string]*series),
+	}
+}
+
+func (v *valueVec) get(labelValues []string) *series {
+	k := v.key(labelValues)
+	s, found := v.series[k]
+	if !found {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		v.series[k] = s
+	}
+	return s
+}
+
+func (v *valueVec) WriteText(w io.Writer) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var buf bytes.Buffer
+	v.writeHeader(&buf)
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		fmt.Fprintf(&buf, "%s%s %s\n", v.name, v.labels(s.labelValues), formatFloat(s.value))
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// CounterVec is a counter partitioned by labels
+type CounterVec struct {
+	valueVec
+}
+
+// NewCounterVec provides a new counter
+func NewCounterVec(name, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{newValueVec("counter", name, help, labelNames)}
+}
+
+// Inc increments the counter of the label values by 1
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter of the label values. Negative values are
+// ignored as counters can only go up.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.get(labelValues).value += v
+}
+
+// GaugeVec is a gauge partitioned by labels
+type GaugeVec struct {
+	valueVec
+}
+
+// NewGaugeVec provides a new gauge
+func NewGaugeVec(name, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{newValueVec("gauge", name, help, labelNames)}
+}
+
+// Set sets the gauge of the label values
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.get(labelValues).value = v
+}
+
+// Reset removes all the series of the gauge
+func (g *GaugeVec) Reset() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.series = make(map[string]*series)
+}
+
+// histogramSeries is the value of a histogram for a set of label values
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+// HistogramVec is a histogram partitioned by labels
+type HistogramVec struct {
+	metricVec
+	buckets []float64
+
+	mu     sync.Mutex
+	series map[string]*histogramSeries
+}
+
+// NewHistogramVec provides a new histogram. DefaultBuckets are used if
+// buckets is nil.
+func NewHistogramVec(name, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &HistogramVec{
+		metricVec: metricVec{
+			name:       name,
+			help:       help,
+			typ:        "histogram",
+			labelNames: labelNames,
+		},
+		buckets: sorted,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe adds an observation to the histogram of the label values
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := h.key(labelValues)
+	s, found := h.series[k]
+	if !found {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[k] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+func (h *HistogramVec) WriteText(w io.Writer) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := make([]string, 0, len(h.series))
+	for k := range h.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	h.writeHeader(&buf)
+	for _, k := range keys {
+		s := h.series[k]
+		for i, upper := range h.buckets {
+			fmt.Fprintf(&buf, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", formatFloat(upper)), s.counts[i])
+		}
+		fmt.Fprintf(&buf, "%s_bucket%s %d\n", h.name, h.labels(s.labelValues, "le", "+Inf"), s.count)
+		fmt.Fprintf(&buf, "%s_sum%s %s\n", h.name, h.labels(s.labelValues), formatFloat(s.sum))
+		fmt.Fprintf(&buf, "%s_count%s %d\n", h.name, h.labels(s.labelValues), s.count)
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func sortedKeys(m map[string]*series) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
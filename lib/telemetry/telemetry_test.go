@@ -0,0 +1,69 @@
+package telemetry
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := NewCounterVec("maya_test_requests_total", "Total requests.", "route", "code")
+	volumes := NewGaugeVec("maya_test_volumes", "Known volumes.", "phase")
+	latency := NewHistogramVec("maya_test_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	r.MustRegister(requests, volumes, latency)
+
+	requests.Inc("/latest/volumes/", "200")
+	requests.Add(2, "/latest/volumes/", "200")
+	requests.Inc("/", "404")
+	volumes.Set(3, "running")
+	latency.Observe(0.05, "/")
+	latency.Observe(0.5, "/")
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	expected := `# HELP maya_test_duration_seconds Request latency.
+# TYPE maya_test_duration_seconds histogram
+maya_test_duration_seconds_bucket{route="/",le="0.1"} 1
+maya_test_duration_seconds_bucket{route="/",le="1"} 2
+maya_test_duration_seconds_bucket{route="/",le="+Inf"} 2
+maya_test_duration_seconds_sum{route="/"} 0.55
+maya_test_duration_seconds_count{route="/"} 2
+# HELP maya_test_requests_total Total requests.
+# TYPE maya_test_requests_total counter
+maya_test_requests_total{route="/",code="404"} 1
+maya_test_requests_total{route="/latest/volumes/",code="200"} 3
+# HELP maya_test_volumes Known volumes.
+# TYPE maya_test_volumes gauge
+maya_test_volumes{phase="running"} 3
+`
+	if buf.String() != expected {
+		t.Fatalf("bad:\nexpected:\n%s\nactual:\n%s", expected, buf.String())
+	}
+
+	volumes.Reset()
+	buf.Reset()
+	r.WriteText(&buf)
+	if strings.Contains(buf.String(), `phase="running"`) {
+		t.Fatalf("expected the gauge to be reset:\n%s", buf.String())
+	}
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister(NewCounterVec("maya_test_total", "Test."))
+
+	if err := r.Register(NewGaugeVec("maya_test_total", "Test.")); err == nil {
+		t.Fatalf("expected error, got nothing")
+	}
+}
+
+func TestEscapeLabelValue(t *testing.T) {
+	if v := escapeLabelValue("a\"b\\c\nd"); v != `a\"b\\c\nd` {
+		t.Fatalf("bad escaped value: %s", v)
+	}
+}
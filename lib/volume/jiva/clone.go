@@ -0,0 +1,110 @@
+// This file handles the cloning of jiva volumes.
+//
+// NOTE:
+//    A jiva volume is cloned from a snapshot of a running source volume. The
+// replica of the clone syncs the snapshot from the source volume's controller
+// instead of starting with an empty store.
+package jiva
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/golang/glog"
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+const (
+	// jivaCloneSnapPrefix is the prefix of the snapshot that is taken of the
+	// source volume when the claim does not name one
+	jivaCloneSnapPrefix = "clone-"
+
+	// jivaCloneCtlIPLabel is the label of a claim that carries the ip of the
+	// source volume's controller. This is set by the jiva plugin & is
+	// understood by the orchestrators.
+	jivaCloneCtlIPLabel = "jivaclonectlip"
+)
+
+// isCloneClaim returns true if the claim requests a clone of another volume
+func isCloneClaim(pvc *v1.PersistentVolumeClaim) bool {
+	return pvc.Annotations[v1.CloneSourceVolumeAnnotation] != "" ||
+		pvc.Annotations[v1.CloneSourceSnapshotAnnotation] != ""
+}
+
+// prepareClone resolves the source of a clone claim. The snapshot to clone
+// from is taken if the claim does not name one. The claim is then set with
+// the source's snapshot, its controller ip & its size, if not requested.
+func (j *jivaStor) prepareClone(pvc *v1.PersistentVolumeClaim) error {
+	srcVolName := pvc.Annotations[v1.CloneSourceVolumeAnnotation]
+	srcSnapName := pvc.Annotations[v1.CloneSourceSnapshotAnnotation]
+
+	if srcVolName == "" {
+		return v1.NewInvalidError(fmt.Errorf("Source volume of clone '%s' hasn't been provided", pvc.Name))
+	}
+
+	if srcVolName == pvc.Name {
+		return v1.NewInvalidError(fmt.Errorf("Volume '%s' can not be cloned from itself", pvc.Name))
+	}
+
+	srcClaim := &v1.PersistentVolumeClaim{}
+	srcClaim.Name = srcVolName
+	srcClaim.Annotations = map[string]string{
+		v1.RequestIDAnnotation: v1.RequestID(pvc.ObjectMeta),
+	}
+
+	srcPV, _, err := j.jivaOps.Info(srcClaim, nil)
+	if err != nil {
+		return err
+	}
+
+	// A clone is as large as its source unless requested otherwise
+	srcSize, hasSrcSize := srcPV.Spec.Capacity[v1.ResourceStorage]
+	size, hasSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+
+	switch {
+	case hasSrcSize && !hasSize:
+		if pvc.Spec.Resources.Requests == nil {
+			pvc.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = srcSize
+	case hasSrcSize && size.Cmp(srcSize) < 0:
+		return v1.NewInvalidError(fmt.Errorf("Clone '%s' of size '%s' is smaller than its source '%s' of size '%s'", pvc.Name, size.String(), srcVolName, srcSize.String()))
+	}
+
+	host, err := controllerHost(srcPV)
+	if err != nil {
+		return err
+	}
+
+	ctrl := newJivaController("http://" + net.JoinHostPort(host, JivaControllerPort))
+
+	disks, err := ctrl.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	if srcSnapName == "" {
+		srcSnapName = jivaCloneSnapPrefix + pvc.Name
+		if _, found := disks[srcSnapName]; !found {
+			glog.V(2).Infof("Taking snapshot '%s' of jiva volume '%s' to clone '%s' request_id=%s", srcSnapName, srcVolName, pvc.Name, v1.RequestID(pvc.ObjectMeta))
+
+			if err := ctrl.Snapshot(srcSnapName); err != nil {
+				return err
+			}
+		}
+	} else if _, found := disks[srcSnapName]; !found {
+		return &v1.VolumeError{
+			Reason:  v1.ReasonNotFound,
+			Message: fmt.Sprintf("Snapshot '%s' of volume '%s' not found", srcSnapName, srcVolName),
+		}
+	}
+
+	pvc.Annotations[v1.CloneSourceSnapshotAnnotation] = srcSnapName
+
+	if pvc.Labels == nil {
+		pvc.Labels = map[string]string{}
+	}
+	pvc.Labels[jivaCloneCtlIPLabel] = host
+
+	return nil
+}
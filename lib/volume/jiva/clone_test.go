@@ -0,0 +1,71 @@
+package jiva
+
+import (
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// fakeJivaOps serves the info of a single volume
+type fakeJivaOps struct {
+	JivaOps
+	pv *v1.PersistentVolume
+}
+
+func (f *fakeJivaOps) Info(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+	if f.pv == nil || f.pv.Name != pvc.Name {
+		return nil, nil, v1.NewNotFoundError(pvc.Name, nil)
+	}
+	return f.pv, nil, nil
+}
+
+func cloneClaim(srcVolName string) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "clonevol"
+	pvc.Annotations = map[string]string{
+		v1.CloneSourceVolumeAnnotation: srcVolName,
+	}
+	return pvc
+}
+
+func TestPrepareClone(t *testing.T) {
+	src := &v1.PersistentVolume{}
+	src.Name = "srcvol"
+	src.Spec.Capacity = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("5Gi"),
+	}
+
+	j := &jivaStor{jivaOps: &fakeJivaOps{pv: src}}
+
+	if err := j.prepareClone(cloneClaim("")); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for a missing source, got: %v", err)
+	}
+
+	if err := j.prepareClone(cloneClaim("clonevol")); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for a self clone, got: %v", err)
+	}
+
+	if err := j.prepareClone(cloneClaim("othervol")); !v1.IsNotFound(err) {
+		t.Fatalf("expected not found error, got: %v", err)
+	}
+
+	small := cloneClaim("srcvol")
+	small.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("1Gi"),
+	}
+	if err := j.prepareClone(small); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for a smaller clone, got: %v", err)
+	}
+
+	// The source is not running i.e. has no target portal
+	pvc := cloneClaim("srcvol")
+	if err := j.prepareClone(pvc); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	size := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if size.Value() != 5<<30 {
+		t.Fatalf("clone should default to the source's size, got: %s", size.String())
+	}
+}
@@ -0,0 +1,338 @@
+// This file handles the configuration of jiva volume plugin.
+//
+// NOTE:
+//    The config provides the defaults of a jiva volume. These defaults are
+// set against the labels of a claim that does not have them. Hence, the
+// labels of a claim override the config.
+package jiva
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	gcfg "gopkg.in/gcfg.v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// Labels of a claim that tune a jiva volume. These are understood by the
+// orchestrators.
+const (
+	jivaImageLabel           = "jivafeversion"
+	jivaNetworkLabel         = "jivafenetwork"
+	jivaReplicasLabel        = "jivareplicas"
+	jivaCtlIPLabel           = "jivafeip"
+	jivaRepIPLabel           = "jivabeip"
+	jivaCtlArtifactLabel     = "jivafeartifact"
+	jivaCtlCPULabel          = "jivafecpu"
+	jivaCtlMemoryLabel       = "jivafememory"
+	jivaRepArtifactLabel     = "jivarepartifact"
+	jivaRepCPULabel          = "jivarepcpu"
+	jivaRepMemoryLabel       = "jivarepmemory"
+	jivaRepStoreRootLabel    = "jivarepstoreroot"
+	jivaRestartAttemptsLabel = "jivarestartattempts"
+	jivaRestartIntervalLabel = "jivarestartinterval"
+	jivaRestartDelayLabel    = "jivarestartdelay"
+	jivaRestartModeLabel     = "jivarestartmode"
+
+	// jivaRepCloneArtifactLabel is set only if the config provides a
+	// replica artifact that can seed a clone
+	jivaRepCloneArtifactLabel = "jivarepcloneartifact"
+)
+
+// Restart modes of the jiva tasks
+const (
+	jivaRestartModeDelay = "delay"
+	jivaRestartModeFail  = "fail"
+)
+
+// Defaults of a jiva volume in the absence of a config file
+const (
+	defaultJivaImage           = "openebs/jiva:latest"
+	defaultJivaVolSize         = "5Gi"
+	defaultJivaMinVolSize      = "1Gi"
+	defaultJivaMaxVolSize      = "10Ti"
+	defaultJivaNetwork         = "host"
+	defaultJivaReplicas        = 1
+	defaultJivaRepStoreRoot    = "/tmp/jiva"
+	defaultJivaCtlArtifact     = "https://raw.githubusercontent.com/openebs/jiva/master/scripts/launch-jiva-ctl-with-ip"
+	defaultJivaRepArtifact     = "https://raw.githubusercontent.com/openebs/jiva/master/scripts/launch-jiva-rep-with-ip"
+	defaultJivaTaskCPU         = 500
+	defaultJivaTaskMemoryMB    = 256
+	defaultJivaRestartAttempts = 3
+	defaultJivaRestartInterval = "5m"
+	defaultJivaRestartDelay    = "25s"
+)
+
+// JivaConfig provides the defaults of jiva volumes.
+//
+// A JivaConfig file has .INI extension.
+// Below is a sample:
+//
+// [volume]
+// image = openebs/jiva:0.3-RC2
+// size = 10Gi
+// min-size = 1Gi
+// max-size = 1Ti
+// network = host
+// replicas = 3
+// store-root = /var/openebs
+//
+// [controller]
+// artifact = https://example.com/launch-jiva-ctl-with-ip
+// cpu = 500
+// memory = 256
+//
+// [replica]
+// artifact = https://example.com/launch-jiva-rep-with-ip
+// cpu = 1000
+// memory = 512
+//
+// [restart]
+// attempts = 3
+// interval = 5m
+// delay = 25s
+// mode = delay
+//
+// [clone]
+// replica-artifact = https://example.com/launch-jiva-clone-rep-with-ip
+//
+// NOTE:
+//    This is as per gcfg lib's conventions
+type JivaConfig struct {
+	Volume struct {
+		// Image of the jiva controller & replica
+		Image string
+
+		// Size of a volume whose claim does not request any storage
+		Size string
+
+		// MinSize & MaxSize limit the size of a volume
+		MinSize string `gcfg:"min-size"`
+		MaxSize string `gcfg:"max-size"`
+
+		// Network mode of the jiva controller & replica
+		Network string
+
+		// Replicas is the number of replicas of a volume whose claim does
+		// not request any
+		Replicas int
+
+		// StoreRoot is the directory under which the replicas store data
+		StoreRoot string `gcfg:"store-root"`
+	}
+
+	Controller JivaTaskConfig
+
+	Replica JivaTaskConfig
+
+	Restart struct {
+		Attempts int
+		Interval string
+		Delay    string
+		Mode     string
+	}
+
+	Clone struct {
+		// ReplicaArtifact is the source of the script that launches the
+		// replica of a clone. The script seeds the replica from the source
+		// snapshot. Clones are rejected if this is not set.
+		ReplicaArtifact string `gcfg:"replica-artifact"`
+	}
+}
+
+// JivaTaskConfig provides the launch script & the resources of a jiva task
+type JivaTaskConfig struct {
+	// Artifact is the source of the script that launches the task
+	Artifact string
+
+	// CPU in MHz
+	CPU int
+
+	// Memory in MB
+	Memory int
+}
+
+// defaultJivaConfig provides the config that is used in the absence of a
+// config file
+func defaultJivaConfig() *JivaConfig {
+	jCfg := &JivaConfig{}
+
+	jCfg.Volume.Image = defaultJivaImage
+	jCfg.Volume.Size = defaultJivaVolSize
+	jCfg.Volume.MinSize = defaultJivaMinVolSize
+	jCfg.Volume.MaxSize = defaultJivaMaxVolSize
+	jCfg.Volume.Network = defaultJivaNetwork
+	jCfg.Volume.Replicas = defaultJivaReplicas
+	jCfg.Volume.StoreRoot = defaultJivaRepStoreRoot
+
+	jCfg.Controller = JivaTaskConfig{
+		Artifact: defaultJivaCtlArtifact,
+		CPU:      defaultJivaTaskCPU,
+		Memory:   defaultJivaTaskMemoryMB,
+	}
+
+	jCfg.Replica = JivaTaskConfig{
+		Artifact: defaultJivaRepArtifact,
+		CPU:      defaultJivaTaskCPU,
+		Memory:   defaultJivaTaskMemoryMB,
+	}
+
+	jCfg.Restart.Attempts = defaultJivaRestartAttempts
+	jCfg.Restart.Interval = defaultJivaRestartInterval
+	jCfg.Restart.Delay = defaultJivaRestartDelay
+	jCfg.Restart.Mode = jivaRestartModeDelay
+
+	return jCfg
+}
+
+// readJivaConfig reads an instance of JivaConfig from config reader. The
+// settings that are not present in the config retain their defaults.
+func readJivaConfig(config io.Reader) (*JivaConfig, error) {
+	jCfg := defaultJivaConfig()
+
+	if config != nil {
+		if err := gcfg.ReadInto(jCfg, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := jCfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return jCfg, nil
+}
+
+// validate verifies the settings of the config
+func (jCfg *JivaConfig) validate() error {
+	if !jivaImageRegex.MatchString(jCfg.Volume.Image) {
+		return fmt.Errorf("invalid image '%s'", jCfg.Volume.Image)
+	}
+
+	size, err := resource.ParseQuantity(jCfg.Volume.Size)
+	if err != nil || size.Sign() <= 0 {
+		return fmt.Errorf("invalid size '%s'", jCfg.Volume.Size)
+	}
+
+	minSize, err := resource.ParseQuantity(jCfg.Volume.MinSize)
+	if err != nil || minSize.Sign() <= 0 {
+		return fmt.Errorf("invalid min-size '%s'", jCfg.Volume.MinSize)
+	}
+
+	maxSize, err := resource.ParseQuantity(jCfg.Volume.MaxSize)
+	if err != nil || maxSize.Cmp(minSize) < 0 {
+		return fmt.Errorf("invalid max-size '%s'", jCfg.Volume.MaxSize)
+	}
+
+	if err := jCfg.checkSize(size); err != nil {
+		return fmt.Errorf("invalid size '%s': %v", jCfg.Volume.Size, err)
+	}
+
+	if jCfg.Volume.Network == "" {
+		return fmt.Errorf("network is required")
+	}
+
+	if jCfg.Volume.Replicas < 1 {
+		return fmt.Errorf("replicas need to be at least 1")
+	}
+
+	if jCfg.Volume.StoreRoot == "" {
+		return fmt.Errorf("store-root is required")
+	}
+
+	for name, task := range map[string]JivaTaskConfig{"controller": jCfg.Controller, "replica": jCfg.Replica} {
+		if task.Artifact == "" {
+			return fmt.Errorf("artifact of %s is required", name)
+		}
+
+		if task.CPU <= 0 || task.Memory <= 0 {
+			return fmt.Errorf("cpu & memory of %s need to be positive", name)
+		}
+	}
+
+	if jCfg.Restart.Attempts < 0 {
+		return fmt.Errorf("restart attempts can not be negative")
+	}
+
+	for _, d := range []string{jCfg.Restart.Interval, jCfg.Restart.Delay} {
+		if _, err := time.ParseDuration(d); err != nil {
+			return fmt.Errorf("invalid restart duration '%s'", d)
+		}
+	}
+
+	if jCfg.Restart.Mode != jivaRestartModeDelay && jCfg.Restart.Mode != jivaRestartModeFail {
+		return fmt.Errorf("invalid restart mode '%s'", jCfg.Restart.Mode)
+	}
+
+	return nil
+}
+
+// checkSize verifies the size of a volume against the limits of the config
+func (jCfg *JivaConfig) checkSize(size resource.Quantity) error {
+	minSize := resource.MustParse(jCfg.Volume.MinSize)
+	if size.Cmp(minSize) < 0 {
+		return fmt.Errorf("is smaller than the minimum size '%s'", jCfg.Volume.MinSize)
+	}
+
+	maxSize := resource.MustParse(jCfg.Volume.MaxSize)
+	if size.Cmp(maxSize) > 0 {
+		return fmt.Errorf("is larger than the maximum size '%s'", jCfg.Volume.MaxSize)
+	}
+
+	return nil
+}
+
+// labels provides the config as the labels of a claim
+func (jCfg *JivaConfig) labels() map[string]string {
+	labels := map[string]string{
+		jivaImageLabel:           jCfg.Volume.Image,
+		jivaNetworkLabel:         jCfg.Volume.Network,
+		jivaReplicasLabel:        strconv.Itoa(jCfg.Volume.Replicas),
+		jivaRepStoreRootLabel:    jCfg.Volume.StoreRoot,
+		jivaCtlArtifactLabel:     jCfg.Controller.Artifact,
+		jivaCtlCPULabel:          strconv.Itoa(jCfg.Controller.CPU),
+		jivaCtlMemoryLabel:       strconv.Itoa(jCfg.Controller.Memory),
+		jivaRepArtifactLabel:     jCfg.Replica.Artifact,
+		jivaRepCPULabel:          strconv.Itoa(jCfg.Replica.CPU),
+		jivaRepMemoryLabel:       strconv.Itoa(jCfg.Replica.Memory),
+		jivaRestartAttemptsLabel: strconv.Itoa(jCfg.Restart.Attempts),
+		jivaRestartIntervalLabel: jCfg.Restart.Interval,
+		jivaRestartDelayLabel:    jCfg.Restart.Delay,
+		jivaRestartModeLabel:     jCfg.Restart.Mode,
+	}
+
+	if jCfg.Clone.ReplicaArtifact != "" {
+		labels[jivaRepCloneArtifactLabel] = jCfg.Clone.ReplicaArtifact
+	}
+
+	return labels
+}
+
+// withDefaults provides a copy of the claim whose missing labels & storage
+// request are set from the config. The claim itself is not modified.
+func (jCfg *JivaConfig) withDefaults(pvc *v1.PersistentVolumeClaim) *v1.PersistentVolumeClaim {
+	c := *pvc
+
+	c.Labels = make(map[string]string, len(pvc.Labels))
+	for k, v := range jCfg.labels() {
+		c.Labels[k] = v
+	}
+	for k, v := range pvc.Labels {
+		if v != "" {
+			c.Labels[k] = v
+		}
+	}
+
+	c.Spec.Resources.Requests = make(v1.ResourceList, len(pvc.Spec.Resources.Requests)+1)
+	for k, v := range pvc.Spec.Resources.Requests {
+		c.Spec.Resources.Requests[k] = v
+	}
+	if _, found := c.Spec.Resources.Requests[v1.ResourceStorage]; !found {
+		c.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(jCfg.Volume.Size)
+	}
+
+	return &c
+}
@@ -0,0 +1,117 @@
+package jiva
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestReadJivaConfig(t *testing.T) {
+	config := `
+[volume]
+image = openebs/jiva:0.3-RC2
+size = 10Gi
+replicas = 3
+store-root = /var/openebs
+
+[replica]
+cpu = 1000
+memory = 512
+
+[restart]
+mode = fail
+
+[clone]
+replica-artifact = https://example.com/launch-jiva-clone-rep
+`
+
+	jCfg, err := readJivaConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if jCfg.Volume.Image != "openebs/jiva:0.3-RC2" || jCfg.Volume.Size != "10Gi" || jCfg.Volume.Replicas != 3 || jCfg.Volume.StoreRoot != "/var/openebs" {
+		t.Fatalf("bad volume config: %+v", jCfg.Volume)
+	}
+
+	if jCfg.Replica.CPU != 1000 || jCfg.Replica.Memory != 512 || jCfg.Restart.Mode != "fail" {
+		t.Fatalf("bad config: %+v", jCfg)
+	}
+
+	if jCfg.labels()[jivaRepCloneArtifactLabel] != "https://example.com/launch-jiva-clone-rep" {
+		t.Fatalf("bad clone replica artifact: %v", jCfg.labels())
+	}
+
+	// The settings that are not in the config retain their defaults
+	if jCfg.Volume.Network != defaultJivaNetwork || jCfg.Controller.CPU != defaultJivaTaskCPU || jCfg.Restart.Delay != defaultJivaRestartDelay {
+		t.Fatalf("expected defaults, got: %+v", jCfg)
+	}
+}
+
+func TestReadJivaConfig_Nil(t *testing.T) {
+	jCfg, err := readJivaConfig(nil)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if jCfg.Volume.Image != defaultJivaImage || jCfg.Volume.Size != defaultJivaVolSize {
+		t.Fatalf("expected defaults, got: %+v", jCfg.Volume)
+	}
+
+	// Clones can not be seeded by default
+	if _, found := jCfg.labels()[jivaRepCloneArtifactLabel]; found {
+		t.Fatalf("expected no clone replica artifact by default")
+	}
+}
+
+func TestReadJivaConfig_Invalid(t *testing.T) {
+	cases := []string{
+		"[volume]\nimage = openebs/jiva:\n",
+		"[volume]\nsize = -1Gi\n",
+		"[volume]\nreplicas = 0\n",
+		"[volume]\nmin-size = 0\n",
+		"[volume]\nmin-size = 10Gi\nmax-size = 5Gi\n",
+		"[volume]\nsize = 5Gi\nmin-size = 10Gi\n",
+		"[controller]\ncpu = 0\n",
+		"[restart]\ninterval = 5\n",
+		"[restart]\nmode = always\n",
+		"[bogus]\nkey = value\n",
+	}
+
+	for _, c := range cases {
+		if _, err := readJivaConfig(strings.NewReader(c)); err == nil {
+			t.Fatalf("expected error for config: %q", c)
+		}
+	}
+}
+
+func TestJivaConfig_WithDefaults(t *testing.T) {
+	jCfg := defaultJivaConfig()
+	jCfg.Volume.Size = "10Gi"
+
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Labels = map[string]string{
+		jivaImageLabel:  "openebs/jiva:custom",
+		jivaRepCPULabel: "",
+	}
+
+	c := jCfg.withDefaults(pvc)
+
+	// The labels of the claim override the config
+	if c.Labels[jivaImageLabel] != "openebs/jiva:custom" || c.Labels[jivaRepCPULabel] != "500" {
+		t.Fatalf("bad labels: %v", c.Labels)
+	}
+
+	size := c.Spec.Resources.Requests[v1.ResourceStorage]
+	if size.Cmp(resource.MustParse("10Gi")) != 0 {
+		t.Fatalf("bad size: %s", size.String())
+	}
+
+	// The claim itself is not modified
+	if len(pvc.Labels) != 2 || pvc.Spec.Resources.Requests != nil {
+		t.Fatalf("claim was modified: %+v", pvc)
+	}
+}
@@ -0,0 +1,105 @@
+// This file assigns the ip addresses of jiva volumes.
+//
+// NOTE:
+//    The ip addresses requested by a claim are reserved s.t. these do not
+// collide with the ip addresses of other volumes. The ones that are not
+// requested are allocated from the pools of the claim's datacenter.
+package jiva
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/golang/glog"
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// managesIPs returns true if the ip addresses of the claim's datacenter are
+// allocated by the ip address management
+func (j *jivaStor) managesIPs(pvc *v1.PersistentVolumeClaim) bool {
+	return j.ipam != nil && j.ipam.HasPool(pvc.Labels["datacenter"])
+}
+
+// assignIPs reserves the ip addresses requested by the claim & allocates the
+// ones that are not requested. The claim is set with the allocated ip
+// addresses. The ip addresses that are newly assigned to the claim's volume
+// are provided s.t. these can be released if the volume is not provisioned.
+func (j *jivaStor) assignIPs(pvc *v1.PersistentVolumeClaim) ([]string, error) {
+	if j.ipam == nil {
+		return nil, nil
+	}
+
+	dc := pvc.Labels["datacenter"]
+
+	var requested []string
+	if ip := pvc.Labels[jivaCtlIPLabel]; ip != "" {
+		requested = append(requested, ip)
+	}
+	if ips := pvc.Labels[jivaRepIPLabel]; ips != "" {
+		for _, ip := range strings.Split(ips, ",") {
+			requested = append(requested, strings.TrimSpace(ip))
+		}
+	}
+
+	assigned, err := j.ipam.Reserve(dc, pvc.Name, requested)
+	if err != nil {
+		return nil, err
+	}
+
+	if !j.ipam.HasPool(dc) {
+		return assigned, nil
+	}
+
+	allocate := func(n int) ([]string, error) {
+		ips, err := j.ipam.Allocate(dc, pvc.Name, n)
+		if err != nil {
+			if len(assigned) != 0 {
+				j.releaseIPs(pvc.Name, assigned)
+			}
+			return nil, err
+		}
+
+		assigned = append(assigned, ips...)
+		return ips, nil
+	}
+
+	if pvc.Labels[jivaCtlIPLabel] == "" {
+		ips, err := allocate(1)
+		if err != nil {
+			return nil, err
+		}
+		pvc.Labels[jivaCtlIPLabel] = ips[0]
+	}
+
+	if pvc.Labels[jivaRepIPLabel] == "" {
+		replicas, err := strconv.Atoi(pvc.Labels[jivaReplicasLabel])
+		if err != nil || replicas < 1 {
+			replicas = defaultJivaReplicas
+		}
+
+		ips, err := allocate(replicas)
+		if err != nil {
+			return nil, err
+		}
+		pvc.Labels[jivaRepIPLabel] = strings.Join(ips, ",")
+	}
+
+	if len(assigned) != 0 {
+		glog.V(2).Infof("Assigned ip addresses '%s' to volume '%s' request_id=%s", strings.Join(assigned, ","), pvc.Name, v1.RequestID(pvc.ObjectMeta))
+	}
+
+	return assigned, nil
+}
+
+// releaseIPs frees the provided ip addresses of the volume or all of them
+// if none are provided. A failure is logged since the volume's operation
+// has been done.
+func (j *jivaStor) releaseIPs(volName string, ips []string) {
+	if j.ipam == nil {
+		return
+	}
+
+	if err := j.ipam.Release(volName, ips...); err != nil {
+		glog.Errorf("Failed to release ip addresses of volume '%s': %v", volName, err)
+	}
+}
@@ -0,0 +1,112 @@
+package jiva
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/ipam"
+)
+
+// fakeProvisionOps remembers the provisioned claim & fails the provisioning
+// if err is set
+type fakeProvisionOps struct {
+	JivaOps
+	provisioned *v1.PersistentVolumeClaim
+	err         error
+}
+
+func (f *fakeProvisionOps) Provision(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	f.provisioned = pvc
+
+	pv := &v1.PersistentVolume{}
+	pv.Name = pvc.Name
+	return pv, nil
+}
+
+func (f *fakeProvisionOps) Delete(pv *v1.PersistentVolume) (*v1.PersistentVolume, error) {
+	return pv, f.err
+}
+
+func ipamClaim(name string) *v1.PersistentVolumeClaim {
+	pvc := validClaim()
+	pvc.Name = name
+	delete(pvc.Labels, "jivafeip")
+	delete(pvc.Labels, "jivabeip")
+	return pvc
+}
+
+func TestProvision_AssignIPs(t *testing.T) {
+	i, err := ipam.NewIPAM(map[string][]string{"dc1": {"172.28.128.0/29"}}, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	ops := &fakeProvisionOps{}
+	j := &jivaStor{jivaOps: ops, ipam: i}
+
+	pvc := ipamClaim("jivavol1")
+	pvc.Labels["jivareplicas"] = "2"
+
+	// The ip addresses are not required if these can be allocated
+	if err := j.Validate(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := j.Provision(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if ops.provisioned.Labels["jivafeip"] != "172.28.128.1" || ops.provisioned.Labels["jivabeip"] != "172.28.128.2,172.28.128.3" {
+		t.Fatalf("bad ips: %v", ops.provisioned.Labels)
+	}
+
+	// An explicitly requested ip address that is in use is rejected
+	pvc = validClaim()
+	pvc.Name = "jivavol2"
+	pvc.Labels["jivafeip"] = "172.28.128.4"
+	pvc.Labels["jivabeip"] = "172.28.128.2"
+
+	if _, err := j.Provision(pvc); !v1.IsConflict(err) {
+		t.Fatalf("expected conflict error, got: %v", err)
+	}
+
+	// The ip addresses of a volume that failed to provision are released
+	ops.err = v1.NewUnavailableError(fmt.Errorf("nomad is down"))
+	if _, err := j.Provision(ipamClaim("jivavol3")); !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	// The ip addresses of a deleted volume are released
+	ops.err = nil
+	pv := &v1.PersistentVolume{}
+	pv.Name = "jivavol1"
+	if _, err := j.Delete(pv); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, err := j.Provision(ipamClaim("jivavol4")); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if ops.provisioned.Labels["jivafeip"] != "172.28.128.1" || ops.provisioned.Labels["jivabeip"] != "172.28.128.2" {
+		t.Fatalf("bad ips: %v", ops.provisioned.Labels)
+	}
+}
+
+func TestValidate_IPsRequiredWithoutPool(t *testing.T) {
+	i, err := ipam.NewIPAM(map[string][]string{"dc2": {"172.28.128.0/29"}}, "")
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	j := &jivaStor{ipam: i}
+
+	if err := j.Validate(ipamClaim("jivavol1")); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/golang/glog"
 	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/ipam"
 	"github.com/openebs/mayaserver/lib/orchprovider"
 	"github.com/openebs/mayaserver/lib/volume"
 )
@@ -54,21 +55,36 @@ func (jAspect *JivaStorNomadAspect) GetOrchProvider() (orchprovider.Orchestrator
 	return jAspect.Nomad, nil
 }
 
+// GetIPAM provides no ip address management. The claims are expected to
+// request the ip addresses.
+func (jAspect *JivaStorNomadAspect) GetIPAM() (ipam.IPAM, bool) {
+	return nil, false
+}
+
 // jivaStor is the concrete implementation that implements
 // following interfaces:
 //
 //  1. volume.VolumeInterface interface
 //  2. volume.Provisioner interface
 //  3. volume.Deleter interface
+//  4. volume.Informer interface
+//  5. volume.Lister interface
+//  6. volume.Snapshotter interface
+//  7. volume.Resizer interface
+//  8. volume.Attacher interface
+//  9. volume.Detacher interface
+//  10. volume.Validator interface
 type jivaStor struct {
 	// jivaOps abstracts the operations related to this jivaStor
 	// instance
 	jivaOps JivaOps
 
-	// TODO
-	// jConfig provides a handle to tune the operations of
-	// this jivaStor instance
-	//jConfig *JivaConfig
+	// jConfig provides the defaults of the volumes provisioned by this
+	// jivaStor instance
+	jConfig *JivaConfig
+
+	// ipam assigns the ip addresses of the volumes, if set
+	ipam ipam.IPAM
 }
 
 // newJivaStor provides a new instance of jivaStor.
@@ -77,14 +93,10 @@ func newJivaStor(config io.Reader, aspect volume.VolumePluginAspect) (*jivaStor,
 
 	glog.Infof("Building new instance of jiva storage")
 
-	// TODO
-	//jCfg, err := readJivaConfig(config)
-	//if err != nil {
-	//	return nil, fmt.Errorf("unable to read Nomad orchestration provider config file: %v", err)
-	//}
-
-	// TODO
-	// validations of the populated config structure
+	jCfg, err := readJivaConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read jiva volume plugin's config: %v", err)
+	}
 
 	jivaOps, err := newJivaOrchestrator(aspect)
 	if err != nil {
@@ -95,7 +107,11 @@ func newJivaStor(config io.Reader, aspect volume.VolumePluginAspect) (*jivaStor,
 	jivaStor := &jivaStor{
 		//aspect: aspect,
 		jivaOps: jivaOps,
-		//jConfig:    jCfg,
+		jConfig: jCfg,
+	}
+
+	if i, ok := aspect.GetIPAM(); ok {
+		jivaStor.ipam = i
 	}
 
 	return jivaStor, nil
@@ -118,6 +134,15 @@ func (j *jivaStor) Informer() (volume.Informer, bool) {
 	return j, true
 }
 
+// jivaStor supports listing of jiva volumes
+// This is made possible by its jivaOps property
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Lister() (volume.Lister, bool) {
+	return j, true
+}
+
 // jivaStor supports provisioning
 // This is made possible by its jivaOps property
 //
@@ -136,16 +161,53 @@ func (j *jivaStor) Deleter() (volume.Deleter, bool) {
 	return j, true
 }
 
+// jivaStor supports resizing
+// This is made possible by its jivaOps property
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Resizer() (volume.Resizer, bool) {
+	return j, true
+}
+
+// jivaStor supports attaching a volume to an instance
+// This is made possible by its jivaOps property
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Attacher() (volume.Attacher, bool) {
+	return j, true
+}
+
+// jivaStor supports detaching a volume from an instance
+// This is made possible by its jivaOps property
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Detacher() (volume.Detacher, bool) {
+	return j, true
+}
+
 // jivaStor provides information on a volume via its jivaOps property.
 //
 // NOTE:
 //    This is a contract implementation of volume.Informer interface
-func (j *jivaStor) Info(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+func (j *jivaStor) Info(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
 	// TODO
 	// Validations of input i.e. claim
 
 	// Delegate to its provider
-	return j.jivaOps.Info(pvc)
+	return j.jivaOps.Info(pvc, opts)
+}
+
+// jivaStor lists the volumes via its jivaOps property.
+//
+// NOTE:
+//    This is a contract implementation of volume.Lister interface
+func (j *jivaStor) List(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
+
+	// Delegate to its provider
+	return j.jivaOps.List(opts)
 }
 
 // jivaStor provisions a volume via its jivaOps property.
@@ -154,11 +216,36 @@ func (j *jivaStor) Info(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, er
 //    This is a contract implementation of volume.Provisioner interface
 func (j *jivaStor) Provision(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
 
-	// TODO
-	// Validations of input i.e. claim
+	// NOTE:
+	//    The claim is expected to be verified via Validate by the caller
 
-	// Delegate to its provider
-	return j.jivaOps.Provision(pvc)
+	if isCloneClaim(pvc) {
+		if err := j.prepareClone(pvc); err != nil {
+			return nil, err
+		}
+	}
+
+	// A clone's size is known only after its source is resolved
+	claim := j.config().withDefaults(pvc)
+	if err := j.checkSize(claim); err != nil {
+		return nil, err
+	}
+
+	assigned, err := j.assignIPs(claim)
+	if err != nil {
+		return nil, err
+	}
+
+	// Delegate to its provider with the defaults of the config
+	pv, err := j.jivaOps.Provision(claim)
+	if err != nil && len(assigned) != 0 {
+		j.releaseIPs(claim.Name, assigned)
+	}
+
+	// NOTE:
+	//    The provenance of a clone is reported by the orchestrator since it
+	// is the orchestrator that seeds the clone from its source
+	return pv, err
 }
 
 // jivaStor removes a volume via its jivaOps property.
@@ -171,5 +258,81 @@ func (j *jivaStor) Delete(pv *v1.PersistentVolume) (*v1.PersistentVolume, error)
 	// Validations if any
 
 	// Delegate to its provider
-	return j.jivaOps.Delete(pv)
+	dPV, err := j.jivaOps.Delete(pv)
+	if err != nil {
+		return nil, err
+	}
+
+	// The ip addresses of the volume can be assigned to other volumes
+	j.releaseIPs(pv.Name, nil)
+
+	return dPV, nil
+}
+
+// jivaStor grows a volume via its jivaOps property.
+//
+// NOTE:
+//    This is a contract implementation of volume.Resizer interface
+func (j *jivaStor) Resize(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+
+	if err := j.checkSize(pvc); err != nil {
+		return nil, err
+	}
+
+	// Delegate to its provider
+	return j.jivaOps.Resize(pvc)
+}
+
+// jivaStor attaches a volume to an instance via its jivaOps property.
+//
+// NOTE:
+//    This is a contract implementation of volume.Attacher interface
+func (j *jivaStor) Attach(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+
+	// Delegate to its provider
+	return j.jivaOps.Attach(va)
+}
+
+// jivaStor lists the attachments of a volume via its jivaOps property.
+//
+// NOTE:
+//    This is a contract implementation of volume.Attacher interface
+func (j *jivaStor) Attachments(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+
+	// Delegate to its provider
+	return j.jivaOps.Attachments(pvc)
+}
+
+// jivaStor detaches a volume from an instance via its jivaOps property.
+//
+// NOTE:
+//    This is a contract implementation of volume.Detacher interface
+func (j *jivaStor) Detach(va *v1.VolumeAttachment) error {
+
+	// Delegate to its provider
+	return j.jivaOps.Detach(va)
+}
+
+// config provides the config of this jivaStor instance
+func (j *jivaStor) config() *JivaConfig {
+	if j.jConfig == nil {
+		return defaultJivaConfig()
+	}
+
+	return j.jConfig
+}
+
+// checkSize verifies the requested size of the claim against the limits of
+// the config. A claim without a requested size is left to the provider.
+func (j *jivaStor) checkSize(pvc *v1.PersistentVolumeClaim) error {
+	size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	if !found {
+		return nil
+	}
+
+	if err := j.config().checkSize(size); err != nil {
+		return v1.NewInvalidError(fmt.Errorf("Size '%s' of volume '%s' %v", size.String(), pvc.Name, err))
+	}
+
+	return nil
 }
@@ -10,16 +10,27 @@ package jiva
 import (
 	"fmt"
 
+	"github.com/golang/glog"
 	"github.com/openebs/mayaserver/lib/api/v1"
 	"github.com/openebs/mayaserver/lib/volume"
 )
 
 type JivaOps interface {
-	Info(*v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+	Info(*v1.PersistentVolumeClaim, *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error)
+
+	List(*v1.QueryOptions) (*v1.PersistentVolumeList, error)
 
 	Provision(*v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
 
 	Delete(*v1.PersistentVolume) (*v1.PersistentVolume, error)
+
+	Resize(*v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+
+	Attach(*v1.VolumeAttachment) (*v1.VolumeAttachment, error)
+
+	Detach(*v1.VolumeAttachment) error
+
+	Attachments(*v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error)
 }
 
 func newJivaOrchestrator(aspect volume.VolumePluginAspect) (JivaOps, error) {
@@ -40,7 +51,27 @@ type jivaOrchestrator struct {
 }
 
 // Info tries to fetch details of a jiva volume placed in an orchestrator
-func (jOrch *jivaOrchestrator) Info(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+func (jOrch *jivaOrchestrator) Info(pvc *v1.PersistentVolumeClaim, opts *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error) {
+
+	orchestrator, err := jOrch.aspect.GetOrchProvider()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	storageOrchestrator, ok := orchestrator.StoragePlacements()
+
+	if !ok {
+		return nil, nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
+	}
+
+	glog.V(4).Infof("Fetching jiva volume '%s' via '%s' request_id=%s", pvc.Name, orchestrator.Name(), v1.RequestID(pvc.ObjectMeta))
+
+	return storageOrchestrator.StorageInfoReq(pvc, opts)
+}
+
+// List tries to fetch details of all the jiva volumes placed in an
+// orchestrator
+func (jOrch *jivaOrchestrator) List(opts *v1.QueryOptions) (*v1.PersistentVolumeList, error) {
 
 	orchestrator, err := jOrch.aspect.GetOrchProvider()
 	if err != nil {
@@ -53,7 +84,9 @@ func (jOrch *jivaOrchestrator) Info(pvc *v1.PersistentVolumeClaim) (*v1.Persiste
 		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
 	}
 
-	return storageOrchestrator.StorageInfoReq(pvc)
+	glog.V(4).Infof("Listing jiva volumes via '%s' request_id=%s", orchestrator.Name(), requestIDFromOpts(opts))
+
+	return storageOrchestrator.StorageListReq(opts)
 }
 
 // Provision tries to creates a jiva volume via an orchestrator
@@ -69,7 +102,14 @@ func (jOrch *jivaOrchestrator) Provision(pvc *v1.PersistentVolumeClaim) (*v1.Per
 		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
 	}
 
-	return storageOrchestrator.StoragePlacementReq(pvc)
+	glog.V(2).Infof("Provisioning jiva volume '%s' via '%s' request_id=%s", pvc.Name, orchestrator.Name(), v1.RequestID(pvc.ObjectMeta))
+
+	pv, err := storageOrchestrator.StoragePlacementReq(pvc)
+	if err != nil {
+		glog.Errorf("Failed to provision jiva volume '%s': %v request_id=%s", pvc.Name, err, v1.RequestID(pvc.ObjectMeta))
+	}
+
+	return pv, err
 }
 
 // Delete tries to delete the jiva volume via an orchestrator
@@ -85,5 +125,98 @@ func (jOrch *jivaOrchestrator) Delete(pv *v1.PersistentVolume) (*v1.PersistentVo
 		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
 	}
 
-	return storageOrchestrator.StorageRemovalReq(pv)
+	glog.V(2).Infof("Deleting jiva volume '%s' via '%s' request_id=%s", pv.Name, orchestrator.Name(), v1.RequestID(pv.ObjectMeta))
+
+	dPV, err := storageOrchestrator.StorageRemovalReq(pv)
+	if err != nil {
+		glog.Errorf("Failed to delete jiva volume '%s': %v request_id=%s", pv.Name, err, v1.RequestID(pv.ObjectMeta))
+	}
+
+	return dPV, err
+}
+
+// Resize tries to grow a jiva volume via an orchestrator
+func (jOrch *jivaOrchestrator) Resize(pvc *v1.PersistentVolumeClaim) (*v1.PersistentVolume, error) {
+	orchestrator, err := jOrch.aspect.GetOrchProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	storageOrchestrator, ok := orchestrator.StoragePlacements()
+
+	if !ok {
+		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
+	}
+
+	glog.V(2).Infof("Resizing jiva volume '%s' via '%s' request_id=%s", pvc.Name, orchestrator.Name(), v1.RequestID(pvc.ObjectMeta))
+
+	pv, err := storageOrchestrator.StorageResizeReq(pvc)
+	if err != nil {
+		glog.Errorf("Failed to resize jiva volume '%s': %v request_id=%s", pvc.Name, err, v1.RequestID(pvc.ObjectMeta))
+	}
+
+	return pv, err
+}
+
+// Attach tries to record a jiva volume as attached to an instance via an
+// orchestrator
+func (jOrch *jivaOrchestrator) Attach(va *v1.VolumeAttachment) (*v1.VolumeAttachment, error) {
+	orchestrator, err := jOrch.aspect.GetOrchProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	storageOrchestrator, ok := orchestrator.StoragePlacements()
+
+	if !ok {
+		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
+	}
+
+	glog.V(2).Infof("Attaching jiva volume '%s' to instance '%s' via '%s' request_id=%s", va.Spec.VolumeName, va.Spec.InstanceID, orchestrator.Name(), v1.RequestID(va.ObjectMeta))
+
+	return storageOrchestrator.StorageAttachReq(va)
+}
+
+// Detach tries to remove the attachment of a jiva volume to an instance
+// via an orchestrator
+func (jOrch *jivaOrchestrator) Detach(va *v1.VolumeAttachment) error {
+	orchestrator, err := jOrch.aspect.GetOrchProvider()
+	if err != nil {
+		return err
+	}
+
+	storageOrchestrator, ok := orchestrator.StoragePlacements()
+
+	if !ok {
+		return fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
+	}
+
+	glog.V(2).Infof("Detaching jiva volume '%s' from instance '%s' via '%s' request_id=%s", va.Spec.VolumeName, va.Spec.InstanceID, orchestrator.Name(), v1.RequestID(va.ObjectMeta))
+
+	return storageOrchestrator.StorageDetachReq(va)
+}
+
+// Attachments tries to fetch the attachments of a jiva volume via an
+// orchestrator
+func (jOrch *jivaOrchestrator) Attachments(pvc *v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error) {
+	orchestrator, err := jOrch.aspect.GetOrchProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	storageOrchestrator, ok := orchestrator.StoragePlacements()
+
+	if !ok {
+		return nil, fmt.Errorf("Orchestrator '%s' does not provide storage services", orchestrator.Name())
+	}
+
+	return storageOrchestrator.StorageAttachmentsReq(pvc)
+}
+
+// requestIDFromOpts provides the request id set against the query options
+func requestIDFromOpts(opts *v1.QueryOptions) string {
+	if opts == nil {
+		return ""
+	}
+	return opts.RequestID
 }
@@ -0,0 +1,647 @@
+// This file handles the snapshots of jiva volumes.
+//
+// NOTE:
+//    A snapshot is taken by the jiva controller i.e. the frontend of a jiva
+// volume. The snapshots are then kept as disks by each jiva replica. Hence,
+// snapshots are taken via the controller's REST API & are listed or removed
+// via the replicas' REST API. A snapshot's data is coalesced into its child
+// disk by the replicas' sync agents before the snapshot's disk is removed.
+package jiva
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/volume"
+)
+
+const (
+	// JivaControllerPort is the port of jiva controller's REST API
+	JivaControllerPort = "9501"
+
+	// jivaVolumeID is the id of the volume served by a jiva controller.
+	// A jiva controller serves a single volume.
+	jivaVolumeID = "1"
+
+	// jivaReplicaID is the id of the replica served by a jiva replica
+	jivaReplicaID = "1"
+
+	// jivaSnapDiskPrefix & jivaSnapDiskSuffix make up the name of the disk
+	// that holds a snapshot in a jiva replica
+	jivaSnapDiskPrefix = "volume-snap-"
+	jivaSnapDiskSuffix = ".img"
+
+	// jivaReplicaModeRW is the mode of a healthy jiva replica
+	jivaReplicaModeRW = "RW"
+
+	// jivaAPITimeout is the timeout of a call to the jiva REST API
+	jivaAPITimeout = 30 * time.Second
+
+	// jivaDiskOpCoalesce, jivaDiskOpReplace & jivaDiskOpRemove are the
+	// operations a jiva replica asks for to remove a disk
+	jivaDiskOpCoalesce = "coalesce"
+	jivaDiskOpReplace  = "replace"
+	jivaDiskOpRemove   = "remove"
+
+	// jivaSyncAgentPortOffset is the offset of the port of a replica's sync
+	// agent from the replica's port
+	jivaSyncAgentPortOffset = 2
+
+	// jivaProcessRunning is the exit code of a sync agent process that is
+	// still running
+	jivaProcessRunning = -1
+
+	// jivaSnapshotRemovalTimeout bounds the removal of a snapshot from all
+	// the replicas, including the coalesce of its disk
+	jivaSnapshotRemovalTimeout = 10 * time.Minute
+
+	// jivaCoalescePollInterval is the interval of polling a coalesce
+	jivaCoalescePollInterval = time.Second
+)
+
+// jivaStor supports snapshots
+// This is made possible by the REST API of jiva controller
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Snapshotter() (volume.Snapshotter, bool) {
+	return j, true
+}
+
+// CreateSnapshot takes a snapshot of a jiva volume via its controller.
+//
+// NOTE:
+//    This is a contract implementation of volume.Snapshotter interface
+func (j *jivaStor) CreateSnapshot(snap *v1.VolumeSnapshot) (*v1.VolumeSnapshot, error) {
+	if err := validateSnapshot(snap); err != nil {
+		return nil, err
+	}
+
+	ctrl, err := j.controller(snapshotToClaim(snap))
+	if err != nil {
+		return nil, err
+	}
+
+	disks, err := ctrl.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, found := disks[snap.Name]; found {
+		return nil, &v1.VolumeError{
+			Reason:  v1.ReasonAlreadyExists,
+			Message: fmt.Sprintf("Snapshot '%s' of volume '%s' already exists", snap.Name, snap.Spec.VolumeName),
+		}
+	}
+
+	glog.V(2).Infof("Taking snapshot '%s' of jiva volume '%s' request_id=%s", snap.Name, snap.Spec.VolumeName, v1.RequestID(snap.ObjectMeta))
+
+	if err := ctrl.Snapshot(snap.Name); err != nil {
+		glog.Errorf("Failed to take snapshot '%s' of jiva volume '%s': %v request_id=%s", snap.Name, snap.Spec.VolumeName, err, v1.RequestID(snap.ObjectMeta))
+		return nil, err
+	}
+
+	created := &v1.VolumeSnapshot{}
+	created.Name = snap.Name
+	created.Spec.VolumeName = snap.Spec.VolumeName
+
+	return created, nil
+}
+
+// DeleteSnapshot removes a snapshot of a jiva volume from all its replicas.
+// The removal is bounded by jivaSnapshotRemovalTimeout.
+//
+// NOTE:
+//    This is a contract implementation of volume.Snapshotter interface
+func (j *jivaStor) DeleteSnapshot(ctx context.Context, snap *v1.VolumeSnapshot) error {
+	if err := validateSnapshot(snap); err != nil {
+		return err
+	}
+
+	ctrl, err := j.controller(snapshotToClaim(snap))
+	if err != nil {
+		return err
+	}
+
+	glog.V(2).Infof("Removing snapshot '%s' of jiva volume '%s' request_id=%s", snap.Name, snap.Spec.VolumeName, v1.RequestID(snap.ObjectMeta))
+
+	ctx, cancel := context.WithTimeout(ctx, jivaSnapshotRemovalTimeout)
+	defer cancel()
+
+	err = ctrl.RemoveSnapshot(ctx, snap.Name)
+	if err == errJivaSnapshotNotFound {
+		return &v1.VolumeError{
+			Reason:  v1.ReasonNotFound,
+			Message: fmt.Sprintf("Snapshot '%s' of volume '%s' not found", snap.Name, snap.Spec.VolumeName),
+		}
+	}
+	if err != nil {
+		glog.Errorf("Failed to remove snapshot '%s' of jiva volume '%s': %v request_id=%s", snap.Name, snap.Spec.VolumeName, err, v1.RequestID(snap.ObjectMeta))
+		return err
+	}
+
+	return nil
+}
+
+// ListSnapshots provides the snapshots of a jiva volume.
+//
+// NOTE:
+//    This is a contract implementation of volume.Snapshotter interface
+func (j *jivaStor) ListSnapshots(pvc *v1.PersistentVolumeClaim) (*v1.VolumeSnapshotList, error) {
+	if pvc == nil || pvc.Name == "" {
+		return nil, v1.NewInvalidError(fmt.Errorf("Volume name hasn't been provided"))
+	}
+
+	ctrl, err := j.controller(pvc)
+	if err != nil {
+		return nil, err
+	}
+
+	disks, err := ctrl.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(disks))
+	for name := range disks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := &v1.VolumeSnapshotList{
+		Items: make([]v1.VolumeSnapshot, 0, len(names)),
+	}
+	for _, name := range names {
+		snap := v1.VolumeSnapshot{}
+		snap.Name = name
+		snap.Spec.VolumeName = pvc.Name
+		snap.Status.Created = disks[name].Created
+		snap.Status.Size = disks[name].Size
+
+		list.Items = append(list.Items, snap)
+	}
+
+	return list, nil
+}
+
+// controller provides the jiva controller of the claimed volume. The
+// controller is reached at the target portal of the running volume.
+func (j *jivaStor) controller(pvc *v1.PersistentVolumeClaim) (*jivaController, error) {
+	pv, _, err := j.jivaOps.Info(pvc, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	host, err := controllerHost(pv)
+	if err != nil {
+		return nil, err
+	}
+
+	return newJivaController("http://" + net.JoinHostPort(host, JivaControllerPort)), nil
+}
+
+// controllerHost provides the host of the volume's jiva controller from the
+// target portal of the running volume
+func controllerHost(pv *v1.PersistentVolume) (string, error) {
+	host, _, err := net.SplitHostPort(pv.Annotations["targetportal"])
+	if err != nil || host == "" {
+		return "", v1.NewUnavailableError(fmt.Errorf("Jiva controller of volume '%s' is not running", pv.Name))
+	}
+
+	return host, nil
+}
+
+// validateSnapshot verifies if the snapshot names its volume. A snapshot
+// name ends up as a disk file of jiva replicas & hence can not have path
+// separators.
+func validateSnapshot(snap *v1.VolumeSnapshot) error {
+	if snap == nil || snap.Name == "" {
+		return v1.NewInvalidError(fmt.Errorf("Snapshot name hasn't been provided"))
+	}
+
+	if strings.ContainsAny(snap.Name, "/\\") {
+		return v1.NewInvalidError(fmt.Errorf("Invalid snapshot name '%s'", snap.Name))
+	}
+
+	if snap.Spec.VolumeName == "" {
+		return v1.NewInvalidError(fmt.Errorf("Volume name of snapshot '%s' hasn't been provided", snap.Name))
+	}
+
+	return nil
+}
+
+// snapshotToClaim provides the claim of the snapshot's volume. The claim
+// carries the snapshot's annotations e.g. the request id.
+func snapshotToClaim(snap *v1.VolumeSnapshot) *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = snap.Spec.VolumeName
+	pvc.Annotations = snap.Annotations
+
+	return pvc
+}
+
+// jivaController talks to the REST API of a jiva controller & its
+// replicas
+type jivaController struct {
+	addr   string
+	client *http.Client
+
+	// syncAgentURL provides the url of a replica's sync agent from the
+	// replica's address
+	syncAgentURL func(address string) string
+}
+
+// newJivaController provides a new instance of jivaController that is
+// reachable at the provided address e.g. http://10.0.0.1:9501
+func newJivaController(addr string) *jivaController {
+	return &jivaController{
+		addr:         addr,
+		client:       &http.Client{Timeout: jivaAPITimeout},
+		syncAgentURL: syncAgentURL,
+	}
+}
+
+// jivaReplica is a replica as reported by a jiva controller
+type jivaReplica struct {
+	Address string `json:"address"`
+	Mode    string `json:"mode"`
+}
+
+// jivaDisk is a disk of a jiva replica
+type jivaDisk struct {
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Size    string `json:"size"`
+}
+
+// Snapshot takes a snapshot of the controller's volume
+func (c *jivaController) Snapshot(name string) error {
+	input := map[string]string{
+		"name": name,
+	}
+
+	return c.do("POST", c.addr+"/v1/volumes/"+jivaVolumeID+"?action=snapshot", input, nil)
+}
+
+// Replicas provides the replicas that are known to the controller
+func (c *jivaController) Replicas() ([]jivaReplica, error) {
+	var out struct {
+		Data []jivaReplica `json:"data"`
+	}
+
+	if err := c.do("GET", c.addr+"/v1/replicas", nil, &out); err != nil {
+		return nil, err
+	}
+
+	return out.Data, nil
+}
+
+// Snapshots provides the snapshots of the volume mapped by their names.
+// The snapshots are read from a healthy replica.
+func (c *jivaController) Snapshots() (map[string]jivaDisk, error) {
+	replicas, err := c.healthyReplicas()
+	if err != nil {
+		return nil, err
+	}
+
+	disks, err := c.replicaDisks(replicas[0])
+	if err != nil {
+		return nil, err
+	}
+
+	snaps := make(map[string]jivaDisk)
+	for diskName, disk := range disks {
+		if !strings.HasPrefix(diskName, jivaSnapDiskPrefix) || !strings.HasSuffix(diskName, jivaSnapDiskSuffix) {
+			continue
+		}
+
+		name := strings.TrimSuffix(strings.TrimPrefix(diskName, jivaSnapDiskPrefix), jivaSnapDiskSuffix)
+		snaps[name] = disk
+	}
+
+	return snaps, nil
+}
+
+// replicaDisks provides the disks of the replica mapped by their names
+func (c *jivaController) replicaDisks(replica jivaReplica) (map[string]jivaDisk, error) {
+	var info struct {
+		Disks map[string]jivaDisk `json:"disks"`
+	}
+
+	if err := c.do("GET", replicaURL(replica.Address), nil, &info); err != nil {
+		return nil, err
+	}
+
+	return info.Disks, nil
+}
+
+// errJivaSnapshotNotFound is returned if no healthy replica has the
+// snapshot to be removed
+var errJivaSnapshotNotFound = fmt.Errorf("jiva snapshot not found")
+
+// RemoveSnapshot removes the snapshot from every healthy replica. This
+// follows the snapshot removal of jiva i.e. each replica prepares the
+// removal & provides the operations that remove the snapshot's disk without
+// losing its data. The data is coalesced into the child disk before the
+// snapshot's disk is removed.
+//
+// NOTE:
+//    The removal is prepared at all the replicas before any operation is
+// run. An Unsupported error is returned if a replica can not prepare the
+// removal or asks for an unknown operation.
+//
+// NOTE:
+//    A replica that fails does not stop the removal at the other replicas.
+// The error then carries the status of each replica. Only the replicas that
+// still have the snapshot's disk are changed s.t. a removal that failed
+// midway can be retried.
+func (c *jivaController) RemoveSnapshot(ctx context.Context, name string) error {
+	replicas, err := c.healthyReplicas()
+	if err != nil {
+		return err
+	}
+
+	disk := jivaSnapDiskPrefix + name + jivaSnapDiskSuffix
+
+	var holders []jivaReplica
+	for _, replica := range replicas {
+		disks, err := c.replicaDisks(replica)
+		if err != nil {
+			return err
+		}
+		if _, found := disks[disk]; found {
+			holders = append(holders, replica)
+		}
+	}
+
+	if len(holders) == 0 {
+		return errJivaSnapshotNotFound
+	}
+
+	ops := make([][]jivaDiskOp, 0, len(holders))
+	for _, replica := range holders {
+		replicaOps, err := c.prepareRemoveDisk(replica, disk)
+		if err != nil {
+			return err
+		}
+		ops = append(ops, replicaOps)
+	}
+
+	statuses := make([]v1.ReplicaStatus, len(holders))
+	var failures []string
+
+	for i, replica := range holders {
+		statuses[i].Address = replica.Address
+
+		if err := ctx.Err(); err != nil {
+			statuses[i].Status = v1.ReplicaNotAttempted
+			statuses[i].Message = err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", replica.Address, err))
+			continue
+		}
+
+		if err := c.runDiskOps(ctx, replica, ops[i]); err != nil {
+			statuses[i].Status = v1.ReplicaFailed
+			statuses[i].Message = err.Error()
+			failures = append(failures, fmt.Sprintf("%s: %v", replica.Address, err))
+			continue
+		}
+
+		statuses[i].Status = v1.ReplicaDone
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return &v1.VolumeError{
+		Reason:   v1.ReasonUnavailable,
+		Message:  fmt.Sprintf("Snapshot '%s' was removed from %d of %d jiva replicas: %s", name, len(holders)-len(failures), len(holders), strings.Join(failures, "; ")),
+		Replicas: statuses,
+	}
+}
+
+// jivaDiskOp is an operation on the disks of a jiva replica that is
+// needed to remove a disk
+type jivaDiskOp struct {
+	Action string `json:"action"`
+	Source string `json:"source"`
+	Target string `json:"target"`
+}
+
+// prepareRemoveDisk prepares the replica to remove the disk & provides the
+// operations that remove it
+func (c *jivaController) prepareRemoveDisk(replica jivaReplica, disk string) ([]jivaDiskOp, error) {
+	input := map[string]string{
+		"name": disk,
+	}
+
+	var out struct {
+		Operations []jivaDiskOp `json:"operations"`
+	}
+
+	status, err := c.doStatus("POST", replicaURL(replica.Address)+"?action=prepareremovedisk", input, &out)
+	if status == http.StatusNotFound || status == http.StatusNotImplemented {
+		return nil, v1.NewUnsupportedError(fmt.Errorf("Jiva replica '%s' does not support removal of snapshots", replica.Address))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range out.Operations {
+		switch op.Action {
+		case jivaDiskOpCoalesce, jivaDiskOpReplace, jivaDiskOpRemove:
+		default:
+			return nil, v1.NewUnsupportedError(fmt.Errorf("Jiva replica '%s' needs unsupported operation '%s' to remove disk '%s'", replica.Address, op.Action, disk))
+		}
+	}
+
+	return out.Operations, nil
+}
+
+// runDiskOps runs the disk operations at the replica in their order
+func (c *jivaController) runDiskOps(ctx context.Context, replica jivaReplica, ops []jivaDiskOp) error {
+	for _, op := range ops {
+		if err := c.runDiskOp(ctx, replica, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runDiskOp runs a disk operation at the replica. A coalesce is run by the
+// replica's sync agent.
+func (c *jivaController) runDiskOp(ctx context.Context, replica jivaReplica, op jivaDiskOp) error {
+	switch op.Action {
+	case jivaDiskOpCoalesce:
+		return c.coalesce(ctx, replica, op.Source, op.Target)
+	case jivaDiskOpReplace:
+		input := map[string]string{
+			"target": op.Target,
+			"source": op.Source,
+		}
+		_, err := c.doStatusContext(ctx, "POST", replicaURL(replica.Address)+"?action=replacedisk", input, nil)
+		return err
+	default:
+		input := map[string]string{
+			"name": op.Source,
+		}
+		_, err := c.doStatusContext(ctx, "POST", replicaURL(replica.Address)+"?action=removedisk", input, nil)
+		return err
+	}
+}
+
+// jivaProcess is a process of the sync agent of a jiva replica
+type jivaProcess struct {
+	ID          string `json:"id,omitempty"`
+	ProcessType string `json:"processType"`
+	SrcFile     string `json:"srcFile"`
+	DestFile    string `json:"destFile"`
+	ExitCode    int    `json:"exitCode"`
+	Output      string `json:"output,omitempty"`
+}
+
+// coalesce folds the source disk into the target disk via the replica's
+// sync agent & waits for the fold to complete.
+//
+// NOTE:
+//    The wait is abandoned once the context is done. The fold itself is
+// not stopped by the sync agent in that case.
+func (c *jivaController) coalesce(ctx context.Context, replica jivaReplica, source, target string) error {
+	url := c.syncAgentURL(replica.Address) + "/v1/processes"
+
+	proc := &jivaProcess{}
+	input := &jivaProcess{
+		ProcessType: "fold",
+		SrcFile:     source,
+		DestFile:    target,
+	}
+
+	if _, err := c.doStatusContext(ctx, "POST", url, input, proc); err != nil {
+		return err
+	}
+
+	for proc.ExitCode == jivaProcessRunning {
+		select {
+		case <-ctx.Done():
+			return v1.NewUnavailableError(fmt.Errorf("Abandoned coalescing disk '%s' into '%s' at jiva replica '%s': %v", source, target, replica.Address, ctx.Err()))
+		case <-time.After(jivaCoalescePollInterval):
+		}
+
+		if _, err := c.doStatusContext(ctx, "GET", url+"/"+proc.ID, nil, proc); err != nil {
+			return err
+		}
+	}
+
+	if proc.ExitCode != 0 {
+		return v1.NewUnavailableError(fmt.Errorf("Failed to coalesce disk '%s' into '%s' at jiva replica '%s': %s", source, target, replica.Address, strings.TrimSpace(proc.Output)))
+	}
+
+	return nil
+}
+
+// healthyReplicas provides the replicas that are in RW mode
+func (c *jivaController) healthyReplicas() ([]jivaReplica, error) {
+	replicas, err := c.Replicas()
+	if err != nil {
+		return nil, err
+	}
+
+	var healthy []jivaReplica
+	for _, replica := range replicas {
+		if replica.Mode == jivaReplicaModeRW {
+			healthy = append(healthy, replica)
+		}
+	}
+
+	if len(healthy) == 0 {
+		return nil, v1.NewUnavailableError(fmt.Errorf("No healthy jiva replica found at '%s'", c.addr))
+	}
+
+	return healthy, nil
+}
+
+// do invokes the jiva REST API. The input, if any, is sent as JSON & the
+// JSON response is decoded to out, if provided.
+func (c *jivaController) do(method, url string, input interface{}, out interface{}) error {
+	_, err := c.doStatus(method, url, input, out)
+	return err
+}
+
+// doStatus invokes the jiva REST API similar to do & provides the http
+// status code of the response. The status code is 0 if jiva could not be
+// reached.
+func (c *jivaController) doStatus(method, url string, input interface{}, out interface{}) (int, error) {
+	return c.doStatusContext(context.Background(), method, url, input, out)
+}
+
+// doStatusContext is doStatus that is abandoned once the context is done
+func (c *jivaController) doStatusContext(ctx context.Context, method, url string, input interface{}, out interface{}) (int, error) {
+	var body bytes.Buffer
+	if input != nil {
+		if err := json.NewEncoder(&body).Encode(input); err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, url, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0, v1.NewUnavailableError(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		err := fmt.Errorf("Jiva %s %s failed with %d: %s", method, url, resp.StatusCode, strings.TrimSpace(string(msg)))
+		if resp.StatusCode == 400 {
+			return resp.StatusCode, v1.NewInvalidError(err)
+		}
+		return resp.StatusCode, v1.NewUnavailableError(err)
+	}
+
+	if out == nil {
+		return resp.StatusCode, nil
+	}
+
+	return resp.StatusCode, json.NewDecoder(resp.Body).Decode(out)
+}
+
+// replicaURL provides the REST url of a jiva replica from its address
+// e.g. tcp://10.0.0.2:9502
+func replicaURL(address string) string {
+	return "http://" + strings.TrimPrefix(address, "tcp://") + "/v1/replicas/" + jivaReplicaID
+}
+
+// syncAgentURL provides the REST url of the sync agent of a jiva replica
+// from the replica's address. The sync agent listens two ports above the
+// replica e.g. 9504 for a replica at tcp://10.0.0.2:9502.
+func syncAgentURL(address string) string {
+	host, port, err := net.SplitHostPort(strings.TrimPrefix(address, "tcp://"))
+	if err != nil {
+		return "http://" + strings.TrimPrefix(address, "tcp://")
+	}
+
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "http://" + net.JoinHostPort(host, port)
+	}
+
+	return "http://" + net.JoinHostPort(host, strconv.Itoa(p+jivaSyncAgentPortOffset))
+}
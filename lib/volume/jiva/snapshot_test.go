@@ -0,0 +1,343 @@
+package jiva
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+)
+
+// fakeJiva serves a jiva controller, a jiva replica & its sync agent at the
+// same address
+type fakeJiva struct {
+	mu    sync.Mutex
+	disks map[string]jivaDisk
+	srv   *httptest.Server
+
+	// chain has the snapshot disks from the oldest to the latest
+	chain []string
+
+	// folds has the coalesced disks as source>target
+	folds []string
+
+	// prepareOps, if set, is sent instead of the operations of the chain
+	prepareOps []jivaDiskOp
+
+	// noPrepare makes the replica unaware of prepareremovedisk
+	noPrepare bool
+
+	// failRemove makes the replica fail removedisk
+	failRemove bool
+
+	// peers are the other healthy replicas of the fake's controller
+	peers []*fakeJiva
+}
+
+func newFakeJiva() *fakeJiva {
+	f := &fakeJiva{disks: map[string]jivaDisk{}}
+	f.srv = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeJiva) serve(w http.ResponseWriter, req *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var raw map[string]interface{}
+	if req.Method == "POST" {
+		json.NewDecoder(req.Body).Decode(&raw)
+	}
+
+	input := map[string]string{}
+	for k, v := range raw {
+		input[k] = fmt.Sprint(v)
+	}
+
+	switch {
+	case req.URL.Path == "/v1/volumes/1" && req.URL.Query().Get("action") == "snapshot":
+		name := jivaSnapDiskPrefix + input["name"] + jivaSnapDiskSuffix
+		f.addDisk(name)
+		for _, peer := range f.peers {
+			peer.mu.Lock()
+			peer.addDisk(name)
+			peer.mu.Unlock()
+		}
+
+	case req.URL.Path == "/v1/replicas" && req.Method == "GET":
+		replicas := []jivaReplica{jivaReplica{Address: f.address(), Mode: "RW"}}
+		for _, peer := range f.peers {
+			replicas = append(replicas, jivaReplica{Address: peer.address(), Mode: "RW"})
+		}
+		replicas = append(replicas, jivaReplica{Address: "tcp://127.0.0.1:1", Mode: "ERR"})
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": replicas})
+
+	case req.URL.Path == "/v1/replicas/1" && req.Method == "GET":
+		disks := map[string]jivaDisk{
+			"volume-head-001.img": jivaDisk{Name: "volume-head-001.img"},
+		}
+		for name, disk := range f.disks {
+			disks[name] = disk
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"disks": disks})
+
+	case req.URL.Path == "/v1/replicas/1" && req.URL.Query().Get("action") == "prepareremovedisk" && !f.noPrepare:
+		ops := f.prepareOps
+		if ops == nil {
+			ops = f.removeOps(input["name"])
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"operations": ops})
+
+	case req.URL.Path == "/v1/replicas/1" && req.URL.Query().Get("action") == "removedisk" && f.failRemove:
+		w.WriteHeader(500)
+		fmt.Fprint(w, "disk is busy")
+
+	case req.URL.Path == "/v1/replicas/1" && req.URL.Query().Get("action") == "removedisk":
+		delete(f.disks, input["name"])
+		for i, name := range f.chain {
+			if name == input["name"] {
+				f.chain = append(f.chain[:i], f.chain[i+1:]...)
+				break
+			}
+		}
+
+	case req.URL.Path == "/v1/processes" && req.Method == "POST":
+		f.folds = append(f.folds, input["srcFile"]+">"+input["destFile"])
+		json.NewEncoder(w).Encode(jivaProcess{ID: "1", ProcessType: input["processType"], ExitCode: -1})
+
+	case req.URL.Path == "/v1/processes/1" && req.Method == "GET":
+		json.NewEncoder(w).Encode(jivaProcess{ID: "1", ProcessType: "fold", ExitCode: 0})
+
+	default:
+		w.WriteHeader(404)
+		fmt.Fprintf(w, "unexpected %s %s", req.Method, req.URL)
+	}
+}
+
+// addDisk adds a snapshot disk as the latest of the chain
+func (f *fakeJiva) addDisk(name string) {
+	f.disks[name] = jivaDisk{Name: name, Created: "2017-05-01T10:00:00Z", Size: "4096"}
+	f.chain = append(f.chain, name)
+}
+
+// address provides the fake's address as reported by a jiva controller
+func (f *fakeJiva) address() string {
+	return "tcp://" + strings.TrimPrefix(f.srv.URL, "http://")
+}
+
+// removeOps provides the operations that remove the disk. A snapshot that
+// has a snapshot as its child is coalesced into the child first.
+func (f *fakeJiva) removeOps(disk string) []jivaDiskOp {
+	ops := []jivaDiskOp{}
+	for i, name := range f.chain {
+		if name != disk {
+			continue
+		}
+
+		if i+1 < len(f.chain) {
+			ops = append(ops, jivaDiskOp{Action: "coalesce", Source: disk, Target: f.chain[i+1]})
+		}
+		ops = append(ops, jivaDiskOp{Action: "remove", Source: disk})
+	}
+
+	return ops
+}
+
+// newFakeJivaController provides a controller of the fake whose replicas'
+// sync agents are served by the replicas themselves
+func newFakeJivaController(f *fakeJiva) *jivaController {
+	ctrl := newJivaController(f.srv.URL)
+	ctrl.syncAgentURL = func(address string) string {
+		return "http://" + strings.TrimPrefix(address, "tcp://")
+	}
+	return ctrl
+}
+
+func TestJivaController_Snapshots(t *testing.T) {
+	f := newFakeJiva()
+	defer f.srv.Close()
+
+	ctrl := newFakeJivaController(f)
+
+	if err := ctrl.Snapshot("snap1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	snaps, err := ctrl.Snapshots()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(snaps) != 1 || snaps["snap1"].Size != "4096" {
+		t.Fatalf("bad snapshots: %#v", snaps)
+	}
+
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	snaps, err = ctrl.Snapshots()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(snaps) != 0 || len(f.folds) != 0 {
+		t.Fatalf("expected no snapshots & no coalesce, got: %#v, folds: %v", snaps, f.folds)
+	}
+}
+
+func TestJivaController_RemoveSnapshotCoalesce(t *testing.T) {
+	f := newFakeJiva()
+	defer f.srv.Close()
+
+	ctrl := newFakeJivaController(f)
+	ctrl.Snapshot("snap1")
+	ctrl.Snapshot("snap2")
+
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(f.folds) != 1 || f.folds[0] != "volume-snap-snap1.img>volume-snap-snap2.img" {
+		t.Fatalf("expected snap1 to be coalesced into snap2, got: %v", f.folds)
+	}
+
+	snaps, err := ctrl.Snapshots()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if _, found := snaps["snap2"]; len(snaps) != 1 || !found {
+		t.Fatalf("expected only snap2, got: %#v", snaps)
+	}
+}
+
+func TestJivaController_RemoveSnapshotUnsupported(t *testing.T) {
+	f := newFakeJiva()
+	defer f.srv.Close()
+
+	ctrl := newFakeJivaController(f)
+	ctrl.Snapshot("snap1")
+	ctrl.Snapshot("snap2")
+
+	f.noPrepare = true
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); !v1.IsUnsupported(err) {
+		t.Fatalf("expected unsupported error, got: %v", err)
+	}
+
+	f.noPrepare = false
+	f.prepareOps = []jivaDiskOp{jivaDiskOp{Action: "rebuild", Source: "volume-snap-snap1.img"}}
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); !v1.IsUnsupported(err) {
+		t.Fatalf("expected unsupported error for an unknown operation, got: %v", err)
+	}
+
+	if len(f.disks) != 2 || len(f.folds) != 0 {
+		t.Fatalf("expected the snapshots to be untouched, got: %v, folds: %v", f.disks, f.folds)
+	}
+}
+
+func TestJivaController_RemoveSnapshotPartialFailure(t *testing.T) {
+	f := newFakeJiva()
+	defer f.srv.Close()
+
+	peer := newFakeJiva()
+	defer peer.srv.Close()
+	f.peers = []*fakeJiva{peer}
+
+	ctrl := newFakeJivaController(f)
+	ctrl.Snapshot("snap1")
+	ctrl.Snapshot("snap2")
+
+	peer.failRemove = true
+	err := ctrl.RemoveSnapshot(context.Background(), "snap1")
+	if !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	replicas := err.(*v1.VolumeError).Replicas
+	if len(replicas) != 2 ||
+		replicas[0].Address != f.address() || replicas[0].Status != v1.ReplicaDone ||
+		replicas[1].Address != peer.address() || replicas[1].Status != v1.ReplicaFailed || replicas[1].Message == "" {
+		t.Fatalf("bad replica statuses: %#v", replicas)
+	}
+
+	if _, found := f.disks["volume-snap-snap1.img"]; found {
+		t.Fatalf("expected snap1 to be removed from the first replica, got: %v", f.disks)
+	}
+
+	// A retry changes only the replica that still has the snapshot
+	peer.failRemove = false
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	if len(f.folds) != 1 || len(peer.folds) != 2 {
+		t.Fatalf("expected a single coalesce at the first replica, got: %v, %v", f.folds, peer.folds)
+	}
+
+	if _, found := peer.disks["volume-snap-snap1.img"]; found {
+		t.Fatalf("expected snap1 to be removed from the second replica, got: %v", peer.disks)
+	}
+
+	if err := ctrl.RemoveSnapshot(context.Background(), "snap1"); err != errJivaSnapshotNotFound {
+		t.Fatalf("expected snapshot not found, got: %v", err)
+	}
+}
+
+func TestJivaController_RemoveSnapshotCanceled(t *testing.T) {
+	f := newFakeJiva()
+	defer f.srv.Close()
+
+	ctrl := newFakeJivaController(f)
+	ctrl.Snapshot("snap1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ctrl.RemoveSnapshot(ctx, "snap1")
+	if !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+
+	replicas := err.(*v1.VolumeError).Replicas
+	if len(replicas) != 1 || replicas[0].Status != v1.ReplicaNotAttempted {
+		t.Fatalf("bad replica statuses: %#v", replicas)
+	}
+
+	if len(f.disks) != 1 {
+		t.Fatalf("expected snap1 to be untouched, got: %v", f.disks)
+	}
+}
+
+func TestJivaController_Unreachable(t *testing.T) {
+	f := newFakeJiva()
+	f.srv.Close()
+
+	_, err := newJivaController(f.srv.URL).Snapshots()
+	if !v1.IsUnavailable(err) {
+		t.Fatalf("expected unavailable error, got: %v", err)
+	}
+}
+
+func TestValidateSnapshot(t *testing.T) {
+	snap := &v1.VolumeSnapshot{}
+	if err := validateSnapshot(snap); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for a missing name, got: %v", err)
+	}
+
+	snap.Name = "../snap1"
+	snap.Spec.VolumeName = "myvol"
+	if err := validateSnapshot(snap); !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error for a nested name, got: %v", err)
+	}
+
+	snap.Name = "snap1"
+	if err := validateSnapshot(snap); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
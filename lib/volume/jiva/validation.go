@@ -0,0 +1,205 @@
+// This file validates the claims of jiva volumes.
+//
+// NOTE:
+//    The claim's labels are the inputs to the orchestrator's placement of a
+// jiva volume. These are validated together s.t. all the problems of a claim
+// are reported at once, rather than one label at a time by the orchestrator.
+package jiva
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/volume"
+)
+
+var (
+	// jivaRequiredLabels are the labels that are required by the
+	// orchestrators to place a jiva volume
+	jivaRequiredLabels = []string{
+		"region",
+		"datacenter",
+		"jivafeversion",
+		"jivafenetwork",
+		"jivafeip",
+		"jivabeip",
+		"jivafesubnet",
+		"jivafeinterface",
+	}
+
+	// jivaImageRegex matches a container image reference with an optional
+	// registry host, tag & digest e.g. openebs/jiva:latest
+	jivaImageRegex = regexp.MustCompile(`^([a-zA-Z0-9.-]+(:[0-9]+)?/)?[a-z0-9]+([._-][a-z0-9]+)*(/[a-z0-9]+([._-][a-z0-9]+)*)*(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+	// jivaIfaceRegex matches a network interface name. Linux limits these
+	// to 15 characters.
+	jivaIfaceRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]{0,14}$`)
+)
+
+// jivaStor supports validation of claims
+//
+// NOTE:
+//    This is a contract implementation of volume.VolumeInterface
+func (j *jivaStor) Validator() (volume.Validator, bool) {
+	return j, true
+}
+
+// Validate verifies the claim of a jiva volume. All the problems of the
+// claim are reported together.
+//
+// NOTE:
+//    This is a contract implementation of volume.Validator interface
+func (j *jivaStor) Validate(pvc *v1.PersistentVolumeClaim) error {
+	if pvc == nil {
+		return v1.NewInvalidError(fmt.Errorf("Nil persistent volume claim provided"))
+	}
+
+	jCfg := j.config()
+	claim := jCfg.withDefaults(pvc)
+
+	// The ip addresses are allocated if these are not requested
+	required := jivaRequiredLabels
+	if j.managesIPs(claim) {
+		required = nil
+		for _, label := range jivaRequiredLabels {
+			if label != jivaCtlIPLabel && label != jivaRepIPLabel {
+				required = append(required, label)
+			}
+		}
+	}
+
+	causes := validateClaim(claim, required)
+
+	// The size is limited by the config
+	if size := claim.Spec.Resources.Requests[v1.ResourceStorage]; size.Sign() > 0 {
+		if err := jCfg.checkSize(size); err != nil {
+			causes = append(causes, v1.FieldError{Field: "spec.resources.requests.storage", Value: size.String(), Message: err.Error()})
+		}
+	}
+
+	if len(causes) != 0 {
+		return v1.NewFieldsInvalidError(pvc.Name, causes)
+	}
+
+	return nil
+}
+
+// validateClaim provides the problems with the fields of a jiva claim. The
+// required labels need to be set.
+func validateClaim(pvc *v1.PersistentVolumeClaim, required []string) []v1.FieldError {
+	var causes []v1.FieldError
+
+	invalid := func(field, value, msg string) {
+		causes = append(causes, v1.FieldError{Field: field, Value: value, Message: msg})
+	}
+
+	if pvc.Name == "" {
+		invalid("metadata.name", "", "is required")
+	}
+
+	for _, label := range required {
+		if pvc.Labels[label] == "" {
+			invalid("metadata.labels."+label, "", "is required")
+		}
+	}
+
+	if version := pvc.Labels["jivafeversion"]; version != "" && !jivaImageRegex.MatchString(version) {
+		invalid("metadata.labels.jivafeversion", version, "is not a valid image")
+	}
+
+	var feIP net.IP
+	if ip := pvc.Labels["jivafeip"]; ip != "" {
+		if feIP = net.ParseIP(ip); feIP == nil {
+			invalid("metadata.labels.jivafeip", ip, "is not a valid ip address")
+		}
+	}
+
+	replicas := -1
+	if v := pvc.Labels[jivaReplicasLabel]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			invalid("metadata.labels."+jivaReplicasLabel, v, "is not a positive integer")
+		} else {
+			replicas = n
+		}
+	}
+
+	// Each replica has its own ip
+	if ips := pvc.Labels[jivaRepIPLabel]; ips != "" {
+		seen := map[string]bool{}
+		repIPs := strings.Split(ips, ",")
+
+		for _, ip := range repIPs {
+			ip = strings.TrimSpace(ip)
+			switch {
+			case net.ParseIP(ip) == nil:
+				invalid("metadata.labels."+jivaRepIPLabel, ip, "is not a valid ip address")
+			case seen[ip]:
+				invalid("metadata.labels."+jivaRepIPLabel, ip, "is a duplicate ip address")
+			}
+			seen[ip] = true
+		}
+
+		if replicas > 0 && len(repIPs) != replicas {
+			invalid("metadata.labels."+jivaRepIPLabel, ips, fmt.Sprintf("has %d ip addresses, expected one per each of the %d replicas", len(repIPs), replicas))
+		}
+	}
+
+	if subnet := pvc.Labels["jivafesubnet"]; subnet != "" {
+		maxBits := 32
+		if feIP != nil && feIP.To4() == nil {
+			maxBits = 128
+		}
+
+		if bits, err := strconv.Atoi(subnet); err != nil || bits < 0 || bits > maxBits {
+			invalid("metadata.labels.jivafesubnet", subnet, fmt.Sprintf("is not a prefix length between 0 & %d", maxBits))
+		}
+	}
+
+	if iface := pvc.Labels["jivafeinterface"]; iface != "" && !jivaIfaceRegex.MatchString(iface) {
+		invalid("metadata.labels.jivafeinterface", iface, "is not a valid network interface name")
+	}
+
+	for _, label := range []string{jivaCtlCPULabel, jivaCtlMemoryLabel, jivaRepCPULabel, jivaRepMemoryLabel} {
+		if v := pvc.Labels[label]; v != "" {
+			if n, err := strconv.Atoi(v); err != nil || n <= 0 {
+				invalid("metadata.labels."+label, v, "is not a positive integer")
+			}
+		}
+	}
+
+	if v := pvc.Labels[jivaRestartAttemptsLabel]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			invalid("metadata.labels."+jivaRestartAttemptsLabel, v, "is not a non-negative integer")
+		}
+	}
+
+	for _, label := range []string{jivaRestartIntervalLabel, jivaRestartDelayLabel} {
+		if v := pvc.Labels[label]; v != "" {
+			if _, err := time.ParseDuration(v); err != nil {
+				invalid("metadata.labels."+label, v, "is not a valid duration")
+			}
+		}
+	}
+
+	if mode := pvc.Labels[jivaRestartModeLabel]; mode != "" && mode != jivaRestartModeDelay && mode != jivaRestartModeFail {
+		invalid("metadata.labels."+jivaRestartModeLabel, mode, fmt.Sprintf("is not one of %s, %s", jivaRestartModeDelay, jivaRestartModeFail))
+	}
+
+	if size, found := pvc.Spec.Resources.Requests[v1.ResourceStorage]; found && size.Sign() <= 0 {
+		invalid("spec.resources.requests.storage", size.String(), "is not a positive quantity")
+	}
+
+	for _, mode := range pvc.Spec.AccessModes {
+		if mode != v1.ReadWriteOnce {
+			invalid("spec.accessModes", string(mode), fmt.Sprintf("is not supported, only %s is", v1.ReadWriteOnce))
+		}
+	}
+
+	return causes
+}
@@ -0,0 +1,145 @@
+package jiva
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/openebs/mayaserver/lib/api/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func validClaim() *v1.PersistentVolumeClaim {
+	pvc := &v1.PersistentVolumeClaim{}
+	pvc.Name = "jivavol1"
+	pvc.Labels = map[string]string{
+		"region":          "global",
+		"datacenter":      "dc1",
+		"jivafeversion":   "openebs/jiva:latest",
+		"jivafenetwork":   "host",
+		"jivafeip":        "172.28.128.101",
+		"jivabeip":        "172.28.128.102",
+		"jivafesubnet":    "24",
+		"jivafeinterface": "enp0s8",
+	}
+	pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteOnce}
+	pvc.Spec.Resources.Requests = v1.ResourceList{
+		v1.ResourceStorage: resource.MustParse("5Gi"),
+	}
+	return pvc
+}
+
+func TestValidate_ValidClaim(t *testing.T) {
+	j := &jivaStor{}
+
+	if err := j.Validate(validClaim()); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	pvc := validClaim()
+	pvc.Labels["jivafeversion"] = "registry.example.com:5000/openebs/jiva:0.3-RC2"
+	if err := j.Validate(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestValidate_AllProblems(t *testing.T) {
+	pvc := validClaim()
+	delete(pvc.Labels, "datacenter")
+	pvc.Labels["jivafeversion"] = "openebs/jiva:"
+	pvc.Labels["jivafeip"] = "172.28.128"
+	pvc.Labels["jivabeip"] = "172.28.128.300"
+	pvc.Labels["jivafesubnet"] = "33"
+	pvc.Labels["jivafeinterface"] = "eth0/1"
+	pvc.Spec.AccessModes = []v1.PersistentVolumeAccessMode{v1.ReadWriteMany}
+	pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse("0")
+
+	err := (&jivaStor{}).Validate(pvc)
+	if !v1.IsInvalid(err) {
+		t.Fatalf("expected invalid error, got: %v", err)
+	}
+
+	var fields []string
+	for _, cause := range err.(*v1.VolumeError).Causes {
+		fields = append(fields, cause.Field)
+	}
+	sort.Strings(fields)
+
+	expected := []string{
+		"metadata.labels.datacenter",
+		"metadata.labels.jivabeip",
+		"metadata.labels.jivafeinterface",
+		"metadata.labels.jivafeip",
+		"metadata.labels.jivafesubnet",
+		"metadata.labels.jivafeversion",
+		"spec.accessModes",
+		"spec.resources.requests.storage",
+	}
+
+	if len(fields) != len(expected) {
+		t.Fatalf("bad causes: expected: %v, got: %v", expected, fields)
+	}
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Fatalf("bad causes: expected: %v, got: %v", expected, fields)
+		}
+	}
+}
+
+func TestValidate_IPv6Subnet(t *testing.T) {
+	pvc := validClaim()
+	pvc.Labels["jivafeip"] = "fd00::101"
+	pvc.Labels["jivafesubnet"] = "64"
+
+	if err := (&jivaStor{}).Validate(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+}
+
+func TestValidate_Replicas(t *testing.T) {
+	pvc := validClaim()
+	pvc.Labels["jivareplicas"] = "3"
+	pvc.Labels["jivabeip"] = "172.28.128.102,172.28.128.103,172.28.128.104"
+
+	if err := (&jivaStor{}).Validate(pvc); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	cases := map[string]string{
+		"172.28.128.102,172.28.128.103":                "3",
+		"172.28.128.102,172.28.128.102,172.28.128.104": "3",
+		"172.28.128.102":                               "0",
+	}
+
+	for ips, replicas := range cases {
+		pvc.Labels["jivabeip"] = ips
+		pvc.Labels["jivareplicas"] = replicas
+
+		if err := (&jivaStor{}).Validate(pvc); !v1.IsInvalid(err) {
+			t.Fatalf("expected invalid error for ips '%s' & replicas '%s', got: %v", ips, replicas, err)
+		}
+	}
+}
+
+func TestValidate_SizeLimits(t *testing.T) {
+	jCfg := defaultJivaConfig()
+	jCfg.Volume.MinSize = "2Gi"
+	jCfg.Volume.MaxSize = "100Gi"
+	j := &jivaStor{jConfig: jCfg}
+
+	for size, valid := range map[string]bool{"1Gi": false, "2Gi": true, "100Gi": true, "1Ti": false} {
+		pvc := validClaim()
+		pvc.Spec.Resources.Requests[v1.ResourceStorage] = resource.MustParse(size)
+
+		err := j.Validate(pvc)
+		if valid && err != nil {
+			t.Fatalf("size '%s': err: %v", size, err)
+		}
+		if !valid && !v1.IsInvalid(err) {
+			t.Fatalf("size '%s': expected invalid error, got: %v", size, err)
+		}
+
+		if err := j.checkSize(pvc); valid != (err == nil) {
+			t.Fatalf("size '%s': bad size check: %v", size, err)
+		}
+	}
+}
@@ -14,6 +14,7 @@ import (
 
 	"github.com/golang/glog"
 	//"github.com/openebs/mayaserver/lib/api/v1"
+	"github.com/openebs/mayaserver/lib/ipam"
 	"github.com/openebs/mayaserver/lib/orchprovider"
 )
 
@@ -36,6 +37,33 @@ type VolumePluginAspect interface {
 	//    OpenEBS believes in running storage software in containers & hence
 	// above container specific orchestrators.
 	GetOrchProvider() (orchprovider.OrchestratorInterface, error)
+
+	// Get the ip address management of the volumes, if any.
+	GetIPAM() (ipam.IPAM, bool)
+}
+
+// OrchProviderAspect is a concrete implementation of VolumePluginAspect.
+// It links a volume plugin instance with a particular orchestration
+// provider & optionally with the ip address management.
+type OrchProviderAspect struct {
+	Orchestrator orchprovider.OrchestratorInterface
+
+	IPAM ipam.IPAM
+}
+
+// GetOrchProvider provides the orchestration provider linked with this
+// aspect.
+func (a *OrchProviderAspect) GetOrchProvider() (orchprovider.OrchestratorInterface, error) {
+	if a.Orchestrator == nil {
+		return nil, fmt.Errorf("Orchestration provider is not set")
+	}
+
+	return a.Orchestrator, nil
+}
+
+// GetIPAM provides the ip address management linked with this aspect.
+func (a *OrchProviderAspect) GetIPAM() (ipam.IPAM, bool) {
+	return a.IPAM, a.IPAM != nil
 }
 
 // All registered volume plugins.
@@ -155,14 +183,21 @@ func InitVolumePlugin(name string, configFilePath string, aspect VolumePluginAsp
 		return nil, nil
 	}
 
+	var config *os.File
 	if configFilePath != "" {
-		var config *os.File
 		config, err = os.Open(configFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("could not open volume plugin %q configuration: %v", name, err)
+		}
+
+		// A missing config file implies the plugin's defaults
 		if err != nil {
-			glog.Fatalf("Couldn't open volume plugin configuration %s: %#v",
-				configFilePath, err)
+			glog.V(2).Infof("Volume plugin configuration %s not found", configFilePath)
+			config = nil
 		}
+	}
 
+	if config != nil {
 		defer config.Close()
 		volumeInterface, err = GetVolumePlugin(name, config, aspect)
 	} else {
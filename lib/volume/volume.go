@@ -6,6 +6,8 @@
 package volume
 
 import (
+	"context"
+
 	"github.com/openebs/mayaserver/lib/api/v1"
 )
 
@@ -25,6 +27,30 @@ type VolumeInterface interface {
 	// This is a builder for Informer interface. Will return
 	// false if not supported.
 	Informer() (Informer, bool)
+
+	// This is a builder for Lister interface. Will return
+	// false if not supported.
+	Lister() (Lister, bool)
+
+	// This is a builder for Snapshotter interface. Will return
+	// false if not supported.
+	Snapshotter() (Snapshotter, bool)
+
+	// This is a builder for Resizer interface. Will return
+	// false if not supported.
+	Resizer() (Resizer, bool)
+
+	// This is a builder for Attacher interface. Will return
+	// false if not supported.
+	Attacher() (Attacher, bool)
+
+	// This is a builder for Detacher interface. Will return
+	// false if not supported.
+	Detacher() (Detacher, bool)
+
+	// This is a builder for Validator interface. Will return
+	// false if not supported.
+	Validator() (Validator, bool)
 }
 
 // Informer is an interface that can fetch details of the volume from
@@ -33,7 +59,21 @@ type Informer interface {
 	// Info tries to fetch the details of a claim from the underlying storage
 	// system. This method returns PersistentVolume representing the
 	// already available storage resource.
-	Info(*v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+	//
+	// NOTE:
+	//    The query options, if provided, can block this call till the
+	// volume changes. The returned query meta provides the index that can
+	// be used for a subsequent blocking call.
+	Info(*v1.PersistentVolumeClaim, *v1.QueryOptions) (*v1.PersistentVolume, *v1.QueryMeta, error)
+}
+
+// Lister is an interface that can fetch details of all the volumes from
+// the storage infrastructure.
+type Lister interface {
+	// List tries to fetch the details of all the volumes from the underlying
+	// storage system. The volumes are filtered as per the provided query
+	// options.
+	List(*v1.QueryOptions) (*v1.PersistentVolumeList, error)
 }
 
 // Provisioner is an interface that can create the volume as a new resource in
@@ -52,3 +92,61 @@ type Deleter interface {
 	// Delete removes the allocated resource in the storage system.
 	Delete(*v1.PersistentVolume) (*v1.PersistentVolume, error)
 }
+
+// Resizer changes the capacity of an existing volume in the storage
+// infrastructure.
+type Resizer interface {
+	// Resize tries to grow the claimed volume to the storage size requested
+	// by the claim. Shrinking a volume is not supported. This method returns
+	// PersistentVolume whose status reports the progress of the resize.
+	Resize(*v1.PersistentVolumeClaim) (*v1.PersistentVolume, error)
+}
+
+// Validator verifies a claim before it is provisioned.
+//
+// NOTE:
+//    All the problems of the claim are reported at once as the causes of a
+// v1.VolumeError with v1.ReasonInvalid.
+type Validator interface {
+	// Validate verifies the claim. This method returns nil if the claim can
+	// be provisioned.
+	Validate(*v1.PersistentVolumeClaim) error
+}
+
+// Attacher records the compute instance that holds a volume.
+//
+// NOTE:
+//    A volume can be attached to one instance at a time i.e. volumes are
+// ReadWriteOnce.
+type Attacher interface {
+	// Attach records the volume as attached to the instance named in the
+	// attachment's spec. This method returns the attachment along with the
+	// iSCSI target of the volume.
+	Attach(*v1.VolumeAttachment) (*v1.VolumeAttachment, error)
+
+	// Attachments provides the current attachments of the claimed volume.
+	Attachments(*v1.PersistentVolumeClaim) (*v1.VolumeAttachmentList, error)
+}
+
+// Detacher removes the record of a volume's attachment to a compute
+// instance.
+type Detacher interface {
+	// Detach removes the attachment of the volume to the instance named in
+	// the attachment's spec.
+	Detach(*v1.VolumeAttachment) error
+}
+
+// Snapshotter manages the point-in-time copies of the volumes in the storage
+// infrastructure.
+type Snapshotter interface {
+	// CreateSnapshot takes a snapshot of the volume named in the snapshot's
+	// spec. This method returns the created snapshot.
+	CreateSnapshot(*v1.VolumeSnapshot) (*v1.VolumeSnapshot, error)
+
+	// DeleteSnapshot removes the snapshot of the volume named in the
+	// snapshot's spec. The removal is abandoned once the context is done.
+	DeleteSnapshot(context.Context, *v1.VolumeSnapshot) error
+
+	// ListSnapshots provides the snapshots of the claimed volume.
+	ListSnapshots(*v1.PersistentVolumeClaim) (*v1.VolumeSnapshotList, error)
+}